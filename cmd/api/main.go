@@ -1,15 +1,27 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"textile-admin/internal/config"
 	"textile-admin/internal/domain/entity"
 	"textile-admin/internal/handler"
 	"textile-admin/internal/middleware"
+	"textile-admin/internal/processor"
 	"textile-admin/internal/repository"
+	"textile-admin/internal/search"
 	"textile-admin/internal/service"
+	"textile-admin/internal/storage"
+	"textile-admin/internal/worker"
 	"textile-admin/pkg/db"
 	"textile-admin/pkg/logger"
+	"textile-admin/pkg/oidc"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -30,13 +42,44 @@ func main() {
 	logger.Info("Server will listen on " + cfg.ServerAddress)
 
 	// Initialize all components
-	router := initializeApp(cfg)
+	router, workerPool, janitor := initializeApp(cfg)
+
+	// Start the task workers and the upload janitor
+	workerPool.Start()
+	janitor.Start()
 
 	// Start the server
-	logger.Info("Starting server on " + cfg.ServerAddress)
-	if err := router.Run(cfg.ServerAddress); err != nil {
-		logger.Fatal("Failed to start server: " + err.Error())
+	server := &http.Server{
+		Addr:    cfg.ServerAddress,
+		Handler: router,
 	}
+
+	go func() {
+		logger.Info("Starting server on " + cfg.ServerAddress)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("Failed to start server: " + err.Error())
+		}
+	}()
+
+	// Wait for an interrupt signal to shut down gracefully
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	logger.Info("Shutting down server...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error("Server forced to shut down: " + err.Error())
+	}
+
+	// Let in-flight tasks finish before exiting
+	workerPool.Stop()
+	janitor.Stop()
+
+	logger.Info("Server exited")
 }
 
 // getEnv returns the current environment
@@ -49,15 +92,12 @@ func getEnv() string {
 }
 
 // initializeApp initializes all components of the application
-func initializeApp(cfg config.Config) *gin.Engine {
+func initializeApp(cfg config.Config) (*gin.Engine, *worker.Pool, *worker.Janitor) {
 	// Set Gin mode based on environment
 	if getEnv() == "prod" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// Ensure upload directory exists
-	ensureUploadDirExists(cfg.UploadDir)
-
 	// Initialize database connection
 	dbConn := connectDatabase(cfg)
 
@@ -66,8 +106,59 @@ func initializeApp(cfg config.Config) *gin.Engine {
 
 	// Initialize components
 	readingRepo := repository.NewReadingRepository(dbConn)
-	readingService := service.NewReadingService(readingRepo, cfg.UploadDir, cfg.FileURLPrefix)
-	readingHandler := handler.NewReadingHandler(readingService, cfg.UploadDir)
+	userRepo := repository.NewUserRepository(dbConn)
+	retryPolicy := service.RetryPolicy{
+		MaxAttempts: cfg.TaskMaxAttempts,
+		BaseDelay:   cfg.TaskRetryBaseDelay,
+		MaxDelay:    cfg.TaskRetryMaxDelay,
+	}
+	processors := newProcessorRegistry()
+	searchIndex := openSearchIndex(cfg)
+	files := newStorageBackend(cfg)
+	readingSpeed := service.ReadingSpeed{
+		WordsPerMinute:    cfg.ReadingWordsPerMinute,
+		CJKCharsPerMinute: cfg.ReadingCJKCharsPerMinute,
+	}
+	readingService := service.NewReadingService(readingRepo, userRepo, processors, searchIndex, files, cfg.FileURLPrefix, presignExpiry(cfg), retryPolicy, cfg.TaskLeaseDuration, readingSpeed)
+	readingHandler := handler.NewReadingHandler(readingService)
+	uploadRepo := repository.NewUploadRepository(dbConn)
+	uploadService := service.NewUploadService(uploadRepo, readingService, files, cfg.TusMaxSize, cfg.TusSessionTTL)
+	uploadHandler := handler.NewUploadHandler(uploadService)
+	progressRepo := repository.NewProgressRepository(dbConn)
+	progressService := service.NewProgressService(progressRepo, readingRepo)
+	progressHandler := handler.NewProgressHandler(progressService)
+	annotationRepo := repository.NewAnnotationRepository(dbConn)
+	annotationService := service.NewAnnotationService(annotationRepo, readingRepo)
+	annotationHandler := handler.NewAnnotationHandler(annotationService)
+	sessionRepo := repository.NewSessionRepository(dbConn)
+	sessionService := service.NewSessionService(sessionRepo, progressRepo, readingRepo, userRepo, cfg.SessionIdleTimeout)
+	sessionHandler := handler.NewSessionHandler(sessionService)
+	searchService := service.NewSearchService(searchIndex, readingRepo)
+	searchHandler := handler.NewSearchHandler(searchService)
+	tokenRepo := repository.NewTokenRepository(dbConn)
+	authService := service.NewAuthService(userRepo, tokenRepo, jwtSecret(cfg), cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	authHandler := handler.NewAuthHandler(authService)
+	apiKeyRepo := repository.NewAPIKeyRepository(dbConn)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, userRepo)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+	roleRepo := repository.NewRoleRepository(dbConn)
+	roleService := service.NewRoleService(roleRepo, userRepo)
+	roleHandler := handler.NewRoleHandler(roleService)
+	userService := service.NewUserService(userRepo, tokenRepo, roleRepo)
+	userHandler := handler.NewUserHandler(userService, cfg.AllowSignup)
+	orgRepo := repository.NewOrganizationRepository(dbConn)
+	orgService := service.NewOrganizationService(orgRepo, userRepo)
+	orgHandler := handler.NewOrganizationHandler(orgService)
+	oidcHandler := newOIDCHandler(cfg, authService, userRepo, roleRepo)
+	// Seed the default organization, the built-in roles and the configured
+	// admin account
+	if err := orgService.SeedDefaultOrganization(); err != nil {
+		logger.Fatal("Failed to seed default organization: " + err.Error())
+	}
+	seedRoles(roleService, cfg)
+
+	workerPool := worker.NewPool(readingService, cfg.WorkerCount, cfg.WorkerPollInterval, cfg.TaskLeaseDuration)
+	janitor := worker.NewJanitor(uploadService, cfg.TusCleanupInterval)
 
 	// Initialize Gin router
 	router := gin.Default()
@@ -75,8 +166,23 @@ func initializeApp(cfg config.Config) *gin.Engine {
 	// Configure CORS
 	router.Use(middleware.CORSMiddleware())
 
-	// Register routes
-	readingHandler.RegisterRoutes(router)
+	// Register routes; all but login and sign-up require an access token
+	// or an API key
+	auth := middleware.AuthMiddleware(authService, apiKeyService)
+	authHandler.RegisterRoutes(router)
+	if oidcHandler != nil {
+		oidcHandler.RegisterRoutes(router)
+	}
+	readingHandler.RegisterRoutes(router, auth)
+	uploadHandler.RegisterRoutes(router, auth)
+	progressHandler.RegisterRoutes(router, auth)
+	annotationHandler.RegisterRoutes(router, auth)
+	sessionHandler.RegisterRoutes(router, auth)
+	searchHandler.RegisterRoutes(router, auth)
+	userHandler.RegisterRoutes(router, auth)
+	roleHandler.RegisterRoutes(router, auth)
+	orgHandler.RegisterRoutes(router, auth)
+	apiKeyHandler.RegisterRoutes(router, auth)
 
 	// Add a health check endpoint
 	router.GET("/health", func(c *gin.Context) {
@@ -86,17 +192,71 @@ func initializeApp(cfg config.Config) *gin.Engine {
 		})
 	})
 
-	return router
+	return router, workerPool, janitor
 }
 
-// ensureUploadDirExists ensures that the upload directory exists
-func ensureUploadDirExists(uploadDir string) {
-	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
-		if err := os.MkdirAll(uploadDir, 0755); err != nil {
-			logger.Fatal("Failed to create upload directory: " + err.Error())
+// newProcessorRegistry registers the document processors by MIME type and extension
+func newProcessorRegistry() *processor.Registry {
+	registry := processor.NewRegistry()
+	registry.Register(processor.NewTextProcessor(),
+		[]string{"text/plain", "text/markdown"},
+		[]string{".txt", ".text", ".md", ".markdown"})
+	registry.Register(processor.NewHTMLProcessor(),
+		[]string{"text/html", "application/xhtml+xml"},
+		[]string{".html", ".htm", ".xhtml"})
+	registry.Register(processor.NewEPUBProcessor(),
+		[]string{"application/epub+zip"},
+		[]string{".epub"})
+	registry.Register(processor.NewDOCXProcessor(),
+		[]string{"application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		[]string{".docx"})
+	registry.Register(processor.NewPDFProcessor(),
+		[]string{"application/pdf"},
+		[]string{".pdf"})
+	return registry
+}
+
+// newStorageBackend opens the configured file storage backend
+func newStorageBackend(cfg config.Config) storage.Backend {
+	switch cfg.StorageBackend {
+	case "local":
+		backend, err := storage.NewLocal(cfg.UploadDir)
+		if err != nil {
+			logger.Fatal("Failed to open file storage: " + err.Error())
+		}
+		logger.Info("Storing files in " + cfg.UploadDir)
+		return backend
+	case "s3":
+		backend, err := storage.NewS3(storage.S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PathStyle:       cfg.S3PathStyle,
+		})
+		if err != nil {
+			logger.Fatal("Failed to open file storage: " + err.Error())
 		}
-		logger.Info("Created upload directory: " + uploadDir)
+		logger.Info("Storing files in S3 bucket " + cfg.S3Bucket + " at " + cfg.S3Endpoint)
+		return backend
+	default:
+		logger.Fatal("Unknown storage backend: " + cfg.StorageBackend)
+		return nil
+	}
+}
+
+// presignExpiry returns how long presigned download URLs are valid, or 0
+// when downloads are served through the API
+func presignExpiry(cfg config.Config) time.Duration {
+	if !cfg.StoragePresignDownloads {
+		return 0
+	}
+	if cfg.StorageBackend == "local" {
+		logger.Warn("The local storage backend cannot presign URLs; serving downloads through the API")
+		return 0
 	}
+	return cfg.StoragePresignExpiry
 }
 
 // connectDatabase initializes the database connection
@@ -111,10 +271,77 @@ func connectDatabase(cfg config.Config) *gorm.DB {
 	return dbConn
 }
 
+// openSearchIndex opens the full-text search index
+func openSearchIndex(cfg config.Config) *search.Index {
+	index, err := search.NewIndex(cfg.SearchIndexDir)
+	if err != nil {
+		logger.Fatal("Failed to open search index: " + err.Error())
+	}
+
+	logger.Info("Search index at " + cfg.SearchIndexDir)
+	return index
+}
+
+// seedRoles creates the built-in roles and, on first start, the admin account
+func seedRoles(roleService *service.RoleService, cfg config.Config) {
+	if err := roleService.SeedRoles(); err != nil {
+		logger.Fatal("Failed to seed roles: " + err.Error())
+	}
+
+	if err := roleService.SeedAdmin(cfg.AdminUsername, cfg.AdminEmail, cfg.AdminPassword); err != nil {
+		logger.Fatal("Failed to seed admin account: " + err.Error())
+	}
+}
+
+// newOIDCHandler sets up single sign-on, or returns nil when no OIDC
+// issuer is configured
+func newOIDCHandler(cfg config.Config, authService *service.AuthService, userRepo *repository.UserRepository, roleRepo *repository.RoleRepository) *handler.OIDCHandler {
+	if cfg.OIDCIssuer == "" {
+		return nil
+	}
+	if cfg.OIDCClientID == "" || cfg.OIDCRedirectURL == "" {
+		logger.Fatal("OIDC issuer configured without client_id or redirect_url")
+	}
+
+	client := oidc.NewClient(oidc.Config{
+		Issuer:       cfg.OIDCIssuer,
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  cfg.OIDCRedirectURL,
+		Scopes:       cfg.OIDCScopes,
+	})
+	oidcService := service.NewOIDCService(client, cfg.OIDCIssuer, authService, userRepo, roleRepo)
+
+	logger.Info("OIDC single sign-on enabled with issuer " + cfg.OIDCIssuer)
+	return handler.NewOIDCHandler(oidcService, strings.HasPrefix(cfg.OIDCRedirectURL, "https://"))
+}
+
+// jwtSecret returns the key tokens are signed with. Without a configured
+// secret a random one is used in development, so tokens do not survive a
+// restart; elsewhere that would also make each replica reject the others'
+// tokens, so the application stops instead.
+func jwtSecret(cfg config.Config) []byte {
+	if cfg.JWTSecret != "" {
+		return []byte(cfg.JWTSecret)
+	}
+
+	if getEnv() != "dev" {
+		logger.Fatal("No JWT secret configured; set JWT_SECRET")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		logger.Fatal("Failed to generate JWT secret: " + err.Error())
+	}
+
+	logger.Warn("No JWT secret configured, using a random one; tokens will not survive a restart")
+	return secret
+}
+
 // migrateDatabase runs auto-migrations for database schema
 func migrateDatabase(db *gorm.DB) {
 	logger.Info("Running database migrations...")
-	err := db.AutoMigrate(&entity.User{}, &entity.ReadingTask{})
+	err := db.AutoMigrate(&entity.Organization{}, &entity.User{}, &entity.ReadingTask{}, &entity.Blob{}, &entity.ReadingTaskEvent{}, &entity.ReadingContent{}, &entity.ReadingChapter{}, &entity.ReadingProgress{}, &entity.Bookmark{}, &entity.Annotation{}, &entity.ReadingSession{}, &entity.RefreshToken{}, &entity.Role{}, &entity.Permission{}, &entity.APIKey{}, &entity.UserIdentity{}, &entity.UploadSession{}, &entity.UploadPart{})
 	if err != nil {
 		logger.Fatal("Failed to run database migrations: " + err.Error())
 	}
@@ -0,0 +1,32 @@
+// Command mockidp is a minimal OpenID Connect provider for trying out and
+// testing single sign-on locally, without an internet connection. It
+// signs in every authorization request without asking: as the user given
+// by -email, or by the login_hint parameter when one is passed.
+//
+// Do not use it for anything but development.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"textile-admin/pkg/oidc/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9000", "address to listen on")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL; must match how clients reach this server")
+	clientID := flag.String("client-id", "textile-admin", "the only client ID accepted")
+	clientSecret := flag.String("client-secret", "mock-secret", "the client's secret")
+	email := flag.String("email", "sso.user@example.com", "email of the user signed in without a login_hint")
+	name := flag.String("name", "SSO User", "name of the user signed in without a login_hint")
+	flag.Parse()
+
+	provider, err := oidctest.NewProvider(*issuer, *clientID, *clientSecret, *email, *name)
+	if err != nil {
+		log.Fatalf("Failed to generate signing key: %v", err)
+	}
+
+	log.Printf("Mock OIDC provider for client %q at %s, listening on %s", *clientID, provider.Issuer(), *addr)
+	log.Fatal(http.ListenAndServe(*addr, provider.Handler()))
+}
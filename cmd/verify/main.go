@@ -0,0 +1,83 @@
+// Command verify re-reads every file in the storage backend and checks it
+// against the database: that it exists, and for files stored under their
+// hash, that its size and SHA-256 hash are unchanged and that its reference
+// count matches the tasks using it. Each problem is printed on a line of
+// its own, and the command exits with status 1 if there were any.
+//
+// It reads the same configuration as the API server (APP_ENV, the YAML
+// config and environment variables) and only reads from the database and
+// the storage backend.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"textile-admin/internal/config"
+	"textile-admin/internal/repository"
+	"textile-admin/internal/service"
+	"textile-admin/internal/storage"
+	"textile-admin/pkg/db"
+)
+
+func main() {
+	asJSON := flag.Bool("json", false, "print problems as JSON lines")
+	flag.Parse()
+
+	cfg := config.LoadConfig()
+
+	dbConn, err := db.NewGormDBConnection(cfg.DBConfig)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	files, err := openStorage(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open file storage: %v", err)
+	}
+
+	integrityService := service.NewIntegrityService(repository.NewReadingRepository(dbConn), files)
+
+	encoder := json.NewEncoder(os.Stdout)
+	report, err := integrityService.VerifyFiles(context.Background(), func(drift *service.FileDrift) {
+		if *asJSON {
+			encoder.Encode(drift)
+			return
+		}
+		if drift.TaskID != 0 {
+			fmt.Printf("%s (task %d): %s\n", drift.Key, drift.TaskID, drift.Problem)
+			return
+		}
+		fmt.Printf("%s: %s\n", drift.Key, drift.Problem)
+	})
+	if err != nil {
+		log.Fatalf("Verification stopped after %d files: %v", report.Checked, err)
+	}
+
+	log.Printf("Verified %d files, %d problems found", report.Checked, report.Problems)
+	if report.Problems > 0 {
+		os.Exit(1)
+	}
+}
+
+// openStorage opens the configured storage backend
+func openStorage(cfg config.Config) (storage.Backend, error) {
+	switch cfg.StorageBackend {
+	case "local":
+		return storage.NewLocal(cfg.UploadDir)
+	case "s3":
+		return storage.NewS3(storage.S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PathStyle:       cfg.S3PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 	"textile-admin/pkg/db"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -19,6 +20,65 @@ type Config struct {
 	UploadDir      string
 	FileURLPrefix  string
 
+	// Task worker configuration
+	WorkerCount        int
+	WorkerPollInterval time.Duration
+	TaskLeaseDuration  time.Duration
+	TaskMaxAttempts    int
+	TaskRetryBaseDelay time.Duration
+	TaskRetryMaxDelay  time.Duration
+
+	// Reading speed used to estimate reading time
+	ReadingWordsPerMinute    int
+	ReadingCJKCharsPerMinute int
+
+	// Reading sessions without a heartbeat for this long are ended
+	SessionIdleTimeout time.Duration
+
+	// Directory of the full-text search index
+	SearchIndexDir string
+
+	// File storage: "local" keeps files in UploadDir, "s3" in an
+	// S3-compatible bucket. Downloads are redirected to presigned URLs
+	// when StoragePresignDownloads is set and the backend supports it.
+	StorageBackend          string
+	StoragePresignDownloads bool
+	StoragePresignExpiry    time.Duration
+	S3Endpoint              string
+	S3Region                string
+	S3Bucket                string
+	S3AccessKeyID           string
+	S3SecretAccessKey       string
+	S3PathStyle             bool
+
+	// Resumable (tus) uploads: the largest file accepted, in bytes, how
+	// long an upload may sit idle before it is deleted, and how often
+	// expired uploads are looked for
+	TusMaxSize         int64
+	TusSessionTTL      time.Duration
+	TusCleanupInterval time.Duration
+
+	// Authentication configuration
+	JWTSecret       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// Whether anyone may sign up; otherwise accounts are created by users
+	// with PermUsersManage
+	AllowSignup bool
+
+	// Admin account created on first start; none when AdminEmail is empty
+	AdminUsername string
+	AdminEmail    string
+	AdminPassword string
+
+	// OpenID Connect single sign-on; disabled when OIDCIssuer is empty
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       []string
+
 	// Database configuration
 	DBConfig db.DBConfig
 
@@ -32,9 +92,72 @@ type Config struct {
 
 // ServerConfig represents server configuration in YAML
 type ServerConfig struct {
-	Address       string `yaml:"address"`
-	UploadDir     string `yaml:"upload_dir"`
-	FileURLPrefix string `yaml:"file_url_prefix"`
+	Address        string        `yaml:"address"`
+	UploadDir      string        `yaml:"upload_dir"`
+	FileURLPrefix  string        `yaml:"file_url_prefix"`
+	Workers        int           `yaml:"workers"`
+	PollInterval   time.Duration `yaml:"poll_interval"`
+	LeaseDuration  time.Duration `yaml:"lease_duration"`
+	MaxAttempts    int           `yaml:"max_attempts"`
+	RetryBaseDelay time.Duration `yaml:"retry_base_delay"`
+	RetryMaxDelay  time.Duration `yaml:"retry_max_delay"`
+}
+
+// ReadingConfig represents reading settings in YAML
+type ReadingConfig struct {
+	WordsPerMinute     int           `yaml:"words_per_minute"`
+	CJKCharsPerMinute  int           `yaml:"cjk_chars_per_minute"`
+	SessionIdleTimeout time.Duration `yaml:"session_idle_timeout"`
+}
+
+// SearchConfig represents full-text search settings in YAML
+type SearchConfig struct {
+	IndexDir string `yaml:"index_dir"`
+}
+
+// StorageConfig represents file storage settings in YAML
+type StorageConfig struct {
+	Backend          string        `yaml:"backend"`
+	PresignDownloads bool          `yaml:"presign_downloads"`
+	PresignExpiry    time.Duration `yaml:"presign_expiry"`
+	S3               S3Config      `yaml:"s3"`
+}
+
+// S3Config represents the settings of an S3-compatible store in YAML
+type S3Config struct {
+	Endpoint        string `yaml:"endpoint"`
+	Region          string `yaml:"region"`
+	Bucket          string `yaml:"bucket"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	PathStyle       bool   `yaml:"path_style"`
+}
+
+// TusConfig represents resumable upload settings in YAML
+type TusConfig struct {
+	MaxSize         int64         `yaml:"max_size"`
+	SessionTTL      time.Duration `yaml:"session_ttl"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval"`
+}
+
+// AuthConfig represents authentication settings in YAML
+type AuthConfig struct {
+	JWTSecret       string        `yaml:"jwt_secret"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
+	AllowSignup     bool          `yaml:"allow_signup"`
+	AdminUsername   string        `yaml:"admin_username"`
+	AdminEmail      string        `yaml:"admin_email"`
+	AdminPassword   string        `yaml:"admin_password"`
+}
+
+// OIDCConfig represents OpenID Connect single sign-on settings in YAML
+type OIDCConfig struct {
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
 }
 
 // DatabaseConfig represents database configuration in YAML
@@ -55,6 +178,12 @@ type LogConfig struct {
 // YAMLConfig represents the root configuration structure in YAML
 type YAMLConfig struct {
 	Server   ServerConfig   `yaml:"server"`
+	Reading  ReadingConfig  `yaml:"reading"`
+	Search   SearchConfig   `yaml:"search"`
+	Storage  StorageConfig  `yaml:"storage"`
+	Tus      TusConfig      `yaml:"tus"`
+	Auth     AuthConfig     `yaml:"auth"`
+	OIDC     OIDCConfig     `yaml:"oidc"`
 	Database DatabaseConfig `yaml:"database"`
 	Log      LogConfig      `yaml:"log"`
 }
@@ -91,9 +220,36 @@ func LoadConfig() Config {
 
 	// Create and initialize the config with default values
 	cfg := Config{
-		ServerAddress: ":8080",
-		UploadDir:     "uploads",
-		FileURLPrefix: "http://localhost:8080/files",
+		ServerAddress:      ":8080",
+		UploadDir:          "uploads",
+		FileURLPrefix:      "http://localhost:8080/files",
+		WorkerCount:        2,
+		WorkerPollInterval: 2 * time.Second,
+		TaskLeaseDuration:  5 * time.Minute,
+		TaskMaxAttempts:    3,
+		TaskRetryBaseDelay: 30 * time.Second,
+		TaskRetryMaxDelay:  30 * time.Minute,
+
+		ReadingWordsPerMinute:    200,
+		ReadingCJKCharsPerMinute: 300,
+		SessionIdleTimeout:       5 * time.Minute,
+
+		SearchIndexDir: "data/search",
+
+		StorageBackend:       "local",
+		StoragePresignExpiry: 15 * time.Minute,
+		S3Region:             "us-east-1",
+
+		TusMaxSize:         1 << 30,
+		TusSessionTTL:      24 * time.Hour,
+		TusCleanupInterval: 10 * time.Minute,
+
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 7 * 24 * time.Hour,
+		AdminUsername:   "admin",
+
+		OIDCScopes: []string{"openid", "email", "profile"},
+
 		DBConfig: db.DBConfig{
 			Host:     "localhost",
 			Port:     3306,
@@ -118,6 +274,114 @@ func LoadConfig() Config {
 		if yamlConfig.Server.FileURLPrefix != "" {
 			cfg.FileURLPrefix = yamlConfig.Server.FileURLPrefix
 		}
+		if yamlConfig.Server.Workers != 0 {
+			cfg.WorkerCount = yamlConfig.Server.Workers
+		}
+		if yamlConfig.Server.PollInterval != 0 {
+			cfg.WorkerPollInterval = yamlConfig.Server.PollInterval
+		}
+		if yamlConfig.Server.LeaseDuration != 0 {
+			cfg.TaskLeaseDuration = yamlConfig.Server.LeaseDuration
+		}
+		if yamlConfig.Server.MaxAttempts != 0 {
+			cfg.TaskMaxAttempts = yamlConfig.Server.MaxAttempts
+		}
+		if yamlConfig.Server.RetryBaseDelay != 0 {
+			cfg.TaskRetryBaseDelay = yamlConfig.Server.RetryBaseDelay
+		}
+		if yamlConfig.Server.RetryMaxDelay != 0 {
+			cfg.TaskRetryMaxDelay = yamlConfig.Server.RetryMaxDelay
+		}
+
+		// Set reading config
+		if yamlConfig.Reading.WordsPerMinute != 0 {
+			cfg.ReadingWordsPerMinute = yamlConfig.Reading.WordsPerMinute
+		}
+		if yamlConfig.Reading.CJKCharsPerMinute != 0 {
+			cfg.ReadingCJKCharsPerMinute = yamlConfig.Reading.CJKCharsPerMinute
+		}
+		if yamlConfig.Reading.SessionIdleTimeout != 0 {
+			cfg.SessionIdleTimeout = yamlConfig.Reading.SessionIdleTimeout
+		}
+
+		// Set search config
+		if yamlConfig.Search.IndexDir != "" {
+			cfg.SearchIndexDir = yamlConfig.Search.IndexDir
+		}
+
+		// Set storage config
+		if yamlConfig.Storage.Backend != "" {
+			cfg.StorageBackend = yamlConfig.Storage.Backend
+		}
+		cfg.StoragePresignDownloads = yamlConfig.Storage.PresignDownloads
+		if yamlConfig.Storage.PresignExpiry != 0 {
+			cfg.StoragePresignExpiry = yamlConfig.Storage.PresignExpiry
+		}
+		if yamlConfig.Storage.S3.Endpoint != "" {
+			cfg.S3Endpoint = yamlConfig.Storage.S3.Endpoint
+		}
+		if yamlConfig.Storage.S3.Region != "" {
+			cfg.S3Region = yamlConfig.Storage.S3.Region
+		}
+		if yamlConfig.Storage.S3.Bucket != "" {
+			cfg.S3Bucket = yamlConfig.Storage.S3.Bucket
+		}
+		if yamlConfig.Storage.S3.AccessKeyID != "" {
+			cfg.S3AccessKeyID = yamlConfig.Storage.S3.AccessKeyID
+		}
+		if yamlConfig.Storage.S3.SecretAccessKey != "" {
+			cfg.S3SecretAccessKey = yamlConfig.Storage.S3.SecretAccessKey
+		}
+		cfg.S3PathStyle = yamlConfig.Storage.S3.PathStyle
+
+		// Set resumable upload config
+		if yamlConfig.Tus.MaxSize != 0 {
+			cfg.TusMaxSize = yamlConfig.Tus.MaxSize
+		}
+		if yamlConfig.Tus.SessionTTL != 0 {
+			cfg.TusSessionTTL = yamlConfig.Tus.SessionTTL
+		}
+		if yamlConfig.Tus.CleanupInterval != 0 {
+			cfg.TusCleanupInterval = yamlConfig.Tus.CleanupInterval
+		}
+
+		// Set auth config
+		if yamlConfig.Auth.JWTSecret != "" {
+			cfg.JWTSecret = yamlConfig.Auth.JWTSecret
+		}
+		if yamlConfig.Auth.AccessTokenTTL != 0 {
+			cfg.AccessTokenTTL = yamlConfig.Auth.AccessTokenTTL
+		}
+		if yamlConfig.Auth.RefreshTokenTTL != 0 {
+			cfg.RefreshTokenTTL = yamlConfig.Auth.RefreshTokenTTL
+		}
+		cfg.AllowSignup = yamlConfig.Auth.AllowSignup
+		if yamlConfig.Auth.AdminUsername != "" {
+			cfg.AdminUsername = yamlConfig.Auth.AdminUsername
+		}
+		if yamlConfig.Auth.AdminEmail != "" {
+			cfg.AdminEmail = yamlConfig.Auth.AdminEmail
+		}
+		if yamlConfig.Auth.AdminPassword != "" {
+			cfg.AdminPassword = yamlConfig.Auth.AdminPassword
+		}
+
+		// Set OIDC config
+		if yamlConfig.OIDC.Issuer != "" {
+			cfg.OIDCIssuer = yamlConfig.OIDC.Issuer
+		}
+		if yamlConfig.OIDC.ClientID != "" {
+			cfg.OIDCClientID = yamlConfig.OIDC.ClientID
+		}
+		if yamlConfig.OIDC.ClientSecret != "" {
+			cfg.OIDCClientSecret = yamlConfig.OIDC.ClientSecret
+		}
+		if yamlConfig.OIDC.RedirectURL != "" {
+			cfg.OIDCRedirectURL = yamlConfig.OIDC.RedirectURL
+		}
+		if len(yamlConfig.OIDC.Scopes) > 0 {
+			cfg.OIDCScopes = yamlConfig.OIDC.Scopes
+		}
 
 		// Set database config
 		if yamlConfig.Database.Host != "" {
@@ -154,6 +418,11 @@ func LoadConfig() Config {
 		cfg.UploadDir = absUploadDir
 	}
 
+	absIndexDir, err := filepath.Abs(cfg.SearchIndexDir)
+	if err == nil {
+		cfg.SearchIndexDir = absIndexDir
+	}
+
 	return cfg
 }
 
@@ -188,6 +457,156 @@ func processEnvVars(cfg *Config) {
 	if val := os.Getenv("FILE_URL_PREFIX"); val != "" {
 		cfg.FileURLPrefix = val
 	}
+	if val := os.Getenv("WORKER_COUNT"); val != "" {
+		if count, err := strconv.Atoi(val); err == nil {
+			cfg.WorkerCount = count
+		}
+	}
+	if val := os.Getenv("WORKER_POLL_INTERVAL"); val != "" {
+		if interval, err := time.ParseDuration(val); err == nil {
+			cfg.WorkerPollInterval = interval
+		}
+	}
+	if val := os.Getenv("TASK_LEASE_DURATION"); val != "" {
+		if lease, err := time.ParseDuration(val); err == nil {
+			cfg.TaskLeaseDuration = lease
+		}
+	}
+	if val := os.Getenv("TASK_MAX_ATTEMPTS"); val != "" {
+		if attempts, err := strconv.Atoi(val); err == nil {
+			cfg.TaskMaxAttempts = attempts
+		}
+	}
+	if val := os.Getenv("TASK_RETRY_BASE_DELAY"); val != "" {
+		if delay, err := time.ParseDuration(val); err == nil {
+			cfg.TaskRetryBaseDelay = delay
+		}
+	}
+	if val := os.Getenv("TASK_RETRY_MAX_DELAY"); val != "" {
+		if delay, err := time.ParseDuration(val); err == nil {
+			cfg.TaskRetryMaxDelay = delay
+		}
+	}
+
+	// Process environment variables for reading settings
+	if val := os.Getenv("READING_WORDS_PER_MINUTE"); val != "" {
+		if speed, err := strconv.Atoi(val); err == nil {
+			cfg.ReadingWordsPerMinute = speed
+		}
+	}
+	if val := os.Getenv("READING_CJK_CHARS_PER_MINUTE"); val != "" {
+		if speed, err := strconv.Atoi(val); err == nil {
+			cfg.ReadingCJKCharsPerMinute = speed
+		}
+	}
+	if val := os.Getenv("READING_SESSION_IDLE_TIMEOUT"); val != "" {
+		if timeout, err := time.ParseDuration(val); err == nil {
+			cfg.SessionIdleTimeout = timeout
+		}
+	}
+
+	// Process environment variables for search settings
+	if val := os.Getenv("SEARCH_INDEX_DIR"); val != "" {
+		cfg.SearchIndexDir = val
+	}
+
+	// Process environment variables for storage settings
+	if val := os.Getenv("STORAGE_BACKEND"); val != "" {
+		cfg.StorageBackend = val
+	}
+	if val := os.Getenv("STORAGE_PRESIGN_DOWNLOADS"); val != "" {
+		if presign, err := strconv.ParseBool(val); err == nil {
+			cfg.StoragePresignDownloads = presign
+		}
+	}
+	if val := os.Getenv("STORAGE_PRESIGN_EXPIRY"); val != "" {
+		if expiry, err := time.ParseDuration(val); err == nil {
+			cfg.StoragePresignExpiry = expiry
+		}
+	}
+	if val := os.Getenv("S3_ENDPOINT"); val != "" {
+		cfg.S3Endpoint = val
+	}
+	if val := os.Getenv("S3_REGION"); val != "" {
+		cfg.S3Region = val
+	}
+	if val := os.Getenv("S3_BUCKET"); val != "" {
+		cfg.S3Bucket = val
+	}
+	if val := os.Getenv("S3_ACCESS_KEY_ID"); val != "" {
+		cfg.S3AccessKeyID = val
+	}
+	if val := os.Getenv("S3_SECRET_ACCESS_KEY"); val != "" {
+		cfg.S3SecretAccessKey = val
+	}
+	if val := os.Getenv("S3_PATH_STYLE"); val != "" {
+		if pathStyle, err := strconv.ParseBool(val); err == nil {
+			cfg.S3PathStyle = pathStyle
+		}
+	}
+
+	// Process environment variables for resumable upload settings
+	if val := os.Getenv("TUS_MAX_SIZE"); val != "" {
+		if maxSize, err := strconv.ParseInt(val, 10, 64); err == nil {
+			cfg.TusMaxSize = maxSize
+		}
+	}
+	if val := os.Getenv("TUS_SESSION_TTL"); val != "" {
+		if ttl, err := time.ParseDuration(val); err == nil {
+			cfg.TusSessionTTL = ttl
+		}
+	}
+	if val := os.Getenv("TUS_CLEANUP_INTERVAL"); val != "" {
+		if interval, err := time.ParseDuration(val); err == nil {
+			cfg.TusCleanupInterval = interval
+		}
+	}
+
+	// Process environment variables for auth settings
+	if val := os.Getenv("JWT_SECRET"); val != "" {
+		cfg.JWTSecret = val
+	}
+	if val := os.Getenv("ACCESS_TOKEN_TTL"); val != "" {
+		if ttl, err := time.ParseDuration(val); err == nil {
+			cfg.AccessTokenTTL = ttl
+		}
+	}
+	if val := os.Getenv("REFRESH_TOKEN_TTL"); val != "" {
+		if ttl, err := time.ParseDuration(val); err == nil {
+			cfg.RefreshTokenTTL = ttl
+		}
+	}
+	if val := os.Getenv("ALLOW_SIGNUP"); val != "" {
+		if allow, err := strconv.ParseBool(val); err == nil {
+			cfg.AllowSignup = allow
+		}
+	}
+	if val := os.Getenv("ADMIN_USERNAME"); val != "" {
+		cfg.AdminUsername = val
+	}
+	if val := os.Getenv("ADMIN_EMAIL"); val != "" {
+		cfg.AdminEmail = val
+	}
+	if val := os.Getenv("ADMIN_PASSWORD"); val != "" {
+		cfg.AdminPassword = val
+	}
+
+	// Process environment variables for OIDC settings
+	if val := os.Getenv("OIDC_ISSUER"); val != "" {
+		cfg.OIDCIssuer = val
+	}
+	if val := os.Getenv("OIDC_CLIENT_ID"); val != "" {
+		cfg.OIDCClientID = val
+	}
+	if val := os.Getenv("OIDC_CLIENT_SECRET"); val != "" {
+		cfg.OIDCClientSecret = val
+	}
+	if val := os.Getenv("OIDC_REDIRECT_URL"); val != "" {
+		cfg.OIDCRedirectURL = val
+	}
+	if val := os.Getenv("OIDC_SCOPES"); val != "" {
+		cfg.OIDCScopes = strings.Fields(val)
+	}
 
 	// Process environment variables for database settings
 	if val := os.Getenv("DB_HOST"); val != "" {
@@ -224,6 +643,11 @@ func processEnvVars(cfg *Config) {
 func overrideFromEnv(cfg *Config) {
 	// Replace ${ENV_VAR} in database password
 	cfg.DBConfig.Password = replaceEnvVars(cfg.DBConfig.Password)
+	cfg.JWTSecret = replaceEnvVars(cfg.JWTSecret)
+	cfg.AdminPassword = replaceEnvVars(cfg.AdminPassword)
+	cfg.OIDCClientSecret = replaceEnvVars(cfg.OIDCClientSecret)
+	cfg.S3AccessKeyID = replaceEnvVars(cfg.S3AccessKeyID)
+	cfg.S3SecretAccessKey = replaceEnvVars(cfg.S3SecretAccessKey)
 
 	// Replace other values as needed
 	cfg.UploadDir = replaceEnvVars(cfg.UploadDir)
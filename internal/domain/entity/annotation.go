@@ -0,0 +1,71 @@
+package entity
+
+import "time"
+
+// Anchor locates a passage of a task's extracted text. Offsets count
+// Unicode characters from the start of the text; EndOffset is exclusive.
+type Anchor struct {
+	Chapter     int `json:"chapter" gorm:"column:chapter;not null;default:0"`
+	StartOffset int `json:"start_offset" gorm:"column:start_offset;not null"`
+	EndOffset   int `json:"end_offset" gorm:"column:end_offset;not null"`
+
+	// ChapterTitle is filled in for exports
+	ChapterTitle string `json:"chapter_title,omitempty" gorm:"-"`
+}
+
+// Bookmark marks a position in a reading task for a user
+type Bookmark struct {
+	ID        int64 `json:"bookmark_id" gorm:"primaryKey;column:id;autoIncrement"`
+	UserID    int64 `json:"user_id" gorm:"column:user_id;not null;index:idx_reading_bookmarks_user_task,priority:1"`
+	TaskID    int64 `json:"task_id" gorm:"column:task_id;not null;index:idx_reading_bookmarks_user_task,priority:2"`
+	Anchor    `gorm:"embedded"`
+	Title     string    `json:"title" gorm:"column:title;not null;default:'';size:255"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// TableName specifies the table name for Bookmark
+func (Bookmark) TableName() string {
+	return "reading_bookmarks"
+}
+
+// Annotation is a highlighted passage of a reading task with an optional note
+type Annotation struct {
+	ID     int64 `json:"annotation_id" gorm:"primaryKey;column:id;autoIncrement"`
+	UserID int64 `json:"user_id" gorm:"column:user_id;not null;index:idx_reading_annotations_user_task,priority:1"`
+	TaskID int64 `json:"task_id" gorm:"column:task_id;not null;index:idx_reading_annotations_user_task,priority:2"`
+	Anchor `gorm:"embedded"`
+	// Quote is the highlighted text as it was when the annotation was saved
+	Quote     string    `json:"quote" gorm:"column:quote;type:text"`
+	Note      string    `json:"note" gorm:"column:note;type:text"`
+	Color     string    `json:"color,omitempty" gorm:"column:color;not null;default:'';size:32"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// TableName specifies the table name for Annotation
+func (Annotation) TableName() string {
+	return "reading_annotations"
+}
+
+// AnnotationsResponse lists a user's bookmarks and annotations on a task
+type AnnotationsResponse struct {
+	TaskID      int64         `json:"task_id"`
+	Bookmarks   []*Bookmark   `json:"bookmarks"`
+	Annotations []*Annotation `json:"annotations"`
+}
+
+// AnnotationExport is a user's bookmarks and annotations, grouped by task
+type AnnotationExport struct {
+	UserID     int64                  `json:"user_id"`
+	ExportedAt time.Time              `json:"exported_at"`
+	Tasks      []*TaskAnnotationGroup `json:"tasks"`
+}
+
+// TaskAnnotationGroup holds the bookmarks and annotations of one task in an export
+type TaskAnnotationGroup struct {
+	TaskID      int64         `json:"task_id"`
+	FileName    string        `json:"file_name"`
+	Bookmarks   []*Bookmark   `json:"bookmarks"`
+	Annotations []*Annotation `json:"annotations"`
+}
@@ -0,0 +1,63 @@
+package entity
+
+import "time"
+
+// APIKeyPrefix starts every API key, so that keys can be told apart from
+// access tokens and recognised when leaked
+const APIKeyPrefix = "tak_"
+
+// API key scopes. A key can call only the endpoints its scopes allow, and
+// only with the permissions of the user who owns it.
+const (
+	// ScopeTasksRead allows reading tasks, their content and files, and searching
+	ScopeTasksRead = "tasks:read"
+	// ScopeTasksWrite allows uploading files and retrying failed tasks
+	ScopeTasksWrite = "tasks:write"
+	// ScopeTasksStatusWrite allows only changing the status of tasks
+	ScopeTasksStatusWrite = "tasks:status:write"
+)
+
+// APIKeyScopes lists the scopes a key can be given
+var APIKeyScopes = []string{ScopeTasksRead, ScopeTasksWrite, ScopeTasksStatusWrite}
+
+// APIKey is a long-lived credential for scripts and workers. Only a hash
+// of the key is stored; the key itself is shown once, when it is created.
+type APIKey struct {
+	ID         int64      `json:"key_id" gorm:"primaryKey;column:id;autoIncrement"`
+	UserID     int64      `json:"user_id" gorm:"column:user_id;not null;index"`
+	Name       string     `json:"name" gorm:"column:name;not null;size:128"`
+	Prefix     string     `json:"prefix" gorm:"column:prefix;not null;size:16"`
+	KeyHash    string     `json:"-" gorm:"column:key_hash;not null;uniqueIndex;size:64"`
+	Scopes     []string   `json:"scopes" gorm:"column:scopes;type:varchar(512);not null;serializer:json"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" gorm:"column:expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" gorm:"column:last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" gorm:"column:revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// TableName specifies the table name for APIKey
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// HasScope reports whether the key was given scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Usable reports whether the key is neither revoked nor expired at now
+func (k *APIKey) Usable(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// CreatedAPIKey is returned when a key is created. Key is the only time
+// the key itself is ever shown.
+type CreatedAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,45 @@
+package entity
+
+import "time"
+
+// RefreshToken records an issued refresh token so it can be revoked.
+// Only the token's ID is stored, never the token itself.
+type RefreshToken struct {
+	ID        int64      `json:"-" gorm:"primaryKey;column:id;autoIncrement"`
+	TokenID   string     `json:"-" gorm:"column:token_id;not null;uniqueIndex;size:64"`
+	UserID    int64      `json:"-" gorm:"column:user_id;not null;index"`
+	ExpiresAt time.Time  `json:"-" gorm:"column:expires_at;not null"`
+	RevokedAt *time.Time `json:"-" gorm:"column:revoked_at"`
+	CreatedAt time.Time  `json:"-" gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// TableName specifies the table name for RefreshToken
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// UserIdentity links a user to an account at an OpenID Connect provider,
+// identified by the provider's issuer and the account's subject
+type UserIdentity struct {
+	ID        int64     `json:"-" gorm:"primaryKey;column:id;autoIncrement"`
+	UserID    int64     `json:"-" gorm:"column:user_id;not null;index"`
+	Issuer    string    `json:"-" gorm:"column:issuer;not null;size:255;uniqueIndex:uk_user_identities_issuer_subject"`
+	Subject   string    `json:"-" gorm:"column:subject;not null;size:255;uniqueIndex:uk_user_identities_issuer_subject"`
+	Email     string    `json:"-" gorm:"column:email;not null;default:'';size:255"`
+	CreatedAt time.Time `json:"-" gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// TableName specifies the table name for UserIdentity
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+// TokenResponse represents the tokens issued on login and refresh
+type TokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int    `json:"expires_in"`
+	RefreshExpiresIn int    `json:"refresh_expires_in"`
+	User             *User  `json:"user"`
+}
@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// Blob is a file in the storage backend, stored once per organization
+// under its SHA-256 hash however many tasks reference it. RefCount counts
+// the references; the blob is deleted when the last one is released.
+type Blob struct {
+	ID        int64     `json:"-" gorm:"primaryKey;column:id;autoIncrement"`
+	OrgID     int64     `json:"org_id" gorm:"column:org_id;not null;uniqueIndex:uk_blobs_org_sha256,priority:1"`
+	SHA256    string    `json:"sha256" gorm:"column:sha256;not null;size:64;uniqueIndex:uk_blobs_org_sha256,priority:2"`
+	Size      int64     `json:"size" gorm:"column:size;not null"`
+	RefCount  int       `json:"ref_count" gorm:"column:ref_count;not null;default:0"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// TableName specifies the table name for Blob
+func (Blob) TableName() string {
+	return "blobs"
+}
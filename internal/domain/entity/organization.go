@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// DefaultOrgID is the organization users join when they sign up. It is
+// created on start.
+const DefaultOrgID int64 = 1
+
+// Organization is a tenant: a business unit whose users and reading tasks
+// are kept apart from those of other organizations
+type Organization struct {
+	ID        int64     `json:"org_id" gorm:"primaryKey;column:id;autoIncrement"`
+	Name      string    `json:"name" gorm:"column:name;not null;uniqueIndex;size:255"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// TableName specifies the table name for Organization
+func (Organization) TableName() string {
+	return "organizations"
+}
@@ -0,0 +1,27 @@
+package entity
+
+// ReadingChapter is a chapter of a reading task's extracted text.
+// Offsets count Unicode characters; EndOffset is exclusive.
+type ReadingChapter struct {
+	ID          int64  `json:"-" gorm:"primaryKey;column:id;autoIncrement"`
+	TaskID      int64  `json:"task_id" gorm:"column:task_id;not null;uniqueIndex:uk_reading_chapters_task_ordinal,priority:1"`
+	Ordinal     int    `json:"chapter" gorm:"column:ordinal;not null;uniqueIndex:uk_reading_chapters_task_ordinal,priority:2"`
+	Title       string `json:"title" gorm:"column:title;size:255;not null;default:''"`
+	StartOffset int    `json:"start_offset" gorm:"column:start_offset;not null"`
+	EndOffset   int    `json:"end_offset" gorm:"column:end_offset;not null"`
+}
+
+// TableName specifies the table name for ReadingChapter
+func (ReadingChapter) TableName() string {
+	return "reading_chapters"
+}
+
+// ChapterResponse represents a chapter of a task together with its text
+type ChapterResponse struct {
+	TaskID      int64  `json:"task_id"`
+	Chapter     int    `json:"chapter"`
+	Title       string `json:"title"`
+	StartOffset int    `json:"start_offset"`
+	EndOffset   int    `json:"end_offset"`
+	Content     string `json:"content"`
+}
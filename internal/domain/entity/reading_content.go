@@ -0,0 +1,28 @@
+package entity
+
+import "time"
+
+// ReadingContent holds the plain text extracted from a reading task's file
+type ReadingContent struct {
+	TaskID    int64     `json:"task_id" gorm:"primaryKey;column:task_id;autoIncrement:false"`
+	Content   string    `json:"content" gorm:"column:content;type:longtext;not null"`
+	CharCount int       `json:"char_count" gorm:"column:char_count;not null;default:0"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// TableName specifies the table name for ReadingContent
+func (ReadingContent) TableName() string {
+	return "reading_contents"
+}
+
+// ContentResponse represents a page of a task's extracted text.
+// Offsets and lengths count Unicode characters, not bytes.
+type ContentResponse struct {
+	TaskID     int64  `json:"task_id"`
+	Offset     int    `json:"offset"`
+	Length     int    `json:"length"`
+	TotalChars int    `json:"total_chars"`
+	NextOffset *int   `json:"next_offset"`
+	Content    string `json:"content"`
+}
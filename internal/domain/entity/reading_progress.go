@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// ReadingProgress records where a user stopped reading a task's text
+type ReadingProgress struct {
+	ID         int64     `json:"-" gorm:"primaryKey;column:id;autoIncrement"`
+	UserID     int64     `json:"user_id" gorm:"column:user_id;not null;uniqueIndex:uk_reading_progress_user_task,priority:1"`
+	TaskID     int64     `json:"task_id" gorm:"column:task_id;not null;uniqueIndex:uk_reading_progress_user_task,priority:2;index"`
+	Chapter    int       `json:"chapter" gorm:"column:chapter;not null;default:0"`
+	Offset     int       `json:"offset" gorm:"column:char_offset;not null;default:0"`
+	Percentage float64   `json:"percentage" gorm:"column:percentage;type:decimal(5,2);not null;default:0"`
+	LastReadAt time.Time `json:"last_read_at" gorm:"column:last_read_at;type:datetime(3);not null"`
+	UpdatedAt  time.Time `json:"updated_at" gorm:"column:updated_at;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// TableName specifies the table name for ReadingProgress
+func (ReadingProgress) TableName() string {
+	return "reading_progress"
+}
@@ -0,0 +1,41 @@
+package entity
+
+import "time"
+
+// ReadingSession is a stretch of time a user spent reading a task.
+// ActiveSeconds only grows while heartbeats arrive, so a client that goes
+// away without ending its session is not credited for the idle time.
+type ReadingSession struct {
+	ID              int64      `json:"session_id" gorm:"primaryKey;column:id;autoIncrement"`
+	UserID          int64      `json:"user_id" gorm:"column:user_id;not null;index:idx_reading_sessions_user_started,priority:1"`
+	TaskID          int64      `json:"task_id" gorm:"column:task_id;not null;index"`
+	StartedAt       time.Time  `json:"started_at" gorm:"column:started_at;not null;index:idx_reading_sessions_user_started,priority:2"`
+	LastHeartbeatAt time.Time  `json:"last_heartbeat_at" gorm:"column:last_heartbeat_at;not null"`
+	EndedAt         *time.Time `json:"ended_at,omitempty" gorm:"column:ended_at"`
+	ActiveSeconds   int        `json:"active_seconds" gorm:"column:active_seconds;not null;default:0"`
+}
+
+// TableName specifies the table name for ReadingSession
+func (ReadingSession) TableName() string {
+	return "reading_sessions"
+}
+
+// UserReadingStats aggregates a user's reading over a date range
+type UserReadingStats struct {
+	UserID         int64          `json:"user_id"`
+	From           string         `json:"from"`
+	To             string         `json:"to"`
+	TimeZone       string         `json:"time_zone"`
+	TotalMinutes   float64        `json:"total_minutes"`
+	Sessions       int            `json:"sessions"`
+	BooksCompleted int            `json:"books_completed"`
+	CurrentStreak  int            `json:"current_streak"`
+	LongestStreak  int            `json:"longest_streak"`
+	Days           []DailyReading `json:"days"`
+}
+
+// DailyReading is the reading time of one day in a stats histogram
+type DailyReading struct {
+	Date    string  `json:"date"`
+	Minutes float64 `json:"minutes"`
+}
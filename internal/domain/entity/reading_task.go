@@ -2,14 +2,55 @@ package entity
 
 import "time"
 
-// ReadingTask represents a user's reading task and its associated file
+// Reading task statuses
+const (
+	TaskStatusPending    = "pending"
+	TaskStatusProcessing = "processing"
+	TaskStatusCompleted  = "completed"
+	TaskStatusFailed     = "failed"
+)
+
+// ReadingTask represents a user's reading task and its associated file.
+// FilePath and NormalizedPath are keys in the file storage backend. Files
+// are stored as blobs named by the SHA256 of their content, so identical
+// uploads share them; tasks created before that have no hashes.
 type ReadingTask struct {
 	ID        int64     `json:"task_id" gorm:"primaryKey;column:id;autoIncrement"`
 	UserID    int64     `json:"user_id" gorm:"column:user_id;not null;index"`
+	OrgID     int64     `json:"org_id" gorm:"column:org_id;not null;default:1;index"`
 	FileName  string    `json:"file_name" gorm:"column:file_name;not null;size:255"`
-	FilePath  string    `json:"file_path" gorm:"column:file_path;not null;size:512"`
+	FilePath  string    `json:"file_path" gorm:"column:file_path;not null;size:512;index"`
 	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP"`
 	Status    string    `json:"status" gorm:"column:status;not null;default:pending;type:enum('pending','processing','completed','failed')"`
+
+	// Character encoding of text uploads and the key of their UTF-8 copy.
+	// NormalizedPath is empty when the original is already plain UTF-8.
+	Encoding       string `json:"encoding,omitempty" gorm:"column:encoding;not null;default:'';size:32"`
+	NormalizedPath string `json:"normalized_path,omitempty" gorm:"column:normalized_path;not null;default:'';size:512;index"`
+
+	// Size and checksums of the original file, and the SHA-256 hash of
+	// the UTF-8 copy, if any
+	FileSize         int64  `json:"file_size" gorm:"column:file_size;not null;default:0"`
+	SHA256           string `json:"sha256,omitempty" gorm:"column:sha256;not null;default:'';size:64;index"`
+	MD5              string `json:"md5,omitempty" gorm:"column:md5;not null;default:'';size:32"`
+	NormalizedSHA256 string `json:"normalized_sha256,omitempty" gorm:"column:normalized_sha256;not null;default:'';size:64"`
+
+	// Document information filled in by processing
+	MIMEType       string `json:"mime_type,omitempty" gorm:"column:mime_type;not null;default:'';size:127"`
+	PageCount      int    `json:"page_count" gorm:"column:page_count;not null;default:0"`
+	ChapterCount   int    `json:"chapter_count" gorm:"column:chapter_count;not null;default:0"`
+	WordCount      int    `json:"word_count" gorm:"column:word_count;not null;default:0"`
+	CJKCharCount   int    `json:"cjk_char_count" gorm:"column:cjk_char_count;not null;default:0"`
+	ReadingMinutes int    `json:"reading_minutes" gorm:"column:reading_minutes;not null;default:0"`
+
+	// Lease held by the worker currently processing the task
+	LeaseOwner     string     `json:"lease_owner,omitempty" gorm:"column:lease_owner;not null;default:'';size:128"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty" gorm:"column:lease_expires_at;index"`
+
+	// Processing attempts and the most recent failure
+	ErrorMessage  string     `json:"error_message,omitempty" gorm:"column:error_message;type:text"`
+	Attempts      int        `json:"attempts" gorm:"column:attempts;not null;default:0"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty" gorm:"column:next_attempt_at;index"`
 }
 
 // TableName specifies the table name for ReadingTask
@@ -25,6 +66,32 @@ type TaskResponse struct {
 	FileURL   string    `json:"file_url"`
 	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"created_at"`
+	FileSize  int64     `json:"file_size,omitempty"`
+	SHA256    string    `json:"sha256,omitempty"`
+	MD5       string    `json:"md5,omitempty"`
+
+	Encoding          string `json:"encoding,omitempty"`
+	NormalizedFileURL string `json:"normalized_file_url,omitempty"`
+	MIMEType          string `json:"mime_type,omitempty"`
+	PageCount         int    `json:"page_count"`
+	ChapterCount      int    `json:"chapter_count"`
+	WordCount         int    `json:"word_count"`
+	CJKCharCount      int    `json:"cjk_char_count"`
+	ReadingMinutes    int    `json:"reading_minutes"`
+
+	ErrorMessage  string     `json:"error_message,omitempty"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+// DocumentInfo is what processing learned about a task's file
+type DocumentInfo struct {
+	MIMEType       string
+	PageCount      int
+	ChapterCount   int
+	WordCount      int
+	CJKCharCount   int
+	ReadingMinutes int
 }
 
 // UploadResponse represents the response for a file upload
@@ -32,4 +99,14 @@ type UploadResponse struct {
 	TaskID   int64  `json:"task_id"`
 	FileName string `json:"file_name"`
 	FileURL  string `json:"file_url"`
+	FileSize int64  `json:"file_size"`
+	SHA256   string `json:"sha256"`
+	MD5      string `json:"md5"`
+
+	// Deduplicated reports that the user had already uploaded an identical
+	// file, whose stored copy is shared
+	Deduplicated bool `json:"deduplicated"`
+
+	Encoding          string `json:"encoding,omitempty"`
+	NormalizedFileURL string `json:"normalized_file_url,omitempty"`
 } 
\ No newline at end of file
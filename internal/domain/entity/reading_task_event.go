@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+// ReadingTaskEvent records a single status transition of a reading task
+type ReadingTaskEvent struct {
+	ID         int64     `json:"event_id" gorm:"primaryKey;column:id;autoIncrement"`
+	TaskID     int64     `json:"task_id" gorm:"column:task_id;not null;index"`
+	Actor      string    `json:"actor" gorm:"column:actor;not null;size:128"`
+	FromStatus string    `json:"from_status" gorm:"column:from_status;not null;size:32"`
+	ToStatus   string    `json:"to_status" gorm:"column:to_status;not null;size:32"`
+	Reason     string    `json:"reason,omitempty" gorm:"column:reason;size:512"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// TableName specifies the table name for ReadingTaskEvent
+func (ReadingTaskEvent) TableName() string {
+	return "reading_task_events"
+}
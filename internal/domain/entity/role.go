@@ -0,0 +1,54 @@
+package entity
+
+// Role names. Admins manage their own organization; super admins manage
+// the deployment's organizations.
+const (
+	RoleAdmin      = "admin"
+	RoleReader     = "reader"
+	RoleWorker     = "worker"
+	RoleSuperAdmin = "superadmin"
+)
+
+// Permission names. Users can always access their own tasks and data;
+// permissions grant access beyond that.
+const (
+	// PermTasksReadAll allows reading every user's tasks, task lists and files
+	PermTasksReadAll = "tasks:read:all"
+	// PermTasksStatusWrite allows changing the status of tasks
+	PermTasksStatusWrite = "tasks:status:write"
+	// PermTasksDeleteAll allows deleting every user's tasks
+	PermTasksDeleteAll = "tasks:delete:all"
+	// PermStatsReadAll allows reading every user's reading statistics
+	PermStatsReadAll = "stats:read:all"
+	// PermUsersManage allows listing users, changing other users' accounts
+	// and assigning roles
+	PermUsersManage = "users:manage"
+	// PermOrgsManage allows listing and creating organizations, moving
+	// users between them and assigning the super admin role
+	PermOrgsManage = "orgs:manage"
+)
+
+// Role is a named set of permissions assigned to users
+type Role struct {
+	ID          int64         `json:"-" gorm:"primaryKey;column:id;autoIncrement"`
+	Name        string        `json:"name" gorm:"column:name;not null;uniqueIndex;size:64"`
+	Description string        `json:"description" gorm:"column:description;not null;default:'';size:255"`
+	Permissions []*Permission `json:"permissions,omitempty" gorm:"many2many:role_permissions"`
+}
+
+// TableName specifies the table name for Role
+func (Role) TableName() string {
+	return "roles"
+}
+
+// Permission allows an action beyond a user's own data
+type Permission struct {
+	ID          int64  `json:"-" gorm:"primaryKey;column:id;autoIncrement"`
+	Name        string `json:"name" gorm:"column:name;not null;uniqueIndex;size:64"`
+	Description string `json:"description" gorm:"column:description;not null;default:'';size:255"`
+}
+
+// TableName specifies the table name for Permission
+func (Permission) TableName() string {
+	return "permissions"
+}
@@ -0,0 +1,27 @@
+package entity
+
+// SearchResponse lists the tasks matching a full-text search
+type SearchResponse struct {
+	Query   string          `json:"query"`
+	Total   int             `json:"total"`
+	Results []*SearchResult `json:"results"`
+}
+
+// SearchResult is a task matching a search, with snippets of the matches
+type SearchResult struct {
+	TaskID     int64            `json:"task_id"`
+	FileName   string           `json:"file_name"`
+	Score      float64          `json:"score"`
+	MatchCount int              `json:"match_count"`
+	Snippets   []*SearchSnippet `json:"snippets"`
+}
+
+// SearchSnippet is a passage around a match. Text is HTML-escaped, with the
+// query terms wrapped in <mark> elements; Offset is the character offset of
+// the match in the task's extracted text.
+type SearchSnippet struct {
+	Offset       int    `json:"offset"`
+	Chapter      int    `json:"chapter"`
+	ChapterTitle string `json:"chapter_title,omitempty"`
+	Text         string `json:"text"`
+}
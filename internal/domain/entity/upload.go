@@ -0,0 +1,47 @@
+package entity
+
+import "time"
+
+// UploadSession is a resumable upload in progress, following the tus
+// protocol. The file arrives in chunks, each stored as an UploadPart; once
+// Offset reaches Length the parts are joined into a reading task.
+type UploadSession struct {
+	ID       string `json:"upload_id" gorm:"primaryKey;column:id;size:32"`
+	UserID   int64  `json:"user_id" gorm:"column:user_id;not null;index"`
+	FileName string `json:"file_name" gorm:"column:file_name;not null;size:255"`
+	Encoding string `json:"encoding,omitempty" gorm:"column:encoding;not null;default:'';size:32"`
+	Metadata string `json:"-" gorm:"column:metadata;not null;default:'';size:2048"`
+	Length   int64  `json:"length" gorm:"column:upload_length;not null"`
+	Offset   int64  `json:"offset" gorm:"column:upload_offset;not null;default:0"`
+	TaskID   *int64 `json:"task_id,omitempty" gorm:"column:task_id"`
+	// Completing is set while a request creates the upload's task
+	Completing bool      `json:"-" gorm:"column:completing;not null;default:false"`
+	ExpiresAt  time.Time `json:"expires_at" gorm:"column:expires_at;not null;index"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// TableName specifies the table name for UploadSession
+func (UploadSession) TableName() string {
+	return "upload_sessions"
+}
+
+// Complete reports whether all of the file has been received
+func (u *UploadSession) Complete() bool {
+	return u.Offset == u.Length
+}
+
+// UploadPart is a chunk of an upload, stored in the file storage backend
+// under Key
+type UploadPart struct {
+	ID        int64     `json:"-" gorm:"primaryKey;column:id;autoIncrement"`
+	SessionID string    `json:"-" gorm:"column:session_id;not null;size:32;index"`
+	Offset    int64     `json:"-" gorm:"column:upload_offset;not null"`
+	Size      int64     `json:"-" gorm:"column:size;not null"`
+	Key       string    `json:"-" gorm:"column:storage_key;not null;size:512"`
+	CreatedAt time.Time `json:"-" gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// TableName specifies the table name for UploadPart
+func (UploadPart) TableName() string {
+	return "upload_parts"
+}
@@ -4,13 +4,41 @@ import "time"
 
 // User represents a user in the system
 type User struct {
-	ID        int64     `json:"user_id" gorm:"primaryKey;column:id;autoIncrement"`
-	Username  string    `json:"username" gorm:"column:username;not null;size:255"`
-	Email     string    `json:"email" gorm:"column:email;not null;uniqueIndex;size:255"`
-	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP"`
+	ID       int64  `json:"user_id" gorm:"primaryKey;column:id;autoIncrement"`
+	OrgID    int64  `json:"org_id" gorm:"column:org_id;not null;default:1;index"`
+	Username string `json:"username" gorm:"column:username;not null;size:255"`
+	Email    string `json:"email" gorm:"column:email;not null;uniqueIndex;size:255"`
+	// PasswordHash is the bcrypt hash of the user's password, empty for
+	// users who cannot log in with a password
+	PasswordHash string    `json:"-" gorm:"column:password_hash;not null;default:'';size:255"`
+	CreatedAt    time.Time `json:"created_at" gorm:"column:created_at;not null;default:CURRENT_TIMESTAMP"`
+	Roles        []*Role   `json:"roles,omitempty" gorm:"many2many:user_roles"`
+	// Identities are the single sign-on accounts linked to the user
+	Identities []*UserIdentity `json:"-" gorm:"foreignKey:UserID"`
 }
 
 // TableName specifies the table name for User
 func (User) TableName() string {
 	return "users"
-} 
\ No newline at end of file
+}
+
+// HasPermission reports whether one of the user's roles grants permission.
+// The roles must have been loaded with their permissions.
+func (u *User) HasPermission(permission string) bool {
+	for _, role := range u.Roles {
+		for _, p := range role.Permissions {
+			if p.Name == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// UserListResponse represents a page of users
+type UserListResponse struct {
+	Users    []*User `json:"users"`
+	Page     int     `json:"page"`
+	PageSize int     `json:"page_size"`
+	Total    int64   `json:"total"`
+}
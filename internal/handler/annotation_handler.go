@@ -0,0 +1,410 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/middleware"
+	"textile-admin/internal/service"
+	"textile-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnnotationHandler handles HTTP requests for bookmarks and annotations
+type AnnotationHandler struct {
+	service *service.AnnotationService
+}
+
+// NewAnnotationHandler creates a new instance of AnnotationHandler
+func NewAnnotationHandler(service *service.AnnotationService) *AnnotationHandler {
+	return &AnnotationHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the routes for bookmarks and annotations
+func (h *AnnotationHandler) RegisterRoutes(router *gin.Engine, auth gin.HandlerFunc) {
+	readingGroup := router.Group("/api/reading", auth)
+	{
+		readingGroup.GET("/task/:task_id/annotations", h.GetTaskAnnotations)
+
+		readingGroup.POST("/task/:task_id/annotations/bookmarks", h.CreateBookmark)
+		readingGroup.GET("/task/:task_id/annotations/bookmarks/:bookmark_id", h.GetBookmark)
+		readingGroup.PUT("/task/:task_id/annotations/bookmarks/:bookmark_id", h.UpdateBookmark)
+		readingGroup.DELETE("/task/:task_id/annotations/bookmarks/:bookmark_id", h.DeleteBookmark)
+
+		readingGroup.POST("/task/:task_id/annotations/highlights", h.CreateAnnotation)
+		readingGroup.GET("/task/:task_id/annotations/highlights/:annotation_id", h.GetAnnotation)
+		readingGroup.PUT("/task/:task_id/annotations/highlights/:annotation_id", h.UpdateAnnotation)
+		readingGroup.DELETE("/task/:task_id/annotations/highlights/:annotation_id", h.DeleteAnnotation)
+
+		readingGroup.GET("/annotations/export", h.ExportAnnotations)
+	}
+}
+
+// anchorRequest is the anchor of a bookmark or annotation in a request body
+type anchorRequest struct {
+	Chapter     int `json:"chapter"`
+	StartOffset int `json:"start_offset"`
+	EndOffset   int `json:"end_offset"`
+}
+
+// GetTaskAnnotations handles listing a user's bookmarks and annotations on a task
+func (h *AnnotationHandler) GetTaskAnnotations(c *gin.Context) {
+	taskID, ok := parseIDParam(c, "task_id", "task")
+	if !ok {
+		return
+	}
+
+	userID := middleware.CurrentUser(c).ID
+
+	result, err := h.service.GetTaskAnnotations(userID, taskID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "查询成功", result)
+}
+
+// CreateBookmark handles adding a bookmark to a task
+func (h *AnnotationHandler) CreateBookmark(c *gin.Context) {
+	taskID, ok := parseIDParam(c, "task_id", "task")
+	if !ok {
+		return
+	}
+
+	var requestBody struct {
+		anchorRequest
+		Title string `json:"title"`
+	}
+
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		response.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	bookmark := &entity.Bookmark{
+		UserID: middleware.CurrentUser(c).ID,
+		TaskID: taskID,
+		Anchor: entity.Anchor{
+			Chapter:     requestBody.Chapter,
+			StartOffset: requestBody.StartOffset,
+			EndOffset:   requestBody.EndOffset,
+		},
+		Title: requestBody.Title,
+	}
+
+	if err := h.service.CreateBookmark(bookmark); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "书签创建成功", bookmark)
+}
+
+// GetBookmark handles the retrieval of a bookmark
+func (h *AnnotationHandler) GetBookmark(c *gin.Context) {
+	taskID, ok := parseIDParam(c, "task_id", "task")
+	if !ok {
+		return
+	}
+
+	bookmarkID, ok := parseIDParam(c, "bookmark_id", "bookmark")
+	if !ok {
+		return
+	}
+
+	userID := middleware.CurrentUser(c).ID
+
+	bookmark, err := h.service.GetBookmark(userID, taskID, bookmarkID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "查询成功", bookmark)
+}
+
+// UpdateBookmark handles changing the anchor or title of a bookmark.
+// Fields left out of the request body keep their values.
+func (h *AnnotationHandler) UpdateBookmark(c *gin.Context) {
+	taskID, ok := parseIDParam(c, "task_id", "task")
+	if !ok {
+		return
+	}
+
+	bookmarkID, ok := parseIDParam(c, "bookmark_id", "bookmark")
+	if !ok {
+		return
+	}
+
+	var requestBody struct {
+		Chapter     *int    `json:"chapter"`
+		StartOffset *int    `json:"start_offset"`
+		EndOffset   *int    `json:"end_offset"`
+		Title       *string `json:"title"`
+	}
+
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		response.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	bookmark, err := h.service.GetBookmark(middleware.CurrentUser(c).ID, taskID, bookmarkID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	applyAnchor(&bookmark.Anchor, requestBody.Chapter, requestBody.StartOffset, requestBody.EndOffset)
+	if requestBody.Title != nil {
+		bookmark.Title = *requestBody.Title
+	}
+
+	if err := h.service.UpdateBookmark(bookmark); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "书签更新成功", bookmark)
+}
+
+// DeleteBookmark handles deleting a bookmark
+func (h *AnnotationHandler) DeleteBookmark(c *gin.Context) {
+	taskID, ok := parseIDParam(c, "task_id", "task")
+	if !ok {
+		return
+	}
+
+	bookmarkID, ok := parseIDParam(c, "bookmark_id", "bookmark")
+	if !ok {
+		return
+	}
+
+	userID := middleware.CurrentUser(c).ID
+
+	if err := h.service.DeleteBookmark(userID, taskID, bookmarkID); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "书签已删除", nil)
+}
+
+// CreateAnnotation handles highlighting a passage of a task
+func (h *AnnotationHandler) CreateAnnotation(c *gin.Context) {
+	taskID, ok := parseIDParam(c, "task_id", "task")
+	if !ok {
+		return
+	}
+
+	var requestBody struct {
+		anchorRequest
+		Note  string `json:"note"`
+		Color string `json:"color" binding:"max=32"`
+	}
+
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		response.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	annotation := &entity.Annotation{
+		UserID: middleware.CurrentUser(c).ID,
+		TaskID: taskID,
+		Anchor: entity.Anchor{
+			Chapter:     requestBody.Chapter,
+			StartOffset: requestBody.StartOffset,
+			EndOffset:   requestBody.EndOffset,
+		},
+		Note:  requestBody.Note,
+		Color: requestBody.Color,
+	}
+
+	if err := h.service.CreateAnnotation(annotation); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "批注创建成功", annotation)
+}
+
+// GetAnnotation handles the retrieval of an annotation
+func (h *AnnotationHandler) GetAnnotation(c *gin.Context) {
+	taskID, ok := parseIDParam(c, "task_id", "task")
+	if !ok {
+		return
+	}
+
+	annotationID, ok := parseIDParam(c, "annotation_id", "annotation")
+	if !ok {
+		return
+	}
+
+	userID := middleware.CurrentUser(c).ID
+
+	annotation, err := h.service.GetAnnotation(userID, taskID, annotationID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "查询成功", annotation)
+}
+
+// UpdateAnnotation handles changing an annotation.
+// Fields left out of the request body keep their values.
+func (h *AnnotationHandler) UpdateAnnotation(c *gin.Context) {
+	taskID, ok := parseIDParam(c, "task_id", "task")
+	if !ok {
+		return
+	}
+
+	annotationID, ok := parseIDParam(c, "annotation_id", "annotation")
+	if !ok {
+		return
+	}
+
+	var requestBody struct {
+		Chapter     *int    `json:"chapter"`
+		StartOffset *int    `json:"start_offset"`
+		EndOffset   *int    `json:"end_offset"`
+		Note        *string `json:"note"`
+		Color       *string `json:"color" binding:"omitempty,max=32"`
+	}
+
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		response.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	annotation, err := h.service.GetAnnotation(middleware.CurrentUser(c).ID, taskID, annotationID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	applyAnchor(&annotation.Anchor, requestBody.Chapter, requestBody.StartOffset, requestBody.EndOffset)
+	if requestBody.Note != nil {
+		annotation.Note = *requestBody.Note
+	}
+	if requestBody.Color != nil {
+		annotation.Color = *requestBody.Color
+	}
+
+	if err := h.service.UpdateAnnotation(annotation); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "批注更新成功", annotation)
+}
+
+// DeleteAnnotation handles deleting an annotation
+func (h *AnnotationHandler) DeleteAnnotation(c *gin.Context) {
+	taskID, ok := parseIDParam(c, "task_id", "task")
+	if !ok {
+		return
+	}
+
+	annotationID, ok := parseIDParam(c, "annotation_id", "annotation")
+	if !ok {
+		return
+	}
+
+	userID := middleware.CurrentUser(c).ID
+
+	if err := h.service.DeleteAnnotation(userID, taskID, annotationID); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "批注已删除", nil)
+}
+
+// ExportAnnotations handles exporting a user's bookmarks and annotations as
+// a Markdown or JSON file, for all tasks or the one given by task_id
+func (h *AnnotationHandler) ExportAnnotations(c *gin.Context) {
+	userID := middleware.CurrentUser(c).ID
+
+	var taskID int64
+	if taskIDStr := c.Query("task_id"); taskIDStr != "" {
+		id, err := strconv.ParseInt(taskIDStr, 10, 64)
+		if err != nil {
+			response.BadRequest(c, "Invalid task ID format")
+			return
+		}
+		taskID = id
+	}
+
+	format := c.DefaultQuery("format", "markdown")
+	if format != "markdown" && format != "json" {
+		response.BadRequest(c, "Invalid format. Must be one of: markdown, json")
+		return
+	}
+
+	export, err := h.service.ExportAnnotations(userID, taskID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	fileName := fmt.Sprintf("annotations-%d", userID)
+	if taskID != 0 {
+		fileName = fmt.Sprintf("%s-task-%d", fileName, taskID)
+	}
+
+	if format == "json" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.json", fileName))
+		c.JSON(http.StatusOK, export)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.md", fileName))
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(service.RenderAnnotationsMarkdown(export)))
+}
+
+// handleError maps annotation service errors to responses
+func (h *AnnotationHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTaskNotFound):
+		response.NotFound(c, "Task not found")
+	case errors.Is(err, service.ErrBookmarkNotFound):
+		response.NotFound(c, "Bookmark not found")
+	case errors.Is(err, service.ErrAnnotationNotFound):
+		response.NotFound(c, "Annotation not found")
+	case errors.Is(err, service.ErrContentNotAvailable):
+		response.NotFound(c, "Content not available yet")
+	case errors.Is(err, service.ErrInvalidAnchor):
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalServerError(c, "Failed to process annotation request: "+err.Error())
+	}
+}
+
+// applyAnchor overwrites the anchor fields that were given in a request
+func applyAnchor(anchor *entity.Anchor, chapter, startOffset, endOffset *int) {
+	if chapter != nil {
+		anchor.Chapter = *chapter
+	}
+	if startOffset != nil {
+		anchor.StartOffset = *startOffset
+	}
+	if endOffset != nil {
+		anchor.EndOffset = *endOffset
+	}
+}
+
+// parseIDParam parses a numeric path parameter, responding with 400 if it is malformed
+func parseIDParam(c *gin.Context, param, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(param), 10, 64)
+	if err != nil {
+		response.BadRequest(c, fmt.Sprintf("Invalid %s ID format", name))
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"errors"
+	"textile-admin/internal/middleware"
+	"textile-admin/internal/service"
+	"textile-admin/pkg/response"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler handles HTTP requests for API keys
+type APIKeyHandler struct {
+	service *service.APIKeyService
+}
+
+// NewAPIKeyHandler creates a new instance of APIKeyHandler
+func NewAPIKeyHandler(service *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the routes for API keys. No scope allows them,
+// so keys can only be managed with an access token.
+func (h *APIKeyHandler) RegisterRoutes(router *gin.Engine, auth gin.HandlerFunc) {
+	keyGroup := router.Group("/api/keys", auth)
+	{
+		keyGroup.POST("", h.CreateAPIKey)
+		keyGroup.GET("", h.ListAPIKeys)
+		keyGroup.DELETE("/:key_id", h.RevokeAPIKey)
+	}
+}
+
+// CreateAPIKey handles the creation of an API key for the current user.
+// The response is the only time the key is shown.
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var requestBody struct {
+		Name      string     `json:"name" binding:"required"`
+		Scopes    []string   `json:"scopes" binding:"required"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		response.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	key, err := h.service.CreateAPIKey(middleware.CurrentUser(c), requestBody.Name, requestBody.Scopes, requestBody.ExpiresAt)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "API 密钥创建成功", key)
+}
+
+// ListAPIKeys handles the retrieval of the current user's API keys
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.service.ListAPIKeys(middleware.CurrentUser(c))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "查询成功", keys)
+}
+
+// RevokeAPIKey handles revoking an API key
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	keyID, ok := parseIDParam(c, "key_id", "API key")
+	if !ok {
+		return
+	}
+
+	key, err := h.service.RevokeAPIKey(middleware.CurrentUser(c), keyID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "API 密钥已吊销", key)
+}
+
+// handleError maps API key service errors to responses
+func (h *APIKeyHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidAPIKey):
+		response.BadRequest(c, err.Error())
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		response.NotFound(c, "API key not found")
+	default:
+		response.InternalServerError(c, "Failed to process API key request: "+err.Error())
+	}
+}
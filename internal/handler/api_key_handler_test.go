@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"textile-admin/internal/domain/entity"
+)
+
+func TestAPIKeyScopes(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.createUser(t, "script@example.com", entity.RoleWorker)
+
+	var upload entity.UploadResponse
+	decodeData(t, s.upload(t, token, "book.txt", "Chapter 1"), &upload)
+	taskPath := fmt.Sprintf("/api/reading/task/%d", upload.TaskID)
+
+	var created entity.CreatedAPIKey
+	decodeData(t, s.request(t, http.MethodPost, "/api/keys", token,
+		map[string]interface{}{"name": "reports", "scopes": []string{entity.ScopeTasksRead}}), &created)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		want   int
+	}{
+		{"read a task", http.MethodGet, taskPath, nil, http.StatusOK},
+		{"download a file", http.MethodGet, upload.FileURL, nil, http.StatusOK},
+		{"delete a task", http.MethodDelete, taskPath, nil, http.StatusForbidden},
+		{"set a task's status", http.MethodPut, taskPath + "/status", map[string]string{"status": entity.TaskStatusProcessing}, http.StatusForbidden},
+		{"create another key", http.MethodPost, "/api/keys", map[string]interface{}{"name": "more", "scopes": []string{entity.ScopeTasksWrite}}, http.StatusForbidden},
+		{"list users", http.MethodGet, "/api/users", nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := s.request(t, tt.method, tt.path, created.Key, tt.body); rec.Code != tt.want {
+				t.Errorf("%s %s with a tasks:read key: status %d, want %d: %s", tt.method, tt.path, rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	if rec := s.upload(t, created.Key, "other.txt", "Chapter 2"); rec.Code != http.StatusForbidden {
+		t.Errorf("upload with a tasks:read key: status %d, want 403", rec.Code)
+	}
+
+	// A revoked key no longer authenticates
+	decodeData(t, s.request(t, http.MethodDelete, fmt.Sprintf("/api/keys/%d", created.ID), token, nil), &entity.APIKey{})
+	if rec := s.request(t, http.MethodGet, taskPath, created.Key, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("request with a revoked key: status %d, want 401", rec.Code)
+	}
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"errors"
+	"textile-admin/internal/service"
+	"textile-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthHandler handles HTTP requests for login and tokens
+type AuthHandler struct {
+	service *service.AuthService
+}
+
+// NewAuthHandler creates a new instance of AuthHandler
+func NewAuthHandler(service *service.AuthService) *AuthHandler {
+	return &AuthHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the routes for authentication
+func (h *AuthHandler) RegisterRoutes(router *gin.Engine) {
+	authGroup := router.Group("/api/auth")
+	{
+		authGroup.POST("/login", h.Login)
+		authGroup.POST("/refresh", h.Refresh)
+		authGroup.POST("/logout", h.Logout)
+	}
+}
+
+// Login handles exchanging an email and password for tokens
+func (h *AuthHandler) Login(c *gin.Context) {
+	var requestBody struct {
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		response.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	tokens, err := h.service.Login(requestBody.Email, requestBody.Password)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "登录成功", tokens)
+}
+
+// Refresh handles exchanging a refresh token for new tokens
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	refreshToken, ok := bindRefreshToken(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.service.Refresh(refreshToken)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "令牌刷新成功", tokens)
+}
+
+// Logout handles revoking a refresh token
+func (h *AuthHandler) Logout(c *gin.Context) {
+	refreshToken, ok := bindRefreshToken(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Logout(refreshToken); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "已退出登录", nil)
+}
+
+// handleError maps auth service errors to responses
+func (h *AuthHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidCredentials):
+		response.Unauthorized(c, "Invalid email or password")
+	case errors.Is(err, service.ErrInvalidToken):
+		response.Unauthorized(c, "Invalid or expired refresh token")
+	default:
+		response.InternalServerError(c, "Failed to process auth request: "+err.Error())
+	}
+}
+
+// bindRefreshToken parses the refresh_token field of the request body,
+// responding with 400 when it is missing
+func bindRefreshToken(c *gin.Context) (string, bool) {
+	var requestBody struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		response.BadRequest(c, "Invalid request body: "+err.Error())
+		return "", false
+	}
+
+	return requestBody.RefreshToken, true
+}
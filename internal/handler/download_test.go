@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"textile-admin/internal/service"
+	"textile-admin/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newDownloadRouter serves a file stored in a local backend the way
+// DownloadFile does once the service has found it
+func newDownloadRouter(t *testing.T, content string) (*gin.Engine, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	ctx := context.Background()
+	files, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte(content))
+	key := hex.EncodeToString(sum[:]) + ".txt"
+	if err := files.Put(ctx, key, strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.GET("/files/:file_name", func(c *gin.Context) {
+		info, err := files.Stat(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, err := storage.OpenSeeker(ctx, files, key, info.Size)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		serveFile(c, &service.FileDownload{
+			FileName:           "春天.txt",
+			SHA256:             hex.EncodeToString(sum[:]),
+			ContentType:        "text/plain; charset=utf-8",
+			ContentDisposition: `attachment; filename="__.txt"; filename*=UTF-8''%E6%98%A5%E5%A4%A9.txt`,
+			Content:            reader,
+			Info:               info,
+		})
+	})
+
+	return router, `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func TestServeFile(t *testing.T) {
+	const content = "0123456789abcdefghij"
+	router, etag := newDownloadRouter(t, content)
+
+	tests := []struct {
+		name         string
+		headers      map[string]string
+		wantStatus   int
+		wantBody     string
+		wantRange    string
+		wantETagSent bool
+	}{
+		{
+			name:         "full download",
+			wantStatus:   http.StatusOK,
+			wantBody:     content,
+			wantETagSent: true,
+		},
+		{
+			name:         "range",
+			headers:      map[string]string{"Range": "bytes=5-9"},
+			wantStatus:   http.StatusPartialContent,
+			wantBody:     "56789",
+			wantRange:    "bytes 5-9/20",
+			wantETagSent: true,
+		},
+		{
+			name:         "suffix range",
+			headers:      map[string]string{"Range": "bytes=-3"},
+			wantStatus:   http.StatusPartialContent,
+			wantBody:     "hij",
+			wantRange:    "bytes 17-19/20",
+			wantETagSent: true,
+		},
+		{
+			name:       "range past the end",
+			headers:    map[string]string{"Range": "bytes=50-60"},
+			wantStatus: http.StatusRequestedRangeNotSatisfiable,
+			wantRange:  "bytes */20",
+		},
+		{
+			name:         "matching ETag",
+			headers:      map[string]string{"If-None-Match": etag},
+			wantStatus:   http.StatusNotModified,
+			wantETagSent: true,
+		},
+		{
+			name:         "stale ETag",
+			headers:      map[string]string{"If-None-Match": `"0000"`},
+			wantStatus:   http.StatusOK,
+			wantBody:     content,
+			wantETagSent: true,
+		},
+		{
+			name:         "range if unchanged",
+			headers:      map[string]string{"Range": "bytes=0-3", "If-Range": etag},
+			wantStatus:   http.StatusPartialContent,
+			wantBody:     "0123",
+			wantRange:    "bytes 0-3/20",
+			wantETagSent: true,
+		},
+		{
+			name:         "range if changed",
+			headers:      map[string]string{"Range": "bytes=0-3", "If-Range": `"0000"`},
+			wantStatus:   http.StatusOK,
+			wantBody:     content,
+			wantETagSent: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/files/book.txt", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusRequestedRangeNotSatisfiable && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if got := rec.Header().Get("Content-Range"); got != tt.wantRange {
+				t.Errorf("Content-Range = %q, want %q", got, tt.wantRange)
+			}
+			if got := rec.Header().Get("ETag"); tt.wantETagSent && got != etag {
+				t.Errorf("ETag = %q, want %q", got, etag)
+			}
+		})
+	}
+}
+
+func TestServeFileHeaders(t *testing.T) {
+	router, _ := newDownloadRouter(t, "第一章")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/files/book.txt", nil))
+
+	want := map[string]string{
+		"Content-Type":           "text/plain; charset=utf-8",
+		"Content-Disposition":    `attachment; filename="__.txt"; filename*=UTF-8''%E6%98%A5%E5%A4%A9.txt`,
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "private, no-cache",
+		"Accept-Ranges":          "bytes",
+		// base64 of the SHA-256 of the content
+		"Digest": "sha-256=kTSwCCUvJnygcoqzD/vld++1c7qgblTLMzji0neGDOA=",
+	}
+	for name, value := range want {
+		if got := rec.Header().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
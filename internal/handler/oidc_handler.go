@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"textile-admin/internal/service"
+	"textile-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// oidcLoginCookie holds the login state between redirecting to the
+	// identity provider and its callback
+	oidcLoginCookie = "oidc_login"
+	// oidcCookiePath limits the login state cookie to the OIDC routes
+	oidcCookiePath = "/api/auth/oidc"
+	// oidcCookieMaxAge is the cookie's lifetime in seconds; the state
+	// token inside expires on its own as well
+	oidcCookieMaxAge = 600
+)
+
+// OIDCHandler handles HTTP requests for OpenID Connect single sign-on
+type OIDCHandler struct {
+	service *service.OIDCService
+	// secureCookie marks the login state cookie Secure; set it when the
+	// callback is served over HTTPS
+	secureCookie bool
+}
+
+// NewOIDCHandler creates a new instance of OIDCHandler
+func NewOIDCHandler(service *service.OIDCService, secureCookie bool) *OIDCHandler {
+	return &OIDCHandler{
+		service:      service,
+		secureCookie: secureCookie,
+	}
+}
+
+// RegisterRoutes registers the routes for single sign-on
+func (h *OIDCHandler) RegisterRoutes(router *gin.Engine) {
+	oidcGroup := router.Group(oidcCookiePath)
+	{
+		oidcGroup.GET("/login", h.Login)
+		oidcGroup.GET("/callback", h.Callback)
+	}
+}
+
+// Login handles starting a sign-in by redirecting to the identity provider
+func (h *OIDCHandler) Login(c *gin.Context) {
+	authURL, loginState, err := h.service.StartLogin(c.Request.Context())
+	if err != nil {
+		response.InternalServerError(c, "Failed to start single sign-on: "+err.Error())
+		return
+	}
+
+	// Lax, not Strict: the callback is a cross-site navigation from the
+	// identity provider
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcLoginCookie, loginState, oidcCookieMaxAge, oidcCookiePath, "", h.secureCookie, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback handles the identity provider redirecting back after sign-in,
+// and responds with tokens like a password login
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	loginState, _ := c.Cookie(oidcLoginCookie)
+
+	// The login state is single use
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcLoginCookie, "", -1, oidcCookiePath, "", h.secureCookie, true)
+
+	if idpError := c.Query("error"); idpError != "" {
+		if description := c.Query("error_description"); description != "" {
+			idpError += ": " + description
+		}
+		response.Unauthorized(c, "Sign-in failed at the identity provider: "+idpError)
+		return
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		response.BadRequest(c, "Missing authorization code")
+		return
+	}
+	if loginState == "" {
+		response.Unauthorized(c, "Sign-in was not started here or has expired, start again")
+		return
+	}
+
+	tokens, err := h.service.FinishLogin(c.Request.Context(), loginState, c.Query("state"), code)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSSOFailed):
+			response.Unauthorized(c, err.Error())
+		case errors.Is(err, service.ErrEmailExists):
+			response.Conflict(c, "Another account already uses this email: "+err.Error())
+		default:
+			response.InternalServerError(c, "Failed to complete single sign-on: "+err.Error())
+		}
+		return
+	}
+
+	response.Success(c, "登录成功", tokens)
+}
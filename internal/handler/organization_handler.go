@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/middleware"
+	"textile-admin/internal/service"
+	"textile-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrganizationHandler handles HTTP requests for organizations
+type OrganizationHandler struct {
+	service *service.OrganizationService
+}
+
+// NewOrganizationHandler creates a new instance of OrganizationHandler
+func NewOrganizationHandler(service *service.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the routes for organizations. All of them
+// require PermOrgsManage.
+func (h *OrganizationHandler) RegisterRoutes(router *gin.Engine, auth gin.HandlerFunc) {
+	manage := middleware.RequirePermission(entity.PermOrgsManage)
+
+	router.GET("/api/organizations", auth, manage, h.ListOrganizations)
+	router.POST("/api/organizations", auth, manage, h.CreateOrganization)
+	router.PUT("/api/users/:user_id/organization", auth, manage, h.MoveUser)
+}
+
+// ListOrganizations handles the retrieval of all organizations
+func (h *OrganizationHandler) ListOrganizations(c *gin.Context) {
+	orgs, err := h.service.ListOrganizations()
+	if err != nil {
+		response.InternalServerError(c, "Failed to retrieve organizations: "+err.Error())
+		return
+	}
+
+	response.Success(c, "查询成功", orgs)
+}
+
+// CreateOrganization handles the creation of an organization
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	var requestBody struct {
+		Name string `json:"name" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		response.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	org, err := h.service.CreateOrganization(requestBody.Name)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidOrg):
+			response.BadRequest(c, err.Error())
+		case errors.Is(err, service.ErrOrgExists):
+			response.Conflict(c, "Organization already exists")
+		default:
+			response.InternalServerError(c, "Failed to create organization: "+err.Error())
+		}
+		return
+	}
+
+	response.Success(c, "组织创建成功", org)
+}
+
+// MoveUser handles moving a user to another organization
+func (h *OrganizationHandler) MoveUser(c *gin.Context) {
+	userID, ok := parseIDParam(c, "user_id", "user")
+	if !ok {
+		return
+	}
+
+	var requestBody struct {
+		OrgID int64 `json:"org_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		response.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	user, err := h.service.MoveUser(userID, requestBody.OrgID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			response.NotFound(c, "User not found")
+		case errors.Is(err, service.ErrOrgNotFound):
+			response.NotFound(c, "Organization not found")
+		case errors.Is(err, service.ErrUserHasTasks):
+			response.Conflict(c, err.Error())
+		default:
+			response.InternalServerError(c, "Failed to move user: "+err.Error())
+		}
+		return
+	}
+
+	response.Success(c, "组织更新成功", user)
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/middleware"
+	"textile-admin/internal/service"
+	"textile-admin/pkg/response"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProgressHandler handles HTTP requests for reading progress
+type ProgressHandler struct {
+	service *service.ProgressService
+}
+
+// NewProgressHandler creates a new instance of ProgressHandler
+func NewProgressHandler(service *service.ProgressService) *ProgressHandler {
+	return &ProgressHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the routes for reading progress
+func (h *ProgressHandler) RegisterRoutes(router *gin.Engine, auth gin.HandlerFunc) {
+	readingGroup := router.Group("/api/reading", auth)
+	{
+		readingGroup.GET("/task/:task_id/progress", h.GetProgress)
+		readingGroup.PUT("/task/:task_id/progress", h.SaveProgress)
+	}
+}
+
+// GetProgress handles the retrieval of a user's progress on a task
+func (h *ProgressHandler) GetProgress(c *gin.Context) {
+	taskIDStr := c.Param("task_id")
+	taskID, err := strconv.ParseInt(taskIDStr, 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid task ID format")
+		return
+	}
+
+	progress, err := h.service.GetProgress(middleware.CurrentUser(c).ID, taskID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTaskNotFound):
+			response.NotFound(c, "Task not found")
+		case errors.Is(err, service.ErrProgressNotFound):
+			response.NotFound(c, "No reading progress recorded")
+		default:
+			response.InternalServerError(c, "Failed to retrieve reading progress: "+err.Error())
+		}
+		return
+	}
+
+	response.Success(c, "查询成功", progress)
+}
+
+// SaveProgress handles recording a user's progress on a task
+func (h *ProgressHandler) SaveProgress(c *gin.Context) {
+	taskIDStr := c.Param("task_id")
+	taskID, err := strconv.ParseInt(taskIDStr, 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid task ID format")
+		return
+	}
+
+	var requestBody struct {
+		Chapter    int        `json:"chapter"`
+		Offset     int        `json:"offset"`
+		Percentage float64    `json:"percentage"`
+		LastReadAt *time.Time `json:"last_read_at"`
+	}
+
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		response.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	progress := &entity.ReadingProgress{
+		UserID:     middleware.CurrentUser(c).ID,
+		TaskID:     taskID,
+		Chapter:    requestBody.Chapter,
+		Offset:     requestBody.Offset,
+		Percentage: requestBody.Percentage,
+	}
+	if requestBody.LastReadAt != nil {
+		progress.LastReadAt = *requestBody.LastReadAt
+	}
+
+	saved, err := h.service.SaveProgress(progress)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTaskNotFound):
+			response.NotFound(c, "Task not found")
+		case errors.Is(err, service.ErrInvalidProgress):
+			response.BadRequest(c, err.Error())
+		case errors.Is(err, service.ErrStaleProgress):
+			response.Conflict(c, "Newer progress already recorded: "+err.Error())
+		default:
+			response.InternalServerError(c, "Failed to save reading progress: "+err.Error())
+		}
+		return
+	}
+
+	response.Success(c, "阅读进度已保存", saved)
+}
@@ -1,58 +1,68 @@
 package handler
 
 import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"os"
+	"net/http"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/middleware"
 	"textile-admin/internal/service"
+	"textile-admin/pkg/charset"
 	"textile-admin/pkg/response"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Content paging limits, in characters
+const (
+	defaultContentPageLength = 5000
+	maxContentPageLength     = 50000
+)
+
 // ReadingHandler handles HTTP requests for reading tasks
 type ReadingHandler struct {
-	service     *service.ReadingService
-	uploadDir   string
+	service *service.ReadingService
 }
 
 // NewReadingHandler creates a new instance of ReadingHandler
-func NewReadingHandler(service *service.ReadingService, uploadDir string) *ReadingHandler {
+func NewReadingHandler(service *service.ReadingService) *ReadingHandler {
 	return &ReadingHandler{
-		service:     service,
-		uploadDir:   uploadDir,
+		service: service,
 	}
 }
 
 // RegisterRoutes registers the routes for reading tasks
-func (h *ReadingHandler) RegisterRoutes(router *gin.Engine) {
-	readingGroup := router.Group("/api/reading")
+func (h *ReadingHandler) RegisterRoutes(router *gin.Engine, auth gin.HandlerFunc) {
+	readingGroup := router.Group("/api/reading", auth)
 	{
 		readingGroup.POST("/upload", h.UploadFile)
 		readingGroup.GET("/task/:task_id", h.GetTask)
+		readingGroup.DELETE("/task/:task_id", h.DeleteTask)
 		readingGroup.GET("/tasks/user/:user_id", h.GetUserTasks)
-		readingGroup.PUT("/task/:task_id/status", h.UpdateTaskStatus)
+		readingGroup.PUT("/task/:task_id/status", middleware.RequirePermission(entity.PermTasksStatusWrite), h.UpdateTaskStatus)
+		readingGroup.GET("/task/:task_id/events", h.GetTaskEvents)
+		readingGroup.POST("/task/:task_id/retry", h.RetryTask)
+		readingGroup.GET("/task/:task_id/content", h.GetTaskContent)
+		readingGroup.GET("/task/:task_id/chapters", h.GetTaskChapters)
+		readingGroup.GET("/task/:task_id/chapters/:n", h.GetTaskChapter)
 	}
 
 	// Route for file download
-	router.GET("/files/:file_name", h.DownloadFile)
+	router.GET("/files/:file_name", auth, h.DownloadFile)
 }
 
 // UploadFile handles the file upload and creation of reading task
 func (h *ReadingHandler) UploadFile(c *gin.Context) {
-	// Parse user ID from form data
-	userIDStr := c.PostForm("user_id")
-	if userIDStr == "" {
-		response.BadRequest(c, "User ID is required")
-		return
-	}
-
-	userID, err := strconv.ParseInt(userIDStr, 10, 64)
-	if err != nil {
-		response.BadRequest(c, "Invalid user ID format")
-		return
-	}
+	// The task belongs to the authenticated user
+	userID := middleware.CurrentUser(c).ID
 
 	// Get the file from form data
 	file, err := c.FormFile("file")
@@ -70,10 +80,27 @@ func (h *ReadingHandler) UploadFile(c *gin.Context) {
 	// Validate file type if needed
 	// ...
 
+	// Optional source encoding of text files, detected when omitted
+	encoding := c.PostForm("encoding")
+
+	// Optional checksums of the file to verify it against
+	expected, err := parseChecksums(c.Request.Header)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
 	// Create the reading task
-	result, err := h.service.CreateTask(userID, file)
+	result, err := h.service.CreateTask(c.Request.Context(), userID, file, encoding, expected)
 	if err != nil {
-		response.InternalServerError(c, "Failed to create reading task: "+err.Error())
+		switch {
+		case errors.Is(err, charset.ErrUnknownEncoding):
+			response.BadRequest(c, "Unsupported encoding: "+encoding)
+		case errors.Is(err, service.ErrChecksumMismatch):
+			response.BadRequest(c, "File does not match its checksum: "+err.Error())
+		default:
+			response.InternalServerError(c, "Failed to create reading task: "+err.Error())
+		}
 		return
 	}
 
@@ -89,31 +116,57 @@ func (h *ReadingHandler) GetTask(c *gin.Context) {
 		return
 	}
 
-	task, err := h.service.GetTaskByID(taskID)
+	task, err := h.service.GetTaskByID(middleware.CurrentUser(c), taskID)
 	if err != nil {
+		if errors.Is(err, service.ErrTaskNotFound) {
+			response.NotFound(c, "Task not found")
+			return
+		}
 		response.InternalServerError(c, "Failed to retrieve task: "+err.Error())
 		return
 	}
 
-	if task == nil {
-		response.NotFound(c, "Task not found")
+	response.Success(c, "查询成功", task)
+}
+
+// DeleteTask handles the deletion of a reading task
+func (h *ReadingHandler) DeleteTask(c *gin.Context) {
+	taskID, ok := parseIDParam(c, "task_id", "task")
+	if !ok {
 		return
 	}
 
-	response.Success(c, "查询成功", task)
+	err := h.service.DeleteTask(c.Request.Context(), middleware.CurrentUser(c), taskID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTaskNotFound):
+			response.NotFound(c, "Task not found")
+		case errors.Is(err, service.ErrForbidden):
+			response.Forbidden(c, "You can only delete your own tasks")
+		case errors.Is(err, service.ErrTaskProcessing):
+			response.Conflict(c, "Task is being processed, try again later")
+		default:
+			response.InternalServerError(c, "Failed to delete task: "+err.Error())
+		}
+		return
+	}
+
+	response.Success(c, "任务删除成功", nil)
 }
 
 // GetUserTasks handles the retrieval of all reading tasks for a user
 func (h *ReadingHandler) GetUserTasks(c *gin.Context) {
-	userIDStr := c.Param("user_id")
-	userID, err := strconv.ParseInt(userIDStr, 10, 64)
-	if err != nil {
-		response.BadRequest(c, "Invalid user ID format")
+	userID, ok := parseIDParam(c, "user_id", "user")
+	if !ok {
 		return
 	}
 
-	tasks, err := h.service.GetTasksByUserID(userID)
+	tasks, err := h.service.GetTasksByUserID(middleware.CurrentUser(c), userID)
 	if err != nil {
+		if errors.Is(err, service.ErrForbidden) {
+			response.Forbidden(c, "You can only list your own tasks")
+			return
+		}
 		response.InternalServerError(c, "Failed to retrieve tasks: "+err.Error())
 		return
 	}
@@ -130,9 +183,10 @@ func (h *ReadingHandler) UpdateTaskStatus(c *gin.Context) {
 		return
 	}
 
-	// Get the status from JSON body
+	// Get the status and optional reason from JSON body
 	var requestBody struct {
 		Status string `json:"status" binding:"required"`
+		Reason string `json:"reason"`
 	}
 
 	if err := c.ShouldBindJSON(&requestBody); err != nil {
@@ -141,45 +195,304 @@ func (h *ReadingHandler) UpdateTaskStatus(c *gin.Context) {
 	}
 
 	// Validate status
-	validStatuses := map[string]bool{
-		"pending":    true,
-		"processing": true,
-		"completed":  true,
-		"failed":     true,
-	}
-
-	if !validStatuses[requestBody.Status] {
+	if !service.IsValidTaskStatus(requestBody.Status) {
 		response.BadRequest(c, "Invalid status value. Must be one of: pending, processing, completed, failed")
 		return
 	}
 
 	// Update the task status
-	err = h.service.UpdateTaskStatus(taskID, requestBody.Status)
+	user := middleware.CurrentUser(c)
+	actor := actorOf(c)
+	err = h.service.UpdateTaskStatus(user, taskID, requestBody.Status, actor, requestBody.Reason)
 	if err != nil {
-		response.InternalServerError(c, "Failed to update task status: "+err.Error())
+		switch {
+		case errors.Is(err, service.ErrTaskNotFound):
+			response.NotFound(c, "Task not found")
+		case errors.Is(err, service.ErrInvalidTransition):
+			response.Conflict(c, "Status change not allowed: "+err.Error())
+		default:
+			response.InternalServerError(c, "Failed to update task status: "+err.Error())
+		}
 		return
 	}
 
 	response.Success(c, "状态更新成功", nil)
 }
 
-// DownloadFile handles file download requests
+// GetTaskContent handles paging through the extracted text of a reading task
+func (h *ReadingHandler) GetTaskContent(c *gin.Context) {
+	taskIDStr := c.Param("task_id")
+	taskID, err := strconv.ParseInt(taskIDStr, 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid task ID format")
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		response.BadRequest(c, "Invalid offset, must be a non-negative integer")
+		return
+	}
+
+	length, err := strconv.Atoi(c.DefaultQuery("length", strconv.Itoa(defaultContentPageLength)))
+	if err != nil || length <= 0 || length > maxContentPageLength {
+		response.BadRequest(c, fmt.Sprintf("Invalid length, must be between 1 and %d", maxContentPageLength))
+		return
+	}
+
+	page, err := h.service.GetTaskContent(middleware.CurrentUser(c), taskID, offset, length)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTaskNotFound):
+			response.NotFound(c, "Task not found")
+		case errors.Is(err, service.ErrContentNotAvailable):
+			response.NotFound(c, "Content not available yet")
+		default:
+			response.InternalServerError(c, "Failed to retrieve task content: "+err.Error())
+		}
+		return
+	}
+
+	response.Success(c, "查询成功", page)
+}
+
+// GetTaskChapters handles the retrieval of a reading task's chapter list
+func (h *ReadingHandler) GetTaskChapters(c *gin.Context) {
+	taskIDStr := c.Param("task_id")
+	taskID, err := strconv.ParseInt(taskIDStr, 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid task ID format")
+		return
+	}
+
+	chapters, err := h.service.GetTaskChapters(middleware.CurrentUser(c), taskID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTaskNotFound):
+			response.NotFound(c, "Task not found")
+		case errors.Is(err, service.ErrContentNotAvailable):
+			response.NotFound(c, "Content not available yet")
+		default:
+			response.InternalServerError(c, "Failed to retrieve task chapters: "+err.Error())
+		}
+		return
+	}
+
+	response.Success(c, "查询成功", chapters)
+}
+
+// GetTaskChapter handles the retrieval of a single chapter's text
+func (h *ReadingHandler) GetTaskChapter(c *gin.Context) {
+	taskIDStr := c.Param("task_id")
+	taskID, err := strconv.ParseInt(taskIDStr, 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid task ID format")
+		return
+	}
+
+	ordinal, err := strconv.Atoi(c.Param("n"))
+	if err != nil || ordinal < 1 {
+		response.BadRequest(c, "Invalid chapter number, must be a positive integer")
+		return
+	}
+
+	chapter, err := h.service.GetTaskChapter(middleware.CurrentUser(c), taskID, ordinal)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTaskNotFound):
+			response.NotFound(c, "Task not found")
+		case errors.Is(err, service.ErrContentNotAvailable):
+			response.NotFound(c, "Content not available yet")
+		case errors.Is(err, service.ErrChapterNotFound):
+			response.NotFound(c, "Chapter not found")
+		default:
+			response.InternalServerError(c, "Failed to retrieve chapter: "+err.Error())
+		}
+		return
+	}
+
+	response.Success(c, "查询成功", chapter)
+}
+
+// RetryTask handles manually retrying a failed reading task
+func (h *ReadingHandler) RetryTask(c *gin.Context) {
+	taskIDStr := c.Param("task_id")
+	taskID, err := strconv.ParseInt(taskIDStr, 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid task ID format")
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	actor := actorOf(c)
+	err = h.service.RetryTask(user, taskID, actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTaskNotFound):
+			response.NotFound(c, "Task not found")
+		case errors.Is(err, service.ErrInvalidTransition):
+			response.Conflict(c, "Retry not allowed: "+err.Error())
+		default:
+			response.InternalServerError(c, "Failed to retry task: "+err.Error())
+		}
+		return
+	}
+
+	response.Success(c, "任务已重新排队", nil)
+}
+
+// GetTaskEvents handles the retrieval of the status history of a reading task
+func (h *ReadingHandler) GetTaskEvents(c *gin.Context) {
+	taskIDStr := c.Param("task_id")
+	taskID, err := strconv.ParseInt(taskIDStr, 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid task ID format")
+		return
+	}
+
+	events, err := h.service.GetTaskEvents(middleware.CurrentUser(c), taskID)
+	if err != nil {
+		if errors.Is(err, service.ErrTaskNotFound) {
+			response.NotFound(c, "Task not found")
+			return
+		}
+		response.InternalServerError(c, "Failed to retrieve task events: "+err.Error())
+		return
+	}
+
+	response.Success(c, "查询成功", events)
+}
+
+// DownloadFile handles file download requests. Files of tasks the caller
+// may not access are reported as missing. When the storage backend hands
+// out presigned URLs, the client is redirected to one instead. Files are
+// offered under the name they were uploaded with; with inline=true,
+// browsers may display PDFs, images and plain text rather than save them.
 func (h *ReadingHandler) DownloadFile(c *gin.Context) {
-	fileName := c.Param("file_name")
-	
-	// For security reasons, let's sanitize the filename to prevent directory traversal
-	fileName = filepath.Base(fileName)
-	
-	// Construct the file path
-	filePath := filepath.Join(h.uploadDir, fileName)
-	
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		response.NotFound(c, "File not found")
-		return
-	}
-	
-	// Set the appropriate content disposition and serve the file
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
-	c.File(filePath)
-} 
\ No newline at end of file
+	// Only the base name is used, which prevents directory traversal
+	fileName := filepath.Base(c.Param("file_name"))
+
+	inline, err := strconv.ParseBool(c.DefaultQuery("inline", "false"))
+	if err != nil {
+		response.BadRequest(c, "Invalid inline, must be true or false")
+		return
+	}
+
+	download, err := h.service.OpenFile(c.Request.Context(), middleware.CurrentUser(c), fileName, inline)
+	if err != nil {
+		if errors.Is(err, service.ErrFileNotFound) {
+			response.NotFound(c, "File not found")
+			return
+		}
+		response.InternalServerError(c, "Failed to retrieve file: "+err.Error())
+		return
+	}
+
+	if download.URL != "" {
+		c.Redirect(http.StatusFound, download.URL)
+		return
+	}
+
+	serveFile(c, download)
+}
+
+// serveFile sends the content of a download, answering conditional and
+// range requests
+func serveFile(c *gin.Context, download *service.FileDownload) {
+	defer download.Content.Close()
+
+	// Files stored under their hash carry it for clients to verify them,
+	// and as the validator of conditional requests
+	if download.SHA256 != "" {
+		c.Header("ETag", `"`+download.SHA256+`"`)
+		if sum, err := hex.DecodeString(download.SHA256); err == nil {
+			c.Header("Digest", "sha-256="+base64.StdEncoding.EncodeToString(sum))
+		}
+	}
+
+	c.Header("Content-Type", download.ContentType)
+	c.Header("Content-Disposition", download.ContentDisposition)
+	c.Header("X-Content-Type-Options", "nosniff")
+	// Files are private to their task's users, and browsers revalidate
+	// them with the ETag or modification time
+	c.Header("Cache-Control", "private, no-cache")
+
+	// ServeContent answers Range, If-Range, If-None-Match and
+	// If-Modified-Since requests
+	http.ServeContent(c.Writer, c.Request, download.FileName, download.Info.ModTime, download.Content)
+}
+
+// parseChecksums reads the checksums a client sent with an upload, from a
+// Content-MD5 header (RFC 1864) or a Digest header (RFC 3230) with sha-256
+// or md5 values. Both describe the uploaded file, and values for the same
+// algorithm must agree. It returns nil when neither is present; algorithms
+// other than these are ignored.
+func parseChecksums(header http.Header) (*service.Checksums, error) {
+	var sums service.Checksums
+
+	if value := header.Get("Content-MD5"); value != "" {
+		sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil || len(sum) != md5.Size {
+			return nil, errors.New("Invalid Content-MD5 header")
+		}
+		sums.MD5 = sum
+	}
+
+	// Digest values may be split over several header fields
+	var digests []string
+	for _, field := range header.Values("Digest") {
+		digests = append(digests, strings.Split(field, ",")...)
+	}
+
+	// Digest values found so far, to tell a conflict among them from one
+	// with Content-MD5
+	inDigest := make(map[int]bool, 2)
+	for _, value := range digests {
+		algorithm, encoded, found := strings.Cut(strings.TrimSpace(value), "=")
+		if !found {
+			continue
+		}
+
+		var size int
+		switch strings.ToLower(algorithm) {
+		case "sha-256":
+			size = sha256.Size
+		case "md5":
+			size = md5.Size
+		default:
+			continue
+		}
+
+		sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(sum) != size {
+			return nil, errors.New("Invalid " + algorithm + " value in Digest header")
+		}
+
+		known := &sums.MD5
+		if size == sha256.Size {
+			known = &sums.SHA256
+		}
+		if *known != nil && !bytes.Equal(*known, sum) {
+			if inDigest[size] {
+				return nil, errors.New("Conflicting " + algorithm + " checksums in Digest header")
+			}
+			return nil, errors.New("Conflicting " + algorithm + " checksums in Content-MD5 and Digest headers")
+		}
+		*known = sum
+		inDigest[size] = true
+	}
+
+	if sums.MD5 == nil && sums.SHA256 == nil {
+		return nil, nil
+	}
+	return &sums, nil
+}
+
+// actorOf names who made a request in task events: the API key when the
+// request used one, otherwise the user
+func actorOf(c *gin.Context) string {
+	if key := middleware.CurrentAPIKey(c); key != nil {
+		return fmt.Sprintf("api_key:%d", key.ID)
+	}
+	return fmt.Sprintf("user:%d", middleware.CurrentUser(c).ID)
+}
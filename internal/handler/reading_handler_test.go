@@ -0,0 +1,379 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/middleware"
+	"textile-admin/internal/processor"
+	"textile-admin/internal/repository"
+	"textile-admin/internal/repository/repotest"
+	"textile-admin/internal/search"
+	"textile-admin/internal/service"
+	"textile-admin/internal/storage"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestParseChecksums(t *testing.T) {
+	content := []byte("第一章 春天来了。")
+	md5Sum := md5.Sum(content)
+	sha256Sum := sha256.Sum256(content)
+	otherMD5 := md5.Sum([]byte("other"))
+	otherSHA256 := sha256.Sum256([]byte("other"))
+
+	md5B64 := base64.StdEncoding.EncodeToString(md5Sum[:])
+	sha256B64 := base64.StdEncoding.EncodeToString(sha256Sum[:])
+	otherMD5B64 := base64.StdEncoding.EncodeToString(otherMD5[:])
+	otherSHA256B64 := base64.StdEncoding.EncodeToString(otherSHA256[:])
+
+	tests := []struct {
+		name       string
+		header     http.Header
+		wantMD5    []byte
+		wantSHA256 []byte
+		wantNil    bool
+		wantErr    string
+	}{
+		{
+			name:    "no checksums",
+			header:  http.Header{},
+			wantNil: true,
+		},
+		{
+			name:    "Content-MD5",
+			header:  http.Header{"Content-Md5": {md5B64}},
+			wantMD5: md5Sum[:],
+		},
+		{
+			name:    "Content-MD5 with spaces",
+			header:  http.Header{"Content-Md5": {" " + md5B64 + " "}},
+			wantMD5: md5Sum[:],
+		},
+		{
+			name:    "Content-MD5 not base64",
+			header:  http.Header{"Content-Md5": {"not base64!"}},
+			wantErr: "Invalid Content-MD5 header",
+		},
+		{
+			name:    "Content-MD5 hex instead of base64",
+			header:  http.Header{"Content-Md5": {"9e107d9d372bb6826bd81d3542a419d6"}},
+			wantErr: "Invalid Content-MD5 header",
+		},
+		{
+			name:       "Digest sha-256",
+			header:     http.Header{"Digest": {"sha-256=" + sha256B64}},
+			wantSHA256: sha256Sum[:],
+		},
+		{
+			name:       "Digest algorithm names are case-insensitive",
+			header:     http.Header{"Digest": {"SHA-256=" + sha256B64 + ",MD5=" + md5B64}},
+			wantMD5:    md5Sum[:],
+			wantSHA256: sha256Sum[:],
+		},
+		{
+			name:       "Digest with several values",
+			header:     http.Header{"Digest": {"unixsum=30637, sha-256=" + sha256B64 + ", md5=" + md5B64}},
+			wantMD5:    md5Sum[:],
+			wantSHA256: sha256Sum[:],
+		},
+		{
+			name:       "Digest split over header fields",
+			header:     http.Header{"Digest": {"md5=" + md5B64, "sha-256=" + sha256B64}},
+			wantMD5:    md5Sum[:],
+			wantSHA256: sha256Sum[:],
+		},
+		{
+			name:    "Digest with unknown algorithms only",
+			header:  http.Header{"Digest": {"sha-512=abc, crc32c=xyz, garbage"}},
+			wantNil: true,
+		},
+		{
+			name:    "Digest sha-256 of the wrong length",
+			header:  http.Header{"Digest": {"sha-256=" + md5B64}},
+			wantErr: "Invalid sha-256 value in Digest header",
+		},
+		{
+			name:    "Digest md5 not base64",
+			header:  http.Header{"Digest": {"md5=%%%"}},
+			wantErr: "Invalid md5 value in Digest header",
+		},
+		{
+			name:       "Content-MD5 and Digest agree",
+			header:     http.Header{"Content-Md5": {md5B64}, "Digest": {"md5=" + md5B64 + ", sha-256=" + sha256B64}},
+			wantMD5:    md5Sum[:],
+			wantSHA256: sha256Sum[:],
+		},
+		{
+			name:    "Content-MD5 and Digest disagree",
+			header:  http.Header{"Content-Md5": {md5B64}, "Digest": {"md5=" + otherMD5B64}},
+			wantErr: "Conflicting md5 checksums in Content-MD5 and Digest headers",
+		},
+		{
+			name:    "Digest md5 values disagree",
+			header:  http.Header{"Digest": {"md5=" + md5B64, "md5=" + otherMD5B64}},
+			wantErr: "Conflicting md5 checksums in Digest header",
+		},
+		{
+			name:    "Digest sha-256 values disagree",
+			header:  http.Header{"Digest": {"sha-256=" + sha256B64 + ", sha-256=" + otherSHA256B64}},
+			wantErr: "Conflicting sha-256 checksums in Digest header",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sums, err := parseChecksums(tt.header)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("parseChecksums() = %+v, %v; want error %q", sums, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseChecksums() error = %v", err)
+			}
+
+			if tt.wantNil {
+				if sums != nil {
+					t.Errorf("parseChecksums() = %+v, want nil", sums)
+				}
+				return
+			}
+			if sums == nil {
+				t.Fatal("parseChecksums() = nil")
+			}
+			if !bytes.Equal(sums.MD5, tt.wantMD5) {
+				t.Errorf("MD5 = %x, want %x", sums.MD5, tt.wantMD5)
+			}
+			if !bytes.Equal(sums.SHA256, tt.wantSHA256) {
+				t.Errorf("SHA256 = %x, want %x", sums.SHA256, tt.wantSHA256)
+			}
+		})
+	}
+}
+
+// testPassword is the password of users created by testServer
+const testPassword = "correct horse"
+
+// testServer serves the API the way main wires it, on a test database with
+// files and the search index in temporary directories
+type testServer struct {
+	router   *gin.Engine
+	db       *gorm.DB
+	reading  *service.ReadingService
+	users    *service.UserService
+	roleRepo *repository.RoleRepository
+	auth     *service.AuthService
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db := repotest.Open(t)
+	userRepo := repository.NewUserRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
+	if err := service.NewRoleService(roleRepo, userRepo).SeedRoles(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := search.NewIndex(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := processor.NewRegistry()
+	registry.Register(processor.NewTextProcessor(), []string{"text/plain"}, []string{".txt"})
+
+	readingRepo := repository.NewReadingRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+	s := &testServer{
+		router: gin.New(),
+		db:     db,
+		reading: service.NewReadingService(readingRepo, userRepo, registry, index, files, "/files", 0,
+			service.RetryPolicy{MaxAttempts: 1}, time.Minute, service.ReadingSpeed{}),
+		users:    service.NewUserService(userRepo, tokenRepo, roleRepo),
+		roleRepo: roleRepo,
+		auth:     service.NewAuthService(userRepo, tokenRepo, []byte("test secret"), time.Hour, time.Hour),
+	}
+
+	apiKeyService := service.NewAPIKeyService(repository.NewAPIKeyRepository(db), userRepo)
+	auth := middleware.AuthMiddleware(s.auth, apiKeyService)
+	NewAuthHandler(s.auth).RegisterRoutes(s.router)
+	NewReadingHandler(s.reading).RegisterRoutes(s.router, auth)
+	NewUserHandler(s.users, true).RegisterRoutes(s.router, auth)
+	NewRoleHandler(service.NewRoleService(roleRepo, userRepo)).RegisterRoutes(s.router, auth)
+	NewAPIKeyHandler(apiKeyService).RegisterRoutes(s.router, auth)
+	return s
+}
+
+// createUser adds a user to the default organization, replacing the reader
+// role with roles if any are given, and returns it with an access token
+func (s *testServer) createUser(t *testing.T, email string, roles ...string) (*entity.User, string) {
+	t.Helper()
+
+	user, err := s.users.CreateUser(entity.DefaultOrgID, email, email, testPassword)
+	if err != nil {
+		t.Fatalf("CreateUser(%s) = %v", email, err)
+	}
+
+	if len(roles) > 0 {
+		found, err := s.roleRepo.GetRolesByNames(roles)
+		if err != nil || len(found) != len(roles) {
+			t.Fatalf("GetRolesByNames(%v) = %v, %v", roles, found, err)
+		}
+		if err := s.roleRepo.SetUserRoles(user, found); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tokens, err := s.auth.Login(email, testPassword)
+	if err != nil {
+		t.Fatalf("Login(%s) = %v", email, err)
+	}
+	return user, tokens.AccessToken
+}
+
+// request sends a request with body, if not nil, encoded as JSON
+func (s *testServer) request(t *testing.T, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return s.send(req, token)
+}
+
+// upload sends a file to the upload endpoint
+func (s *testServer) upload(t *testing.T, token, fileName, content string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(part, content); err != nil {
+		t.Fatal(err)
+	}
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/reading/upload", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return s.send(req, token)
+}
+
+func (s *testServer) send(req *http.Request, token string) *httptest.ResponseRecorder {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeData decodes the data of a successful response into v
+func decodeData(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", rec.Code, rec.Body)
+	}
+	body := struct {
+		Data interface{} `json:"data"`
+	}{Data: v}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+}
+
+func TestUploadFileRejectsUnknownUser(t *testing.T) {
+	s := newTestServer(t)
+	_, adminToken := s.createUser(t, "admin@example.com", entity.RoleAdmin)
+	reader, token := s.createUser(t, "reader@example.com")
+
+	var upload entity.UploadResponse
+	decodeData(t, s.upload(t, token, "book.txt", "Chapter 1"), &upload)
+	if upload.TaskID == 0 || upload.FileName != "book.txt" {
+		t.Errorf("upload = %+v, want a task for book.txt", upload)
+	}
+	if rec := s.upload(t, "", "book.txt", "Chapter 1"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("upload without a token: status %d, want 401", rec.Code)
+	}
+
+	// The token of a deleted user no longer creates tasks
+	if err := s.reading.DeleteTask(context.Background(), reader, upload.TaskID); err != nil {
+		t.Fatal(err)
+	}
+	if rec := s.request(t, http.MethodDelete, fmt.Sprintf("/api/users/%d", reader.ID), adminToken, nil); rec.Code != http.StatusOK {
+		t.Fatalf("deleting the user: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := s.upload(t, token, "book.txt", "Chapter 1"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("upload by a deleted user: status %d, want 401: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestOtherUsersTasksAreNotFound(t *testing.T) {
+	s := newTestServer(t)
+	_, ownerToken := s.createUser(t, "owner@example.com")
+	_, otherToken := s.createUser(t, "other@example.com")
+	_, adminToken := s.createUser(t, "admin@example.com", entity.RoleAdmin)
+
+	var upload entity.UploadResponse
+	decodeData(t, s.upload(t, ownerToken, "book.txt", "Chapter 1"), &upload)
+	taskPath := fmt.Sprintf("/api/reading/task/%d", upload.TaskID)
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"owner", ownerToken, http.StatusOK},
+		{"other user", otherToken, http.StatusNotFound},
+		{"admin", adminToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := s.request(t, http.MethodGet, taskPath, tt.token, nil); rec.Code != tt.want {
+				t.Errorf("GetTask: status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+
+			rec := s.request(t, http.MethodGet, upload.FileURL, tt.token, nil)
+			if rec.Code != tt.want {
+				t.Errorf("DownloadFile: status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want == http.StatusOK && rec.Body.String() != "Chapter 1" {
+				t.Errorf("DownloadFile: body %q, want the file", rec.Body)
+			}
+		})
+	}
+}
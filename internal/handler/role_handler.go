@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"errors"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/middleware"
+	"textile-admin/internal/service"
+	"textile-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleHandler handles HTTP requests for roles
+type RoleHandler struct {
+	service *service.RoleService
+}
+
+// NewRoleHandler creates a new instance of RoleHandler
+func NewRoleHandler(service *service.RoleService) *RoleHandler {
+	return &RoleHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the routes for roles. All of them require
+// PermUsersManage.
+func (h *RoleHandler) RegisterRoutes(router *gin.Engine, auth gin.HandlerFunc) {
+	manage := middleware.RequirePermission(entity.PermUsersManage)
+
+	router.GET("/api/roles", auth, manage, h.ListRoles)
+	router.PUT("/api/users/:user_id/roles", auth, manage, h.SetUserRoles)
+}
+
+// ListRoles handles the retrieval of all roles and their permissions
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.service.ListRoles()
+	if err != nil {
+		response.InternalServerError(c, "Failed to retrieve roles: "+err.Error())
+		return
+	}
+
+	response.Success(c, "查询成功", roles)
+}
+
+// SetUserRoles handles replacing the roles of a user
+func (h *RoleHandler) SetUserRoles(c *gin.Context) {
+	userID, ok := parseIDParam(c, "user_id", "user")
+	if !ok {
+		return
+	}
+
+	var requestBody struct {
+		Roles []string `json:"roles" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		response.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	user, err := h.service.SetUserRoles(middleware.CurrentUser(c), userID, requestBody.Roles)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			response.NotFound(c, "User not found")
+		case errors.Is(err, service.ErrInvalidRole):
+			response.BadRequest(c, err.Error())
+		case errors.Is(err, service.ErrForbidden):
+			response.Forbidden(c, "Only super admins can assign the superadmin role")
+		default:
+			response.InternalServerError(c, "Failed to update user roles: "+err.Error())
+		}
+		return
+	}
+
+	response.Success(c, "角色更新成功", user)
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"textile-admin/internal/domain/entity"
+)
+
+func TestRoutesRequirePermissions(t *testing.T) {
+	s := newTestServer(t)
+	reader, readerToken := s.createUser(t, "reader@example.com")
+	_, workerToken := s.createUser(t, "worker@example.com", entity.RoleWorker)
+	_, adminToken := s.createUser(t, "admin@example.com", entity.RoleAdmin)
+
+	var upload entity.UploadResponse
+	decodeData(t, s.upload(t, readerToken, "book.txt", "Chapter 1"), &upload)
+	statusPath := fmt.Sprintf("/api/reading/task/%d/status", upload.TaskID)
+	rolesPath := fmt.Sprintf("/api/users/%d/roles", reader.ID)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		token  string
+		want   int
+	}{
+		{"reader lists roles", http.MethodGet, "/api/roles", nil, readerToken, http.StatusForbidden},
+		{"worker lists roles", http.MethodGet, "/api/roles", nil, workerToken, http.StatusForbidden},
+		{"admin lists roles", http.MethodGet, "/api/roles", nil, adminToken, http.StatusOK},
+		{"reader lists users", http.MethodGet, "/api/users", nil, readerToken, http.StatusForbidden},
+		{"admin lists users", http.MethodGet, "/api/users", nil, adminToken, http.StatusOK},
+		{"reader grants itself admin", http.MethodPut, rolesPath, map[string][]string{"roles": {entity.RoleAdmin}}, readerToken, http.StatusForbidden},
+		{"reader sets its task's status", http.MethodPut, statusPath, map[string]string{"status": entity.TaskStatusFailed}, readerToken, http.StatusForbidden},
+		{"worker sets a task's status", http.MethodPut, statusPath, map[string]string{"status": entity.TaskStatusProcessing}, workerToken, http.StatusOK},
+		{"admin grants a role", http.MethodPut, rolesPath, map[string][]string{"roles": {entity.RoleWorker}}, adminToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := s.request(t, tt.method, tt.path, tt.token, tt.body); rec.Code != tt.want {
+				t.Errorf("%s %s: status %d, want %d: %s", tt.method, tt.path, rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
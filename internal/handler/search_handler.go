@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"textile-admin/internal/middleware"
+	"textile-admin/internal/service"
+	"textile-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Search result limits
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// SearchHandler handles HTTP requests for full-text search
+type SearchHandler struct {
+	service *service.SearchService
+}
+
+// NewSearchHandler creates a new instance of SearchHandler
+func NewSearchHandler(service *service.SearchService) *SearchHandler {
+	return &SearchHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the routes for full-text search
+func (h *SearchHandler) RegisterRoutes(router *gin.Engine, auth gin.HandlerFunc) {
+	readingGroup := router.Group("/api/reading", auth)
+	{
+		readingGroup.GET("/search", h.Search)
+	}
+}
+
+// Search handles searching the text of a user's tasks
+func (h *SearchHandler) Search(c *gin.Context) {
+	query := c.Query("q")
+	if query == "" {
+		response.BadRequest(c, "Query is required")
+		return
+	}
+
+	userID := middleware.CurrentUser(c).ID
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSearchLimit)))
+	if err != nil || limit <= 0 || limit > maxSearchLimit {
+		response.BadRequest(c, fmt.Sprintf("Invalid limit, must be between 1 and %d", maxSearchLimit))
+		return
+	}
+
+	result, err := h.service.Search(userID, query, limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidQuery) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		response.InternalServerError(c, "Failed to search: "+err.Error())
+		return
+	}
+
+	response.Success(c, "查询成功", result)
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"errors"
+	"textile-admin/internal/middleware"
+	"textile-admin/internal/service"
+	"textile-admin/pkg/response"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultStatsDays is the length of the stats date range when from is omitted
+const defaultStatsDays = 30
+
+// SessionHandler handles HTTP requests for reading sessions and statistics
+type SessionHandler struct {
+	service *service.SessionService
+}
+
+// NewSessionHandler creates a new instance of SessionHandler
+func NewSessionHandler(service *service.SessionService) *SessionHandler {
+	return &SessionHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the routes for reading sessions and statistics
+func (h *SessionHandler) RegisterRoutes(router *gin.Engine, auth gin.HandlerFunc) {
+	readingGroup := router.Group("/api/reading", auth)
+	{
+		readingGroup.POST("/sessions", h.StartSession)
+		readingGroup.POST("/sessions/:session_id/heartbeat", h.Heartbeat)
+		readingGroup.POST("/sessions/:session_id/end", h.EndSession)
+		readingGroup.GET("/stats/user/:user_id", h.GetUserStats)
+	}
+}
+
+// StartSession handles opening a reading session
+func (h *SessionHandler) StartSession(c *gin.Context) {
+	var requestBody struct {
+		TaskID int64 `json:"task_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		response.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	session, err := h.service.StartSession(middleware.CurrentUser(c).ID, requestBody.TaskID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "阅读会话已开始", session)
+}
+
+// Heartbeat handles a client reporting that its reader is still reading
+func (h *SessionHandler) Heartbeat(c *gin.Context) {
+	sessionID, ok := parseIDParam(c, "session_id", "session")
+	if !ok {
+		return
+	}
+
+	session, err := h.service.Heartbeat(middleware.CurrentUser(c).ID, sessionID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "心跳已记录", session)
+}
+
+// EndSession handles closing a reading session
+func (h *SessionHandler) EndSession(c *gin.Context) {
+	sessionID, ok := parseIDParam(c, "session_id", "session")
+	if !ok {
+		return
+	}
+
+	session, err := h.service.EndSession(middleware.CurrentUser(c).ID, sessionID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "阅读会话已结束", session)
+}
+
+// GetUserStats handles the retrieval of a user's reading statistics.
+// from and to are dates (YYYY-MM-DD), both inclusive; to defaults to today
+// and from to 30 days before to. tz names the time zone days are counted in.
+func (h *SessionHandler) GetUserStats(c *gin.Context) {
+	userID, ok := parseIDParam(c, "user_id", "user")
+	if !ok {
+		return
+	}
+
+	var err error
+	loc := time.Local
+	if tz := c.Query("tz"); tz != "" {
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			response.BadRequest(c, "Invalid time zone: "+tz)
+			return
+		}
+	}
+
+	to := time.Now().In(loc)
+	if toStr := c.Query("to"); toStr != "" {
+		to, err = time.ParseInLocation("2006-01-02", toStr, loc)
+		if err != nil {
+			response.BadRequest(c, "Invalid to date, expected YYYY-MM-DD")
+			return
+		}
+	}
+
+	from := to.AddDate(0, 0, -(defaultStatsDays - 1))
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, err = time.ParseInLocation("2006-01-02", fromStr, loc)
+		if err != nil {
+			response.BadRequest(c, "Invalid from date, expected YYYY-MM-DD")
+			return
+		}
+	}
+
+	stats, err := h.service.GetUserStats(middleware.CurrentUser(c), userID, from, to, loc)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "查询成功", stats)
+}
+
+// handleError maps session service errors to responses
+func (h *SessionHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		response.Forbidden(c, "You can only view your own reading statistics")
+	case errors.Is(err, service.ErrTaskNotFound):
+		response.NotFound(c, "Task not found")
+	case errors.Is(err, service.ErrSessionNotFound):
+		response.NotFound(c, "Session not found")
+	case errors.Is(err, service.ErrSessionClosed):
+		response.Conflict(c, "Session closed, start a new one: "+err.Error())
+	case errors.Is(err, service.ErrInvalidDateRange):
+		response.BadRequest(c, err.Error())
+	default:
+		response.InternalServerError(c, "Failed to process reading session request: "+err.Error())
+	}
+}
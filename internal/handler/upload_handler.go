@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/middleware"
+	"textile-admin/internal/service"
+	"textile-admin/pkg/charset"
+	"textile-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tusVersion is the version of the tus resumable upload protocol served
+const tusVersion = "1.0.0"
+
+// tusExtensions are the tus protocol extensions supported
+const tusExtensions = "creation,expiration,termination"
+
+// UploadHandler handles HTTP requests for resumable uploads, following the
+// tus 1.0 protocol (https://tus.io/protocols/resumable-upload)
+type UploadHandler struct {
+	service *service.UploadService
+}
+
+// NewUploadHandler creates a new instance of UploadHandler
+func NewUploadHandler(service *service.UploadService) *UploadHandler {
+	return &UploadHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers the routes for resumable uploads. OPTIONS
+// describes the server's tus support and needs no authentication.
+func (h *UploadHandler) RegisterRoutes(router *gin.Engine, auth gin.HandlerFunc) {
+	router.OPTIONS("/api/reading/uploads", h.tusResumable, h.Options)
+
+	uploadGroup := router.Group("/api/reading/uploads", h.tusResumable, h.requireTusVersion, auth)
+	{
+		uploadGroup.POST("", h.CreateUpload)
+		uploadGroup.HEAD("/:upload_id", h.GetUploadOffset)
+		uploadGroup.PATCH("/:upload_id", h.WriteChunk)
+		uploadGroup.DELETE("/:upload_id", h.TerminateUpload)
+	}
+}
+
+// tusResumable sets the Tus-Resumable header that every tus response carries
+func (h *UploadHandler) tusResumable(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Next()
+}
+
+// requireTusVersion rejects requests for a version of the protocol other
+// than the one served
+func (h *UploadHandler) requireTusVersion(c *gin.Context) {
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		response.Error(c, http.StatusPreconditionFailed, "Unsupported Tus-Resumable version, must be "+tusVersion)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// Options handles the discovery of the server's tus support
+func (h *UploadHandler) Options(c *gin.Context) {
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(h.service.MaxSize(), 10))
+	c.Status(http.StatusNoContent)
+}
+
+// CreateUpload handles the creation of an upload. The file name is taken
+// from the "filename" (or "name") metadata key and the optional source
+// encoding from "encoding".
+func (h *UploadHandler) CreateUpload(c *gin.Context) {
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		response.BadRequest(c, "Upload-Defer-Length is not supported")
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		response.BadRequest(c, "Invalid Upload-Length, must be a non-negative integer")
+		return
+	}
+
+	rawMetadata := c.GetHeader("Upload-Metadata")
+	metadata, err := parseUploadMetadata(rawMetadata)
+	if err != nil {
+		response.BadRequest(c, "Invalid Upload-Metadata: "+err.Error())
+		return
+	}
+
+	fileName := metadata["filename"]
+	if fileName == "" {
+		fileName = metadata["name"]
+	}
+
+	session, err := h.service.CreateUpload(c.Request.Context(), middleware.CurrentUser(c), length, fileName, metadata["encoding"], rawMetadata)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUploadTooLarge):
+			response.Error(c, http.StatusRequestEntityTooLarge, "Upload too large: "+err.Error())
+		case errors.Is(err, service.ErrInvalidUpload):
+			response.BadRequest(c, "Invalid upload: "+err.Error())
+		case errors.Is(err, charset.ErrUnknownEncoding):
+			response.BadRequest(c, "Unsupported encoding: "+metadata["encoding"])
+		default:
+			response.InternalServerError(c, "Failed to create upload: "+err.Error())
+		}
+		return
+	}
+
+	setUploadHeaders(c, session)
+	c.Header("Location", "/api/reading/uploads/"+session.ID)
+	c.Status(http.StatusCreated)
+}
+
+// GetUploadOffset handles the retrieval of an upload's offset, from which
+// an interrupted upload resumes
+func (h *UploadHandler) GetUploadOffset(c *gin.Context) {
+	session, err := h.service.GetUpload(middleware.CurrentUser(c), c.Param("upload_id"))
+	if err != nil {
+		writeUploadError(c, err, "Failed to retrieve upload: ")
+		return
+	}
+
+	setUploadHeaders(c, session)
+	c.Header("Upload-Length", strconv.FormatInt(session.Length, 10))
+	if session.Metadata != "" {
+		c.Header("Upload-Metadata", session.Metadata)
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+}
+
+// WriteChunk handles appending a chunk to an upload
+func (h *UploadHandler) WriteChunk(c *gin.Context) {
+	if c.ContentType() != "application/offset+octet-stream" {
+		response.Error(c, http.StatusUnsupportedMediaType, "Content-Type must be application/offset+octet-stream")
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		response.BadRequest(c, "Invalid Upload-Offset, must be a non-negative integer")
+		return
+	}
+
+	if c.Request.ContentLength < 0 {
+		response.Error(c, http.StatusLengthRequired, "Content-Length is required")
+		return
+	}
+
+	session, err := h.service.WriteChunk(c.Request.Context(), middleware.CurrentUser(c), c.Param("upload_id"), offset, c.Request.Body, c.Request.ContentLength)
+	if err != nil {
+		// A chunk cut short keeps what was received up to the offset
+		if session != nil {
+			setUploadHeaders(c, session)
+		}
+		writeUploadError(c, err, "Failed to write upload: ")
+		return
+	}
+
+	setUploadHeaders(c, session)
+	c.Status(http.StatusNoContent)
+}
+
+// TerminateUpload handles abandoning an upload
+func (h *UploadHandler) TerminateUpload(c *gin.Context) {
+	if err := h.service.TerminateUpload(middleware.CurrentUser(c), c.Param("upload_id")); err != nil {
+		writeUploadError(c, err, "Failed to terminate upload: ")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// setUploadHeaders sets the headers describing an upload's progress. Task-Id
+// names the reading task of a completed upload.
+func setUploadHeaders(c *gin.Context, session *entity.UploadSession) {
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	if session.TaskID != nil {
+		c.Header("Task-Id", strconv.FormatInt(*session.TaskID, 10))
+	}
+}
+
+// writeUploadError sends the response for an error of an existing upload
+func writeUploadError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrUploadNotFound):
+		response.NotFound(c, "Upload not found")
+	case errors.Is(err, service.ErrUploadExpired):
+		response.Error(c, http.StatusGone, "Upload expired")
+	case errors.Is(err, service.ErrUploadOffsetMismatch):
+		response.Conflict(c, "Upload offset mismatch: "+err.Error())
+	case errors.Is(err, service.ErrUploadTooLarge):
+		response.Error(c, http.StatusRequestEntityTooLarge, "Chunk too large: "+err.Error())
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		response.BadRequest(c, "Chunk ended before Content-Length bytes")
+	default:
+		response.InternalServerError(c, message+err.Error())
+	}
+}
+
+// parseUploadMetadata parses an Upload-Metadata header: comma-separated
+// pairs of a key and its base64-encoded value, which may be omitted
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty key")
+		}
+
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, errors.New("value of " + key + " is not base64")
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
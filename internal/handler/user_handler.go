@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/middleware"
+	"textile-admin/internal/service"
+	"textile-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// User list paging limits
+const (
+	defaultUserPageSize = 20
+	maxUserPageSize     = 100
+)
+
+// UserHandler handles HTTP requests for users
+type UserHandler struct {
+	service     *service.UserService
+	allowSignup bool
+}
+
+// NewUserHandler creates a new instance of UserHandler. Unless allowSignup
+// is set, only users with PermUsersManage can create accounts.
+func NewUserHandler(service *service.UserService, allowSignup bool) *UserHandler {
+	return &UserHandler{
+		service:     service,
+		allowSignup: allowSignup,
+	}
+}
+
+// RegisterRoutes registers the routes for users
+// Signing up is open if allowed; everything else requires authentication,
+// and creating users without signup and listing them require
+// PermUsersManage.
+func (h *UserHandler) RegisterRoutes(router *gin.Engine, auth gin.HandlerFunc) {
+	manage := middleware.RequirePermission(entity.PermUsersManage)
+
+	userGroup := router.Group("/api/users")
+	{
+		if h.allowSignup {
+			userGroup.POST("", h.CreateUser)
+		} else {
+			userGroup.POST("", auth, manage, h.CreateUser)
+		}
+		userGroup.GET("", auth, manage, h.ListUsers)
+		userGroup.GET("/:user_id", auth, h.GetUser)
+		userGroup.PUT("/:user_id", auth, h.UpdateUser)
+		userGroup.DELETE("/:user_id", auth, h.DeleteUser)
+	}
+}
+
+// CreateUser handles the creation of a user. Users who sign up join the
+// default organization, and users created by an admin the admin's.
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	var requestBody struct {
+		Username string `json:"username" binding:"required"`
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		response.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	orgID := entity.DefaultOrgID
+	if requester := middleware.CurrentUser(c); requester != nil {
+		orgID = requester.OrgID
+	}
+
+	user, err := h.service.CreateUser(orgID, requestBody.Username, requestBody.Email, requestBody.Password)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "用户创建成功", user)
+}
+
+// ListUsers handles the retrieval of a page of users
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		response.BadRequest(c, "Invalid page, must be a positive integer")
+		return
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultUserPageSize)))
+	if err != nil || pageSize < 1 || pageSize > maxUserPageSize {
+		response.BadRequest(c, fmt.Sprintf("Invalid page size, must be between 1 and %d", maxUserPageSize))
+		return
+	}
+
+	users, err := h.service.ListUsers(middleware.CurrentUser(c), page, pageSize)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "查询成功", users)
+}
+
+// GetUser handles the retrieval of a user by ID
+func (h *UserHandler) GetUser(c *gin.Context) {
+	userID, ok := parseIDParam(c, "user_id", "user")
+	if !ok {
+		return
+	}
+
+	user, err := h.service.GetUser(middleware.CurrentUser(c), userID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "查询成功", user)
+}
+
+// UpdateUser handles changing a user's username, email or password.
+// Fields left out of the request body keep their values.
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	userID, ok := parseIDParam(c, "user_id", "user")
+	if !ok {
+		return
+	}
+
+	var requestBody struct {
+		Username *string `json:"username"`
+		Email    *string `json:"email"`
+		Password *string `json:"password"`
+	}
+
+	if err := c.ShouldBindJSON(&requestBody); err != nil {
+		response.BadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	user, err := h.service.UpdateUser(middleware.CurrentUser(c), userID, requestBody.Username, requestBody.Email, requestBody.Password)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "用户更新成功", user)
+}
+
+// DeleteUser handles deleting a user
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	userID, ok := parseIDParam(c, "user_id", "user")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteUser(middleware.CurrentUser(c), userID); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	response.Success(c, "用户已删除", nil)
+}
+
+// handleError maps user service errors to responses
+func (h *UserHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		response.Forbidden(c, "You cannot access this account")
+	case errors.Is(err, service.ErrUserNotFound):
+		response.NotFound(c, "User not found")
+	case errors.Is(err, service.ErrInvalidUser):
+		response.BadRequest(c, err.Error())
+	case errors.Is(err, service.ErrEmailExists):
+		response.Conflict(c, "Email already exists")
+	case errors.Is(err, service.ErrUserHasTasks):
+		response.Conflict(c, "User cannot be deleted: "+err.Error())
+	default:
+		response.InternalServerError(c, "Failed to process user request: "+err.Error())
+	}
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"textile-admin/internal/domain/entity"
+)
+
+func TestCreateUserRejectsDuplicateEmail(t *testing.T) {
+	s := newTestServer(t)
+
+	signUp := map[string]string{"username": "Reader", "email": "reader@example.com", "password": testPassword}
+	var user entity.User
+	decodeData(t, s.request(t, http.MethodPost, "/api/users", "", signUp), &user)
+	if user.Email != "reader@example.com" {
+		t.Errorf("created user = %+v", user)
+	}
+
+	// Email addresses are compared without regard to case
+	signUp["email"] = "Reader@Example.com"
+	if rec := s.request(t, http.MethodPost, "/api/users", "", signUp); rec.Code != http.StatusConflict {
+		t.Errorf("signing up again: status %d, want 409: %s", rec.Code, rec.Body)
+	}
+
+	other, token := s.createUser(t, "other@example.com")
+	path := fmt.Sprintf("/api/users/%d", other.ID)
+	if rec := s.request(t, http.MethodPut, path, token, map[string]string{"email": "reader@example.com"}); rec.Code != http.StatusConflict {
+		t.Errorf("taking another user's email: status %d, want 409: %s", rec.Code, rec.Body)
+	}
+	if rec := s.request(t, http.MethodPut, path, token, map[string]string{"email": "other@example.org"}); rec.Code != http.StatusOK {
+		t.Errorf("changing the email: status %d, want 200: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/service"
+	"textile-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gin context keys of the authenticated user and, for API key requests,
+// the key
+const (
+	userContextKey   = "auth.user"
+	apiKeyContextKey = "auth.api_key"
+)
+
+// apiKeyRoutes lists the routes each API key scope allows, as the method
+// and the route's path pattern. Routes not listed here, such as those
+// managing users and keys, cannot be called with an API key at all.
+var apiKeyRoutes = map[string][]string{
+	entity.ScopeTasksRead: {
+		"GET /api/reading/task/:task_id",
+		"GET /api/reading/tasks/user/:user_id",
+		"GET /api/reading/task/:task_id/events",
+		"GET /api/reading/task/:task_id/content",
+		"GET /api/reading/task/:task_id/chapters",
+		"GET /api/reading/task/:task_id/chapters/:n",
+		"GET /api/reading/search",
+		"GET /files/:file_name",
+	},
+	entity.ScopeTasksWrite: {
+		"POST /api/reading/upload",
+		"POST /api/reading/task/:task_id/retry",
+		"DELETE /api/reading/task/:task_id",
+		"POST /api/reading/uploads",
+		"HEAD /api/reading/uploads/:upload_id",
+		"PATCH /api/reading/uploads/:upload_id",
+		"DELETE /api/reading/uploads/:upload_id",
+	},
+	entity.ScopeTasksStatusWrite: {
+		"PUT /api/reading/task/:task_id/status",
+	},
+}
+
+// AuthMiddleware requires an "Authorization: Bearer <token>" header, where
+// the token is an access token or an API key, and puts the authenticated
+// user on the context. API keys are further limited to the routes their
+// scopes allow.
+func AuthMiddleware(authService *service.AuthService, apiKeyService *service.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scheme, token, found := strings.Cut(c.GetHeader("Authorization"), " ")
+		token = strings.TrimSpace(token)
+		if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="textile-admin"`)
+			response.Unauthorized(c, "Missing bearer token")
+			c.Abort()
+			return
+		}
+
+		var user *entity.User
+		var key *entity.APIKey
+		var err error
+		if strings.HasPrefix(token, entity.APIKeyPrefix) {
+			user, key, err = apiKeyService.Authenticate(token)
+		} else {
+			user, err = authService.Authenticate(token)
+		}
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidToken) {
+				c.Header("WWW-Authenticate", `Bearer realm="textile-admin", error="invalid_token"`)
+				response.Unauthorized(c, "Invalid or expired token")
+			} else {
+				response.InternalServerError(c, "Failed to authenticate: "+err.Error())
+			}
+			c.Abort()
+			return
+		}
+
+		if key != nil && !apiKeyAllows(key, c.Request.Method, c.FullPath()) {
+			c.Header("WWW-Authenticate", `Bearer realm="textile-admin", error="insufficient_scope"`)
+			response.Forbidden(c, "API key scopes do not allow "+c.Request.Method+" "+c.FullPath())
+			c.Abort()
+			return
+		}
+
+		c.Set(userContextKey, user)
+		if key != nil {
+			c.Set(apiKeyContextKey, key)
+		}
+		c.Next()
+	}
+}
+
+// apiKeyAllows reports whether one of key's scopes allows the route
+func apiKeyAllows(key *entity.APIKey, method, path string) bool {
+	route := method + " " + path
+	for _, scope := range key.Scopes {
+		for _, allowed := range apiKeyRoutes[scope] {
+			if allowed == route {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CurrentUser returns the user authenticated by AuthMiddleware, or nil on
+// routes without it
+func CurrentUser(c *gin.Context) *entity.User {
+	if value, ok := c.Get(userContextKey); ok {
+		if user, ok := value.(*entity.User); ok {
+			return user
+		}
+	}
+	return nil
+}
+
+// CurrentAPIKey returns the API key the request was authenticated with, or
+// nil when it used an access token
+func CurrentAPIKey(c *gin.Context) *entity.APIKey {
+	if value, ok := c.Get(apiKeyContextKey); ok {
+		if key, ok := value.(*entity.APIKey); ok {
+			return key
+		}
+	}
+	return nil
+}
+
+// RequirePermission allows only users whose roles grant permission. It
+// must run after AuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := CurrentUser(c)
+		if user == nil || !user.HasPermission(permission) {
+			response.Forbidden(c, "Permission required: "+permission)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
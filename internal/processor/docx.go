@@ -0,0 +1,96 @@
+package processor
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DOCXProcessor handles Word (Office Open XML) documents
+type DOCXProcessor struct{}
+
+// NewDOCXProcessor creates a new instance of DOCXProcessor
+func NewDOCXProcessor() *DOCXProcessor {
+	return &DOCXProcessor{}
+}
+
+// Name returns the processor name
+func (p *DOCXProcessor) Name() string {
+	return "docx"
+}
+
+// Process extracts the text of the main document part
+func (p *DOCXProcessor) Process(ctx context.Context, doc *Document) (*Result, error) {
+	zr, err := zip.OpenReader(doc.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not a zip archive: %v", ErrInvalidDocument, err)
+	}
+	defer zr.Close()
+
+	data, err := readZipEntry(&zr.Reader, "word/document.xml", maxEntrySize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+
+	text, err := extractDOCXText(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: word/document.xml: %v", ErrInvalidDocument, err)
+	}
+
+	return &Result{Text: text}, nil
+}
+
+// extractDOCXText walks WordprocessingML and returns the text of its runs,
+// with one line per paragraph. Deleted revisions and field codes are skipped
+// because they are stored in other elements than w:t.
+func extractDOCXText(r io.Reader) (string, error) {
+	decoder := xml.NewDecoder(r)
+
+	var b strings.Builder
+	inText := false
+	inTabStops := false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return normalizeText(b.String()), nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tabs":
+				// Tab stop definitions in paragraph properties, not tab characters
+				inTabStops = true
+			case "tab":
+				if !inTabStops {
+					b.WriteString("\t")
+				}
+			case "br", "cr":
+				b.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "tabs":
+				inTabStops = false
+			case "p":
+				b.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+}
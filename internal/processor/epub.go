@@ -0,0 +1,391 @@
+package processor
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// maxEntrySize limits how much of a single archive entry is read, as a guard
+// against zip bombs
+const maxEntrySize = 64 << 20
+
+// epubContainer is META-INF/container.xml
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// epubPackage is the OPF package document
+type epubPackage struct {
+	Manifest []epubManifestItem `xml:"manifest>item"`
+	Spine    struct {
+		Toc   string `xml:"toc,attr"`
+		Items []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+// epubManifestItem is a single resource listed in the OPF manifest
+type epubManifestItem struct {
+	ID         string `xml:"id,attr"`
+	Href       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+}
+
+// epubNavPoint is an entry of an EPUB 2 NCX table of contents
+type epubNavPoint struct {
+	Label   string `xml:"navLabel>text"`
+	Content struct {
+		Src string `xml:"src,attr"`
+	} `xml:"content"`
+	Children []epubNavPoint `xml:"navPoint"`
+}
+
+// epubSection is the text of one spine document
+type epubSection struct {
+	Path string
+	Text string
+}
+
+// epubTOCEntry is a table of contents entry resolved to an archive path
+type epubTOCEntry struct {
+	Title string
+	Path  string
+}
+
+// EPUBProcessor handles EPUB e-books
+type EPUBProcessor struct{}
+
+// NewEPUBProcessor creates a new instance of EPUBProcessor
+func NewEPUBProcessor() *EPUBProcessor {
+	return &EPUBProcessor{}
+}
+
+// Name returns the processor name
+func (p *EPUBProcessor) Name() string {
+	return "epub"
+}
+
+// Process extracts the text of the book's spine documents in reading order.
+// Chapters follow the book's table of contents: a spine document listed in
+// it starts a chapter, and documents not listed continue the previous one.
+func (p *EPUBProcessor) Process(ctx context.Context, doc *Document) (*Result, error) {
+	zr, err := zip.OpenReader(doc.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not a zip archive: %v", ErrInvalidDocument, err)
+	}
+	defer zr.Close()
+
+	sections, toc, err := readEPUBSections(ctx, &zr.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	titles := make(map[string]string, len(toc))
+	for _, entry := range toc {
+		if _, ok := titles[entry.Path]; !ok {
+			titles[entry.Path] = entry.Title
+		}
+	}
+
+	var b strings.Builder
+	var chapters []Chapter
+	fromTOC := false
+	offset := 0
+
+	for _, section := range sections {
+		if section.Text == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+			offset += 2
+		}
+
+		title, listed := titles[section.Path]
+		if listed || len(chapters) == 0 {
+			if !listed {
+				title = firstLineTitle(section.Text)
+			}
+			fromTOC = fromTOC || listed
+			chapters = append(chapters, Chapter{Title: truncateTitle(title), Start: offset})
+		}
+
+		b.WriteString(section.Text)
+		offset += utf8.RuneCountInString(section.Text)
+		chapters[len(chapters)-1].End = offset
+	}
+
+	// Without a usable table of contents, leave segmentation to the caller
+	if !fromTOC {
+		chapters = nil
+	}
+
+	return &Result{Text: b.String(), Chapters: chapters}, nil
+}
+
+// readEPUBSections validates the container and returns the text of every
+// XHTML document in the spine, along with the table of contents
+func readEPUBSections(ctx context.Context, zr *zip.Reader) ([]epubSection, []epubTOCEntry, error) {
+	mimeType, err := readZipEntry(zr, "mimetype", 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	if strings.TrimSpace(string(mimeType)) != "application/epub+zip" {
+		return nil, nil, fmt.Errorf("%w: unexpected EPUB mimetype %q", ErrInvalidDocument, mimeType)
+	}
+
+	opfPath, pkg, err := readEPUBPackage(zr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manifest := make(map[string]epubManifestItem, len(pkg.Manifest))
+	for _, item := range pkg.Manifest {
+		manifest[item.ID] = item
+	}
+
+	var sections []epubSection
+	for _, itemRef := range pkg.Spine.Items {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+
+		item, ok := manifest[itemRef.IDRef]
+		if !ok || !isXHTMLMediaType(item.MediaType) {
+			continue
+		}
+
+		entryPath := resolveEPUBHref(opfPath, item.Href)
+		data, err := readZipEntry(zr, entryPath, maxEntrySize)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+		}
+
+		text, err := extractHTMLText(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s: %v", ErrInvalidDocument, entryPath, err)
+		}
+
+		sections = append(sections, epubSection{Path: entryPath, Text: text})
+	}
+
+	return sections, readEPUBTOC(zr, opfPath, pkg, manifest), nil
+}
+
+// readEPUBTOC returns the entries of the EPUB 3 navigation document or, for
+// EPUB 2 books, the NCX. A missing or unreadable table of contents yields no
+// entries rather than an error.
+func readEPUBTOC(zr *zip.Reader, opfPath string, pkg *epubPackage, manifest map[string]epubManifestItem) []epubTOCEntry {
+	for _, item := range pkg.Manifest {
+		for _, property := range strings.Fields(item.Properties) {
+			if property != "nav" {
+				continue
+			}
+			navPath := resolveEPUBHref(opfPath, item.Href)
+			data, err := readZipEntry(zr, navPath, maxEntrySize)
+			if err != nil {
+				return nil
+			}
+			if entries := parseEPUBNav(data, navPath); len(entries) > 0 {
+				return entries
+			}
+		}
+	}
+
+	ncx, ok := manifest[pkg.Spine.Toc]
+	if !ok {
+		return nil
+	}
+
+	ncxPath := resolveEPUBHref(opfPath, ncx.Href)
+	data, err := readZipEntry(zr, ncxPath, maxEntrySize)
+	if err != nil {
+		return nil
+	}
+
+	var doc struct {
+		NavPoints []epubNavPoint `xml:"navMap>navPoint"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+
+	var entries []epubTOCEntry
+	var walk func(points []epubNavPoint)
+	walk = func(points []epubNavPoint) {
+		for _, point := range points {
+			if point.Content.Src != "" {
+				entries = append(entries, epubTOCEntry{
+					Title: strings.Join(strings.Fields(point.Label), " "),
+					Path:  resolveEPUBHref(ncxPath, point.Content.Src),
+				})
+			}
+			walk(point.Children)
+		}
+	}
+	walk(doc.NavPoints)
+
+	return entries
+}
+
+// parseEPUBNav collects the links of the toc nav element of an EPUB 3
+// navigation document, in document order
+func parseEPUBNav(data []byte, navPath string) []epubTOCEntry {
+	z := html.NewTokenizer(bytes.NewReader(data))
+
+	var entries []epubTOCEntry
+	navDepth := 0 // nesting depth inside the toc nav, 0 when outside
+	var link *epubTOCEntry
+	var label strings.Builder
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return entries
+
+		case html.StartTagToken:
+			token := z.Token()
+			switch {
+			case token.Data == "nav" && navDepth == 0 && isTOCNav(token):
+				navDepth = 1
+			case token.Data == "nav" && navDepth > 0:
+				navDepth++
+			case token.Data == "a" && navDepth > 0:
+				for _, attr := range token.Attr {
+					if attr.Key == "href" {
+						link = &epubTOCEntry{Path: resolveEPUBHref(navPath, attr.Val)}
+						label.Reset()
+					}
+				}
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "nav":
+				if navDepth > 0 {
+					navDepth--
+					if navDepth == 0 {
+						return entries
+					}
+				}
+			case "a":
+				if link != nil {
+					link.Title = strings.Join(strings.Fields(label.String()), " ")
+					entries = append(entries, *link)
+					link = nil
+				}
+			}
+
+		case html.TextToken:
+			if link != nil {
+				label.Write(z.Text())
+			}
+		}
+	}
+}
+
+// isTOCNav reports whether a nav element is the table of contents
+func isTOCNav(token html.Token) bool {
+	for _, attr := range token.Attr {
+		if (attr.Key == "epub:type" || attr.Key == "type" || attr.Key == "role") &&
+			strings.Contains(attr.Val, "toc") {
+			return true
+		}
+	}
+	return false
+}
+
+// readEPUBPackage locates and parses the OPF package document
+func readEPUBPackage(zr *zip.Reader) (string, *epubPackage, error) {
+	data, err := readZipEntry(zr, "META-INF/container.xml", maxEntrySize)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+
+	var container epubContainer
+	if err := xml.Unmarshal(data, &container); err != nil {
+		return "", nil, fmt.Errorf("%w: container.xml: %v", ErrInvalidDocument, err)
+	}
+	if len(container.Rootfiles) == 0 || container.Rootfiles[0].FullPath == "" {
+		return "", nil, fmt.Errorf("%w: container.xml names no package document", ErrInvalidDocument)
+	}
+
+	opfPath := container.Rootfiles[0].FullPath
+	data, err = readZipEntry(zr, opfPath, maxEntrySize)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+
+	var pkg epubPackage
+	if err := xml.Unmarshal(data, &pkg); err != nil {
+		return "", nil, fmt.Errorf("%w: %s: %v", ErrInvalidDocument, opfPath, err)
+	}
+
+	return opfPath, &pkg, nil
+}
+
+// resolveEPUBHref resolves a manifest href against the package document path
+func resolveEPUBHref(opfPath, href string) string {
+	if i := strings.IndexByte(href, '#'); i >= 0 {
+		href = href[:i]
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return path.Join(path.Dir(opfPath), href)
+}
+
+// isXHTMLMediaType reports whether a manifest media type holds readable markup
+func isXHTMLMediaType(mediaType string) bool {
+	return mediaType == "application/xhtml+xml" || mediaType == "text/html"
+}
+
+// findZipEntry returns the archive entry with the given name, or nil
+func findZipEntry(zr *zip.Reader, name string) *zip.File {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// readZipEntry reads an archive entry, refusing entries larger than limit bytes
+func readZipEntry(zr *zip.Reader, name string, limit int64) ([]byte, error) {
+	f := findZipEntry(zr, name)
+	if f == nil {
+		return nil, fmt.Errorf("%s is missing", name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s exceeds %d bytes", name, limit)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,197 @@
+package processor
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// htmlSkippedElements are elements whose content is never readable text
+var htmlSkippedElements = map[string]bool{
+	"head":     true,
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+	"svg":      true,
+}
+
+// htmlBlockElements start a new line in the extracted text
+var htmlBlockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"br": true, "dd": true, "div": true, "dl": true, "dt": true,
+	"figcaption": true, "figure": true, "footer": true, "h1": true,
+	"h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "li": true, "main": true, "nav": true,
+	"ol": true, "p": true, "pre": true, "section": true, "table": true,
+	"tr": true, "ul": true,
+}
+
+// HTMLProcessor handles HTML and XHTML files
+type HTMLProcessor struct{}
+
+// NewHTMLProcessor creates a new instance of HTMLProcessor
+func NewHTMLProcessor() *HTMLProcessor {
+	return &HTMLProcessor{}
+}
+
+// Name returns the processor name
+func (p *HTMLProcessor) Name() string {
+	return "html"
+}
+
+// Process extracts the visible text of the document
+func (p *HTMLProcessor) Process(ctx context.Context, doc *Document) (*Result, error) {
+	if err := checkTextFile(doc.Path); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(doc.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := extractHTMLText(strings.NewReader(decodeUTF8(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{Text: text}, nil
+}
+
+// extractHTMLText returns the readable text of an HTML or XHTML document.
+// Block elements become line breaks and whitespace is collapsed outside <pre>.
+func extractHTMLText(r io.Reader) (string, error) {
+	z := html.NewTokenizer(r)
+	w := &textWriter{}
+
+	skipDepth := 0
+	preDepth := 0
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return normalizeText(w.String()), nil
+			}
+			return "", z.Err()
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			tag := strings.ToLower(string(name))
+			if tt == html.StartTagToken {
+				if htmlSkippedElements[tag] {
+					skipDepth++
+				}
+				if tag == "pre" {
+					preDepth++
+				}
+			}
+			if htmlBlockElements[tag] && skipDepth == 0 {
+				w.Newline()
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := strings.ToLower(string(name))
+			if htmlSkippedElements[tag] && skipDepth > 0 {
+				skipDepth--
+			}
+			if tag == "pre" && preDepth > 0 {
+				preDepth--
+			}
+			if htmlBlockElements[tag] && skipDepth == 0 {
+				w.Newline()
+			}
+
+		case html.TextToken:
+			if skipDepth > 0 {
+				continue
+			}
+			if preDepth > 0 {
+				w.WriteRaw(string(z.Text()))
+			} else {
+				w.WriteCollapsed(string(z.Text()))
+			}
+		}
+	}
+}
+
+// textWriter accumulates extracted text, collapsing insignificant whitespace
+type textWriter struct {
+	b         strings.Builder
+	lastRune  rune
+	lineStart bool
+	space     bool // a collapsed whitespace run is pending
+	spaceNL   bool // the pending run contained a line break
+}
+
+// Newline ends the current line, unless it is empty
+func (w *textWriter) Newline() {
+	if w.lineStart || w.b.Len() == 0 {
+		w.space, w.spaceNL = false, false
+		return
+	}
+	w.b.WriteString("\n")
+	w.lastRune = '\n'
+	w.lineStart = true
+	w.space, w.spaceNL = false, false
+}
+
+// WriteRaw writes text verbatim, as inside <pre>
+func (w *textWriter) WriteRaw(text string) {
+	w.flushSpace(0)
+	w.b.WriteString(text)
+	if r, _ := utf8.DecodeLastRuneInString(text); r != utf8.RuneError {
+		w.lastRune = r
+		w.lineStart = r == '\n'
+	}
+}
+
+// WriteCollapsed writes text with whitespace runs collapsed to one space.
+// Line breaks between two CJK characters are dropped, as browsers do.
+func (w *textWriter) WriteCollapsed(text string) {
+	for _, r := range text {
+		if unicode.IsSpace(r) && r != '\u00a0' && r != '\u3000' {
+			w.space = true
+			if r == '\n' {
+				w.spaceNL = true
+			}
+			continue
+		}
+		w.flushSpace(r)
+		w.b.WriteRune(r)
+		w.lastRune = r
+		w.lineStart = false
+	}
+}
+
+// flushSpace emits a pending whitespace run before next, if it is significant
+func (w *textWriter) flushSpace(next rune) {
+	if !w.space {
+		return
+	}
+	dropped := w.lineStart || w.b.Len() == 0 ||
+		(w.spaceNL && isCJK(w.lastRune) && isCJK(next))
+	if !dropped {
+		w.b.WriteByte(' ')
+	}
+	w.space, w.spaceNL = false, false
+}
+
+// String returns the text written so far
+func (w *textWriter) String() string {
+	return w.b.String()
+}
+
+// isCJK reports whether r is a Han, Kana or Hangul character or CJK punctuation
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x3000 && r <= 0x303F) || (r >= 0xFF00 && r <= 0xFFEF)
+}
@@ -0,0 +1,61 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+var (
+	// pdfPageObject matches page objects but not the /Pages tree nodes
+	pdfPageObject = regexp.MustCompile(`/Type\s*/Page[^s]`)
+	// pdfPageCount matches the page counts of /Pages tree nodes
+	pdfPageCount = regexp.MustCompile(`/Count\s+(\d+)`)
+)
+
+// PDFProcessor handles PDF documents
+type PDFProcessor struct{}
+
+// NewPDFProcessor creates a new instance of PDFProcessor
+func NewPDFProcessor() *PDFProcessor {
+	return &PDFProcessor{}
+}
+
+// Name returns the processor name
+func (p *PDFProcessor) Name() string {
+	return "pdf"
+}
+
+// Process checks the PDF header and counts the pages. Page objects stored in
+// compressed object streams are not visible to this scan, in which case the
+// page count is 0.
+func (p *PDFProcessor) Process(ctx context.Context, doc *Document) (*Result, error) {
+	data, err := os.ReadFile(doc.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, fmt.Errorf("%w: missing PDF header", ErrInvalidDocument)
+	}
+
+	return &Result{PageCount: countPDFPages(data)}, nil
+}
+
+// countPDFPages counts page objects, falling back to the largest page tree count
+func countPDFPages(data []byte) int {
+	if n := len(pdfPageObject.FindAllIndex(data, -1)); n > 0 {
+		return n
+	}
+
+	pages := 0
+	for _, m := range pdfPageCount.FindAllSubmatch(data, -1) {
+		if n, err := strconv.Atoi(string(m[1])); err == nil && n > pages {
+			pages = n
+		}
+	}
+	return pages
+}
@@ -0,0 +1,50 @@
+package processor
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrUnsupportedFormat is returned when no processor handles a file type
+	ErrUnsupportedFormat = errors.New("unsupported file format")
+
+	// ErrInvalidDocument is returned when a file does not match its format
+	ErrInvalidDocument = errors.New("invalid document")
+)
+
+// Document describes a stored file handed to a processor
+type Document struct {
+	// Path is the location of the file on local disk
+	Path string
+	// FileName is the name the file was uploaded with
+	FileName string
+	// MIMEType is the sniffed content type of the file
+	MIMEType string
+}
+
+// Result holds what a processor learned about a document
+type Result struct {
+	// Text is the readable plain text of the document, normalized to "\n"
+	// line endings. It is empty for formats without text extraction.
+	Text string
+	// PageCount is the number of pages, or 0 if the format has no pages
+	PageCount int
+	// Chapters are the document's own chapter boundaries, such as an EPUB
+	// table of contents. When empty, chapters are detected from Text.
+	Chapters []Chapter
+}
+
+// Processor handles one family of document formats
+type Processor interface {
+	// Name identifies the processor in logs and task events
+	Name() string
+	// Process reads the document and returns the processing result
+	Process(ctx context.Context, doc *Document) (*Result, error)
+}
+
+// IsPermanent reports whether a processing error will recur on every attempt,
+// so that retrying the task is pointless
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrUnsupportedFormat) || errors.Is(err, ErrInvalidDocument)
+}
@@ -0,0 +1,92 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// genericMIMETypes are container or fallback types that say little about
+// the actual format, so the file extension decides between processors
+var genericMIMETypes = map[string]bool{
+	"text/plain":               true,
+	"application/zip":          true,
+	"application/octet-stream": true,
+}
+
+// Registry selects a processor for a file by its sniffed MIME type and extension
+type Registry struct {
+	byMIME      map[string]Processor
+	byExtension map[string]Processor
+}
+
+// NewRegistry creates a new, empty instance of Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		byMIME:      make(map[string]Processor),
+		byExtension: make(map[string]Processor),
+	}
+}
+
+// Register makes p the processor for the given MIME types and file
+// extensions. Extensions include the leading dot. Later registrations
+// replace earlier ones for the same key.
+func (r *Registry) Register(p Processor, mimeTypes []string, extensions []string) {
+	for _, mimeType := range mimeTypes {
+		r.byMIME[strings.ToLower(mimeType)] = p
+	}
+	for _, ext := range extensions {
+		r.byExtension[strings.ToLower(ext)] = p
+	}
+}
+
+// Lookup sniffs the file at path and returns the processor for it together
+// with the detected MIME type. fileName is the name the file was uploaded
+// with and supplies the extension. A specific sniffed type wins over the
+// extension; for generic types such as text/plain or application/zip the
+// extension is used to pick the processor.
+func (r *Registry) Lookup(path, fileName string) (Processor, string, error) {
+	detected, err := mimetype.DetectFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	mimeType := baseMIMEType(detected.String())
+
+	// Most specific sniffed type first, then its parents
+	var generic Processor
+	for m := detected; m != nil; m = m.Parent() {
+		candidate := baseMIMEType(m.String())
+		p, ok := r.byMIME[candidate]
+		if !ok {
+			continue
+		}
+		if !genericMIMETypes[candidate] {
+			return p, mimeType, nil
+		}
+		if generic == nil {
+			generic = p
+		}
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if p, ok := r.byExtension[ext]; ok {
+		return p, mimeType, nil
+	}
+
+	if generic != nil {
+		return generic, mimeType, nil
+	}
+
+	return nil, mimeType, fmt.Errorf("%w: %s (%s)", ErrUnsupportedFormat, mimeType, ext)
+}
+
+// baseMIMEType strips parameters such as charset from a MIME type
+func baseMIMEType(mimeType string) string {
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(mimeType))
+}
@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxHeadingLength is the longest line, in characters, taken as a heading
+const maxHeadingLength = 50
+
+// maxTitleLength is the longest chapter title kept, in characters
+const maxTitleLength = 100
+
+// chapterHeadings match lines that start a chapter
+var chapterHeadings = []*regexp.Regexp{
+	// 第一章 / 第12回 / 第三卷, optionally followed by a title
+	regexp.MustCompile(`^第[0-9０-９零〇一二三四五六七八九十百千万两]+[章回节卷部篇集](?:[\s:：　].*)?$`),
+	// Chapter 1 / CHAPTER XII / Chapter One, optionally followed by a title
+	regexp.MustCompile(`^(?i:chapter)\s+(?:[0-9]+|(?i:[ivxlcdm]+|one|two|three|four|five|six|seven|eight|nine|ten|eleven|twelve|thirteen|fourteen|fifteen|sixteen|seventeen|eighteen|nineteen|twenty)[a-z-]*)\b.*$`),
+	// Front and back matter common in Chinese novels
+	regexp.MustCompile(`^(?:序章|序言|序|楔子|引子|前言|尾声|后记|後記|番外)(?:[\s:：　].*)?$`),
+}
+
+// Chapter is a section of a document's text. Start and End are character
+// offsets into the extracted text; End is exclusive.
+type Chapter struct {
+	Title string
+	Start int
+	End   int
+}
+
+// SegmentChapters splits text into chapters at heading lines such as
+// "第一章", "Chapter 3" or "楔子". Text before the first heading becomes a
+// chapter of its own. Headings directly followed by another heading, as in a
+// table of contents, are not treated as chapter starts. Text without any
+// headings is returned as a single chapter.
+func SegmentChapters(text string) []Chapter {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+
+	type heading struct {
+		title   string
+		start   int  // character offset of the heading line
+		hasBody bool // a non-blank line follows before the next heading
+	}
+
+	var headings []heading
+	offset := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimFunc(line, unicode.IsSpace)
+		switch {
+		case trimmed == "":
+		case utf8.RuneCountInString(trimmed) <= maxHeadingLength && isChapterHeading(trimmed):
+			headings = append(headings, heading{title: trimmed, start: offset})
+		case len(headings) > 0:
+			headings[len(headings)-1].hasBody = true
+		}
+		offset += utf8.RuneCountInString(line)
+	}
+	total := offset
+
+	// Drop headings without a body of their own, such as table of contents
+	// lines; their text is absorbed by the preceding chapter
+	var kept []heading
+	for _, h := range headings {
+		if h.hasBody {
+			kept = append(kept, h)
+		}
+	}
+
+	var chapters []Chapter
+	if len(kept) == 0 || strings.TrimSpace(prefixChars(text, kept[0].start)) != "" {
+		end := total
+		if len(kept) > 0 {
+			end = kept[0].start
+		}
+		chapters = append(chapters, Chapter{Title: firstLineTitle(text), Start: 0, End: end})
+	}
+
+	for i, h := range kept {
+		end := total
+		if i+1 < len(kept) {
+			end = kept[i+1].start
+		}
+		chapters = append(chapters, Chapter{Title: truncateTitle(h.title), Start: h.start, End: end})
+	}
+
+	// Leading blank lines belong to the first chapter
+	chapters[0].Start = 0
+
+	return chapters
+}
+
+// prefixChars returns the first n characters of text
+func prefixChars(text string, n int) string {
+	i := 0
+	for pos := range text {
+		if i == n {
+			return text[:pos]
+		}
+		i++
+	}
+	return text
+}
+
+// isChapterHeading reports whether a trimmed line looks like a chapter heading
+func isChapterHeading(line string) bool {
+	for _, re := range chapterHeadings {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstLineTitle returns the first non-empty line of text as a title
+func firstLineTitle(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return truncateTitle(line)
+		}
+	}
+	return ""
+}
+
+// truncateTitle shortens a title to maxTitleLength characters
+func truncateTitle(title string) string {
+	if utf8.RuneCountInString(title) <= maxTitleLength {
+		return title
+	}
+	runes := []rune(title)
+	return string(runes[:maxTitleLength]) + "…"
+}
@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestIsChapterHeading(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"第一章", true},
+		{"第十二章 风起", true},
+		{"第12回：夜宴", true},
+		{"第１２章", true},
+		{"第三卷　江湖", true},
+		{"第一百零八章", true},
+		{"第二节", true},
+		{"第一章风起", false},
+		{"第章", false},
+		{"他第一次见到她", false},
+		{"Chapter 1", true},
+		{"CHAPTER XII", true},
+		{"chapter one", true},
+		{"Chapter Twenty-One: The End", true},
+		{"Chapter 3 The Storm", true},
+		{"Chapters 1", false},
+		{"Chapter", false},
+		{"Chapterhouse", false},
+		{"In chapter 1 we saw", false},
+		{"楔子", true},
+		{"序章 开端", true},
+		{"后记", true},
+		{"番外：旧事", true},
+		{"序幕拉开了", false},
+	}
+
+	for _, tt := range tests {
+		if got := isChapterHeading(tt.line); got != tt.want {
+			t.Errorf("isChapterHeading(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSegmentChapters(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Chapter
+	}{
+		{
+			name: "empty",
+			text: " \n\n",
+			want: nil,
+		},
+		{
+			name: "no headings",
+			text: "\n一段没有标题的文字。\n第二段。\n",
+			want: []Chapter{{Title: "一段没有标题的文字。", Start: 0, End: 17}},
+		},
+		{
+			name: "chinese headings",
+			text: "第一章 开始\n春天来了。\n第二章 结束\n秋天到了。\n",
+			want: []Chapter{
+				{Title: "第一章 开始", Start: 0, End: 13},
+				{Title: "第二章 结束", Start: 13, End: 26},
+			},
+		},
+		{
+			name: "preface before first heading",
+			text: "书名\n作者：某人\n\n楔子\n很久以前。\n第1回\n故事开始。",
+			want: []Chapter{
+				{Title: "书名", Start: 0, End: 10},
+				{Title: "楔子", Start: 10, End: 19},
+				{Title: "第1回", Start: 19, End: 28},
+			},
+		},
+		{
+			name: "leading blank lines",
+			text: "\n\n  Chapter 1  \nIt began.\nChapter Two: Later\nIt ended.",
+			want: []Chapter{
+				{Title: "Chapter 1", Start: 0, End: 26},
+				{Title: "Chapter Two: Later", Start: 26, End: 54},
+			},
+		},
+		{
+			name: "table of contents",
+			text: "目录\n第一章\n第二章\n\n第一章\n正文一。\n第二章\n正文二。",
+			want: []Chapter{
+				{Title: "目录", Start: 0, End: 12},
+				{Title: "第一章", Start: 12, End: 21},
+				{Title: "第二章", Start: 21, End: 29},
+			},
+		},
+		{
+			name: "crlf line endings",
+			text: "Chapter 1\r\nOne.\r\nChapter 2\r\nTwo.",
+			want: []Chapter{
+				{Title: "Chapter 1", Start: 0, End: 17},
+				{Title: "Chapter 2", Start: 17, End: 32},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SegmentChapters(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("SegmentChapters() = %+v, want %+v", got, tt.want)
+			}
+			if len(got) > 0 && got[len(got)-1].End != utf8.RuneCountInString(tt.text) {
+				t.Errorf("last chapter ends at %d, text has %d characters", got[len(got)-1].End, utf8.RuneCountInString(tt.text))
+			}
+		})
+	}
+}
+
+func TestSegmentChaptersTitleLength(t *testing.T) {
+	long := strings.Repeat("长", maxTitleLength+10)
+	chapters := SegmentChapters(long + "\n正文")
+	if len(chapters) != 1 {
+		t.Fatalf("got %d chapters, want 1", len(chapters))
+	}
+	if want := strings.Repeat("长", maxTitleLength) + "…"; chapters[0].Title != want {
+		t.Errorf("title = %q, want %q", chapters[0].Title, want)
+	}
+
+	// Lines longer than a heading can be are body text
+	heading := "第一章 " + strings.Repeat("长", maxHeadingLength)
+	if chapters := SegmentChapters("前言\n" + heading + "\n正文"); len(chapters) != 1 {
+		t.Errorf("overlong heading line started a chapter: %+v", chapters)
+	}
+}
@@ -0,0 +1,49 @@
+package processor
+
+import "unicode"
+
+// Stats are word and character counts of extracted text
+type Stats struct {
+	// Words counts runs of letters and digits outside CJK scripts
+	Words int
+	// CJKChars counts Chinese, Japanese and Korean characters, which are
+	// read one by one rather than as words. Punctuation is not counted.
+	CJKChars int
+}
+
+// CountText computes the statistics of text. Apostrophes and hyphens between
+// letters do not split a word, so "don't" and "well-known" count once.
+func CountText(text string) Stats {
+	var stats Stats
+
+	inWord := false
+	joiner := false // previous rune was an apostrophe or hyphen inside a word
+
+	for _, r := range text {
+		switch {
+		case isCJKLetter(r):
+			stats.CJKChars++
+			inWord, joiner = false, false
+
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			if !inWord {
+				stats.Words++
+				inWord = true
+			}
+			joiner = false
+
+		case inWord && !joiner && (r == '\'' || r == '’' || r == '-'):
+			joiner = true
+
+		default:
+			inWord, joiner = false, false
+		}
+	}
+
+	return stats
+}
+
+// isCJKLetter reports whether r is a CJK ideograph, kana or hangul syllable
+func isCJKLetter(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// sniffLen is how much of a text file is inspected for binary content
+const sniffLen = 8192
+
+// TextProcessor handles plain text and Markdown files
+type TextProcessor struct{}
+
+// NewTextProcessor creates a new instance of TextProcessor
+func NewTextProcessor() *TextProcessor {
+	return &TextProcessor{}
+}
+
+// Name returns the processor name
+func (p *TextProcessor) Name() string {
+	return "text"
+}
+
+// Process reads the document as UTF-8 text
+func (p *TextProcessor) Process(ctx context.Context, doc *Document) (*Result, error) {
+	if err := checkTextFile(doc.Path); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(doc.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{Text: normalizeText(decodeUTF8(data))}, nil
+}
+
+// checkTextFile rejects empty files and files that contain NUL bytes
+func checkTextFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+
+	if n == 0 {
+		return fmt.Errorf("%w: file is empty", ErrInvalidDocument)
+	}
+
+	// UTF-16 text legitimately contains NUL bytes
+	if bytes.HasPrefix(head, []byte{0xFF, 0xFE}) || bytes.HasPrefix(head, []byte{0xFE, 0xFF}) {
+		return nil
+	}
+
+	if bytes.IndexByte(head[:n], 0) >= 0 {
+		return fmt.Errorf("%w: file contains binary data", ErrInvalidDocument)
+	}
+
+	return nil
+}
+
+// decodeUTF8 strips a UTF-8 byte order mark and replaces invalid sequences
+func decodeUTF8(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	return strings.ToValidUTF8(string(data), "�")
+}
+
+// normalizeText unifies line endings, trims trailing whitespace on each line
+// and collapses runs of blank lines, so that character offsets into the
+// extracted text are stable
+func normalizeText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+
+	lines := strings.Split(s, "\n")
+	var b strings.Builder
+	b.Grow(len(s))
+
+	blank := 0
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t 　")
+		if line == "" {
+			blank++
+			continue
+		}
+		if b.Len() > 0 {
+			if blank > 0 {
+				b.WriteString("\n\n")
+			} else {
+				b.WriteString("\n")
+			}
+		}
+		blank = 0
+		b.WriteString(line)
+	}
+
+	return b.String()
+}
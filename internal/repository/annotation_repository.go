@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"log"
+	"textile-admin/internal/domain/entity"
+
+	"gorm.io/gorm"
+)
+
+// AnnotationRepository handles database operations for bookmarks and annotations
+type AnnotationRepository struct {
+	db *gorm.DB
+}
+
+// NewAnnotationRepository creates a new instance of AnnotationRepository
+func NewAnnotationRepository(db *gorm.DB) *AnnotationRepository {
+	return &AnnotationRepository{db: db}
+}
+
+// CreateBookmark creates a new bookmark in the database
+func (r *AnnotationRepository) CreateBookmark(bookmark *entity.Bookmark) error {
+	result := r.db.Create(bookmark)
+	if result.Error != nil {
+		log.Printf("Error creating bookmark: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// GetBookmarkByID retrieves a bookmark by its ID
+func (r *AnnotationRepository) GetBookmarkByID(bookmarkID int64) (*entity.Bookmark, error) {
+	var bookmark entity.Bookmark
+
+	result := r.db.First(&bookmark, bookmarkID)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // Bookmark not found
+		}
+		log.Printf("Error querying bookmark: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &bookmark, nil
+}
+
+// GetBookmarks retrieves a user's bookmarks, in reading order. A taskID of
+// 0 returns the bookmarks of all tasks.
+func (r *AnnotationRepository) GetBookmarks(userID, taskID int64) ([]*entity.Bookmark, error) {
+	var bookmarks []*entity.Bookmark
+
+	query := r.db.Where("user_id = ?", userID)
+	if taskID != 0 {
+		query = query.Where("task_id = ?", taskID)
+	}
+
+	result := query.Order("task_id ASC, start_offset ASC, id ASC").Find(&bookmarks)
+	if result.Error != nil {
+		log.Printf("Error querying bookmarks: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return bookmarks, nil
+}
+
+// UpdateBookmark saves the anchor and title of a bookmark
+func (r *AnnotationRepository) UpdateBookmark(bookmark *entity.Bookmark) error {
+	result := r.db.Model(bookmark).Select("chapter", "start_offset", "end_offset", "title", "updated_at").Updates(bookmark)
+	if result.Error != nil {
+		log.Printf("Error updating bookmark: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// DeleteBookmark deletes a bookmark
+func (r *AnnotationRepository) DeleteBookmark(bookmarkID int64) error {
+	result := r.db.Delete(&entity.Bookmark{}, bookmarkID)
+	if result.Error != nil {
+		log.Printf("Error deleting bookmark: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// CreateAnnotation creates a new annotation in the database
+func (r *AnnotationRepository) CreateAnnotation(annotation *entity.Annotation) error {
+	result := r.db.Create(annotation)
+	if result.Error != nil {
+		log.Printf("Error creating annotation: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// GetAnnotationByID retrieves an annotation by its ID
+func (r *AnnotationRepository) GetAnnotationByID(annotationID int64) (*entity.Annotation, error) {
+	var annotation entity.Annotation
+
+	result := r.db.First(&annotation, annotationID)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // Annotation not found
+		}
+		log.Printf("Error querying annotation: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &annotation, nil
+}
+
+// GetAnnotations retrieves a user's annotations, in reading order. A taskID
+// of 0 returns the annotations of all tasks.
+func (r *AnnotationRepository) GetAnnotations(userID, taskID int64) ([]*entity.Annotation, error) {
+	var annotations []*entity.Annotation
+
+	query := r.db.Where("user_id = ?", userID)
+	if taskID != 0 {
+		query = query.Where("task_id = ?", taskID)
+	}
+
+	result := query.Order("task_id ASC, start_offset ASC, id ASC").Find(&annotations)
+	if result.Error != nil {
+		log.Printf("Error querying annotations: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return annotations, nil
+}
+
+// UpdateAnnotation saves the anchor, quote, note and color of an annotation
+func (r *AnnotationRepository) UpdateAnnotation(annotation *entity.Annotation) error {
+	result := r.db.Model(annotation).
+		Select("chapter", "start_offset", "end_offset", "quote", "note", "color", "updated_at").
+		Updates(annotation)
+	if result.Error != nil {
+		log.Printf("Error updating annotation: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// DeleteAnnotation deletes an annotation
+func (r *AnnotationRepository) DeleteAnnotation(annotationID int64) error {
+	result := r.db.Delete(&entity.Annotation{}, annotationID)
+	if result.Error != nil {
+		log.Printf("Error deleting annotation: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
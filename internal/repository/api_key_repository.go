@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"log"
+	"textile-admin/internal/domain/entity"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository handles database operations for API keys
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a new instance of APIKeyRepository
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// CreateAPIKey records a new API key
+func (r *APIKeyRepository) CreateAPIKey(key *entity.APIKey) error {
+	result := r.db.Create(key)
+	if result.Error != nil {
+		log.Printf("Error creating API key: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// GetAPIKeyByID retrieves an API key by its ID
+func (r *APIKeyRepository) GetAPIKeyByID(keyID int64) (*entity.APIKey, error) {
+	var key entity.APIKey
+
+	result := r.db.First(&key, keyID)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // Key not found
+		}
+		log.Printf("Error querying API key: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &key, nil
+}
+
+// GetAPIKeyByHash retrieves an API key by the hash of the key
+func (r *APIKeyRepository) GetAPIKeyByHash(keyHash string) (*entity.APIKey, error) {
+	var key entity.APIKey
+
+	result := r.db.Where("key_hash = ?", keyHash).First(&key)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // Key not found
+		}
+		log.Printf("Error querying API key by hash: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &key, nil
+}
+
+// GetAPIKeysByUserID retrieves all API keys of a user, newest first,
+// including revoked and expired ones
+func (r *APIKeyRepository) GetAPIKeysByUserID(userID int64) ([]*entity.APIKey, error) {
+	var keys []*entity.APIKey
+
+	result := r.db.Where("user_id = ?", userID).Order("id DESC").Find(&keys)
+	if result.Error != nil {
+		log.Printf("Error querying API keys of user %d: %v", userID, result.Error)
+		return nil, result.Error
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey marks an API key as revoked. It reports false when the key
+// was already revoked.
+func (r *APIKeyRepository) RevokeAPIKey(keyID int64, at time.Time) (bool, error) {
+	result := r.db.Model(&entity.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", keyID).
+		Update("revoked_at", at)
+	if result.Error != nil {
+		log.Printf("Error revoking API key: %v", result.Error)
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// TouchAPIKey records that an API key was used at the given time
+func (r *APIKeyRepository) TouchAPIKey(keyID int64, at time.Time) error {
+	result := r.db.Model(&entity.APIKey{}).
+		Where("id = ?", keyID).
+		Update("last_used_at", at)
+	if result.Error != nil {
+		log.Printf("Error recording use of API key: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"errors"
+	"log"
+	"textile-admin/internal/domain/entity"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrDuplicateOrgName is returned when another organization already has the name
+var ErrDuplicateOrgName = errors.New("organization name already in use")
+
+// OrganizationRepository handles database operations for organizations
+type OrganizationRepository struct {
+	db *gorm.DB
+}
+
+// NewOrganizationRepository creates a new instance of OrganizationRepository
+func NewOrganizationRepository(db *gorm.DB) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+// EnsureOrganization creates an organization with a fixed ID unless one
+// with that ID exists already
+func (r *OrganizationRepository) EnsureOrganization(org *entity.Organization) error {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(org)
+	if result.Error != nil {
+		log.Printf("Error saving organization %d: %v", org.ID, result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// CreateOrganization creates a new organization in the database
+func (r *OrganizationRepository) CreateOrganization(org *entity.Organization) error {
+	result := r.db.Create(org)
+	if result.Error != nil {
+		if isDuplicateKey(result.Error) {
+			return ErrDuplicateOrgName
+		}
+		log.Printf("Error creating organization: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// GetOrganizationByID retrieves an organization by its ID
+func (r *OrganizationRepository) GetOrganizationByID(orgID int64) (*entity.Organization, error) {
+	var org entity.Organization
+
+	result := r.db.First(&org, orgID)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // Organization not found
+		}
+		log.Printf("Error querying organization: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &org, nil
+}
+
+// ListOrganizations retrieves all organizations ordered by ID
+func (r *OrganizationRepository) ListOrganizations() ([]*entity.Organization, error) {
+	var orgs []*entity.Organization
+
+	result := r.db.Order("id ASC").Find(&orgs)
+	if result.Error != nil {
+		log.Printf("Error querying organizations: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return orgs, nil
+}
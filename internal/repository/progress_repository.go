@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"errors"
+	"log"
+	"textile-admin/internal/domain/entity"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrStaleProgress is returned when a progress update is older than the stored progress
+var ErrStaleProgress = errors.New("reading progress is older than the stored progress")
+
+// ProgressRepository handles database operations for reading progress
+type ProgressRepository struct {
+	db *gorm.DB
+}
+
+// NewProgressRepository creates a new instance of ProgressRepository
+func NewProgressRepository(db *gorm.DB) *ProgressRepository {
+	return &ProgressRepository{db: db}
+}
+
+// GetProgress retrieves a user's progress on a task
+func (r *ProgressRepository) GetProgress(userID, taskID int64) (*entity.ReadingProgress, error) {
+	var progress entity.ReadingProgress
+
+	result := r.db.Where("user_id = ? AND task_id = ?", userID, taskID).First(&progress)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // No progress recorded
+		}
+		log.Printf("Error querying reading progress: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &progress, nil
+}
+
+// SaveProgress stores a user's progress on a task unless the stored progress
+// was read later. On ErrStaleProgress, progress is overwritten with the
+// stored record.
+func (r *ProgressRepository) SaveProgress(progress *entity.ReadingProgress) error {
+	err := r.saveProgress(progress)
+	if isDuplicateKey(err) {
+		// Another device recorded the first progress concurrently; the row
+		// exists now, so the second try compares against it
+		err = r.saveProgress(progress)
+	}
+	if err != nil && !errors.Is(err, ErrStaleProgress) {
+		log.Printf("Error saving reading progress: %v", err)
+	}
+
+	return err
+}
+
+// saveProgress performs SaveProgress in a single transaction
+func (r *ProgressRepository) saveProgress(progress *entity.ReadingProgress) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing entity.ReadingProgress
+
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND task_id = ?", progress.UserID, progress.TaskID).
+			Take(&existing)
+		if result.Error == gorm.ErrRecordNotFound {
+			return tx.Create(progress).Error
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if progress.LastReadAt.Before(existing.LastReadAt) {
+			*progress = existing
+			return ErrStaleProgress
+		}
+
+		progress.ID = existing.ID
+		return tx.Model(&existing).Updates(map[string]interface{}{
+			"chapter":      progress.Chapter,
+			"char_offset":  progress.Offset,
+			"percentage":   progress.Percentage,
+			"last_read_at": progress.LastReadAt,
+			"updated_at":   gorm.Expr("CURRENT_TIMESTAMP"),
+		}).Error
+	})
+}
+
+// CountFinishedTasks counts the tasks a user finished reading in [from, to),
+// judged by progress that reached 100 percent
+func (r *ProgressRepository) CountFinishedTasks(userID int64, from, to time.Time) (int, error) {
+	var count int64
+
+	result := r.db.Model(&entity.ReadingProgress{}).
+		Where("user_id = ? AND percentage >= 100 AND last_read_at >= ? AND last_read_at < ?", userID, from, to).
+		Count(&count)
+	if result.Error != nil {
+		log.Printf("Error counting finished tasks: %v", result.Error)
+		return 0, result.Error
+	}
+
+	return int(count), nil
+}
@@ -1,32 +1,61 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"textile-admin/internal/domain/entity"
+	"time"
+	"unicode/utf8"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// ReadingRepository handles database operations for reading tasks
+var (
+	// ErrLeaseLost is returned when a worker no longer holds the lease on a task
+	ErrLeaseLost = errors.New("task lease lost")
+
+	// ErrStatusChanged is returned when a task's status changed concurrently
+	ErrStatusChanged = errors.New("task status changed concurrently")
+)
+
+// ReadingRepository handles database operations for reading tasks. Every
+// query is limited to one tenant: the repository returned by
+// NewReadingRepository fails all queries with ErrNoTenant, and ForOrg,
+// ForOwner or System give one that can be used.
 type ReadingRepository struct {
 	db *gorm.DB
 }
 
 // NewReadingRepository creates a new instance of ReadingRepository
 func NewReadingRepository(db *gorm.DB) *ReadingRepository {
-	return &ReadingRepository{db: db}
+	registerTenantCallbacks(db)
+	return &ReadingRepository{db: withTenantScope(db, tenantScope{})}
 }
 
-// CreateTask creates a new reading task in the database
-func (r *ReadingRepository) CreateTask(userID int64, fileName, filePath string) (int64, error) {
-	task := entity.ReadingTask{
-		UserID:   userID,
-		FileName: fileName,
-		FilePath: filePath,
-		Status:   "pending",
-	}
+// ForOrg returns the repository limited to the tasks of an organization.
+// New tasks are created in it.
+func (r *ReadingRepository) ForOrg(orgID int64) *ReadingRepository {
+	return &ReadingRepository{db: withTenantScope(r.db, tenantScope{orgID: orgID})}
+}
+
+// ForOwner returns the repository limited to the tasks of one user
+func (r *ReadingRepository) ForOwner(userID int64) *ReadingRepository {
+	return &ReadingRepository{db: withTenantScope(r.db, tenantScope{ownerID: userID})}
+}
 
-	result := r.db.Create(&task)
+// System returns the repository unlimited, for background processing that
+// serves every organization
+func (r *ReadingRepository) System() *ReadingRepository {
+	return &ReadingRepository{db: withTenantScope(r.db, tenantScope{system: true})}
+}
+
+// CreateTask creates a new pending reading task in the database
+func (r *ReadingRepository) CreateTask(task *entity.ReadingTask) (int64, error) {
+	task.Status = entity.TaskStatusPending
+
+	result := r.db.Create(task)
 	if result.Error != nil {
 		log.Printf("Error creating reading task: %v", result.Error)
 		return 0, result.Error
@@ -51,6 +80,200 @@ func (r *ReadingRepository) GetTaskByID(taskID int64) (*entity.ReadingTask, erro
 	return &task, nil
 }
 
+// GetTaskByFileKey retrieves the task whose original file or UTF-8 copy is
+// stored under key
+func (r *ReadingRepository) GetTaskByFileKey(key string) (*entity.ReadingTask, error) {
+	var task entity.ReadingTask
+
+	result := r.db.Where("file_path = ? OR normalized_path = ?", key, key).First(&task)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // No task found
+		}
+		log.Printf("Error querying task by file key: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &task, nil
+}
+
+// GetProcessedTaskByHash retrieves a completed task other than excludeID
+// whose original file and UTF-8 copy have the given hashes, so that its
+// extraction can be reused. It returns nil when there is none.
+func (r *ReadingRepository) GetProcessedTaskByHash(sum, normalizedSum string, excludeID int64) (*entity.ReadingTask, error) {
+	var task entity.ReadingTask
+
+	result := r.db.Where("sha256 = ? AND normalized_sha256 = ? AND status = ? AND id <> ?",
+		sum, normalizedSum, entity.TaskStatusCompleted, excludeID).Order("id ASC").First(&task)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // No task found
+		}
+		log.Printf("Error querying task by hash: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &task, nil
+}
+
+// HasTaskWithHash reports whether there is a task whose original file has
+// the given hash
+func (r *ReadingRepository) HasTaskWithHash(sum string) (bool, error) {
+	var count int64
+
+	result := r.db.Model(&entity.ReadingTask{}).Where("sha256 = ?", sum).Limit(1).Count(&count)
+	if result.Error != nil {
+		log.Printf("Error querying tasks by hash: %v", result.Error)
+		return false, result.Error
+	}
+
+	return count > 0, nil
+}
+
+// DeleteTask deletes a task that is not being processed, together with its
+// status history, extracted text, chapters and reading data. It returns
+// ErrStatusChanged if the task is being processed or no longer exists.
+func (r *ReadingRepository) DeleteTask(taskID int64) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Rows belonging to the task go first; the tenant scope finds them
+		// through the task
+		children := []interface{}{
+			&entity.ReadingTaskEvent{}, &entity.ReadingContent{}, &entity.ReadingChapter{},
+			&entity.ReadingProgress{}, &entity.Bookmark{}, &entity.Annotation{}, &entity.ReadingSession{},
+		}
+		for _, model := range children {
+			if err := tx.Where("task_id = ?", taskID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Where("id = ? AND status <> ?", taskID, entity.TaskStatusProcessing).Delete(&entity.ReadingTask{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStatusChanged
+		}
+
+		return nil
+	})
+	if err != nil && err != ErrStatusChanged {
+		log.Printf("Error deleting task: %v", err)
+	}
+
+	return err
+}
+
+// AcquireBlob adds a reference to the blob with the given hash, creating
+// its record on first use
+func (r *ReadingRepository) AcquireBlob(sum string, size int64) error {
+	blob := entity.Blob{SHA256: sum, Size: size, RefCount: 1}
+
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "org_id"}, {Name: "sha256"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"ref_count": gorm.Expr("ref_count + 1")}),
+	}).Create(&blob)
+	if result.Error != nil {
+		log.Printf("Error acquiring blob %s: %v", sum, result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// ReleaseBlob removes a reference to the blob with the given hash. When it
+// was the last one the record is deleted and, once that has committed,
+// onLast is called to delete the stored file. The file is deleted holding a
+// lock on the blob's row, so a concurrent upload of the same content waits
+// and then stores the file anew. A file left behind by an error from onLast
+// is reused by the next upload of its content.
+func (r *ReadingRepository) ReleaseBlob(sum string, onLast func() error) error {
+	last := false
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var blob entity.Blob
+
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("sha256 = ?", sum).Limit(1).Find(&blob)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil // Already released
+		}
+
+		if blob.RefCount > 1 {
+			return tx.Model(&entity.Blob{}).Where("id = ?", blob.ID).Update("ref_count", gorm.Expr("ref_count - 1")).Error
+		}
+
+		last = true
+		return tx.Where("id = ?", blob.ID).Delete(&entity.Blob{}).Error
+	})
+	if err == nil && last {
+		err = r.db.Transaction(func(tx *gorm.DB) error {
+			var blob entity.Blob
+
+			result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("sha256 = ?", sum).Limit(1).Find(&blob)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				return nil // Acquired again, keeping the file
+			}
+
+			return onLast()
+		})
+	}
+	if err != nil {
+		log.Printf("Error releasing blob %s: %v", sum, err)
+	}
+
+	return err
+}
+
+// ListBlobs retrieves up to limit blobs with IDs above afterID, in ID
+// order, for paging through all of them
+func (r *ReadingRepository) ListBlobs(afterID int64, limit int) ([]*entity.Blob, error) {
+	var blobs []*entity.Blob
+
+	result := r.db.Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&blobs)
+	if result.Error != nil {
+		log.Printf("Error listing blobs: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return blobs, nil
+}
+
+// CountBlobReferences counts the tasks of an organization whose original
+// file or UTF-8 copy is the blob with the given hash
+func (r *ReadingRepository) CountBlobReferences(orgID int64, sum string) (int64, error) {
+	var count int64
+
+	result := r.db.Model(&entity.ReadingTask{}).
+		Where("org_id = ? AND (sha256 = ? OR normalized_sha256 = ?)", orgID, sum, sum).
+		Count(&count)
+	if result.Error != nil {
+		log.Printf("Error counting blob references: %v", result.Error)
+		return 0, result.Error
+	}
+
+	return count, nil
+}
+
+// ListUnhashedTasks retrieves up to limit tasks with IDs above afterID
+// whose files were stored before they were hashed, in ID order
+func (r *ReadingRepository) ListUnhashedTasks(afterID int64, limit int) ([]*entity.ReadingTask, error) {
+	var tasks []*entity.ReadingTask
+
+	result := r.db.Where("id > ? AND sha256 = ?", afterID, "").Order("id ASC").Limit(limit).Find(&tasks)
+	if result.Error != nil {
+		log.Printf("Error listing unhashed tasks: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return tasks, nil
+}
+
 // GetTasksByUserID retrieves all reading tasks for a given user
 func (r *ReadingRepository) GetTasksByUserID(userID int64) ([]*entity.ReadingTask, error) {
 	var tasks []*entity.ReadingTask
@@ -64,17 +287,357 @@ func (r *ReadingRepository) GetTasksByUserID(userID int64) ([]*entity.ReadingTas
 	return tasks, nil
 }
 
-// UpdateTaskStatus updates the status of a reading task
-func (r *ReadingRepository) UpdateTaskStatus(taskID int64, status string) error {
-	result := r.db.Model(&entity.ReadingTask{}).Where("id = ?", taskID).Update("status", status)
+// UpdateTaskDocumentInfo stores the detected file type and document statistics of a task
+func (r *ReadingRepository) UpdateTaskDocumentInfo(taskID int64, info *entity.DocumentInfo) error {
+	result := r.db.Model(&entity.ReadingTask{}).Where("id = ?", taskID).Updates(map[string]interface{}{
+		"mime_type":       info.MIMEType,
+		"page_count":      info.PageCount,
+		"chapter_count":   info.ChapterCount,
+		"word_count":      info.WordCount,
+		"cjk_char_count":  info.CJKCharCount,
+		"reading_minutes": info.ReadingMinutes,
+	})
 	if result.Error != nil {
-		log.Printf("Error updating task status: %v", result.Error)
+		log.Printf("Error updating task document info: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// SaveTaskContent stores the extracted text of a task, replacing any earlier extraction
+func (r *ReadingRepository) SaveTaskContent(taskID int64, content string) error {
+	record := entity.ReadingContent{
+		TaskID:    taskID,
+		Content:   content,
+		CharCount: utf8.RuneCountInString(content),
+	}
+
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "task_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content", "char_count", "updated_at"}),
+	}).Create(&record)
+	if result.Error != nil {
+		log.Printf("Error saving task content: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// GetTaskContent retrieves the full extracted text of a task
+func (r *ReadingRepository) GetTaskContent(taskID int64) (*entity.ReadingContent, error) {
+	var content entity.ReadingContent
+
+	result := r.db.Where("task_id = ?", taskID).Take(&content)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // No content extracted yet
+		}
+		log.Printf("Error querying task content: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &content, nil
+}
+
+// GetTaskContentPage retrieves up to length characters of a task's extracted
+// text starting at the zero-based character offset. The substring is taken
+// by the database, so the full text is never loaded.
+func (r *ReadingRepository) GetTaskContentPage(taskID int64, offset, length int) (*entity.ReadingContent, error) {
+	var content entity.ReadingContent
+
+	result := r.db.Model(&entity.ReadingContent{}).
+		Select("task_id, char_count, created_at, updated_at, SUBSTRING(content, ?, ?) AS content", offset+1, length).
+		Where("task_id = ?", taskID).
+		Take(&content)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // No content extracted yet
+		}
+		log.Printf("Error querying task content: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &content, nil
+}
+
+// SaveTaskChapters replaces the chapters of a task
+func (r *ReadingRepository) SaveTaskChapters(taskID int64, chapters []*entity.ReadingChapter) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id = ?", taskID).Delete(&entity.ReadingChapter{}).Error; err != nil {
+			return err
+		}
+		if len(chapters) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(chapters, 500).Error
+	})
+	if err != nil {
+		log.Printf("Error saving task chapters: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetTaskChapters retrieves the chapters of a task in reading order
+func (r *ReadingRepository) GetTaskChapters(taskID int64) ([]*entity.ReadingChapter, error) {
+	var chapters []*entity.ReadingChapter
+
+	result := r.db.Where("task_id = ?", taskID).Order("ordinal ASC").Find(&chapters)
+	if result.Error != nil {
+		log.Printf("Error querying task chapters: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return chapters, nil
+}
+
+// GetTaskChapter retrieves a single chapter of a task by its 1-based ordinal
+func (r *ReadingRepository) GetTaskChapter(taskID int64, ordinal int) (*entity.ReadingChapter, error) {
+	var chapter entity.ReadingChapter
+
+	result := r.db.Where("task_id = ? AND ordinal = ?", taskID, ordinal).First(&chapter)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // Chapter not found
+		}
+		log.Printf("Error querying task chapter: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &chapter, nil
+}
+
+// TransitionTaskStatus moves a task from one status to another and records the
+// transition. It returns ErrStatusChanged if the task is no longer in status from.
+func (r *ReadingRepository) TransitionTaskStatus(taskID int64, from, to, actor, reason string) error {
+	return r.transition(taskID, from, to, actor, reason, nil)
+}
+
+// StartTask moves a task from status from to processing on behalf of actor,
+// leasing it to actor until now+leaseDuration and counting an attempt as a
+// claim does, so that workers take the task over once the lease expires.
+// It returns ErrStatusChanged if the task is no longer in status from.
+func (r *ReadingRepository) StartTask(taskID int64, from, actor, reason string, leaseDuration time.Duration) error {
+	return r.transition(taskID, from, entity.TaskStatusProcessing, actor, reason, map[string]interface{}{
+		"lease_owner":      actor,
+		"lease_expires_at": time.Now().Add(leaseDuration),
+		"attempts":         gorm.Expr("attempts + 1"),
+	})
+}
+
+// RequeueFailedTask sends a failed task back to pending with a fresh attempt
+// budget. It returns ErrStatusChanged if the task is no longer failed.
+func (r *ReadingRepository) RequeueFailedTask(taskID int64, actor, reason string) error {
+	return r.transition(taskID, entity.TaskStatusFailed, entity.TaskStatusPending, actor, reason, map[string]interface{}{
+		"attempts":        0,
+		"next_attempt_at": nil,
+	})
+}
+
+// transition applies a conditional status change plus any extra column
+// updates, and records the transition in the same database transaction
+func (r *ReadingRepository) transition(taskID int64, from, to, actor, reason string, extra map[string]interface{}) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"status": to}
+		if to != entity.TaskStatusProcessing {
+			// Any worker still holding the task loses it
+			updates["lease_owner"] = ""
+			updates["lease_expires_at"] = nil
+		}
+		for column, value := range extra {
+			updates[column] = value
+		}
+
+		result := tx.Model(&entity.ReadingTask{}).Where("id = ? AND status = ?", taskID, from).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStatusChanged
+		}
+
+		return createTaskEvent(tx, taskID, actor, from, to, reason)
+	})
+	if err != nil && err != ErrStatusChanged {
+		log.Printf("Error updating task status: %v", err)
+	}
+
+	return err
+}
+
+// GetTaskEvents retrieves the status transitions of a task, oldest first
+func (r *ReadingRepository) GetTaskEvents(taskID int64) ([]*entity.ReadingTaskEvent, error) {
+	var events []*entity.ReadingTaskEvent
+
+	result := r.db.Where("task_id = ?", taskID).Order("id ASC").Find(&events)
+	if result.Error != nil {
+		log.Printf("Error querying task events: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return events, nil
+}
+
+// ClaimTask atomically takes the next pending task that is due, or a
+// processing task whose lease has expired, leases it to owner until
+// now+leaseDuration and counts a new attempt. Rows locked by a concurrent
+// claim are skipped, so two instances never receive the same task.
+// Expired tasks that have made maxAttempts attempts are failed instead of
+// claimed, since their file keeps killing or hanging the workers.
+// It returns nil when there is nothing to claim.
+func (r *ReadingRepository) ClaimTask(owner string, leaseDuration time.Duration, maxAttempts int) (*entity.ReadingTask, error) {
+	var claimed *entity.ReadingTask
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var task entity.ReadingTask
+		now := time.Now()
+
+		for {
+			task = entity.ReadingTask{}
+			result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: clause.LockingOptionsSkipLocked}).
+				Where("(status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)) OR (status = ? AND lease_expires_at < ?)",
+					entity.TaskStatusPending, now, entity.TaskStatusProcessing, now).
+				Order("id ASC").Limit(1).Find(&task)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return nil // Nothing to claim
+			}
+
+			if task.Status != entity.TaskStatusProcessing || task.Attempts < maxAttempts {
+				break
+			}
+
+			if err := failExpiredTask(tx, &task); err != nil {
+				return err
+			}
+		}
+
+		reason := ""
+		if task.Status == entity.TaskStatusProcessing {
+			reason = "lease held by " + task.LeaseOwner + " expired"
+		}
+
+		expiresAt := now.Add(leaseDuration)
+		result := tx.Model(&entity.ReadingTask{}).Where("id = ?", task.ID).Updates(map[string]interface{}{
+			"status":           entity.TaskStatusProcessing,
+			"lease_owner":      owner,
+			"lease_expires_at": expiresAt,
+			"attempts":         gorm.Expr("attempts + 1"),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if err := createTaskEvent(tx, task.ID, owner, task.Status, entity.TaskStatusProcessing, reason); err != nil {
+			return err
+		}
+
+		task.Status = entity.TaskStatusProcessing
+		task.LeaseOwner = owner
+		task.LeaseExpiresAt = &expiresAt
+		task.Attempts++
+		claimed = &task
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error claiming task: %v", err)
+		return nil, err
+	}
+
+	return claimed, nil
+}
+
+// failExpiredTask fails a processing task whose lease expired on its last
+// attempt, releasing the lease
+func failExpiredTask(tx *gorm.DB, task *entity.ReadingTask) error {
+	message := fmt.Sprintf("lease held by %s expired on attempt %d", task.LeaseOwner, task.Attempts)
+	result := tx.Model(&entity.ReadingTask{}).Where("id = ?", task.ID).Updates(map[string]interface{}{
+		"status":           entity.TaskStatusFailed,
+		"lease_owner":      "",
+		"lease_expires_at": nil,
+		"error_message":    message,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return createTaskEvent(tx, task.ID, task.LeaseOwner, entity.TaskStatusProcessing, entity.TaskStatusFailed, message+", giving up")
+}
+
+// RenewLease extends the lease owner holds on a processing task.
+// It returns ErrLeaseLost if the lease has been taken over by another owner.
+func (r *ReadingRepository) RenewLease(taskID int64, owner string, leaseDuration time.Duration) error {
+	result := r.db.Model(&entity.ReadingTask{}).
+		Where("id = ? AND status = ? AND lease_owner = ?", taskID, entity.TaskStatusProcessing, owner).
+		Update("lease_expires_at", time.Now().Add(leaseDuration))
+	if result.Error != nil {
+		log.Printf("Error renewing task lease: %v", result.Error)
 		return result.Error
 	}
 
 	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+		return ErrLeaseLost
 	}
 
 	return nil
-} 
\ No newline at end of file
+}
+
+// CompleteLeasedTask moves a task leased to owner out of processing, records
+// the transition and releases the lease. The error message and next attempt
+// time are stored on the task; pass an empty message and nil time on success.
+// It returns ErrLeaseLost if owner no longer holds the lease.
+func (r *ReadingRepository) CompleteLeasedTask(taskID int64, owner, status, errorMessage string, nextAttemptAt *time.Time, reason string) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.ReadingTask{}).
+			Where("id = ? AND status = ? AND lease_owner = ?", taskID, entity.TaskStatusProcessing, owner).
+			Updates(map[string]interface{}{
+				"status":           status,
+				"lease_owner":      "",
+				"lease_expires_at": nil,
+				"error_message":    errorMessage,
+				"next_attempt_at":  nextAttemptAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrLeaseLost
+		}
+
+		return createTaskEvent(tx, taskID, owner, entity.TaskStatusProcessing, status, reason)
+	})
+	if err != nil && err != ErrLeaseLost {
+		log.Printf("Error completing leased task: %v", err)
+	}
+
+	return err
+}
+
+// createTaskEvent records a status transition within the given transaction
+func createTaskEvent(tx *gorm.DB, taskID int64, actor, from, to, reason string) error {
+	event := entity.ReadingTaskEvent{
+		TaskID:     taskID,
+		Actor:      actor,
+		FromStatus: from,
+		ToStatus:   to,
+		Reason:     truncate(reason, 512),
+	}
+
+	return tx.Create(&event).Error
+}
+
+// truncate shortens s to at most max bytes without splitting a UTF-8 sequence
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
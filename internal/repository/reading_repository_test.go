@@ -0,0 +1,384 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository/repotest"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// newTestReadingRepository returns a reading repository on a test database
+// together with the database and a user of the default organization
+func newTestReadingRepository(t *testing.T) (*ReadingRepository, *gorm.DB, *entity.User) {
+	t.Helper()
+
+	db := repotest.Open(t)
+	user := createTestUser(t, db, entity.DefaultOrgID, "reader@example.com")
+	return NewReadingRepository(db), db, user
+}
+
+// createTestUser adds a user to an organization
+func createTestUser(t *testing.T, db *gorm.DB, orgID int64, email string) *entity.User {
+	t.Helper()
+
+	user := &entity.User{OrgID: orgID, Username: email, Email: email}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// createTestTask adds a pending task of user
+func createTestTask(t *testing.T, repo *ReadingRepository, user *entity.User) int64 {
+	t.Helper()
+
+	taskID, err := repo.ForOrg(user.OrgID).CreateTask(&entity.ReadingTask{
+		UserID:   user.ID,
+		FileName: "book.txt",
+		FilePath: fmt.Sprintf("%d/%d.txt", user.OrgID, time.Now().UnixNano()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return taskID
+}
+
+// getTestTask loads a task regardless of its organization
+func getTestTask(t *testing.T, repo *ReadingRepository, taskID int64) *entity.ReadingTask {
+	t.Helper()
+
+	task, err := repo.System().GetTaskByID(taskID)
+	if err != nil || task == nil {
+		t.Fatalf("GetTaskByID(%d) = %v, %v", taskID, task, err)
+	}
+	return task
+}
+
+// setTaskColumns changes a task behind the repository's back
+func setTaskColumns(t *testing.T, db *gorm.DB, taskID int64, columns map[string]interface{}) {
+	t.Helper()
+
+	if err := db.Model(&entity.ReadingTask{}).Where("id = ?", taskID).Updates(columns).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClaimTaskLeasesEachTaskOnce(t *testing.T) {
+	repo, _, user := newTestReadingRepository(t)
+	first := createTestTask(t, repo, user)
+	second := createTestTask(t, repo, user)
+
+	before := time.Now()
+	claimed := map[int64]string{}
+	for _, owner := range []string{"worker-a", "worker-b"} {
+		task, err := repo.System().ClaimTask(owner, time.Minute, 3)
+		if err != nil || task == nil {
+			t.Fatalf("ClaimTask(%s) = %v, %v", owner, task, err)
+		}
+		if _, ok := claimed[task.ID]; ok {
+			t.Fatalf("task %d claimed twice", task.ID)
+		}
+		claimed[task.ID] = owner
+	}
+	if claimed[first] != "worker-a" || claimed[second] != "worker-b" {
+		t.Errorf("claims = %v, want tasks in ID order", claimed)
+	}
+
+	task, err := repo.System().ClaimTask("worker-c", time.Minute, 3)
+	if err != nil || task != nil {
+		t.Errorf("ClaimTask() with every task leased = %v, %v; want nil", task, err)
+	}
+
+	stored := getTestTask(t, repo, first)
+	if stored.Status != entity.TaskStatusProcessing || stored.LeaseOwner != "worker-a" || stored.Attempts != 1 {
+		t.Errorf("claimed task: status %s, owner %q, attempts %d", stored.Status, stored.LeaseOwner, stored.Attempts)
+	}
+	// DATETIME columns keep whole seconds
+	if stored.LeaseExpiresAt == nil || stored.LeaseExpiresAt.Before(before.Add(time.Minute-time.Second)) {
+		t.Errorf("lease expires at %v, want a minute from %v", stored.LeaseExpiresAt, before)
+	}
+
+	events, err := repo.System().GetTaskEvents(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Actor != "worker-a" || events[0].FromStatus != entity.TaskStatusPending ||
+		events[0].ToStatus != entity.TaskStatusProcessing {
+		t.Errorf("events = %+v, want the claim by worker-a", events)
+	}
+}
+
+func TestClaimTaskTakesOverExpiredLease(t *testing.T) {
+	repo, db, user := newTestReadingRepository(t)
+	taskID := createTestTask(t, repo, user)
+
+	if _, err := repo.System().ClaimTask("worker-a", time.Minute, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// A live lease is respected
+	if task, err := repo.System().ClaimTask("worker-b", time.Minute, 3); err != nil || task != nil {
+		t.Fatalf("ClaimTask() of a leased task = %v, %v; want nil", task, err)
+	}
+
+	setTaskColumns(t, db, taskID, map[string]interface{}{"lease_expires_at": time.Now().Add(-time.Minute)})
+
+	task, err := repo.System().ClaimTask("worker-b", time.Minute, 3)
+	if err != nil || task == nil || task.ID != taskID {
+		t.Fatalf("ClaimTask() after the lease expired = %v, %v; want task %d", task, err, taskID)
+	}
+	if task.LeaseOwner != "worker-b" || task.Attempts != 2 {
+		t.Errorf("taken over task: owner %q, attempts %d; want worker-b and 2", task.LeaseOwner, task.Attempts)
+	}
+
+	// The first worker can neither keep nor finish the task
+	if err := repo.System().RenewLease(taskID, "worker-a", time.Minute); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("RenewLease() by the previous owner = %v, want ErrLeaseLost", err)
+	}
+	err = repo.System().CompleteLeasedTask(taskID, "worker-a", entity.TaskStatusCompleted, "", nil, "")
+	if !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("CompleteLeasedTask() by the previous owner = %v, want ErrLeaseLost", err)
+	}
+	if err := repo.System().CompleteLeasedTask(taskID, "worker-b", entity.TaskStatusCompleted, "", nil, ""); err != nil {
+		t.Errorf("CompleteLeasedTask() by the new owner = %v", err)
+	}
+
+	events, err := repo.System().GetTaskEvents(taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("events = %+v, want claim, takeover and completion", events)
+	}
+	takeover := events[1]
+	if takeover.Actor != "worker-b" || takeover.FromStatus != entity.TaskStatusProcessing ||
+		takeover.Reason != "lease held by worker-a expired" {
+		t.Errorf("takeover event = %+v", takeover)
+	}
+}
+
+func TestRenewLease(t *testing.T) {
+	repo, db, user := newTestReadingRepository(t)
+	taskID := createTestTask(t, repo, user)
+
+	if _, err := repo.System().ClaimTask("worker-a", time.Minute, 3); err != nil {
+		t.Fatal(err)
+	}
+	setTaskColumns(t, db, taskID, map[string]interface{}{"lease_expires_at": time.Now().Add(time.Second)})
+
+	if err := repo.System().RenewLease(taskID, "worker-a", time.Hour); err != nil {
+		t.Fatalf("RenewLease() = %v", err)
+	}
+	if task := getTestTask(t, repo, taskID); task.LeaseExpiresAt == nil || time.Until(*task.LeaseExpiresAt) < 59*time.Minute {
+		t.Errorf("renewed lease expires at %v, want an hour from now", task.LeaseExpiresAt)
+	}
+
+	if err := repo.System().RenewLease(taskID, "worker-b", time.Hour); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("RenewLease() by another worker = %v, want ErrLeaseLost", err)
+	}
+
+	if err := repo.System().CompleteLeasedTask(taskID, "worker-a", entity.TaskStatusCompleted, "", nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.System().RenewLease(taskID, "worker-a", time.Hour); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("RenewLease() of a completed task = %v, want ErrLeaseLost", err)
+	}
+}
+
+func TestTransitionTaskStatus(t *testing.T) {
+	repo, _, user := newTestReadingRepository(t)
+	taskID := createTestTask(t, repo, user)
+	orgRepo := repo.ForOrg(user.OrgID)
+
+	if err := orgRepo.StartTask(taskID, entity.TaskStatusPending, "admin", "by hand", time.Minute); err != nil {
+		t.Fatalf("StartTask() = %v", err)
+	}
+	task := getTestTask(t, repo, taskID)
+	if task.Status != entity.TaskStatusProcessing || task.LeaseOwner != "admin" || task.LeaseExpiresAt == nil || task.Attempts != 1 {
+		t.Errorf("started task: status %s, owner %q until %v, attempts %d", task.Status, task.LeaseOwner, task.LeaseExpiresAt, task.Attempts)
+	}
+
+	// The status is checked when the change is made, not only before
+	err := orgRepo.TransitionTaskStatus(taskID, entity.TaskStatusPending, entity.TaskStatusFailed, "admin", "stale")
+	if !errors.Is(err, ErrStatusChanged) {
+		t.Errorf("TransitionTaskStatus() from a status the task left = %v, want ErrStatusChanged", err)
+	}
+
+	if err := orgRepo.TransitionTaskStatus(taskID, entity.TaskStatusProcessing, entity.TaskStatusPending, "admin", "put back"); err != nil {
+		t.Fatalf("TransitionTaskStatus() = %v", err)
+	}
+	task = getTestTask(t, repo, taskID)
+	if task.Status != entity.TaskStatusPending || task.LeaseOwner != "" || task.LeaseExpiresAt != nil {
+		t.Errorf("task put back: status %s, lease %q until %v; want pending without a lease", task.Status, task.LeaseOwner, task.LeaseExpiresAt)
+	}
+
+	events, err := orgRepo.GetTaskEvents(taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []entity.ReadingTaskEvent{
+		{Actor: "admin", FromStatus: entity.TaskStatusPending, ToStatus: entity.TaskStatusProcessing, Reason: "by hand"},
+		{Actor: "admin", FromStatus: entity.TaskStatusProcessing, ToStatus: entity.TaskStatusPending, Reason: "put back"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %+v, want %d", events, len(want))
+	}
+	for i, event := range events {
+		if event.Actor != want[i].Actor || event.FromStatus != want[i].FromStatus || event.ToStatus != want[i].ToStatus || event.Reason != want[i].Reason {
+			t.Errorf("event %d = %+v, want %+v", i, event, want[i])
+		}
+	}
+}
+
+func TestTransitionTaskStatusInAnotherOrganization(t *testing.T) {
+	repo, _, user := newTestReadingRepository(t)
+	taskID := createTestTask(t, repo, user)
+
+	err := repo.ForOrg(user.OrgID+1).TransitionTaskStatus(taskID, entity.TaskStatusPending, entity.TaskStatusFailed, "admin", "")
+	if !errors.Is(err, ErrStatusChanged) {
+		t.Errorf("TransitionTaskStatus() in another organization = %v, want ErrStatusChanged", err)
+	}
+	if status := getTestTask(t, repo, taskID).Status; status != entity.TaskStatusPending {
+		t.Errorf("status = %s, want pending", status)
+	}
+}
+
+func TestClaimTaskWaitsForNextAttempt(t *testing.T) {
+	repo, db, user := newTestReadingRepository(t)
+	taskID := createTestTask(t, repo, user)
+
+	setTaskColumns(t, db, taskID, map[string]interface{}{"next_attempt_at": time.Now().Add(time.Hour)})
+	if task, err := repo.System().ClaimTask("worker-a", time.Minute, 3); err != nil || task != nil {
+		t.Fatalf("ClaimTask() before the next attempt is due = %v, %v; want nil", task, err)
+	}
+
+	setTaskColumns(t, db, taskID, map[string]interface{}{"next_attempt_at": time.Now().Add(-time.Minute)})
+	if task, err := repo.System().ClaimTask("worker-a", time.Minute, 3); err != nil || task == nil || task.ID != taskID {
+		t.Fatalf("ClaimTask() once the next attempt is due = %v, %v; want task %d", task, err, taskID)
+	}
+}
+
+func TestClaimTaskFailsExpiredTaskAtAttemptLimit(t *testing.T) {
+	repo, db, user := newTestReadingRepository(t)
+	hanging := createTestTask(t, repo, user)
+	next := createTestTask(t, repo, user)
+
+	// The task has used up its attempts and its worker hung on the last one
+	setTaskColumns(t, db, hanging, map[string]interface{}{
+		"status":           entity.TaskStatusProcessing,
+		"lease_owner":      "worker-a",
+		"lease_expires_at": time.Now().Add(-time.Minute),
+		"attempts":         3,
+	})
+
+	task, err := repo.System().ClaimTask("worker-b", time.Minute, 3)
+	if err != nil || task == nil || task.ID != next {
+		t.Fatalf("ClaimTask() = %v, %v; want the next task %d", task, err, next)
+	}
+
+	failed := getTestTask(t, repo, hanging)
+	if failed.Status != entity.TaskStatusFailed || failed.LeaseOwner != "" || failed.LeaseExpiresAt != nil || failed.Attempts != 3 {
+		t.Errorf("hanging task: status %s, lease %q until %v, attempts %d; want failed without a lease after 3 attempts",
+			failed.Status, failed.LeaseOwner, failed.LeaseExpiresAt, failed.Attempts)
+	}
+	if failed.ErrorMessage != "lease held by worker-a expired on attempt 3" {
+		t.Errorf("error message = %q", failed.ErrorMessage)
+	}
+
+	events, err := repo.System().GetTaskEvents(hanging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].FromStatus != entity.TaskStatusProcessing || events[0].ToStatus != entity.TaskStatusFailed ||
+		events[0].Reason != "lease held by worker-a expired on attempt 3, giving up" {
+		t.Errorf("events = %+v, want the task failed", events)
+	}
+
+	// Below the limit the task is taken over instead
+	setTaskColumns(t, db, hanging, map[string]interface{}{
+		"status":           entity.TaskStatusProcessing,
+		"lease_owner":      "worker-a",
+		"lease_expires_at": time.Now().Add(-time.Minute),
+		"attempts":         2,
+	})
+	task, err = repo.System().ClaimTask("worker-c", time.Minute, 3)
+	if err != nil || task == nil || task.ID != hanging || task.Attempts != 3 {
+		t.Fatalf("ClaimTask() below the attempt limit = %+v, %v; want task %d on attempt 3", task, err, hanging)
+	}
+}
+
+func TestRequeueFailedTask(t *testing.T) {
+	repo, db, user := newTestReadingRepository(t)
+	taskID := createTestTask(t, repo, user)
+	orgRepo := repo.ForOrg(user.OrgID)
+
+	if err := orgRepo.RequeueFailedTask(taskID, "admin", "retry"); !errors.Is(err, ErrStatusChanged) {
+		t.Errorf("RequeueFailedTask() of a pending task = %v, want ErrStatusChanged", err)
+	}
+
+	setTaskColumns(t, db, taskID, map[string]interface{}{
+		"status":          entity.TaskStatusFailed,
+		"attempts":        3,
+		"next_attempt_at": time.Now().Add(time.Hour),
+	})
+	if err := orgRepo.RequeueFailedTask(taskID, "admin", "retry"); err != nil {
+		t.Fatalf("RequeueFailedTask() = %v", err)
+	}
+
+	task := getTestTask(t, repo, taskID)
+	if task.Status != entity.TaskStatusPending || task.Attempts != 0 || task.NextAttemptAt != nil {
+		t.Errorf("requeued task: status %s, attempts %d, next attempt %v; want pending with a fresh budget",
+			task.Status, task.Attempts, task.NextAttemptAt)
+	}
+
+	// The task is due right away, and gets all its attempts again
+	claimed, err := repo.System().ClaimTask("worker-a", time.Minute, 1)
+	if err != nil || claimed == nil || claimed.ID != taskID || claimed.Attempts != 1 {
+		t.Errorf("ClaimTask() after requeueing = %+v, %v", claimed, err)
+	}
+}
+
+func TestReleaseBlob(t *testing.T) {
+	repo, _, _ := newTestReadingRepository(t)
+	blobs := repo.ForOrg(entity.DefaultOrgID)
+	const sum = "5f0c6a2b0f6a6f6b0c1e3b4a7d8e9f00112233445566778899aabbccddeeff00"
+
+	for i := 0; i < 2; i++ {
+		if err := blobs.AcquireBlob(sum, 9); err != nil {
+			t.Fatalf("AcquireBlob() = %v", err)
+		}
+	}
+
+	deleted := 0
+	onLast := func() error {
+		deleted++
+		return nil
+	}
+	if err := blobs.ReleaseBlob(sum, onLast); err != nil || deleted != 0 {
+		t.Fatalf("ReleaseBlob() of one of two references = %v, deleted %d times; want the file kept", err, deleted)
+	}
+	if err := blobs.ReleaseBlob(sum, onLast); err != nil || deleted != 1 {
+		t.Fatalf("ReleaseBlob() of the last reference = %v, deleted %d times; want it deleted once", err, deleted)
+	}
+	if err := blobs.ReleaseBlob(sum, onLast); err != nil || deleted != 1 {
+		t.Errorf("ReleaseBlob() once released = %v, deleted %d times; want nothing done", err, deleted)
+	}
+
+	// A failed deletion has released the record all the same
+	if err := blobs.AcquireBlob(sum, 9); err != nil {
+		t.Fatal(err)
+	}
+	if err := blobs.ReleaseBlob(sum, func() error { return errors.New("disk hiccup") }); err == nil {
+		t.Error("ReleaseBlob() = nil, want the deletion error")
+	}
+	if err := blobs.AcquireBlob(sum, 9); err != nil {
+		t.Fatal(err)
+	}
+	if err := blobs.ReleaseBlob(sum, onLast); err != nil || deleted != 2 {
+		t.Errorf("ReleaseBlob() after a failed deletion = %v, deleted %d times; want a single reference", err, deleted)
+	}
+}
@@ -0,0 +1,131 @@
+// Package repotest gives tests an empty MySQL database with the
+// application's schema, created from scripts/schema.sql as in production. Tests that need one are skipped unless the
+// TEST_MYSQL_DSN environment variable names a server to create it on, for
+// example:
+//
+//	TEST_MYSQL_DSN='root:password@tcp(localhost:3306)/' go test ./...
+//
+// Each call creates a database of its own, so tests and packages can run in
+// parallel against one server.
+package repotest
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DSNVariable names the environment variable holding the DSN of the MySQL
+// server tests create their databases on
+const DSNVariable = "TEST_MYSQL_DSN"
+
+// Open creates a database with the application's schema, which is dropped
+// when the test ends. It skips the test when no server is configured.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv(DSNVariable)
+	if dsn == "" {
+		t.Skipf("%s not set, skipping test that needs MySQL", DSNVariable)
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("%s: %v", DSNVariable, err)
+	}
+	cfg.DBName = ""
+
+	server, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	name := "textile_test_" + randomSuffix(t)
+	if _, err := server.Exec("CREATE DATABASE " + name + " CHARACTER SET utf8mb4"); err != nil {
+		t.Fatalf("creating test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := server.Exec("DROP DATABASE " + name); err != nil {
+			t.Errorf("dropping test database: %v", err)
+		}
+	})
+
+	cfg.DBName = name
+	cfg.ParseTime = true
+	cfg.Loc = time.Local
+	if cfg.Params == nil {
+		cfg.Params = map[string]string{}
+	}
+	cfg.Params["charset"] = "utf8mb4"
+
+	db, err := gorm.Open(gormmysql.Open(cfg.FormatDSN()), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	for _, statement := range schemaStatements(t) {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("creating schema: %v\n%s", err, statement)
+		}
+	}
+
+	return db
+}
+
+// schemaStatements returns the statements of scripts/schema.sql, leaving
+// out those that create and select the production database
+func schemaStatements(t testing.TB) []string {
+	t.Helper()
+
+	_, file, _, _ := runtime.Caller(0)
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(file), "..", "..", "..", "scripts", "schema.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+
+	var statements []string
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";") {
+		statement = strings.TrimSpace(statement)
+		upper := strings.ToUpper(statement)
+		if statement == "" || strings.HasPrefix(upper, "CREATE DATABASE") || strings.HasPrefix(upper, "USE ") {
+			continue
+		}
+		statements = append(statements, statement)
+	}
+	return statements
+}
+
+// randomSuffix returns a random string to make database names unique
+func randomSuffix(t testing.TB) string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
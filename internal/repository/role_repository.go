@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"log"
+	"textile-admin/internal/domain/entity"
+
+	"gorm.io/gorm"
+)
+
+// RoleRepository handles database operations for roles and permissions
+type RoleRepository struct {
+	db *gorm.DB
+}
+
+// NewRoleRepository creates a new instance of RoleRepository
+func NewRoleRepository(db *gorm.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+// EnsureRole creates a role and its permissions if they do not exist, and
+// sets the role's permissions to exactly the given ones
+func (r *RoleRepository) EnsureRole(role *entity.Role) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, permission := range role.Permissions {
+			result := tx.Where(entity.Permission{Name: permission.Name}).
+				Assign(entity.Permission{Description: permission.Description}).
+				FirstOrCreate(permission)
+			if result.Error != nil {
+				log.Printf("Error saving permission %s: %v", permission.Name, result.Error)
+				return result.Error
+			}
+		}
+
+		permissions := role.Permissions
+		result := tx.Omit("Permissions").Where(entity.Role{Name: role.Name}).
+			Assign(entity.Role{Description: role.Description}).
+			FirstOrCreate(role)
+		if result.Error != nil {
+			log.Printf("Error saving role %s: %v", role.Name, result.Error)
+			return result.Error
+		}
+
+		if err := tx.Model(role).Association("Permissions").Replace(permissions); err != nil {
+			log.Printf("Error saving permissions of role %s: %v", role.Name, err)
+			return err
+		}
+
+		return nil
+	})
+}
+
+// ListRoles retrieves all roles with their permissions, ordered by name
+func (r *RoleRepository) ListRoles() ([]*entity.Role, error) {
+	var roles []*entity.Role
+
+	result := r.db.Preload("Permissions").Order("name ASC").Find(&roles)
+	if result.Error != nil {
+		log.Printf("Error querying roles: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return roles, nil
+}
+
+// GetRolesByNames retrieves the roles with the given names. Names without a
+// role are left out.
+func (r *RoleRepository) GetRolesByNames(names []string) ([]*entity.Role, error) {
+	var roles []*entity.Role
+
+	result := r.db.Where("name IN ?", names).Order("name ASC").Find(&roles)
+	if result.Error != nil {
+		log.Printf("Error querying roles by name: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return roles, nil
+}
+
+// SetUserRoles replaces the roles assigned to a user
+func (r *RoleRepository) SetUserRoles(user *entity.User, roles []*entity.Role) error {
+	err := r.db.Model(user).Association("Roles").Replace(roles)
+	if err != nil {
+		log.Printf("Error saving roles of user %d: %v", user.ID, err)
+		return err
+	}
+
+	return nil
+}
+
+// CountUsersWithRole counts the users assigned the named role
+func (r *RoleRepository) CountUsersWithRole(name string) (int64, error) {
+	var count int64
+
+	result := r.db.Table("user_roles").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("roles.name = ?", name).
+		Count(&count)
+	if result.Error != nil {
+		log.Printf("Error counting users with role %s: %v", name, result.Error)
+		return 0, result.Error
+	}
+
+	return count, nil
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"errors"
+	"log"
+	"textile-admin/internal/domain/entity"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrSessionEnded is returned when a reading session has already ended
+	ErrSessionEnded = errors.New("reading session has ended")
+
+	// ErrSessionExpired is returned when a reading session went without
+	// heartbeats for too long. The session is ended at its last heartbeat.
+	ErrSessionExpired = errors.New("reading session expired")
+)
+
+// SessionRepository handles database operations for reading sessions
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new instance of SessionRepository
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// CreateSession creates a new reading session in the database
+func (r *SessionRepository) CreateSession(session *entity.ReadingSession) error {
+	result := r.db.Create(session)
+	if result.Error != nil {
+		log.Printf("Error creating reading session: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// GetSessionByID retrieves a reading session by its ID
+func (r *SessionRepository) GetSessionByID(sessionID int64) (*entity.ReadingSession, error) {
+	var session entity.ReadingSession
+
+	result := r.db.First(&session, sessionID)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // Session not found
+		}
+		log.Printf("Error querying reading session: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &session, nil
+}
+
+// ExtendSession credits a session with the time since its last heartbeat
+// and, if end is set, ends it at. A gap longer than maxGap is not credited:
+// the session is ended at its last heartbeat and ErrSessionExpired returned.
+func (r *SessionRepository) ExtendSession(sessionID int64, at time.Time, maxGap time.Duration, end bool) (*entity.ReadingSession, error) {
+	var session entity.ReadingSession
+	expired := false
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, sessionID)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if session.EndedAt != nil {
+			return ErrSessionEnded
+		}
+
+		gap := at.Sub(session.LastHeartbeatAt)
+		if gap > maxGap {
+			endedAt := session.LastHeartbeatAt
+			session.EndedAt = &endedAt
+			// Returning an error would roll the end back; report it after the commit
+			expired = true
+			return tx.Model(&session).Update("ended_at", endedAt).Error
+		}
+
+		// Heartbeats may arrive out of order; never move time backwards
+		if gap > 0 {
+			session.ActiveSeconds += int(gap.Seconds())
+			session.LastHeartbeatAt = at
+		}
+
+		updates := map[string]interface{}{
+			"active_seconds":    session.ActiveSeconds,
+			"last_heartbeat_at": session.LastHeartbeatAt,
+		}
+		if end {
+			endedAt := session.LastHeartbeatAt
+			session.EndedAt = &endedAt
+			updates["ended_at"] = endedAt
+		}
+
+		return tx.Model(&session).Updates(updates).Error
+	})
+	if err != nil && !errors.Is(err, ErrSessionEnded) {
+		log.Printf("Error extending reading session: %v", err)
+		return nil, err
+	}
+	if err == nil && expired {
+		err = ErrSessionExpired
+	}
+
+	return &session, err
+}
+
+// GetSessionsStartedBetween retrieves a user's sessions that started in [from, to)
+func (r *SessionRepository) GetSessionsStartedBetween(userID int64, from, to time.Time) ([]*entity.ReadingSession, error) {
+	var sessions []*entity.ReadingSession
+
+	result := r.db.Where("user_id = ? AND started_at >= ? AND started_at < ?", userID, from, to).
+		Order("started_at ASC").
+		Find(&sessions)
+	if result.Error != nil {
+		log.Printf("Error querying reading sessions: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return sessions, nil
+}
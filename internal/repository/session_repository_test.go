@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"textile-admin/internal/domain/entity"
+	"time"
+)
+
+// createTestSession opens a session on a new task whose last heartbeat was at
+func createTestSession(t *testing.T, lastHeartbeatAt time.Time) (*SessionRepository, int64) {
+	t.Helper()
+
+	repo, db, user := newTestReadingRepository(t)
+	sessions := NewSessionRepository(db)
+	session := &entity.ReadingSession{
+		UserID:          user.ID,
+		TaskID:          createTestTask(t, repo, user),
+		StartedAt:       lastHeartbeatAt,
+		LastHeartbeatAt: lastHeartbeatAt,
+	}
+	if err := sessions.CreateSession(session); err != nil {
+		t.Fatal(err)
+	}
+	return sessions, session.ID
+}
+
+// getTestSession loads a session
+func getTestSession(t *testing.T, sessions *SessionRepository, sessionID int64) *entity.ReadingSession {
+	t.Helper()
+
+	session, err := sessions.GetSessionByID(sessionID)
+	if err != nil || session == nil {
+		t.Fatalf("GetSessionByID(%d) = %v, %v", sessionID, session, err)
+	}
+	return session
+}
+
+func TestExtendSession(t *testing.T) {
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	sessions, sessionID := createTestSession(t, start)
+
+	session, err := sessions.ExtendSession(sessionID, start.Add(90*time.Second), time.Minute*5, false)
+	if err != nil {
+		t.Fatalf("ExtendSession() = %v", err)
+	}
+	if session.ActiveSeconds != 90 || session.EndedAt != nil {
+		t.Errorf("after a heartbeat: %d active seconds, ended at %v; want 90 and open", session.ActiveSeconds, session.EndedAt)
+	}
+
+	// A late heartbeat from before the last one adds nothing
+	if session, err = sessions.ExtendSession(sessionID, start.Add(30*time.Second), time.Minute*5, false); err != nil {
+		t.Fatalf("ExtendSession() of an old heartbeat = %v", err)
+	}
+	if session.ActiveSeconds != 90 {
+		t.Errorf("after an old heartbeat: %d active seconds, want 90", session.ActiveSeconds)
+	}
+
+	if _, err := sessions.ExtendSession(sessionID, start.Add(150*time.Second), time.Minute*5, true); err != nil {
+		t.Fatalf("ExtendSession() ending the session = %v", err)
+	}
+	stored := getTestSession(t, sessions, sessionID)
+	if stored.ActiveSeconds != 150 || stored.EndedAt == nil || !stored.EndedAt.Equal(start.Add(150*time.Second)) {
+		t.Errorf("ended session: %d active seconds, ended at %v; want 150 and %v",
+			stored.ActiveSeconds, stored.EndedAt, start.Add(150*time.Second))
+	}
+
+	if _, err := sessions.ExtendSession(sessionID, start.Add(160*time.Second), time.Minute*5, false); !errors.Is(err, ErrSessionEnded) {
+		t.Errorf("ExtendSession() of an ended session = %v, want ErrSessionEnded", err)
+	}
+}
+
+func TestExtendSessionExpires(t *testing.T) {
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	sessions, sessionID := createTestSession(t, start)
+
+	session, err := sessions.ExtendSession(sessionID, start.Add(6*time.Minute), 5*time.Minute, false)
+	if !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("ExtendSession() after the idle timeout = %v, want ErrSessionExpired", err)
+	}
+	if session == nil || session.ActiveSeconds != 0 {
+		t.Errorf("expired session = %+v, want no active time", session)
+	}
+
+	// The session stays ended at its last heartbeat
+	stored := getTestSession(t, sessions, sessionID)
+	if stored.EndedAt == nil || !stored.EndedAt.Equal(start) || stored.ActiveSeconds != 0 {
+		t.Errorf("stored session ended at %v with %d active seconds, want %v and 0", stored.EndedAt, stored.ActiveSeconds, start)
+	}
+	if _, err := sessions.ExtendSession(sessionID, start.Add(7*time.Minute), 5*time.Minute, false); !errors.Is(err, ErrSessionEnded) {
+		t.Errorf("ExtendSession() of an expired session = %v, want ErrSessionEnded", err)
+	}
+}
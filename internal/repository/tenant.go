@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrNoTenant is returned when a tenant-scoped query runs without an
+// organization, so that a forgotten scope fails instead of leaking data
+var ErrNoTenant = errors.New("query is not scoped to an organization")
+
+// tenantScopeKey is the GORM setting holding a handle's tenantScope
+const tenantScopeKey = "tenant:scope"
+
+// tenantScope limits the rows a database handle can see. Exactly one of
+// its fields should be set; the zero value sees nothing.
+type tenantScope struct {
+	// orgID limits rows to one organization
+	orgID int64
+	// ownerID limits rows to one user's tasks, which lie in the user's
+	// organization
+	ownerID int64
+	// system sees every organization; for background work only
+	system bool
+}
+
+// withTenantScope returns a reusable handle whose statements are limited
+// to scope
+func withTenantScope(db *gorm.DB, scope tenantScope) *gorm.DB {
+	return db.Set(tenantScopeKey, scope).Session(&gorm.Session{})
+}
+
+// registerTenantCallbacks installs the callbacks that apply tenant scopes.
+// They act only on handles made by withTenantScope and leave every other
+// query alone. Registering twice is a no-op.
+func registerTenantCallbacks(db *gorm.DB) {
+	callbacks := db.Callback()
+	if callbacks.Query().Get("tenant:query") != nil {
+		return
+	}
+
+	callbacks.Create().Before("gorm:create").Register("tenant:create", applyTenantToCreate)
+	callbacks.Query().Before("gorm:query").Register("tenant:query", applyTenantScope)
+	callbacks.Row().Before("gorm:row").Register("tenant:row", applyTenantScope)
+	callbacks.Update().Before("gorm:update").Register("tenant:update", applyTenantScope)
+	callbacks.Delete().Before("gorm:delete").Register("tenant:delete", applyTenantScope)
+}
+
+// scopeOf returns the tenant scope of a statement, and false for handles
+// without one
+func scopeOf(db *gorm.DB) (tenantScope, bool) {
+	value, ok := db.Statement.Settings.Load(tenantScopeKey)
+	if !ok {
+		return tenantScope{}, false
+	}
+	scope, ok := value.(tenantScope)
+	return scope, ok
+}
+
+// applyTenantScope adds the scope's condition to a query, update or
+// delete. Tasks are filtered by their org_id or user_id column; rows
+// belonging to a task, found by a TaskID field, by their task.
+func applyTenantScope(db *gorm.DB) {
+	scope, ok := scopeOf(db)
+	if !ok || scope.system || db.Error != nil {
+		return
+	}
+
+	stmt := db.Statement
+	if stmt.SQL.Len() > 0 || stmt.Schema == nil {
+		db.AddError(errors.New("raw SQL cannot be tenant-scoped"))
+		return
+	}
+
+	var condition clause.Expression
+	switch {
+	case stmt.Schema.LookUpField("OrgID") != nil && scope.orgID != 0:
+		condition = clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "org_id"}, Value: scope.orgID}
+	case stmt.Schema.LookUpField("OrgID") != nil && scope.ownerID != 0:
+		condition = clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: "user_id"}, Value: scope.ownerID}
+	case stmt.Schema.LookUpField("TaskID") != nil && scope.orgID != 0:
+		condition = clause.Expr{
+			SQL:  "? IN (SELECT id FROM reading_tasks WHERE org_id = ?)",
+			Vars: []interface{}{clause.Column{Table: clause.CurrentTable, Name: "task_id"}, scope.orgID},
+		}
+	case stmt.Schema.LookUpField("TaskID") != nil && scope.ownerID != 0:
+		condition = clause.Expr{
+			SQL:  "? IN (SELECT id FROM reading_tasks WHERE user_id = ?)",
+			Vars: []interface{}{clause.Column{Table: clause.CurrentTable, Name: "task_id"}, scope.ownerID},
+		}
+	default:
+		db.AddError(ErrNoTenant)
+		return
+	}
+
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{condition}})
+}
+
+// applyTenantToCreate stamps new tasks with the scope's organization and
+// rejects tasks for another one. Rows belonging to a task are created
+// only after the task itself was found within the scope, so they are
+// not checked here.
+func applyTenantToCreate(db *gorm.DB) {
+	scope, ok := scopeOf(db)
+	if !ok || scope.system || db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+
+	field := db.Statement.Schema.LookUpField("OrgID")
+	if field == nil {
+		return
+	}
+	if scope.orgID == 0 {
+		db.AddError(ErrNoTenant)
+		return
+	}
+
+	stampOrg(db, field, db.Statement.ReflectValue, scope.orgID)
+}
+
+// stampOrg sets the OrgID of a record, or of each record of a batch
+func stampOrg(db *gorm.DB, field *schema.Field, value reflect.Value, orgID int64) {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			stampOrg(db, field, reflect.Indirect(value.Index(i)), orgID)
+		}
+	case reflect.Struct:
+		current, isZero := field.ValueOf(db.Statement.Context, value)
+		if isZero {
+			db.AddError(field.Set(db.Statement.Context, value, orgID))
+		} else if current != orgID {
+			db.AddError(errors.New("record belongs to another organization"))
+		}
+	}
+}
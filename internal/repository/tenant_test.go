@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"textile-admin/internal/domain/entity"
+)
+
+// createTestOrg adds an organization and returns its ID
+func createTestOrg(t *testing.T, repo *OrganizationRepository, name string) int64 {
+	t.Helper()
+
+	org := &entity.Organization{Name: name}
+	if err := repo.CreateOrganization(org); err != nil {
+		t.Fatal(err)
+	}
+	return org.ID
+}
+
+func TestTenantScopeLimitsReads(t *testing.T) {
+	repo, db, user := newTestReadingRepository(t)
+	otherOrg := createTestOrg(t, NewOrganizationRepository(db), "Other")
+	neighbour := createTestUser(t, db, entity.DefaultOrgID, "neighbour@example.com")
+	taskID := createTestTask(t, repo, user)
+	if err := repo.System().SaveTaskContent(taskID, "Chapter 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		repo    *ReadingRepository
+		visible bool
+	}{
+		{"own organization", repo.ForOrg(entity.DefaultOrgID), true},
+		{"owner", repo.ForOwner(user.ID), true},
+		{"system", repo.System(), true},
+		{"other organization", repo.ForOrg(otherOrg), false},
+		{"other user", repo.ForOwner(neighbour.ID), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task, err := tt.repo.GetTaskByID(taskID)
+			if err != nil || (task != nil) != tt.visible {
+				t.Errorf("GetTaskByID() = %v, %v; want visible %v", task, err, tt.visible)
+			}
+
+			tasks, err := tt.repo.GetTasksByUserID(user.ID)
+			if err != nil || (len(tasks) == 1) != tt.visible {
+				t.Errorf("GetTasksByUserID() = %d tasks, %v; want visible %v", len(tasks), err, tt.visible)
+			}
+
+			// Rows belonging to a task are scoped through the task
+			content, err := tt.repo.GetTaskContent(taskID)
+			if err != nil || (content != nil) != tt.visible {
+				t.Errorf("GetTaskContent() = %v, %v; want visible %v", content, err, tt.visible)
+			}
+		})
+	}
+}
+
+func TestTenantScopeLimitsWrites(t *testing.T) {
+	repo, db, user := newTestReadingRepository(t)
+	otherOrg := createTestOrg(t, NewOrganizationRepository(db), "Other")
+	neighbour := createTestUser(t, db, entity.DefaultOrgID, "neighbour@example.com")
+	taskID := createTestTask(t, repo, user)
+	if err := repo.System().SaveTaskContent(taskID, "Chapter 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, other := range []*ReadingRepository{repo.ForOrg(otherOrg), repo.ForOwner(neighbour.ID)} {
+		if err := other.DeleteTask(taskID); !errors.Is(err, ErrStatusChanged) {
+			t.Errorf("DeleteTask() out of scope = %v, want ErrStatusChanged", err)
+		}
+	}
+	if content, err := repo.System().GetTaskContent(taskID); err != nil || content == nil {
+		t.Errorf("content after deletes out of scope = %v, %v; want it kept", content, err)
+	}
+	getTestTask(t, repo, taskID)
+
+	// New tasks are stamped with the scope's organization
+	task := &entity.ReadingTask{UserID: user.ID, FileName: "stamped.txt", FilePath: "1/stamped.txt"}
+	if _, err := repo.ForOrg(entity.DefaultOrgID).CreateTask(task); err != nil {
+		t.Fatalf("CreateTask() = %v", err)
+	}
+	if stored := getTestTask(t, repo, task.ID); stored.OrgID != entity.DefaultOrgID {
+		t.Errorf("created task in organization %d, want %d", stored.OrgID, entity.DefaultOrgID)
+	}
+
+	// and cannot be created in another one
+	foreign := &entity.ReadingTask{OrgID: entity.DefaultOrgID, UserID: user.ID, FileName: "foreign.txt", FilePath: "1/foreign.txt"}
+	if _, err := repo.ForOrg(otherOrg).CreateTask(foreign); err == nil {
+		t.Error("CreateTask() of a task of another organization succeeded")
+	}
+	if _, err := repo.ForOwner(user.ID).CreateTask(&entity.ReadingTask{UserID: user.ID, FileName: "owned.txt", FilePath: "1/owned.txt"}); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("CreateTask() without an organization = %v, want ErrNoTenant", err)
+	}
+}
+
+func TestUnscopedRepositoryFails(t *testing.T) {
+	repo, _, user := newTestReadingRepository(t)
+	taskID := createTestTask(t, repo, user)
+
+	if _, err := repo.GetTaskByID(taskID); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("GetTaskByID() = %v, want ErrNoTenant", err)
+	}
+	if _, err := repo.GetTaskContent(taskID); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("GetTaskContent() = %v, want ErrNoTenant", err)
+	}
+	if _, err := repo.CreateTask(&entity.ReadingTask{UserID: user.ID, FileName: "book.txt", FilePath: "1/unscoped.txt"}); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("CreateTask() = %v, want ErrNoTenant", err)
+	}
+	if err := repo.DeleteTask(taskID); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("DeleteTask() = %v, want ErrNoTenant", err)
+	}
+	getTestTask(t, repo, taskID)
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"log"
+	"textile-admin/internal/domain/entity"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TokenRepository handles database operations for refresh tokens
+type TokenRepository struct {
+	db *gorm.DB
+}
+
+// NewTokenRepository creates a new instance of TokenRepository
+func NewTokenRepository(db *gorm.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+// CreateRefreshToken records an issued refresh token
+func (r *TokenRepository) CreateRefreshToken(token *entity.RefreshToken) error {
+	result := r.db.Create(token)
+	if result.Error != nil {
+		log.Printf("Error creating refresh token: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// GetRefreshToken retrieves a refresh token by its token ID
+func (r *TokenRepository) GetRefreshToken(tokenID string) (*entity.RefreshToken, error) {
+	var token entity.RefreshToken
+
+	result := r.db.Where("token_id = ?", tokenID).First(&token)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // Token not found
+		}
+		log.Printf("Error querying refresh token: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &token, nil
+}
+
+// RevokeRefreshToken marks a refresh token as revoked. It reports false
+// when the token was already revoked, so that two concurrent refreshes
+// with the same token cannot both succeed.
+func (r *TokenRepository) RevokeRefreshToken(tokenID string, at time.Time) (bool, error) {
+	result := r.db.Model(&entity.RefreshToken{}).
+		Where("token_id = ? AND revoked_at IS NULL", tokenID).
+		Update("revoked_at", at)
+	if result.Error != nil {
+		log.Printf("Error revoking refresh token: %v", result.Error)
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// RevokeUserRefreshTokens revokes all outstanding refresh tokens of a user
+func (r *TokenRepository) RevokeUserRefreshTokens(userID int64, at time.Time) error {
+	result := r.db.Model(&entity.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", at)
+	if result.Error != nil {
+		log.Printf("Error revoking refresh tokens of user %d: %v", userID, result.Error)
+		return result.Error
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"log"
+	"textile-admin/internal/domain/entity"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UploadRepository handles database operations for resumable uploads
+type UploadRepository struct {
+	db *gorm.DB
+}
+
+// NewUploadRepository creates a new instance of UploadRepository
+func NewUploadRepository(db *gorm.DB) *UploadRepository {
+	return &UploadRepository{db: db}
+}
+
+// CreateSession creates a new upload session in the database
+func (r *UploadRepository) CreateSession(session *entity.UploadSession) error {
+	result := r.db.Create(session)
+	if result.Error != nil {
+		log.Printf("Error creating upload session: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// GetSession retrieves an upload session by its ID
+func (r *UploadRepository) GetSession(sessionID string) (*entity.UploadSession, error) {
+	var session entity.UploadSession
+
+	result := r.db.Where("id = ?", sessionID).First(&session)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // Session not found
+		}
+		log.Printf("Error querying upload session: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &session, nil
+}
+
+// AppendPart records a chunk stored at the session's current offset and
+// advances the offset past it, extending the session's expiry. It reports
+// false, recording nothing, when the offset has moved since the chunk was
+// started, so that concurrent requests cannot both append at one offset.
+func (r *UploadRepository) AppendPart(part *entity.UploadPart, expiresAt time.Time) (bool, error) {
+	appended := false
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.UploadSession{}).
+			Where("id = ? AND upload_offset = ?", part.SessionID, part.Offset).
+			Updates(map[string]interface{}{
+				"upload_offset": part.Offset + part.Size,
+				"expires_at":    expiresAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if err := tx.Create(part).Error; err != nil {
+			return err
+		}
+
+		appended = true
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error appending part to upload session %s: %v", part.SessionID, err)
+		return false, err
+	}
+
+	return appended, nil
+}
+
+// GetParts retrieves the chunks of an upload session in file order
+func (r *UploadRepository) GetParts(sessionID string) ([]*entity.UploadPart, error) {
+	var parts []*entity.UploadPart
+
+	result := r.db.Where("session_id = ?", sessionID).Order("upload_offset ASC").Find(&parts)
+	if result.Error != nil {
+		log.Printf("Error querying parts of upload session %s: %v", sessionID, result.Error)
+		return nil, result.Error
+	}
+
+	return parts, nil
+}
+
+// ClaimCompletion marks an upload session whose task has not been created
+// as being completed. It reports false, changing nothing, when the task
+// exists or another request is creating it, so that only one request
+// creates the task of an upload.
+func (r *UploadRepository) ClaimCompletion(sessionID string) (bool, error) {
+	result := r.db.Model(&entity.UploadSession{}).
+		Where("id = ? AND task_id IS NULL AND completing = ?", sessionID, false).
+		Update("completing", true)
+	if result.Error != nil {
+		log.Printf("Error claiming completion of upload session %s: %v", sessionID, result.Error)
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// ReleaseCompletion clears the claim of an upload session whose task could
+// not be created, so that it can be retried
+func (r *UploadRepository) ReleaseCompletion(sessionID string) error {
+	result := r.db.Model(&entity.UploadSession{}).Where("id = ?", sessionID).Update("completing", false)
+	if result.Error != nil {
+		log.Printf("Error releasing completion of upload session %s: %v", sessionID, result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// CompleteSession records the task created from an upload session, clearing
+// its completion claim, and deletes the records of its chunks
+func (r *UploadRepository) CompleteSession(sessionID string, taskID int64) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entity.UploadSession{}).Where("id = ?", sessionID).
+			Updates(map[string]interface{}{"task_id": taskID, "completing": false})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Where("session_id = ?", sessionID).Delete(&entity.UploadPart{}).Error
+	})
+	if err != nil {
+		log.Printf("Error completing upload session %s: %v", sessionID, err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteSession deletes an upload session and the records of its chunks
+func (r *UploadRepository) DeleteSession(sessionID string) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id = ?", sessionID).Delete(&entity.UploadPart{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("id = ?", sessionID).Delete(&entity.UploadSession{}).Error
+	})
+	if err != nil {
+		log.Printf("Error deleting upload session %s: %v", sessionID, err)
+		return err
+	}
+
+	return nil
+}
+
+// GetExpiredSessions retrieves up to limit upload sessions that expired
+// before now, oldest first
+func (r *UploadRepository) GetExpiredSessions(now time.Time, limit int) ([]*entity.UploadSession, error) {
+	var sessions []*entity.UploadSession
+
+	result := r.db.Where("expires_at < ?", now).Order("expires_at ASC").Limit(limit).Find(&sessions)
+	if result.Error != nil {
+		log.Printf("Error querying expired upload sessions: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"errors"
+	"log"
+	"textile-admin/internal/domain/entity"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrDuplicateEmail is returned when another user already has the email address
+var ErrDuplicateEmail = errors.New("email already in use")
+
+// UserRepository handles database operations for users
+type UserRepository struct {
+	db *gorm.DB
+}
+
+// NewUserRepository creates a new instance of UserRepository
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// CreateUser creates a new user in the database, assigning them user.Roles
+// and linking user.Identities
+func (r *UserRepository) CreateUser(user *entity.User) error {
+	result := r.db.Create(user)
+	if result.Error != nil {
+		if isDuplicateKey(result.Error) {
+			return ErrDuplicateEmail
+		}
+		log.Printf("Error creating user: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// GetUserByID retrieves a user by their ID, with their roles and
+// permissions
+func (r *UserRepository) GetUserByID(userID int64) (*entity.User, error) {
+	var user entity.User
+
+	result := r.db.Preload("Roles.Permissions").First(&user, userID)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // User not found
+		}
+		log.Printf("Error querying user: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &user, nil
+}
+
+// GetUserByEmail retrieves a user by their email address, with their roles
+// and permissions
+func (r *UserRepository) GetUserByEmail(email string) (*entity.User, error) {
+	var user entity.User
+
+	result := r.db.Preload("Roles.Permissions").Where("email = ?", email).First(&user)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // User not found
+		}
+		log.Printf("Error querying user by email: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &user, nil
+}
+
+// GetUserByIdentity retrieves the user linked to a single sign-on account,
+// with their roles and permissions
+func (r *UserRepository) GetUserByIdentity(issuer, subject string) (*entity.User, error) {
+	var user entity.User
+
+	result := r.db.Preload("Roles.Permissions").
+		Joins("JOIN user_identities ON user_identities.user_id = users.id").
+		Where("user_identities.issuer = ? AND user_identities.subject = ?", issuer, subject).
+		First(&user)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil // User not found
+		}
+		log.Printf("Error querying user by identity: %v", result.Error)
+		return nil, result.Error
+	}
+
+	return &user, nil
+}
+
+// CreateIdentity links an existing user to a single sign-on account
+func (r *UserRepository) CreateIdentity(identity *entity.UserIdentity) error {
+	result := r.db.Create(identity)
+	if result.Error != nil {
+		log.Printf("Error creating user identity: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// ListUsers retrieves a page of an organization's users ordered by ID with
+// their roles, and the total number of its users
+func (r *UserRepository) ListUsers(orgID int64, offset, limit int) ([]*entity.User, int64, error) {
+	var users []*entity.User
+	var total int64
+
+	if err := r.db.Model(&entity.User{}).Where("org_id = ?", orgID).Count(&total).Error; err != nil {
+		log.Printf("Error counting users: %v", err)
+		return nil, 0, err
+	}
+
+	result := r.db.Preload("Roles").Where("org_id = ?", orgID).Order("id ASC").Offset(offset).Limit(limit).Find(&users)
+	if result.Error != nil {
+		log.Printf("Error querying users: %v", result.Error)
+		return nil, 0, result.Error
+	}
+
+	return users, total, nil
+}
+
+// UpdateUser saves the username, email and password hash of a user
+func (r *UserRepository) UpdateUser(user *entity.User) error {
+	result := r.db.Model(user).Select("username", "email", "password_hash").Omit(clause.Associations).Updates(user)
+	if result.Error != nil {
+		if isDuplicateKey(result.Error) {
+			return ErrDuplicateEmail
+		}
+		log.Printf("Error updating user: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// SetUserOrg moves a user to another organization
+func (r *UserRepository) SetUserOrg(userID, orgID int64) error {
+	result := r.db.Model(&entity.User{}).Where("id = ?", userID).Update("org_id", orgID)
+	if result.Error != nil {
+		log.Printf("Error updating user organization: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// DeleteUser deletes a user and their role assignments
+func (r *UserRepository) DeleteUser(userID int64) error {
+	result := r.db.Select("Roles").Delete(&entity.User{ID: userID})
+	if result.Error != nil {
+		log.Printf("Error deleting user: %v", result.Error)
+		return result.Error
+	}
+
+	return nil
+}
+
+// CountUserTasks counts the reading tasks owned by a user
+func (r *UserRepository) CountUserTasks(userID int64) (int64, error) {
+	var count int64
+
+	result := r.db.Model(&entity.ReadingTask{}).Where("user_id = ?", userID).Count(&count)
+	if result.Error != nil {
+		log.Printf("Error counting user tasks: %v", result.Error)
+		return 0, result.Error
+	}
+
+	return count, nil
+}
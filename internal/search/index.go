@@ -0,0 +1,643 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrEmptyQuery is returned when a query contains no searchable terms
+var ErrEmptyQuery = errors.New("query contains no searchable terms")
+
+// mergeFactor is how many segments of about the same size are merged into
+// one. Segments grow by this factor at each merge, so an index of n
+// documents has O(log n) segments and each document is rewritten
+// O(log n) times.
+const mergeFactor = 10
+
+// Index is an inverted index of documents on local disk. Each segment file
+// maps the terms of its documents to where they occur. A document is
+// indexed into a new small segment, and small segments are merged into
+// larger ones as they accumulate, so a search looks up each term in a few
+// segments however many documents there are.
+//
+// Several processes may share an index directory: writers take a lock on
+// it, and readers pick up changes from the manifest.
+type Index struct {
+	dir  string
+	lock *os.File
+
+	// mu guards the fields below. Writers and refresh hold it exclusively,
+	// searches hold it shared.
+	mu       sync.RWMutex
+	manifest manifest
+	segments []*segment
+	// live maps each indexed document to the position in segments of the
+	// segment holding its current version
+	live map[int64]int
+}
+
+// segment is an open segment file
+type segment struct {
+	name   string
+	reader *segmentReader
+	docIDs []int64
+}
+
+// Result is a document matching a query
+type Result struct {
+	DocID int64
+	Score float64
+	// Phrases counts the places where the query terms occur in query order
+	Phrases int
+	// Matches are the places to show, phrase matches when there are any and
+	// otherwise the occurrences of the rarest query term
+	Matches []Posting
+	// Highlights are all occurrences of the query terms, in document order
+	Highlights []Posting
+}
+
+// NewIndex opens the index stored in dir, creating it if needed
+func NewIndex(dir string) (*Index, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create index directory: %v", err)
+	}
+
+	lock, err := os.OpenFile(filepath.Join(dir, lockFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index lock: %v", err)
+	}
+
+	x := &Index{dir: dir, lock: lock, live: make(map[int64]int)}
+	if err := x.write(x.removeStaleFiles); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("failed to open index: %v", err)
+	}
+	return x, nil
+}
+
+// Add indexes a document, replacing any earlier version
+func (x *Index) Add(docID int64, text string) error {
+	postings := make(map[string][]Posting)
+	for _, token := range Tokenize(text) {
+		postings[token.Term] = append(postings[token.Term], Posting{Start: token.Start, Length: token.Length})
+	}
+
+	terms := make([]string, 0, len(postings))
+	for term := range postings {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+
+	return x.write(func() error {
+		m := x.manifest.clone()
+		name := m.newSegment()
+
+		w, err := createSegment(x.segmentPath(name))
+		if err != nil {
+			return err
+		}
+		for _, term := range terms {
+			if err := w.add(term, []docPostings{{docID: docID, postings: postings[term]}}); err != nil {
+				w.abort()
+				return err
+			}
+		}
+		if err := w.finish([]int64{docID}); err != nil {
+			return err
+		}
+
+		m.Segments = append(m.Segments, name)
+		m.Removed = slices.DeleteFunc(m.Removed, func(id int64) bool { return id == docID })
+		if err := x.commit(m); err != nil {
+			os.Remove(x.segmentPath(name))
+			return err
+		}
+
+		return x.mergeSegments()
+	})
+}
+
+// Remove deletes a document from the index
+func (x *Index) Remove(docID int64) error {
+	return x.write(func() error {
+		if _, ok := x.live[docID]; !ok {
+			return nil
+		}
+
+		m := x.manifest.clone()
+		m.Removed = append(m.Removed, docID)
+		return x.commit(m)
+	})
+}
+
+// Missing returns the documents among docIDs that have not been indexed
+func (x *Index) Missing(docIDs []int64) ([]int64, error) {
+	if err := x.sync(); err != nil {
+		return nil, err
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	var missing []int64
+	for _, docID := range docIDs {
+		if _, ok := x.live[docID]; !ok {
+			missing = append(missing, docID)
+		}
+	}
+	return missing, nil
+}
+
+// Search finds the documents among docIDs that contain every term of query,
+// best matches first. Documents that have not been indexed are skipped.
+func (x *Index) Search(query string, docIDs []int64) ([]*Result, error) {
+	tokens := tokenizeQuery(query)
+	if len(tokens) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	var terms []string
+	seen := make(map[string]bool)
+	for _, token := range tokens {
+		if !seen[token.Term] {
+			seen[token.Term] = true
+			terms = append(terms, token.Term)
+		}
+	}
+
+	if err := x.sync(); err != nil {
+		return nil, err
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	allowed := make(map[int64]bool, len(docIDs))
+	for _, docID := range docIDs {
+		allowed[docID] = true
+	}
+
+	// Documents containing every term so far, with their postings, and the
+	// number of searched documents containing each term
+	var found map[int64]map[string][]Posting
+	docFreq := make(map[string]int, len(terms))
+	for i, term := range terms {
+		next := make(map[int64]map[string][]Posting)
+		for pos, seg := range x.segments {
+			docs, err := seg.reader.lookup(term)
+			if err != nil {
+				return nil, fmt.Errorf("segment %s: %w", seg.name, err)
+			}
+
+			for _, doc := range docs {
+				if !allowed[doc.docID] || !x.isLive(doc.docID, pos) {
+					continue
+				}
+				docFreq[term]++
+
+				postings := map[string][]Posting{}
+				if i > 0 {
+					if postings = found[doc.docID]; postings == nil {
+						continue
+					}
+				}
+				postings[term] = doc.postings
+				next[doc.docID] = postings
+			}
+		}
+
+		found = next
+		if len(found) == 0 {
+			return []*Result{}, nil
+		}
+	}
+
+	type candidate struct {
+		docID    int64
+		postings map[string][]Posting
+	}
+
+	var candidates []candidate
+	for _, docID := range docIDs {
+		postings := found[docID]
+		if postings == nil {
+			continue
+		}
+
+		candidates = append(candidates, candidate{docID: docID, postings: postings})
+	}
+
+	results := make([]*Result, 0, len(candidates))
+	for _, c := range candidates {
+		result := &Result{DocID: c.docID}
+
+		rarest := terms[0]
+		for _, term := range terms {
+			tf := float64(len(c.postings[term]))
+			idf := math.Log(1 + float64(len(docIDs))/float64(docFreq[term]))
+			result.Score += (1 + math.Log(tf)) * idf
+
+			if len(c.postings[term]) < len(c.postings[rarest]) {
+				rarest = term
+			}
+			result.Highlights = append(result.Highlights, c.postings[term]...)
+		}
+
+		result.Matches = findPhrases(tokens, c.postings)
+		result.Phrases = len(result.Matches)
+		if result.Phrases > 0 {
+			// Documents containing the query as written rank first
+			result.Score *= 2 + math.Log(float64(result.Phrases))
+		} else {
+			result.Matches = c.postings[rarest]
+		}
+
+		sort.Slice(result.Highlights, func(i, j int) bool {
+			return result.Highlights[i].Start < result.Highlights[j].Start
+		})
+
+		results = append(results, result)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	return results, nil
+}
+
+// isLive reports whether the segment at pos holds the current version of a
+// document
+func (x *Index) isLive(docID int64, pos int) bool {
+	livePos, ok := x.live[docID]
+	return ok && livePos == pos
+}
+
+// sync picks up changes made to the index by other processes
+func (x *Index) sync() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.refresh()
+}
+
+// write runs change with the index locked against other writers and up to
+// date
+func (x *Index) write(change func() error) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if err := lockFile(x.lock); err != nil {
+		return fmt.Errorf("failed to lock index: %v", err)
+	}
+	defer unlockFile(x.lock)
+
+	if err := x.refresh(); err != nil {
+		return err
+	}
+	return change()
+}
+
+// refresh loads the manifest if another process has changed it
+func (x *Index) refresh() error {
+	for attempt := 0; ; attempt++ {
+		m, err := readManifest(x.dir)
+		if err != nil {
+			return err
+		}
+		if m.Version == x.manifest.Version {
+			return nil
+		}
+
+		// A segment listed in the manifest may have been merged away
+		// before it could be opened; the next manifest won't list it
+		err = x.load(m)
+		if errors.Is(err, fs.ErrNotExist) && attempt < 3 {
+			continue
+		}
+		return err
+	}
+}
+
+// load opens the segments of a manifest and makes it current
+func (x *Index) load(m *manifest) error {
+	open := make(map[string]*segment, len(x.segments))
+	for _, seg := range x.segments {
+		open[seg.name] = seg
+	}
+
+	segments := make([]*segment, 0, len(m.Segments))
+	for _, name := range m.Segments {
+		seg := open[name]
+		if seg == nil {
+			var err error
+			if seg, err = x.openSegment(name); err != nil {
+				for _, opened := range segments {
+					if open[opened.name] == nil {
+						opened.reader.Close()
+					}
+				}
+				return err
+			}
+		}
+		segments = append(segments, seg)
+	}
+
+	for _, seg := range x.segments {
+		if !slices.Contains(m.Segments, seg.name) {
+			seg.reader.Close()
+		}
+	}
+
+	live := make(map[int64]int)
+	for pos, seg := range segments {
+		for _, docID := range seg.docIDs {
+			live[docID] = pos
+		}
+	}
+	for _, docID := range m.Removed {
+		delete(live, docID)
+	}
+
+	x.manifest = *m
+	x.segments = segments
+	x.live = live
+	return nil
+}
+
+// openSegment opens a segment file and reads its list of documents
+func (x *Index) openSegment(name string) (*segment, error) {
+	reader, err := openSegment(x.segmentPath(name))
+	if err != nil {
+		return nil, err
+	}
+
+	docIDs, err := reader.docIDs()
+	if err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("segment %s: %w", name, err)
+	}
+
+	return &segment{name: name, reader: reader, docIDs: docIDs}, nil
+}
+
+// commit writes a new manifest, makes it current and deletes the segment
+// files it no longer lists. Processes still reading those keep their open
+// files until they refresh.
+func (x *Index) commit(m manifest) error {
+	m.Version++
+	if err := writeManifest(x.dir, &m); err != nil {
+		return err
+	}
+
+	old := x.segments
+	if err := x.load(&m); err != nil {
+		return err
+	}
+
+	for _, seg := range old {
+		if !slices.Contains(m.Segments, seg.name) {
+			os.Remove(x.segmentPath(seg.name))
+		}
+	}
+	return nil
+}
+
+// mergeSegments merges the newest segments while mergeFactor of them hold
+// about the same number of documents. Only the newest segments are merged,
+// so the merged segment keeps their place in the order.
+func (x *Index) mergeSegments() error {
+	for len(x.segments) >= mergeFactor {
+		from := len(x.segments) - mergeFactor
+		level := x.level(len(x.segments) - 1)
+		for pos := from; pos < len(x.segments)-1; pos++ {
+			if x.level(pos) != level {
+				return nil
+			}
+		}
+
+		if err := x.merge(from); err != nil {
+			return fmt.Errorf("failed to merge index segments: %w", err)
+		}
+	}
+	return nil
+}
+
+// level returns the size class of the segment at pos: the number of
+// current documents it holds, on a log scale
+func (x *Index) level(pos int) int {
+	count := 0
+	for _, docID := range x.segments[pos].docIDs {
+		if x.isLive(docID, pos) {
+			count++
+		}
+	}
+
+	level := 0
+	for count >= mergeFactor {
+		count /= mergeFactor
+		level++
+	}
+	return level
+}
+
+// merge replaces the segments from position from onwards with a single
+// segment holding the current versions of their documents
+func (x *Index) merge(from int) error {
+	m := x.manifest.clone()
+	name := m.newSegment()
+
+	w, err := createSegment(x.segmentPath(name))
+	if err != nil {
+		return err
+	}
+	if err := x.mergeTerms(w, from); err != nil {
+		w.abort()
+		return err
+	}
+
+	var docIDs []int64
+	for pos := from; pos < len(x.segments); pos++ {
+		for _, docID := range x.segments[pos].docIDs {
+			if x.isLive(docID, pos) {
+				docIDs = append(docIDs, docID)
+			}
+		}
+	}
+	slices.Sort(docIDs)
+
+	if err := w.finish(docIDs); err != nil {
+		return err
+	}
+
+	// A removed document must stay marked as removed while an older
+	// segment still holds a version of it
+	older := make(map[int64]bool)
+	for _, seg := range x.segments[:from] {
+		for _, docID := range seg.docIDs {
+			older[docID] = true
+		}
+	}
+	m.Removed = slices.DeleteFunc(m.Removed, func(docID int64) bool { return !older[docID] })
+	m.Segments = append(m.Segments[:from], name)
+
+	if err := x.commit(m); err != nil {
+		os.Remove(x.segmentPath(name))
+		return err
+	}
+	return nil
+}
+
+// mergeTerms writes the postings of the current documents in the segments
+// from position from onwards to w, walking their dictionaries in step
+func (x *Index) mergeTerms(w *segmentWriter, from int) error {
+	type cursor struct {
+		pos   int
+		seg   *segment
+		next  int
+		entry *dictEntry
+	}
+
+	advance := func(c *cursor) error {
+		c.entry = nil
+		if c.next == c.seg.reader.terms {
+			return nil
+		}
+
+		entry, err := c.seg.reader.entry(c.next)
+		if err != nil {
+			return fmt.Errorf("segment %s: %w", c.seg.name, err)
+		}
+		c.entry = entry
+		c.next++
+		return nil
+	}
+
+	var cursors []*cursor
+	for pos := from; pos < len(x.segments); pos++ {
+		c := &cursor{pos: pos, seg: x.segments[pos]}
+		if err := advance(c); err != nil {
+			return err
+		}
+		cursors = append(cursors, c)
+	}
+
+	for {
+		term := ""
+		for _, c := range cursors {
+			if c.entry != nil && (term == "" || c.entry.term < term) {
+				term = c.entry.term
+			}
+		}
+		if term == "" {
+			return nil
+		}
+
+		var docs []docPostings
+		for _, c := range cursors {
+			if c.entry == nil || c.entry.term != term {
+				continue
+			}
+
+			postings, err := c.seg.reader.postings(c.entry)
+			if err != nil {
+				return fmt.Errorf("segment %s: %w", c.seg.name, err)
+			}
+			for _, doc := range postings {
+				if x.isLive(doc.docID, c.pos) {
+					docs = append(docs, doc)
+				}
+			}
+
+			if err := advance(c); err != nil {
+				return err
+			}
+		}
+
+		if len(docs) == 0 {
+			continue
+		}
+
+		sort.Slice(docs, func(i, j int) bool { return docs[i].docID < docs[j].docID })
+		if err := w.add(term, docs); err != nil {
+			return err
+		}
+	}
+}
+
+// removeStaleFiles deletes segment files that the manifest doesn't list,
+// left behind by writers that crashed, and the per-document files of the
+// index format used by earlier versions, whose documents are indexed again
+// on first search
+func (x *Index) removeStaleFiles() error {
+	entries, err := os.ReadDir(x.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case entry.IsDir():
+			legacy, _ := filepath.Glob(filepath.Join(x.dir, name, "*.idx"))
+			for _, path := range legacy {
+				os.Remove(path)
+			}
+			if len(legacy) > 0 {
+				os.Remove(filepath.Join(x.dir, name))
+			}
+		case strings.Contains(name, segmentExt) && !slices.Contains(x.manifest.Segments, name),
+			strings.HasPrefix(name, manifestFile+".tmp"):
+			os.Remove(filepath.Join(x.dir, name))
+		}
+	}
+	return nil
+}
+
+// segmentPath returns the path of a segment file
+func (x *Index) segmentPath(name string) string {
+	return filepath.Join(x.dir, name)
+}
+
+// findPhrases returns the places where the query tokens occur at the same
+// distances from each other as in the query
+func findPhrases(tokens []Token, postings map[string][]Posting) []Posting {
+	if len(tokens) == 1 {
+		return postings[tokens[0].Term]
+	}
+
+	starts := make(map[string]map[int]bool, len(postings))
+	for term, list := range postings {
+		set := make(map[int]bool, len(list))
+		for _, p := range list {
+			set[p.Start] = true
+		}
+		starts[term] = set
+	}
+
+	first, last := tokens[0], tokens[len(tokens)-1]
+	length := last.Start + last.Length - first.Start
+
+	var phrases []Posting
+	for _, p := range postings[first.Term] {
+		matched := true
+		for _, token := range tokens[1:] {
+			if !starts[token.Term][p.Start+token.Start-first.Start] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			phrases = append(phrases, Posting{Start: p.Start, Length: length})
+		}
+	}
+
+	return phrases
+}
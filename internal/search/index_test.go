@@ -0,0 +1,270 @@
+package search
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func resultIDs(results []*Result) map[int64]bool {
+	ids := make(map[int64]bool, len(results))
+	for _, result := range results {
+		ids[result.DocID] = true
+	}
+	return ids
+}
+
+func mustSearch(t *testing.T, x *Index, query string, docIDs []int64) map[int64]bool {
+	t.Helper()
+	results, err := x.Search(query, docIDs)
+	if err != nil {
+		t.Fatalf("Search(%q): %v", query, err)
+	}
+	return resultIDs(results)
+}
+
+func TestIndexSearch(t *testing.T) {
+	x, err := NewIndex(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docs := map[int64]string{
+		1: "第一章 春天来了，花开了。",
+		2: "The quick brown fox jumps over the lazy dog.",
+		3: "A lazy afternoon with a quick nap.",
+	}
+	for id, text := range docs {
+		if err := x.Add(id, text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all := []int64{1, 2, 3}
+	tests := []struct {
+		query  string
+		docIDs []int64
+		want   []int64
+	}{
+		{"春天", all, []int64{1}},
+		{"quick lazy", all, []int64{2, 3}},
+		{"quick lazy", []int64{3}, []int64{3}},
+		{"fox", []int64{1, 3}, nil},
+		{"missing", all, nil},
+	}
+	for _, tt := range tests {
+		got := mustSearch(t, x, tt.query, tt.docIDs)
+		if len(got) != len(tt.want) {
+			t.Errorf("Search(%q, %v) = %v, want %v", tt.query, tt.docIDs, got, tt.want)
+			continue
+		}
+		for _, id := range tt.want {
+			if !got[id] {
+				t.Errorf("Search(%q, %v) = %v, want %v", tt.query, tt.docIDs, got, tt.want)
+			}
+		}
+	}
+
+	results, err := x.Search("brown fox", all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Phrases != 1 || results[0].Matches[0] != (Posting{Start: 10, Length: 9}) {
+		t.Errorf("Search(brown fox) = %+v, want one phrase match at 10", results)
+	}
+
+	if _, err := x.Search("，。", all); err != ErrEmptyQuery {
+		t.Errorf("Search of punctuation: err = %v, want ErrEmptyQuery", err)
+	}
+}
+
+func TestIndexSearchRanksRareTermsHigher(t *testing.T) {
+	x, err := NewIndex(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docs := map[int64]string{
+		1: "Cotton bales and cotton thread, with some silk.",
+		2: "Silk scarves and silk ties, with some cotton.",
+		3: "Cotton shirts.",
+		4: "Cotton socks.",
+		5: "Cotton sheets.",
+	}
+	for id, text := range docs {
+		if err := x.Add(id, text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Both matches hold one term twice; silk is in fewer documents, so
+	// repeating it counts for more
+	results, err := x.Search("silk cotton", []int64{1, 2, 3, 4, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].DocID != 2 || results[1].DocID != 1 {
+		t.Fatalf("Search(silk cotton) = %+v, want documents 2 and 1", results)
+	}
+	if results[0].Score <= results[1].Score {
+		t.Errorf("scores %v and %v, want document 2 to score higher", results[0].Score, results[1].Score)
+	}
+}
+
+func TestIndexReplaceAndRemove(t *testing.T) {
+	x, err := NewIndex(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := x.Add(1, "old text"); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Add(1, "new text"); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustSearch(t, x, "old", []int64{1}); len(got) != 0 {
+		t.Errorf("replaced document still matches its old text")
+	}
+	if got := mustSearch(t, x, "new", []int64{1}); !got[1] {
+		t.Errorf("replaced document doesn't match its new text")
+	}
+
+	if err := x.Remove(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustSearch(t, x, "text", []int64{1}); len(got) != 0 {
+		t.Errorf("removed document still matches")
+	}
+	if missing, _ := x.Missing([]int64{1}); len(missing) != 1 {
+		t.Errorf("Missing after Remove = %v, want [1]", missing)
+	}
+
+	if err := x.Add(1, "again"); err != nil {
+		t.Fatal(err)
+	}
+	if got := mustSearch(t, x, "again", []int64{1}); !got[1] {
+		t.Errorf("document added after removal doesn't match")
+	}
+}
+
+func TestIndexMerge(t *testing.T) {
+	dir := t.TempDir()
+	x, err := NewIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 250
+	for id := int64(1); id <= n; id++ {
+		if err := x.Add(id, fmt.Sprintf("common doc%d", id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Replace and remove documents that have been merged
+	if err := x.Add(5, "replaced"); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Remove(7); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(x.segments) >= 3*mergeFactor {
+		t.Errorf("%d segments for %d documents, want merged", len(x.segments), n)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if len(files) != len(x.segments) {
+		t.Errorf("%d segment files for %d segments", len(files), len(x.segments))
+	}
+
+	all := make([]int64, 0, n)
+	for id := int64(1); id <= n; id++ {
+		all = append(all, id)
+	}
+	got := mustSearch(t, x, "common", all)
+	if len(got) != n-2 || got[5] || got[7] {
+		t.Errorf("Search(common) found %d documents, want %d without 5 and 7", len(got), n-2)
+	}
+	if got := mustSearch(t, x, "doc123", all); !got[123] || len(got) != 1 {
+		t.Errorf("Search(doc123) = %v", got)
+	}
+	if got := mustSearch(t, x, "replaced", all); !got[5] {
+		t.Errorf("Search(replaced) = %v", got)
+	}
+
+	missing, err := x.Missing(all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 1 || missing[0] != 7 {
+		t.Errorf("Missing = %v, want [7]", missing)
+	}
+}
+
+func TestIndexSharedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id := int64(1); id <= 2*mergeFactor; id++ {
+		writer := a
+		if id%2 == 0 {
+			writer = b
+		}
+		if err := writer.Add(id, "shared"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Remove(1); err != nil {
+		t.Fatal(err)
+	}
+
+	all := make([]int64, 0, 2*mergeFactor)
+	for id := int64(1); id <= 2*mergeFactor; id++ {
+		all = append(all, id)
+	}
+	for name, x := range map[string]*Index{"a": a, "b": b} {
+		if got := mustSearch(t, x, "shared", all); len(got) != 2*mergeFactor-1 || got[1] {
+			t.Errorf("index %s found %d documents, want %d", name, len(got), 2*mergeFactor-1)
+		}
+	}
+
+	reopened, err := NewIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mustSearch(t, reopened, "shared", all); len(got) != 2*mergeFactor-1 {
+		t.Errorf("reopened index found %d documents, want %d", len(got), 2*mergeFactor-1)
+	}
+}
+
+func TestIndexRemovesLegacyFiles(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "a")
+	if err := os.MkdirAll(legacy, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(legacy, "10.idx"), []byte("TXI1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "00000009.seg.tmp1"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewIndex(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{legacy, filepath.Join(dir, "00000009.seg.tmp1")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", path)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package search
+
+import "os"
+
+// lockFile does nothing on platforms without flock, where only one process
+// may write to an index
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile releases the lock taken by lockFile
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,94 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const (
+	manifestFile = "manifest.json"
+	lockFileName = "lock"
+	segmentExt   = ".seg"
+)
+
+// manifest records the state of an index: its segments, oldest first, and
+// the documents removed from them. A document's current version is the one
+// in the newest segment that holds it, unless the document was removed.
+type manifest struct {
+	// Version is incremented by every change, so that a process can tell
+	// whether another one changed the index
+	Version     int64    `json:"version"`
+	NextSegment int64    `json:"next_segment"`
+	Segments    []string `json:"segments"`
+	Removed     []int64  `json:"removed,omitempty"`
+}
+
+// clone returns a copy of the manifest that can be changed independently
+func (m manifest) clone() manifest {
+	m.Segments = slices.Clone(m.Segments)
+	m.Removed = slices.Clone(m.Removed)
+	return m
+}
+
+// newSegment allocates the name of a new segment file
+func (m *manifest) newSegment() string {
+	m.NextSegment++
+	return fmt.Sprintf("%08d%s", m.NextSegment, segmentExt)
+}
+
+// readManifest reads the manifest of the index in dir. A missing manifest
+// is an empty index.
+func readManifest(dir string) (*manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if os.IsNotExist(err) {
+		return &manifest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("corrupt index manifest: %v", err)
+	}
+	return &m, nil
+}
+
+// writeManifest replaces the manifest of the index in dir. The new manifest
+// is written under a temporary name and renamed, so readers see either the
+// old or the new one.
+func writeManifest(dir string, m *manifest) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, manifestFile+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), filepath.Join(dir, manifestFile)); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,364 @@
+package search
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// A segment file holds the inverted index of a set of documents:
+//
+//	header    magic "TXI2", term count n and document count d (uint32),
+//	          offsets of the table, document list and dictionary (uint64),
+//	          all big-endian
+//	postings  per term, per document in ID order: uvarint document ID
+//	          delta, uvarint posting count, and per posting uvarint start
+//	          delta and uvarint length
+//	table     n uint32 offsets of the dictionary entries, in term order
+//	docs      d int64 IDs of the documents, in order
+//	dict      per term: uvarint term length, term bytes, uvarint postings
+//	          offset, uvarint postings size, uvarint document count
+//
+// The offset table allows binary search over the dictionary without
+// reading it, so a lookup costs a few small reads however large the
+// segment is. Postings come first so that they can be written as terms
+// are added, while the dictionary is kept in memory.
+const (
+	segmentMagic      = "TXI2"
+	segmentHeaderSize = 40
+
+	// maxEntrySize bounds the encoded size of a dictionary entry: the
+	// longest term plus four varints
+	maxEntrySize = maxTermLength*4 + 4*binary.MaxVarintLen64
+)
+
+// errCorruptSegment is returned when a segment file cannot be decoded
+var errCorruptSegment = errors.New("corrupt index segment")
+
+// Posting is an occurrence of a term in a document
+type Posting struct {
+	Start  int
+	Length int
+}
+
+// docPostings are the occurrences of a term in one document
+type docPostings struct {
+	docID    int64
+	postings []Posting
+}
+
+// segmentWriter writes a segment file term by term, in term order. The
+// file is written under a temporary name and renamed by finish, so readers
+// never see a partial segment.
+type segmentWriter struct {
+	path     string
+	f        *os.File
+	w        *bufio.Writer
+	offset   int64 // bytes of postings written
+	table    []uint32
+	dict     []byte
+	buf      []byte
+	lastTerm string
+}
+
+// createSegment starts writing a segment file at path
+func createSegment(path string) (*segmentWriter, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return nil, err
+	}
+
+	w := &segmentWriter{path: path, f: f, w: bufio.NewWriter(f)}
+
+	// The header is written last, once the offsets are known
+	if _, err := w.w.Write(make([]byte, segmentHeaderSize)); err != nil {
+		w.abort()
+		return nil, err
+	}
+	return w, nil
+}
+
+// add writes the postings of a term in the given documents, which must be
+// in ID order. Terms must be added in order.
+func (w *segmentWriter) add(term string, docs []docPostings) error {
+	if len(w.table) > 0 && term <= w.lastTerm {
+		return fmt.Errorf("segment terms out of order: %q after %q", term, w.lastTerm)
+	}
+	w.lastTerm = term
+
+	buf := w.buf[:0]
+	var prevDoc int64
+	for _, doc := range docs {
+		buf = binary.AppendUvarint(buf, uint64(doc.docID-prevDoc))
+		buf = binary.AppendUvarint(buf, uint64(len(doc.postings)))
+		prevDoc = doc.docID
+
+		prev := 0
+		for _, p := range doc.postings {
+			buf = binary.AppendUvarint(buf, uint64(p.Start-prev))
+			buf = binary.AppendUvarint(buf, uint64(p.Length))
+			prev = p.Start
+		}
+	}
+	w.buf = buf
+
+	if _, err := w.w.Write(buf); err != nil {
+		return err
+	}
+
+	w.table = append(w.table, uint32(len(w.dict)))
+	w.dict = binary.AppendUvarint(w.dict, uint64(len(term)))
+	w.dict = append(w.dict, term...)
+	w.dict = binary.AppendUvarint(w.dict, uint64(w.offset))
+	w.dict = binary.AppendUvarint(w.dict, uint64(len(buf)))
+	w.dict = binary.AppendUvarint(w.dict, uint64(len(docs)))
+
+	w.offset += int64(len(buf))
+	return nil
+}
+
+// finish writes the dictionary and the list of documents in the segment,
+// which must be in order, and moves the file into place
+func (w *segmentWriter) finish(docIDs []int64) error {
+	tableOffset := segmentHeaderSize + w.offset
+	docsOffset := tableOffset + 4*int64(len(w.table))
+	dictOffset := docsOffset + 8*int64(len(docIDs))
+
+	var entry [8]byte
+	for _, offset := range w.table {
+		binary.BigEndian.PutUint32(entry[:4], offset)
+		w.w.Write(entry[:4])
+	}
+	for _, docID := range docIDs {
+		binary.BigEndian.PutUint64(entry[:], uint64(docID))
+		w.w.Write(entry[:])
+	}
+	w.w.Write(w.dict)
+
+	if err := w.w.Flush(); err != nil {
+		w.abort()
+		return err
+	}
+
+	header := make([]byte, segmentHeaderSize)
+	copy(header, segmentMagic)
+	binary.BigEndian.PutUint32(header[4:], uint32(len(w.table)))
+	binary.BigEndian.PutUint32(header[8:], uint32(len(docIDs)))
+	binary.BigEndian.PutUint64(header[16:], uint64(tableOffset))
+	binary.BigEndian.PutUint64(header[24:], uint64(docsOffset))
+	binary.BigEndian.PutUint64(header[32:], uint64(dictOffset))
+	if _, err := w.f.WriteAt(header, 0); err != nil {
+		w.abort()
+		return err
+	}
+
+	if err := w.f.Sync(); err != nil {
+		w.abort()
+		return err
+	}
+	if err := w.f.Close(); err != nil {
+		os.Remove(w.f.Name())
+		return err
+	}
+
+	if err := os.Rename(w.f.Name(), w.path); err != nil {
+		os.Remove(w.f.Name())
+		return err
+	}
+	return nil
+}
+
+// abort abandons the segment and removes its temporary file
+func (w *segmentWriter) abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
+
+// segmentReader looks up terms in a segment file
+type segmentReader struct {
+	f           *os.File
+	terms       int
+	docs        int
+	tableOffset int64
+	docsOffset  int64
+	dictOffset  int64
+}
+
+// openSegment opens the segment file at path
+func openSegment(path string) (*segmentReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, segmentHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil || string(header[:4]) != segmentMagic {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s: bad header", errCorruptSegment, path)
+	}
+
+	return &segmentReader{
+		f:           f,
+		terms:       int(binary.BigEndian.Uint32(header[4:])),
+		docs:        int(binary.BigEndian.Uint32(header[8:])),
+		tableOffset: int64(binary.BigEndian.Uint64(header[16:])),
+		docsOffset:  int64(binary.BigEndian.Uint64(header[24:])),
+		dictOffset:  int64(binary.BigEndian.Uint64(header[32:])),
+	}, nil
+}
+
+// Close closes the segment file
+func (s *segmentReader) Close() error {
+	return s.f.Close()
+}
+
+// docIDs reads the IDs of the documents in the segment, in order
+func (s *segmentReader) docIDs() ([]int64, error) {
+	buf := make([]byte, 8*s.docs)
+	if _, err := s.f.ReadAt(buf, s.docsOffset); err != nil {
+		return nil, fmt.Errorf("%w: %v", errCorruptSegment, err)
+	}
+
+	ids := make([]int64, s.docs)
+	for i := range ids {
+		ids[i] = int64(binary.BigEndian.Uint64(buf[8*i:]))
+	}
+	return ids, nil
+}
+
+// dictEntry is a decoded dictionary entry
+type dictEntry struct {
+	term           string
+	postingsOffset int64
+	postingsSize   int
+	count          int
+}
+
+// lookup returns the postings of term per document, or nil if no document
+// of the segment contains it
+func (s *segmentReader) lookup(term string) ([]docPostings, error) {
+	var lookupErr error
+	i := sort.Search(s.terms, func(i int) bool {
+		if lookupErr != nil {
+			return true
+		}
+		entry, err := s.entry(i)
+		if err != nil {
+			lookupErr = err
+			return true
+		}
+		return entry.term >= term
+	})
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+
+	if i == s.terms {
+		return nil, nil
+	}
+
+	entry, err := s.entry(i)
+	if err != nil || entry.term != term {
+		return nil, err
+	}
+
+	return s.postings(entry)
+}
+
+// entry reads the i-th dictionary entry
+func (s *segmentReader) entry(i int) (*dictEntry, error) {
+	var offsetBuf [4]byte
+	if _, err := s.f.ReadAt(offsetBuf[:], s.tableOffset+4*int64(i)); err != nil {
+		return nil, fmt.Errorf("%w: %v", errCorruptSegment, err)
+	}
+	offset := s.dictOffset + int64(binary.BigEndian.Uint32(offsetBuf[:]))
+
+	buf := make([]byte, maxEntrySize)
+	n, err := s.f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	buf = buf[:n]
+
+	termLen, k := binary.Uvarint(buf)
+	if k <= 0 || uint64(len(buf)-k) < termLen {
+		return nil, errCorruptSegment
+	}
+	buf = buf[k:]
+	entry := &dictEntry{term: string(buf[:termLen])}
+	buf = buf[termLen:]
+
+	values := make([]uint64, 3)
+	for j := range values {
+		v, k := binary.Uvarint(buf)
+		if k <= 0 {
+			return nil, errCorruptSegment
+		}
+		values[j] = v
+		buf = buf[k:]
+	}
+
+	entry.postingsOffset = segmentHeaderSize + int64(values[0])
+	entry.postingsSize = int(values[1])
+	entry.count = int(values[2])
+
+	return entry, nil
+}
+
+// postings reads and decodes the postings of a dictionary entry
+func (s *segmentReader) postings(entry *dictEntry) ([]docPostings, error) {
+	buf := make([]byte, entry.postingsSize)
+	if _, err := s.f.ReadAt(buf, entry.postingsOffset); err != nil {
+		return nil, fmt.Errorf("%w: %v", errCorruptSegment, err)
+	}
+
+	next := func() (uint64, error) {
+		v, k := binary.Uvarint(buf)
+		if k <= 0 {
+			return 0, errCorruptSegment
+		}
+		buf = buf[k:]
+		return v, nil
+	}
+
+	docs := make([]docPostings, 0, entry.count)
+	var docID int64
+	for len(buf) > 0 {
+		delta, err := next()
+		if err != nil {
+			return nil, err
+		}
+		count, err := next()
+		if err != nil {
+			return nil, err
+		}
+		if count > uint64(len(buf)) {
+			return nil, errCorruptSegment
+		}
+
+		docID += int64(delta)
+		doc := docPostings{docID: docID, postings: make([]Posting, 0, count)}
+		start := 0
+		for j := uint64(0); j < count; j++ {
+			startDelta, err := next()
+			if err != nil {
+				return nil, err
+			}
+			length, err := next()
+			if err != nil {
+				return nil, err
+			}
+
+			start += int(startDelta)
+			doc.postings = append(doc.postings, Posting{Start: start, Length: int(length)})
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
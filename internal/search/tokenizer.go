@@ -0,0 +1,111 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxTermLength is the longest word, in characters, that is indexed.
+// Longer runs of letters are usually encoded data rather than words.
+const maxTermLength = 64
+
+// Token is a term of a text and the characters it was derived from.
+// Start and Length count Unicode characters.
+type Token struct {
+	Term   string
+	Start  int
+	Length int
+}
+
+// Tokenize splits text into search terms. Words in alphabetic scripts are
+// lowercased, with full-width letters and digits folded to ASCII. Chinese,
+// Japanese and Korean text has no spaces between words, so every character
+// is indexed on its own and together with the character that follows it.
+func Tokenize(text string) []Token {
+	return tokenize(text, true)
+}
+
+// tokenizeQuery splits a search query into terms. Runs of CJK characters
+// yield only their bigrams, which suffice to find them; a lone CJK character
+// yields itself.
+func tokenizeQuery(query string) []Token {
+	return tokenize(query, false)
+}
+
+// tokenize implements Tokenize and tokenizeQuery. With unigrams set, every
+// CJK character is emitted in addition to the bigrams.
+func tokenize(text string, unigrams bool) []Token {
+	var tokens []Token
+
+	var word strings.Builder
+	wordStart, wordLen := 0, 0
+
+	var run []rune // current run of CJK characters
+	runStart := 0
+
+	flushWord := func() {
+		if wordLen > 0 && wordLen <= maxTermLength {
+			tokens = append(tokens, Token{Term: word.String(), Start: wordStart, Length: wordLen})
+		}
+		word.Reset()
+		wordLen = 0
+	}
+
+	flushRun := func() {
+		for i := range run {
+			if unigrams || len(run) == 1 {
+				tokens = append(tokens, Token{Term: string(run[i]), Start: runStart + i, Length: 1})
+			}
+			if i+1 < len(run) {
+				tokens = append(tokens, Token{Term: string(run[i : i+2]), Start: runStart + i, Length: 2})
+			}
+		}
+		run = run[:0]
+	}
+
+	pos := 0
+	for _, r := range text {
+		r = foldRune(r)
+
+		switch {
+		case isCJKLetter(r):
+			flushWord()
+			if len(run) == 0 {
+				runStart = pos
+			}
+			run = append(run, r)
+
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			flushRun()
+			if wordLen == 0 {
+				wordStart = pos
+			}
+			word.WriteRune(unicode.ToLower(r))
+			wordLen++
+
+		default:
+			flushWord()
+			flushRun()
+		}
+
+		pos++
+	}
+
+	flushWord()
+	flushRun()
+
+	return tokens
+}
+
+// foldRune maps full-width ASCII variants, common in CJK text, to ASCII
+func foldRune(r rune) rune {
+	if r >= 0xFF01 && r <= 0xFF5E {
+		return r - 0xFEE0
+	}
+	return r
+}
+
+// isCJKLetter reports whether r is a CJK ideograph, kana or hangul syllable
+func isCJKLetter(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
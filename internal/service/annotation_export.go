@@ -0,0 +1,70 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"textile-admin/internal/domain/entity"
+	"time"
+)
+
+// RenderAnnotationsMarkdown formats an annotation export as a Markdown
+// document with one section per task
+func RenderAnnotationsMarkdown(export *entity.AnnotationExport) string {
+	var b strings.Builder
+
+	b.WriteString("# Reading Notes\n\n")
+	fmt.Fprintf(&b, "Exported %s\n", export.ExportedAt.Format(time.RFC3339))
+
+	for _, group := range export.Tasks {
+		title := group.FileName
+		if title == "" {
+			title = fmt.Sprintf("Task %d", group.TaskID)
+		}
+		fmt.Fprintf(&b, "\n## %s\n", title)
+
+		if len(group.Bookmarks) > 0 {
+			b.WriteString("\n### Bookmarks\n\n")
+			for _, bookmark := range group.Bookmarks {
+				fmt.Fprintf(&b, "- %s", markdownLocation(&bookmark.Anchor))
+				if bookmark.Title != "" {
+					fmt.Fprintf(&b, ": %s", bookmark.Title)
+				}
+				b.WriteString("\n")
+			}
+		}
+
+		if len(group.Annotations) > 0 {
+			b.WriteString("\n### Highlights\n")
+			for _, annotation := range group.Annotations {
+				b.WriteString("\n")
+				for _, line := range strings.Split(strings.TrimRight(annotation.Quote, "\n"), "\n") {
+					fmt.Fprintf(&b, "> %s\n", line)
+				}
+				if annotation.Note != "" {
+					fmt.Fprintf(&b, "\n%s\n", annotation.Note)
+				}
+				fmt.Fprintf(&b, "\n*%s*\n", markdownLocation(&annotation.Anchor))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// markdownLocation describes where an anchor lies, by chapter when known
+func markdownLocation(anchor *entity.Anchor) string {
+	var location string
+	switch {
+	case anchor.ChapterTitle != "":
+		location = anchor.ChapterTitle
+	case anchor.Chapter > 0:
+		location = fmt.Sprintf("Chapter %d", anchor.Chapter)
+	default:
+		location = "Position"
+	}
+
+	if anchor.EndOffset > anchor.StartOffset {
+		return fmt.Sprintf("%s, characters %d–%d", location, anchor.StartOffset, anchor.EndOffset)
+	}
+	return fmt.Sprintf("%s, character %d", location, anchor.StartOffset)
+}
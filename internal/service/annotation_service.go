@@ -0,0 +1,307 @@
+package service
+
+import (
+	"fmt"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+	"time"
+)
+
+// maxAnnotationLength limits how many characters one annotation may highlight
+const maxAnnotationLength = 10000
+
+// AnnotationService handles the business logic for bookmarks and annotations
+type AnnotationService struct {
+	repo      *repository.AnnotationRepository
+	tasksRepo *repository.ReadingRepository
+}
+
+// NewAnnotationService creates a new instance of AnnotationService
+func NewAnnotationService(repo *repository.AnnotationRepository, tasksRepo *repository.ReadingRepository) *AnnotationService {
+	return &AnnotationService{
+		repo:      repo,
+		tasksRepo: tasksRepo,
+	}
+}
+
+// GetTaskAnnotations retrieves a user's bookmarks and annotations on a task
+func (s *AnnotationService) GetTaskAnnotations(userID, taskID int64) (*entity.AnnotationsResponse, error) {
+	task, err := s.tasksRepo.ForOwner(userID).GetTaskByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Other users' tasks are reported as missing
+	if task == nil || task.UserID != userID {
+		return nil, ErrTaskNotFound
+	}
+
+	bookmarks, err := s.repo.GetBookmarks(userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations, err := s.repo.GetAnnotations(userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.AnnotationsResponse{
+		TaskID:      taskID,
+		Bookmarks:   bookmarks,
+		Annotations: annotations,
+	}, nil
+}
+
+// CreateBookmark adds a bookmark to a task. An end offset before the start
+// offset is moved to the start, so a bookmark may mark a single position.
+func (s *AnnotationService) CreateBookmark(bookmark *entity.Bookmark) error {
+	if bookmark.EndOffset < bookmark.StartOffset {
+		bookmark.EndOffset = bookmark.StartOffset
+	}
+
+	if _, err := s.resolveAnchor(bookmark.UserID, bookmark.TaskID, &bookmark.Anchor, false); err != nil {
+		return err
+	}
+
+	return s.repo.CreateBookmark(bookmark)
+}
+
+// GetBookmark retrieves one of a user's bookmarks on a task
+func (s *AnnotationService) GetBookmark(userID, taskID, bookmarkID int64) (*entity.Bookmark, error) {
+	bookmark, err := s.repo.GetBookmarkByID(bookmarkID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Other users' bookmarks are reported as missing
+	if bookmark == nil || bookmark.UserID != userID || bookmark.TaskID != taskID {
+		return nil, ErrBookmarkNotFound
+	}
+
+	return bookmark, nil
+}
+
+// UpdateBookmark saves a bookmark changed by its owner
+func (s *AnnotationService) UpdateBookmark(bookmark *entity.Bookmark) error {
+	if bookmark.EndOffset < bookmark.StartOffset {
+		bookmark.EndOffset = bookmark.StartOffset
+	}
+
+	if _, err := s.resolveAnchor(bookmark.UserID, bookmark.TaskID, &bookmark.Anchor, false); err != nil {
+		return err
+	}
+
+	bookmark.UpdatedAt = time.Now()
+	return s.repo.UpdateBookmark(bookmark)
+}
+
+// DeleteBookmark deletes one of a user's bookmarks on a task
+func (s *AnnotationService) DeleteBookmark(userID, taskID, bookmarkID int64) error {
+	if _, err := s.GetBookmark(userID, taskID, bookmarkID); err != nil {
+		return err
+	}
+
+	return s.repo.DeleteBookmark(bookmarkID)
+}
+
+// CreateAnnotation highlights a passage of a task. The highlighted text is
+// stored with the annotation as its quote.
+func (s *AnnotationService) CreateAnnotation(annotation *entity.Annotation) error {
+	quote, err := s.resolveHighlight(annotation.UserID, annotation.TaskID, &annotation.Anchor)
+	if err != nil {
+		return err
+	}
+
+	annotation.Quote = quote
+	return s.repo.CreateAnnotation(annotation)
+}
+
+// GetAnnotation retrieves one of a user's annotations on a task
+func (s *AnnotationService) GetAnnotation(userID, taskID, annotationID int64) (*entity.Annotation, error) {
+	annotation, err := s.repo.GetAnnotationByID(annotationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Other users' annotations are reported as missing
+	if annotation == nil || annotation.UserID != userID || annotation.TaskID != taskID {
+		return nil, ErrAnnotationNotFound
+	}
+
+	return annotation, nil
+}
+
+// UpdateAnnotation saves an annotation changed by its owner, refreshing its
+// quote from the anchor
+func (s *AnnotationService) UpdateAnnotation(annotation *entity.Annotation) error {
+	quote, err := s.resolveHighlight(annotation.UserID, annotation.TaskID, &annotation.Anchor)
+	if err != nil {
+		return err
+	}
+
+	annotation.Quote = quote
+	annotation.UpdatedAt = time.Now()
+	return s.repo.UpdateAnnotation(annotation)
+}
+
+// DeleteAnnotation deletes one of a user's annotations on a task
+func (s *AnnotationService) DeleteAnnotation(userID, taskID, annotationID int64) error {
+	if _, err := s.GetAnnotation(userID, taskID, annotationID); err != nil {
+		return err
+	}
+
+	return s.repo.DeleteAnnotation(annotationID)
+}
+
+// ExportAnnotations collects a user's bookmarks and annotations grouped by
+// task. A taskID of 0 exports all tasks.
+func (s *AnnotationService) ExportAnnotations(userID, taskID int64) (*entity.AnnotationExport, error) {
+	if taskID != 0 {
+		task, err := s.tasksRepo.ForOwner(userID).GetTaskByID(taskID)
+		if err != nil {
+			return nil, err
+		}
+		if task == nil || task.UserID != userID {
+			return nil, ErrTaskNotFound
+		}
+	}
+
+	bookmarks, err := s.repo.GetBookmarks(userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations, err := s.repo.GetAnnotations(userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &entity.AnnotationExport{
+		UserID:     userID,
+		ExportedAt: time.Now(),
+		Tasks:      []*entity.TaskAnnotationGroup{},
+	}
+
+	groups := make(map[int64]*entity.TaskAnnotationGroup)
+	group := func(taskID int64) *entity.TaskAnnotationGroup {
+		if g, ok := groups[taskID]; ok {
+			return g
+		}
+		g := &entity.TaskAnnotationGroup{
+			TaskID:      taskID,
+			Bookmarks:   []*entity.Bookmark{},
+			Annotations: []*entity.Annotation{},
+		}
+		groups[taskID] = g
+		export.Tasks = append(export.Tasks, g)
+		return g
+	}
+
+	for _, bookmark := range bookmarks {
+		g := group(bookmark.TaskID)
+		g.Bookmarks = append(g.Bookmarks, bookmark)
+	}
+	for _, annotation := range annotations {
+		g := group(annotation.TaskID)
+		g.Annotations = append(g.Annotations, annotation)
+	}
+
+	for _, g := range export.Tasks {
+		task, err := s.tasksRepo.ForOwner(userID).GetTaskByID(g.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		if task != nil {
+			g.FileName = task.FileName
+		}
+
+		chapters, err := s.tasksRepo.ForOwner(userID).GetTaskChapters(g.TaskID)
+		if err != nil {
+			return nil, err
+		}
+
+		titles := make(map[int]string, len(chapters))
+		for _, chapter := range chapters {
+			titles[chapter.Ordinal] = chapter.Title
+		}
+		for _, bookmark := range g.Bookmarks {
+			bookmark.ChapterTitle = titles[bookmark.Chapter]
+		}
+		for _, annotation := range g.Annotations {
+			annotation.ChapterTitle = titles[annotation.Chapter]
+		}
+	}
+
+	return export, nil
+}
+
+// resolveHighlight validates the anchor of an annotation and returns the
+// highlighted text
+func (s *AnnotationService) resolveHighlight(userID, taskID int64, anchor *entity.Anchor) (string, error) {
+	if anchor.EndOffset <= anchor.StartOffset {
+		return "", fmt.Errorf("%w: end_offset must be greater than start_offset", ErrInvalidAnchor)
+	}
+
+	if anchor.EndOffset-anchor.StartOffset > maxAnnotationLength {
+		return "", fmt.Errorf("%w: annotations may highlight at most %d characters", ErrInvalidAnchor, maxAnnotationLength)
+	}
+
+	return s.resolveAnchor(userID, taskID, anchor, true)
+}
+
+// resolveAnchor checks that anchor lies within the extracted text of a task
+// of the user and, if withText is set, returns the text it spans. A chapter
+// of 0 is filled in from the offsets.
+func (s *AnnotationService) resolveAnchor(userID, taskID int64, anchor *entity.Anchor, withText bool) (string, error) {
+	task, err := s.tasksRepo.ForOwner(userID).GetTaskByID(taskID)
+	if err != nil {
+		return "", err
+	}
+
+	// Other users' tasks are reported as missing
+	if task == nil || task.UserID != userID {
+		return "", ErrTaskNotFound
+	}
+
+	if anchor.StartOffset < 0 || anchor.EndOffset < anchor.StartOffset {
+		return "", fmt.Errorf("%w: offsets must satisfy 0 <= start_offset <= end_offset", ErrInvalidAnchor)
+	}
+
+	if anchor.Chapter < 0 || (anchor.Chapter > task.ChapterCount && task.ChapterCount > 0) {
+		return "", fmt.Errorf("%w: chapter must be between 0 and %d", ErrInvalidAnchor, task.ChapterCount)
+	}
+
+	length := 0
+	if withText {
+		length = anchor.EndOffset - anchor.StartOffset
+	}
+
+	content, err := s.tasksRepo.ForOwner(userID).GetTaskContentPage(taskID, anchor.StartOffset, length)
+	if err != nil {
+		return "", err
+	}
+
+	if content == nil {
+		return "", ErrContentNotAvailable
+	}
+
+	if anchor.EndOffset > content.CharCount {
+		return "", fmt.Errorf("%w: end_offset exceeds the text length of %d", ErrInvalidAnchor, content.CharCount)
+	}
+
+	if anchor.Chapter == 0 {
+		chapters, err := s.tasksRepo.ForOwner(userID).GetTaskChapters(taskID)
+		if err != nil {
+			return "", err
+		}
+		for _, chapter := range chapters {
+			if anchor.StartOffset >= chapter.StartOffset && anchor.StartOffset < chapter.EndOffset {
+				anchor.Chapter = chapter.Ordinal
+				break
+			}
+		}
+	}
+
+	return content.Content, nil
+}
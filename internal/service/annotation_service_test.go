@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// annotatedText has two chapters, at characters 0–31 and 31–55
+const annotatedText = "Chapter 1\nIt was a dark night.\nChapter 2\nThe sun rose.\n"
+
+// newTestAnnotationService returns an annotation service and a processed
+// task of annotatedText owned by the returned user
+func newTestAnnotationService(t *testing.T) (*AnnotationService, *gorm.DB, *entity.User, int64) {
+	t.Helper()
+
+	readingService, db := newTestReadingService(t, funcProcessor(readText), RetryPolicy{MaxAttempts: 1})
+	reader := createTestUser(t, db, entity.DefaultOrgID, "reader@example.com")
+	taskID := uploadTestFile(t, readingService, reader, "night.txt", annotatedText).TaskID
+	processTestTask(t, readingService, taskID)
+
+	s := NewAnnotationService(repository.NewAnnotationRepository(db), readingService.repo)
+	return s, db, reader, taskID
+}
+
+func TestCreateAnnotation(t *testing.T) {
+	s, _, reader, taskID := newTestAnnotationService(t)
+
+	annotation := &entity.Annotation{UserID: reader.ID, TaskID: taskID, Anchor: entity.Anchor{StartOffset: 19, EndOffset: 29}, Note: "ominous"}
+	if err := s.CreateAnnotation(annotation); err != nil {
+		t.Fatalf("CreateAnnotation() = %v", err)
+	}
+	if annotation.Quote != "dark night" || annotation.Chapter != 1 {
+		t.Errorf("annotation quote %q in chapter %d, want \"dark night\" in chapter 1", annotation.Quote, annotation.Chapter)
+	}
+
+	annotation.StartOffset, annotation.EndOffset = 41, 48
+	annotation.Chapter = 0
+	if err := s.UpdateAnnotation(annotation); err != nil {
+		t.Fatalf("UpdateAnnotation() = %v", err)
+	}
+	stored, err := s.GetAnnotation(reader.ID, taskID, annotation.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Quote != "The sun" || stored.Chapter != 2 || stored.Note != "ominous" {
+		t.Errorf("updated annotation = %+v, want the quote \"The sun\" in chapter 2", stored)
+	}
+
+	tests := []struct {
+		name   string
+		anchor entity.Anchor
+	}{
+		{"empty highlight", entity.Anchor{StartOffset: 19, EndOffset: 19}},
+		{"reversed offsets", entity.Anchor{StartOffset: 29, EndOffset: 19}},
+		{"negative offset", entity.Anchor{StartOffset: -1, EndOffset: 4}},
+		{"past the end of the text", entity.Anchor{StartOffset: 50, EndOffset: 56}},
+		{"unknown chapter", entity.Anchor{Chapter: 3, StartOffset: 0, EndOffset: 4}},
+		{"too long", entity.Anchor{StartOffset: 0, EndOffset: maxAnnotationLength + 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.CreateAnnotation(&entity.Annotation{UserID: reader.ID, TaskID: taskID, Anchor: tt.anchor})
+			if !errors.Is(err, ErrInvalidAnchor) {
+				t.Errorf("CreateAnnotation() = %v, want ErrInvalidAnchor", err)
+			}
+		})
+	}
+}
+
+func TestCreateBookmark(t *testing.T) {
+	s, _, reader, taskID := newTestAnnotationService(t)
+
+	// A bookmark may mark a single position
+	bookmark := &entity.Bookmark{UserID: reader.ID, TaskID: taskID, Anchor: entity.Anchor{StartOffset: 45}, Title: "Morning"}
+	if err := s.CreateBookmark(bookmark); err != nil {
+		t.Fatalf("CreateBookmark() = %v", err)
+	}
+	if bookmark.EndOffset != 45 || bookmark.Chapter != 2 {
+		t.Errorf("bookmark ends at %d in chapter %d, want 45 in chapter 2", bookmark.EndOffset, bookmark.Chapter)
+	}
+
+	if err := s.CreateBookmark(&entity.Bookmark{UserID: reader.ID, TaskID: taskID, Anchor: entity.Anchor{StartOffset: 60}}); !errors.Is(err, ErrInvalidAnchor) {
+		t.Errorf("CreateBookmark() past the end of the text = %v, want ErrInvalidAnchor", err)
+	}
+
+	annotations, err := s.GetTaskAnnotations(reader.ID, taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(annotations.Bookmarks) != 1 || annotations.Bookmarks[0].Title != "Morning" || len(annotations.Annotations) != 0 {
+		t.Errorf("GetTaskAnnotations() = %+v, want the one bookmark", annotations)
+	}
+
+	if err := s.DeleteBookmark(reader.ID, taskID, bookmark.ID); err != nil {
+		t.Fatalf("DeleteBookmark() = %v", err)
+	}
+	if _, err := s.GetBookmark(reader.ID, taskID, bookmark.ID); !errors.Is(err, ErrBookmarkNotFound) {
+		t.Errorf("GetBookmark() after DeleteBookmark() = %v, want ErrBookmarkNotFound", err)
+	}
+}
+
+func TestAnnotationsOfAnotherUser(t *testing.T) {
+	s, db, reader, taskID := newTestAnnotationService(t)
+	other := createTestUser(t, db, entity.DefaultOrgID, "other@example.com")
+
+	bookmark := &entity.Bookmark{UserID: reader.ID, TaskID: taskID, Anchor: entity.Anchor{StartOffset: 10}}
+	if err := s.CreateBookmark(bookmark); err != nil {
+		t.Fatal(err)
+	}
+	annotation := &entity.Annotation{UserID: reader.ID, TaskID: taskID, Anchor: entity.Anchor{StartOffset: 10, EndOffset: 12}}
+	if err := s.CreateAnnotation(annotation); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.GetTaskAnnotations(other.ID, taskID); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("GetTaskAnnotations() = %v, want ErrTaskNotFound", err)
+	}
+	if err := s.CreateBookmark(&entity.Bookmark{UserID: other.ID, TaskID: taskID, Anchor: entity.Anchor{StartOffset: 10}}); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("CreateBookmark() = %v, want ErrTaskNotFound", err)
+	}
+	if _, err := s.GetBookmark(other.ID, taskID, bookmark.ID); !errors.Is(err, ErrBookmarkNotFound) {
+		t.Errorf("GetBookmark() = %v, want ErrBookmarkNotFound", err)
+	}
+	if err := s.DeleteAnnotation(other.ID, taskID, annotation.ID); !errors.Is(err, ErrAnnotationNotFound) {
+		t.Errorf("DeleteAnnotation() = %v, want ErrAnnotationNotFound", err)
+	}
+	if _, err := s.ExportAnnotations(other.ID, taskID); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("ExportAnnotations() = %v, want ErrTaskNotFound", err)
+	}
+
+	// The owner's annotations are untouched
+	if _, err := s.GetAnnotation(reader.ID, taskID, annotation.ID); err != nil {
+		t.Errorf("GetAnnotation() by the owner = %v", err)
+	}
+}
+
+func TestAnnotationsBeforeProcessing(t *testing.T) {
+	readingService, db := newTestReadingService(t, funcProcessor(readText), RetryPolicy{MaxAttempts: 1})
+	reader := createTestUser(t, db, entity.DefaultOrgID, "reader@example.com")
+	taskID := uploadTestFile(t, readingService, reader, "night.txt", annotatedText).TaskID
+	s := NewAnnotationService(repository.NewAnnotationRepository(db), readingService.repo)
+
+	err := s.CreateBookmark(&entity.Bookmark{UserID: reader.ID, TaskID: taskID, Anchor: entity.Anchor{StartOffset: 10}})
+	if !errors.Is(err, ErrContentNotAvailable) {
+		t.Errorf("CreateBookmark() = %v, want ErrContentNotAvailable", err)
+	}
+}
+
+func TestExportAnnotations(t *testing.T) {
+	s, _, reader, taskID := newTestAnnotationService(t)
+
+	if err := s.CreateBookmark(&entity.Bookmark{UserID: reader.ID, TaskID: taskID, Anchor: entity.Anchor{StartOffset: 41}, Title: "Dawn"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateAnnotation(&entity.Annotation{UserID: reader.ID, TaskID: taskID, Anchor: entity.Anchor{StartOffset: 19, EndOffset: 29}, Note: "ominous"}); err != nil {
+		t.Fatal(err)
+	}
+
+	export, err := s.ExportAnnotations(reader.ID, 0)
+	if err != nil {
+		t.Fatalf("ExportAnnotations() = %v", err)
+	}
+	if len(export.Tasks) != 1 {
+		t.Fatalf("exported %d tasks, want 1", len(export.Tasks))
+	}
+	group := export.Tasks[0]
+	if group.FileName != "night.txt" || len(group.Bookmarks) != 1 || len(group.Annotations) != 1 {
+		t.Fatalf("exported group = %+v", group)
+	}
+	if group.Bookmarks[0].ChapterTitle != "Chapter 2" || group.Annotations[0].ChapterTitle != "Chapter 1" {
+		t.Errorf("chapter titles %q and %q, want Chapter 2 and Chapter 1",
+			group.Bookmarks[0].ChapterTitle, group.Annotations[0].ChapterTitle)
+	}
+
+	markdown := RenderAnnotationsMarkdown(export)
+	for _, want := range []string{
+		"## night.txt\n",
+		"- Chapter 2, character 41: Dawn\n",
+		"> dark night\n\nominous\n\n*Chapter 1, characters 19–29*\n",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("markdown lacks %q:\n%s", want, markdown)
+		}
+	}
+}
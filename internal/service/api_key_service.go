@@ -0,0 +1,201 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+	"time"
+)
+
+const (
+	// apiKeyBytes is the number of random bytes in a key
+	apiKeyBytes = 32
+	// apiKeyShownPrefix is the number of leading characters of a key kept
+	// in the clear, so that users can tell their keys apart
+	apiKeyShownPrefix = 12
+	// maxAPIKeyName is the maximum length of a key's name
+	maxAPIKeyName = 128
+	// apiKeyTouchInterval limits how often a key's last-used time is
+	// written, so that busy clients do not cause a write per request
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeyService handles the creation, revocation and checking of API
+// keys. Keys are random, so they are stored as plain SHA-256 hashes.
+type APIKeyService struct {
+	repo     *repository.APIKeyRepository
+	userRepo *repository.UserRepository
+}
+
+// NewAPIKeyService creates a new instance of APIKeyService
+func NewAPIKeyService(repo *repository.APIKeyRepository, userRepo *repository.UserRepository) *APIKeyService {
+	return &APIKeyService{repo: repo, userRepo: userRepo}
+}
+
+// CreateAPIKey creates a key owned by requester. expiresAt may be nil for
+// a key that does not expire.
+func (s *APIKeyService) CreateAPIKey(requester *entity.User, name string, scopes []string, expiresAt *time.Time) (*entity.CreatedAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxAPIKeyName {
+		return nil, fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidAPIKey, maxAPIKeyName)
+	}
+
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKey)
+	}
+
+	secret := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	key := entity.APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	record := &entity.APIKey{
+		UserID:    requester.ID,
+		Name:      name,
+		Prefix:    key[:apiKeyShownPrefix],
+		KeyHash:   hashAPIKey(key),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.repo.CreateAPIKey(record); err != nil {
+		return nil, err
+	}
+
+	return &entity.CreatedAPIKey{APIKey: record, Key: key}, nil
+}
+
+// ListAPIKeys returns the keys of requester, including revoked and
+// expired ones
+func (s *APIKeyService) ListAPIKeys(requester *entity.User) ([]*entity.APIKey, error) {
+	keys, err := s.repo.GetAPIKeysByUserID(requester.ID)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		keys = []*entity.APIKey{}
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes a key. Users can revoke their own keys; revoking
+// the keys of other users in their organization requires PermUsersManage.
+// Keys they may not revoke are reported as missing. Revoking a revoked key
+// changes nothing.
+func (s *APIKeyService) RevokeAPIKey(requester *entity.User, keyID int64) (*entity.APIKey, error) {
+	key, err := s.repo.GetAPIKeyByID(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	if key.UserID != requester.ID {
+		if !requester.HasPermission(entity.PermUsersManage) {
+			return nil, ErrAPIKeyNotFound
+		}
+
+		owner, err := s.userRepo.GetUserByID(key.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if owner == nil || owner.OrgID != requester.OrgID {
+			return nil, ErrAPIKeyNotFound
+		}
+	}
+
+	if key.RevokedAt == nil {
+		now := time.Now()
+		if _, err := s.repo.RevokeAPIKey(key.ID, now); err != nil {
+			return nil, err
+		}
+		key.RevokedAt = &now
+	}
+
+	return key, nil
+}
+
+// Authenticate checks an API key and returns the key and its owner. It
+// returns ErrInvalidToken for unknown, revoked and expired keys.
+func (s *APIKeyService) Authenticate(key string) (*entity.User, *entity.APIKey, error) {
+	if !strings.HasPrefix(key, entity.APIKeyPrefix) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	record, err := s.repo.GetAPIKeyByHash(hashAPIKey(key))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if record == nil || !record.Usable(now) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetUserByID(record.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, ErrInvalidToken
+	}
+
+	if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) >= apiKeyTouchInterval {
+		// A failed write only loses the last-used time; it must not
+		// fail the request
+		if err := s.repo.TouchAPIKey(record.ID, now); err != nil {
+			log.Printf("Failed to record use of API key %d: %v", record.ID, err)
+		} else {
+			record.LastUsedAt = &now
+		}
+	}
+
+	return user, record, nil
+}
+
+// normalizeScopes checks that scopes is a non-empty list of known scopes
+// and removes duplicates
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKey)
+	}
+
+	known := make(map[string]bool, len(entity.APIKeyScopes))
+	for _, scope := range entity.APIKeyScopes {
+		known[scope] = true
+	}
+
+	seen := make(map[string]bool, len(scopes))
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if !known[scope] {
+			return nil, fmt.Errorf("%w: unknown scope %q, expected one of %s", ErrInvalidAPIKey, scope, strings.Join(entity.APIKeyScopes, ", "))
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+
+	return normalized, nil
+}
+
+// hashAPIKey returns the hex SHA-256 hash a key is stored under
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+	"time"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  []string
+		want    []string
+		wantErr bool
+	}{
+		{"one scope", []string{entity.ScopeTasksRead}, []string{entity.ScopeTasksRead}, false},
+		{"duplicates and spaces", []string{" tasks:read", "tasks:write", "tasks:read "}, []string{entity.ScopeTasksRead, entity.ScopeTasksWrite}, false},
+		{"no scopes", nil, nil, true},
+		{"unknown scope", []string{entity.ScopeTasksRead, "users:manage"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeScopes(tt.scopes)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidAPIKey) {
+					t.Errorf("normalizeScopes(%q) = %v, %v; want ErrInvalidAPIKey", tt.scopes, got, err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeScopes(%q) = %v, %v; want %v", tt.scopes, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	db := openTestDB(t)
+	s := NewAPIKeyService(repository.NewAPIKeyRepository(db), repository.NewUserRepository(db))
+	user := createTestUser(t, db, entity.DefaultOrgID, "script@example.com")
+	scopes := []string{entity.ScopeTasksRead}
+
+	if _, err := s.CreateAPIKey(user, " ", scopes, nil); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("CreateAPIKey() without a name = %v, want ErrInvalidAPIKey", err)
+	}
+	past := time.Now().Add(-time.Minute)
+	if _, err := s.CreateAPIKey(user, "nightly", scopes, &past); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("CreateAPIKey() expiring in the past = %v, want ErrInvalidAPIKey", err)
+	}
+
+	created, err := s.CreateAPIKey(user, "nightly", scopes, nil)
+	if err != nil {
+		t.Fatalf("CreateAPIKey() = %v", err)
+	}
+	if !strings.HasPrefix(created.Key, entity.APIKeyPrefix) || !strings.HasPrefix(created.Key, created.Prefix) || created.KeyHash == created.Key {
+		t.Errorf("created key %q with prefix %q and hash %q", created.Key, created.Prefix, created.KeyHash)
+	}
+
+	owner, key, err := s.Authenticate(created.Key)
+	if err != nil {
+		t.Fatalf("Authenticate() = %v", err)
+	}
+	if owner.ID != user.ID || key.ID != created.ID || key.LastUsedAt == nil {
+		t.Errorf("Authenticate() = user %d, key %+v; want user %d and key %d marked used", owner.ID, key, user.ID, created.ID)
+	}
+
+	for _, bad := range []string{created.Key + "x", strings.TrimPrefix(created.Key, entity.APIKeyPrefix), ""} {
+		if _, _, err := s.Authenticate(bad); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Authenticate(%q) = %v, want ErrInvalidToken", bad, err)
+		}
+	}
+
+	if _, err := s.RevokeAPIKey(user, created.ID); err != nil {
+		t.Fatalf("RevokeAPIKey() = %v", err)
+	}
+	if _, _, err := s.Authenticate(created.Key); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Authenticate() of a revoked key = %v, want ErrInvalidToken", err)
+	}
+
+	soon := time.Now().Add(time.Hour)
+	expiring, err := s.CreateAPIKey(user, "short-lived", scopes, &soon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(expiring.APIKey).Update("expires_at", past).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Authenticate(expiring.Key); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Authenticate() of an expired key = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	db := openTestDB(t)
+	s := NewAPIKeyService(repository.NewAPIKeyRepository(db), repository.NewUserRepository(db))
+	owner := createTestUser(t, db, entity.DefaultOrgID, "owner@example.com")
+	other := createTestUser(t, db, entity.DefaultOrgID, "other@example.com")
+	admin := createTestUser(t, db, entity.DefaultOrgID, "admin@example.com", entity.RoleAdmin)
+	outsider := createTestUser(t, db, createTestOrg(t, db, "Other"), "outsider@example.com", entity.RoleAdmin)
+
+	created, err := s.CreateAPIKey(owner, "nightly", []string{entity.ScopeTasksRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.RevokeAPIKey(other, created.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("RevokeAPIKey() by another user = %v, want ErrAPIKeyNotFound", err)
+	}
+	if _, err := s.RevokeAPIKey(outsider, created.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("RevokeAPIKey() by an admin of another organization = %v, want ErrAPIKeyNotFound", err)
+	}
+
+	revoked, err := s.RevokeAPIKey(admin, created.ID)
+	if err != nil || revoked.RevokedAt == nil {
+		t.Fatalf("RevokeAPIKey() by an admin = %+v, %v", revoked, err)
+	}
+
+	// Revoking again keeps the original time
+	again, err := s.RevokeAPIKey(owner, created.ID)
+	if err != nil || again.RevokedAt == nil || again.RevokedAt.Sub(*revoked.RevokedAt).Abs() > time.Second {
+		t.Errorf("RevokeAPIKey() of a revoked key = %+v, %v; want it revoked at %v", again, err, revoked.RevokedAt)
+	}
+
+	keys, err := s.ListAPIKeys(owner)
+	if err != nil || len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("ListAPIKeys() = %+v, %v; want the revoked key", keys, err)
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+	"textile-admin/pkg/jwt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Token types, carried in the typ claim so that a refresh token cannot be
+// used as an access token or the other way round
+const (
+	accessTokenType  = "access"
+	refreshTokenType = "refresh"
+)
+
+// dummyPasswordHash is compared against when a login names an unknown
+// email, so that the response time does not reveal which emails exist
+var dummyPasswordHash, _ = hashPassword("not-a-real-password")
+
+// tokenClaims are the claims of access and refresh tokens
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	Type      string `json:"typ"`
+	TokenID   string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// AuthService handles password login and the issuing of access and
+// refresh tokens. Access tokens are stateless and short-lived; refresh
+// tokens are recorded so that they can be rotated and revoked.
+type AuthService struct {
+	userRepo   *repository.UserRepository
+	tokenRepo  *repository.TokenRepository
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// NewAuthService creates a new instance of AuthService
+func NewAuthService(userRepo *repository.UserRepository, tokenRepo *repository.TokenRepository, secret []byte, accessTTL, refreshTTL time.Duration) *AuthService {
+	return &AuthService{
+		userRepo:   userRepo,
+		tokenRepo:  tokenRepo,
+		secret:     secret,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// Login checks an email and password and issues a token pair. It returns
+// ErrInvalidCredentials without saying which of the two was wrong.
+func (s *AuthService) Login(email, password string) (*entity.TokenResponse, error) {
+	user, err := s.userRepo.GetUserByEmail(strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || user.PasswordHash == "" {
+		checkPassword(dummyPasswordHash, password)
+		return nil, ErrInvalidCredentials
+	}
+
+	if !checkPassword(user.PasswordHash, password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	return s.issueTokens(user)
+}
+
+// Refresh exchanges a refresh token for a new token pair. Each refresh
+// token can be used once; presenting a revoked one means it has leaked,
+// so every refresh token of the user is revoked.
+func (s *AuthService) Refresh(refreshToken string) (*entity.TokenResponse, error) {
+	claims, err := s.parseToken(refreshToken, refreshTokenType)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := s.tokenRepo.GetRefreshToken(claims.TokenID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now()
+	revoked, err := s.tokenRepo.RevokeRefreshToken(record.TokenID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		if err := s.tokenRepo.RevokeUserRefreshTokens(record.UserID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetUserByID(record.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidToken
+	}
+
+	return s.issueTokens(user)
+}
+
+// Logout revokes a refresh token. Access tokens already issued stay valid
+// until they expire.
+func (s *AuthService) Logout(refreshToken string) error {
+	claims, err := s.parseToken(refreshToken, refreshTokenType)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.tokenRepo.RevokeRefreshToken(claims.TokenID, time.Now())
+	return err
+}
+
+// Authenticate verifies an access token and returns its user
+func (s *AuthService) Authenticate(accessToken string) (*entity.User, error) {
+	claims, err := s.parseToken(accessToken, accessTokenType)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidToken
+	}
+
+	return user, nil
+}
+
+// issueTokens signs an access token and a refresh token for user and
+// records the refresh token
+func (s *AuthService) issueTokens(user *entity.User) (*entity.TokenResponse, error) {
+	now := time.Now()
+
+	accessToken, err := s.signToken(user.ID, accessTokenType, uuid.New().String(), now, s.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &entity.RefreshToken{
+		TokenID:   uuid.New().String(),
+		UserID:    user.ID,
+		ExpiresAt: now.Add(s.refreshTTL),
+	}
+	refreshToken, err := s.signToken(user.ID, refreshTokenType, record.TokenID, now, s.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.tokenRepo.CreateRefreshToken(record); err != nil {
+		return nil, err
+	}
+
+	return &entity.TokenResponse{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(s.accessTTL.Seconds()),
+		RefreshExpiresIn: int(s.refreshTTL.Seconds()),
+		User:             user,
+	}, nil
+}
+
+// signToken signs a token of the given type for a user
+func (s *AuthService) signToken(userID int64, tokenType, tokenID string, now time.Time, ttl time.Duration) (string, error) {
+	return jwt.SignHS256(tokenClaims{
+		Subject:   strconv.FormatInt(userID, 10),
+		Type:      tokenType,
+		TokenID:   tokenID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}, s.secret)
+}
+
+// parseToken verifies a token and checks that it has the expected type
+func (s *AuthService) parseToken(token, tokenType string) (*tokenClaims, error) {
+	var claims tokenClaims
+	err := jwt.ParseHS256(token, s.secret, &claims)
+	if errors.Is(err, jwt.ErrInvalidToken) || errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Type != tokenType || claims.TokenID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	return &claims, nil
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+)
+
+// Authorization rules shared by the services. Users may access their own
+// tasks and data; permissions granted by their roles extend that to other
+// users' tasks and data.
+//
+// Tasks a user may not access are reported as not found rather than
+// forbidden, so that task IDs and file names cannot be probed. Requests
+// naming another user are forbidden regardless of whether that user exists.
+// Permissions only reach users and tasks of the requester's organization.
+
+// canAccessTask reports whether user may see and manage task
+func canAccessTask(user *entity.User, task *entity.ReadingTask) bool {
+	return task.UserID == user.ID || user.HasPermission(entity.PermTasksReadAll)
+}
+
+// authorizeUser checks that user may act on the data of the user with
+// userID, which requires permission unless it is their own and that the
+// other user is in the same organization. It returns ErrForbidden
+// otherwise. Unknown users are let through for the caller to report.
+func authorizeUser(users *repository.UserRepository, user *entity.User, userID int64, permission string) error {
+	if user.ID == userID {
+		return nil
+	}
+
+	if !user.HasPermission(permission) {
+		return ErrForbidden
+	}
+
+	other, err := users.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if other != nil && other.OrgID != user.OrgID {
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Checksums are digests of a file's content. Fields left nil are not known.
+type Checksums struct {
+	MD5    []byte
+	SHA256 []byte
+}
+
+// verify checks that the known checksums match those of the actual
+// content, returning ErrChecksumMismatch otherwise. A nil Checksums
+// matches anything.
+func (c *Checksums) verify(actual *Checksums) error {
+	if c == nil {
+		return nil
+	}
+
+	if c.MD5 != nil && !bytes.Equal(c.MD5, actual.MD5) {
+		return fmt.Errorf("%w: MD5 of the upload is %s", ErrChecksumMismatch, hex.EncodeToString(actual.MD5))
+	}
+	if c.SHA256 != nil && !bytes.Equal(c.SHA256, actual.SHA256) {
+		return fmt.Errorf("%w: SHA-256 of the upload is %s", ErrChecksumMismatch, hex.EncodeToString(actual.SHA256))
+	}
+
+	return nil
+}
+
+// spoolUpload copies an upload of size bytes to a temporary file, computing
+// its checksums on the way. The caller must remove the file with
+// removeSpool.
+func spoolUpload(src io.Reader, size int64) (*os.File, *Checksums, error) {
+	spool, err := os.CreateTemp("", "textile-upload-*")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(spool, md5Hash, sha256Hash), io.LimitReader(src, size))
+	if err == nil && n != size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		removeSpool(spool)
+		return nil, nil, err
+	}
+
+	return spool, &Checksums{MD5: md5Hash.Sum(nil), SHA256: sha256Hash.Sum(nil)}, nil
+}
+
+// removeSpool closes and removes a temporary file
+func removeSpool(file *os.File) {
+	file.Close()
+	os.Remove(file.Name())
+}
+
+// hashObject reads an object to its end and returns its SHA-256 hash and
+// size
+func hashObject(r io.Reader) ([]byte, int64, error) {
+	hash := sha256.New()
+	n, err := io.Copy(hash, r)
+	if err != nil {
+		return nil, n, err
+	}
+	return hash.Sum(nil), n, nil
+}
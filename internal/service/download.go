@@ -0,0 +1,99 @@
+package service
+
+import (
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+	"textile-admin/internal/domain/entity"
+)
+
+// inlineTypes are the content types browsers may display rather than
+// save. Types that can run script, such as HTML and SVG, are always sent
+// as attachments so that they cannot act on the API's origin.
+var inlineTypes = map[string]bool{
+	"application/pdf": true,
+	"image/gif":       true,
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/webp":      true,
+	"text/plain":      true,
+}
+
+// fileContentType returns the content type of the task's file stored under
+// key, from the name it was uploaded with, since stored files are named by
+// their hash. Text files carry their character set: the detected encoding
+// for the original and UTF-8 for the UTF-8 copy.
+func fileContentType(task *entity.ReadingTask, key string) string {
+	mediaType, params, err := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(task.FileName)))
+	if err != nil {
+		return "application/octet-stream"
+	}
+
+	if strings.HasPrefix(mediaType, "text/") {
+		switch {
+		case key == task.NormalizedPath:
+			params["charset"] = "utf-8"
+		case task.Encoding != "":
+			params["charset"] = task.Encoding
+		}
+	}
+	return mime.FormatMediaType(mediaType, params)
+}
+
+// contentDisposition returns a Content-Disposition header that offers
+// fileName as the name to save a download under, or lets browsers display
+// it if inline is set and contentType is safe to display. The name is sent
+// both as a plain filename, with characters outside printable ASCII
+// replaced, and as an RFC 5987 filename* in UTF-8, which browsers prefer.
+func contentDisposition(fileName, contentType string, inline bool) string {
+	disposition := "attachment"
+	if mediaType, _, err := mime.ParseMediaType(contentType); inline && err == nil && inlineTypes[mediaType] {
+		disposition = "inline"
+	}
+
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, disposition, asciiFileName(fileName), encodeRFC5987(fileName))
+}
+
+// asciiFileName replaces the characters of name that cannot appear in a
+// quoted filename parameter, keeping its extension so that clients
+// without filename* support still pick a suitable application
+func asciiFileName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			b.WriteByte('_')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// encodeRFC5987 percent-encodes the UTF-8 bytes of s other than the
+// attr-chars of RFC 5987
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isAttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+	return b.String()
+}
+
+// isAttrChar reports whether c may appear unencoded in an RFC 5987 value
+func isAttrChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"testing"
+	"textile-admin/internal/domain/entity"
+)
+
+func TestEncodeRFC5987(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"book.txt", "book.txt"},
+		{"春天.txt", "%E6%98%A5%E5%A4%A9.txt"},
+		{"my book (1).pdf", "my%20book%20%281%29.pdf"},
+		{`a"b\c;d,e`, "a%22b%5Cc%3Bd%2Ce"},
+		{"100%.txt", "100%25.txt"},
+		{"!#$&+-.^_`|~", "!#$&+-.^_`|~"},
+		{"a'b*c", "a%27b%2Ac"},
+		{"tab\there", "tab%09here"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := encodeRFC5987(tt.in); got != tt.want {
+			t.Errorf("encodeRFC5987(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		name        string
+		fileName    string
+		contentType string
+		inline      bool
+		want        string
+	}{
+		{
+			name:        "attachment",
+			fileName:    "book.txt",
+			contentType: "text/plain; charset=gbk",
+			want:        `attachment; filename="book.txt"; filename*=UTF-8''book.txt`,
+		},
+		{
+			name:        "non-ASCII name",
+			fileName:    "春天 第一卷.txt",
+			contentType: "text/plain; charset=utf-8",
+			want:        `attachment; filename="__ ___.txt"; filename*=UTF-8''%E6%98%A5%E5%A4%A9%20%E7%AC%AC%E4%B8%80%E5%8D%B7.txt`,
+		},
+		{
+			name:        "quotes and backslashes",
+			fileName:    `say "hi"\now.txt`,
+			contentType: "text/plain",
+			want:        `attachment; filename="say _hi__now.txt"; filename*=UTF-8''say%20%22hi%22%5Cnow.txt`,
+		},
+		{
+			name:        "control characters",
+			fileName:    "a\r\nContent-Type: text/html.txt",
+			contentType: "text/plain",
+			want:        `attachment; filename="a__Content-Type: text/html.txt"; filename*=UTF-8''a%0D%0AContent-Type%3A%20text%2Fhtml.txt`,
+		},
+		{
+			name:        "inline text",
+			fileName:    "book.txt",
+			contentType: "text/plain; charset=utf-8",
+			inline:      true,
+			want:        `inline; filename="book.txt"; filename*=UTF-8''book.txt`,
+		},
+		{
+			name:        "inline PDF",
+			fileName:    "book.pdf",
+			contentType: "application/pdf",
+			inline:      true,
+			want:        `inline; filename="book.pdf"; filename*=UTF-8''book.pdf`,
+		},
+		{
+			name:        "inline image",
+			fileName:    "cover.png",
+			contentType: "image/png",
+			inline:      true,
+			want:        `inline; filename="cover.png"; filename*=UTF-8''cover.png`,
+		},
+		{
+			name:        "HTML is never inline",
+			fileName:    "book.html",
+			contentType: "text/html; charset=utf-8",
+			inline:      true,
+			want:        `attachment; filename="book.html"; filename*=UTF-8''book.html`,
+		},
+		{
+			name:        "SVG is never inline",
+			fileName:    "cover.svg",
+			contentType: "image/svg+xml",
+			inline:      true,
+			want:        `attachment; filename="cover.svg"; filename*=UTF-8''cover.svg`,
+		},
+		{
+			name:        "EPUB is never inline",
+			fileName:    "book.epub",
+			contentType: "application/epub+zip",
+			inline:      true,
+			want:        `attachment; filename="book.epub"; filename*=UTF-8''book.epub`,
+		},
+		{
+			name:        "unparsable content type",
+			fileName:    "book.txt",
+			contentType: "text/plain; charset",
+			inline:      true,
+			want:        `attachment; filename="book.txt"; filename*=UTF-8''book.txt`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contentDisposition(tt.fileName, tt.contentType, tt.inline); got != tt.want {
+				t.Errorf("contentDisposition() = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileContentType(t *testing.T) {
+	// Go knows these extensions without a system MIME table
+	task := &entity.ReadingTask{
+		FileName:       "春天.html",
+		FilePath:       "1/original.html",
+		NormalizedPath: "1/normalized.html",
+		Encoding:       "gbk",
+	}
+
+	tests := []struct {
+		name string
+		task *entity.ReadingTask
+		key  string
+		want string
+	}{
+		{"original text", task, task.FilePath, "text/html; charset=gbk"},
+		{"UTF-8 copy", task, task.NormalizedPath, "text/html; charset=utf-8"},
+		{"text of unknown encoding", &entity.ReadingTask{FileName: "a.html"}, "a.html", "text/html; charset=utf-8"},
+		{"PDF", &entity.ReadingTask{FileName: "book.pdf", Encoding: "gbk"}, "book.pdf", "application/pdf"},
+		{"no extension", &entity.ReadingTask{FileName: "README"}, "README", "application/octet-stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fileContentType(tt.task, tt.key); got != tt.want {
+				t.Errorf("fileContentType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
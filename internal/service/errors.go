@@ -0,0 +1,113 @@
+package service
+
+import "errors"
+
+var (
+	// ErrTaskNotFound is returned when a reading task does not exist
+	ErrTaskNotFound = errors.New("task not found")
+
+	// ErrTaskProcessing is returned when a task cannot be deleted because it is being processed
+	ErrTaskProcessing = errors.New("task is being processed")
+
+	// ErrChecksumMismatch is returned when an upload does not match the checksum supplied with it
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// ErrInvalidTransition is returned when a task cannot move to the requested status
+	ErrInvalidTransition = errors.New("invalid task status transition")
+
+	// ErrContentNotAvailable is returned when a task's text has not been extracted
+	ErrContentNotAvailable = errors.New("content not available")
+
+	// ErrChapterNotFound is returned when a task has no chapter with the requested number
+	ErrChapterNotFound = errors.New("chapter not found")
+
+	// ErrInvalidProgress is returned when reading progress is out of range
+	ErrInvalidProgress = errors.New("invalid reading progress")
+
+	// ErrProgressNotFound is returned when a user has no progress on a task
+	ErrProgressNotFound = errors.New("reading progress not found")
+
+	// ErrStaleProgress is returned when a progress update is older than the stored progress
+	ErrStaleProgress = errors.New("stale reading progress")
+
+	// ErrInvalidAnchor is returned when a bookmark or annotation does not lie within the task's text
+	ErrInvalidAnchor = errors.New("invalid anchor")
+
+	// ErrBookmarkNotFound is returned when a bookmark does not exist or belongs to another user
+	ErrBookmarkNotFound = errors.New("bookmark not found")
+
+	// ErrAnnotationNotFound is returned when an annotation does not exist or belongs to another user
+	ErrAnnotationNotFound = errors.New("annotation not found")
+
+	// ErrSessionNotFound is returned when a reading session does not exist or belongs to another user
+	ErrSessionNotFound = errors.New("reading session not found")
+
+	// ErrSessionClosed is returned when a reading session has ended or expired
+	ErrSessionClosed = errors.New("reading session closed")
+
+	// ErrInvalidDateRange is returned when a stats date range is empty or too long
+	ErrInvalidDateRange = errors.New("invalid date range")
+
+	// ErrInvalidQuery is returned when a search query has nothing to search for
+	ErrInvalidQuery = errors.New("invalid search query")
+
+	// ErrUserNotFound is returned when a user does not exist
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrInvalidUser is returned when user fields fail validation
+	ErrInvalidUser = errors.New("invalid user")
+
+	// ErrEmailExists is returned when another user already has the email address
+	ErrEmailExists = errors.New("email already exists")
+
+	// ErrUserHasTasks is returned when deleting a user who still owns reading tasks
+	ErrUserHasTasks = errors.New("user still has reading tasks")
+
+	// ErrForbidden is returned when a user may not act on another user's data
+	ErrForbidden = errors.New("forbidden")
+
+	// ErrFileNotFound is returned when a file does not exist or belongs to another user's task
+	ErrFileNotFound = errors.New("file not found")
+
+	// ErrInvalidRole is returned when assigning a role that does not exist
+	ErrInvalidRole = errors.New("invalid role")
+
+	// ErrInvalidCredentials is returned when an email and password do not match
+	ErrInvalidCredentials = errors.New("invalid email or password")
+
+	// ErrInvalidToken is returned when an access or refresh token is malformed, expired or revoked
+	ErrInvalidToken = errors.New("invalid token")
+
+	// ErrSSOFailed is returned when a single sign-on login cannot be completed
+	ErrSSOFailed = errors.New("single sign-on failed")
+
+	// ErrOrgNotFound is returned when an organization does not exist
+	ErrOrgNotFound = errors.New("organization not found")
+
+	// ErrInvalidOrg is returned when organization fields fail validation
+	ErrInvalidOrg = errors.New("invalid organization")
+
+	// ErrOrgExists is returned when another organization already has the name
+	ErrOrgExists = errors.New("organization already exists")
+
+	// ErrUploadNotFound is returned when an upload session does not exist or belongs to another user
+	ErrUploadNotFound = errors.New("upload not found")
+
+	// ErrUploadExpired is returned when an upload session has expired
+	ErrUploadExpired = errors.New("upload expired")
+
+	// ErrUploadOffsetMismatch is returned when a chunk does not start at the upload's current offset
+	ErrUploadOffsetMismatch = errors.New("upload offset mismatch")
+
+	// ErrUploadTooLarge is returned when an upload or chunk exceeds its size limit
+	ErrUploadTooLarge = errors.New("upload too large")
+
+	// ErrInvalidUpload is returned when a new upload's length, file name or metadata fail validation
+	ErrInvalidUpload = errors.New("invalid upload")
+
+	// ErrInvalidAPIKey is returned when a new API key's name, scopes or expiry fail validation
+	ErrInvalidAPIKey = errors.New("invalid API key")
+
+	// ErrAPIKeyNotFound is returned when an API key does not exist or belongs to another user
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+	"textile-admin/internal/storage"
+)
+
+// verifyBatch is the number of records loaded per query while verifying
+const verifyBatch = 500
+
+// FileDrift is a stored file that no longer matches what was recorded
+// about it
+type FileDrift struct {
+	Key string `json:"key"`
+	// TaskID is set for files of tasks stored before files were hashed
+	TaskID  int64  `json:"task_id,omitempty"`
+	Problem string `json:"problem"`
+}
+
+// VerifyReport counts the stored files verified and the problems found
+type VerifyReport struct {
+	Checked  int `json:"checked"`
+	Problems int `json:"problems"`
+}
+
+// IntegrityService checks stored files against their records
+type IntegrityService struct {
+	repo  *repository.ReadingRepository
+	files storage.Backend
+}
+
+// NewIntegrityService creates a new instance of IntegrityService
+func NewIntegrityService(repo *repository.ReadingRepository, files storage.Backend) *IntegrityService {
+	return &IntegrityService{repo: repo, files: files}
+}
+
+// VerifyFiles reads every blob of every organization back from the
+// storage backend and compares its size and SHA-256 hash with its record
+// and its reference count with the tasks using it. Files of tasks stored
+// before they were hashed can only be checked for existence. Each problem
+// found is passed to report. Errors other than a missing file stop the
+// verification.
+func (s *IntegrityService) VerifyFiles(ctx context.Context, report func(*FileDrift)) (*VerifyReport, error) {
+	result := &VerifyReport{}
+	add := func(drift *FileDrift) {
+		result.Problems++
+		report(drift)
+	}
+
+	repo := s.repo.System()
+
+	var afterID int64
+	for {
+		blobs, err := repo.ListBlobs(afterID, verifyBatch)
+		if err != nil {
+			return result, err
+		}
+
+		for _, blob := range blobs {
+			problems, err := s.verifyBlob(ctx, blob)
+			if err != nil {
+				return result, err
+			}
+			for _, problem := range problems {
+				add(&FileDrift{Key: orgFileKey(blob.OrgID, blob.SHA256), Problem: problem})
+			}
+			result.Checked++
+			afterID = blob.ID
+		}
+
+		if len(blobs) < verifyBatch {
+			break
+		}
+	}
+
+	afterID = 0
+	for {
+		tasks, err := repo.ListUnhashedTasks(afterID, verifyBatch)
+		if err != nil {
+			return result, err
+		}
+
+		for _, task := range tasks {
+			for _, key := range []string{task.FilePath, task.NormalizedPath} {
+				if key == "" {
+					continue
+				}
+
+				_, err := s.files.Stat(ctx, key)
+				if errors.Is(err, storage.ErrNotFound) {
+					add(&FileDrift{Key: key, TaskID: task.ID, Problem: "file is missing"})
+				} else if err != nil {
+					return result, err
+				}
+				result.Checked++
+			}
+			afterID = task.ID
+		}
+
+		if len(tasks) < verifyBatch {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+// verifyBlob checks a blob's stored file and reference count, returning
+// the problems found
+func (s *IntegrityService) verifyBlob(ctx context.Context, blob *entity.Blob) ([]string, error) {
+	var problems []string
+
+	refs, err := s.repo.System().CountBlobReferences(blob.OrgID, blob.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	if refs != int64(blob.RefCount) {
+		// Uploads in progress hold references before their task exists
+		problems = append(problems, fmt.Sprintf("ref count is %d but %d tasks use the file", blob.RefCount, refs))
+	}
+
+	content, err := s.files.Get(ctx, orgFileKey(blob.OrgID, blob.SHA256))
+	if errors.Is(err, storage.ErrNotFound) {
+		return append(problems, "file is missing"), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	sum, size, err := hashObject(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if size != blob.Size {
+		problems = append(problems, fmt.Sprintf("size is %d bytes, recorded %d", size, blob.Size))
+	}
+
+	expected, err := hex.DecodeString(blob.SHA256)
+	if err != nil || !bytes.Equal(sum, expected) {
+		problems = append(problems, "content changed, SHA-256 is now "+hex.EncodeToString(sum))
+	}
+
+	return problems, nil
+}
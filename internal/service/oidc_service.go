@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+	"textile-admin/pkg/jwt"
+	"textile-admin/pkg/oidc"
+	"time"
+)
+
+const (
+	// oidcLoginType is the typ claim of login state tokens
+	oidcLoginType = "oidc_login"
+	// oidcLoginTTL is how long a user has to sign in at the provider
+	oidcLoginTTL = 10 * time.Minute
+	// oidcRandomBytes is the number of random bytes in states, nonces
+	// and code verifiers
+	oidcRandomBytes = 32
+)
+
+// oidcLoginClaims are the claims of a login state token. It carries what
+// the callback needs to finish a login, so that no server-side state is
+// kept between redirecting to the provider and the callback.
+type oidcLoginClaims struct {
+	Type         string `json:"typ"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"verifier"`
+	ExpiresAt    int64  `json:"exp"`
+}
+
+// OIDCService handles single sign-on through an OpenID Connect provider.
+// Users are matched by the provider's subject; on first sign-in they are
+// linked by email to an existing account, or created.
+type OIDCService struct {
+	client   *oidc.Client
+	issuer   string
+	auth     *AuthService
+	userRepo *repository.UserRepository
+	roleRepo *repository.RoleRepository
+}
+
+// NewOIDCService creates a new instance of OIDCService
+func NewOIDCService(client *oidc.Client, issuer string, auth *AuthService, userRepo *repository.UserRepository, roleRepo *repository.RoleRepository) *OIDCService {
+	return &OIDCService{
+		client:   client,
+		issuer:   issuer,
+		auth:     auth,
+		userRepo: userRepo,
+		roleRepo: roleRepo,
+	}
+}
+
+// StartLogin returns the provider URL to send the user to, and a signed
+// login state token that must be handed back to FinishLogin
+func (s *OIDCService) StartLogin(ctx context.Context) (string, string, error) {
+	claims := oidcLoginClaims{
+		Type:      oidcLoginType,
+		ExpiresAt: time.Now().Add(oidcLoginTTL).Unix(),
+	}
+
+	var err error
+	for _, field := range []*string{&claims.State, &claims.Nonce, &claims.CodeVerifier} {
+		if *field, err = oidc.RandomString(oidcRandomBytes); err != nil {
+			return "", "", err
+		}
+	}
+
+	authURL, err := s.client.AuthCodeURL(ctx, claims.State, claims.Nonce, oidc.CodeChallenge(claims.CodeVerifier))
+	if err != nil {
+		return "", "", err
+	}
+
+	loginState, err := jwt.SignHS256(claims, s.auth.secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, loginState, nil
+}
+
+// FinishLogin handles the provider's callback: it checks state against
+// the login state token, redeems code and issues a token pair for the
+// signed-in user. Failed sign-ins return ErrSSOFailed.
+func (s *OIDCService) FinishLogin(ctx context.Context, loginState, state, code string) (*entity.TokenResponse, error) {
+	var claims oidcLoginClaims
+	err := jwt.ParseHS256(loginState, s.auth.secret, &claims)
+	if errors.Is(err, jwt.ErrInvalidToken) || errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, fmt.Errorf("%w: login expired or was not started here", ErrSSOFailed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != oidcLoginType || claims.State == "" || claims.State != state {
+		return nil, fmt.Errorf("%w: state mismatch", ErrSSOFailed)
+	}
+
+	idToken, err := s.client.Exchange(ctx, code, claims.CodeVerifier, claims.Nonce)
+	if errors.Is(err, oidc.ErrExchangeFailed) || errors.Is(err, oidc.ErrInvalidIDToken) {
+		return nil, fmt.Errorf("%w: %v", ErrSSOFailed, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.resolveUser(idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.auth.issueTokens(user)
+}
+
+// resolveUser returns the user linked to the ID token's subject. An
+// unlinked subject is linked to the account with the same email when the
+// provider has verified the email, and otherwise gets a new account with
+// the reader role in the default organization.
+func (s *OIDCService) resolveUser(idToken *oidc.IDToken) (*entity.User, error) {
+	user, err := s.userRepo.GetUserByIdentity(s.issuer, idToken.Subject)
+	if err != nil || user != nil {
+		return user, err
+	}
+
+	user = &entity.User{OrgID: entity.DefaultOrgID}
+	username := ssoUsername(idToken)
+	if err := applyUserFields(user, &username, &idToken.Email); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSSOFailed, err)
+	}
+
+	identity := &entity.UserIdentity{
+		Issuer:  s.issuer,
+		Subject: idToken.Subject,
+		Email:   user.Email,
+	}
+
+	existing, err := s.userRepo.GetUserByEmail(user.Email)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		// Linking on an unverified email would let anyone who can set
+		// that email at the provider take over the account
+		if !idToken.EmailVerified {
+			return nil, fmt.Errorf("%w: the identity provider has not verified %s", ErrEmailExists, user.Email)
+		}
+		identity.UserID = existing.ID
+		if err := s.userRepo.CreateIdentity(identity); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+
+	roles, err := s.roleRepo.GetRolesByNames([]string{entity.RoleReader})
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = roles
+	user.Identities = []*entity.UserIdentity{identity}
+
+	err = s.userRepo.CreateUser(user)
+	if errors.Is(err, repository.ErrDuplicateEmail) {
+		return nil, ErrEmailExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Reload to get the roles' permissions
+	return s.userRepo.GetUserByID(user.ID)
+}
+
+// ssoUsername picks a username from the ID token's profile claims,
+// falling back to the local part of the email
+func ssoUsername(idToken *oidc.IDToken) string {
+	name := strings.TrimSpace(idToken.PreferredUsername)
+	if name == "" {
+		name = strings.TrimSpace(idToken.Name)
+	}
+	if name == "" {
+		name, _, _ = strings.Cut(idToken.Email, "@")
+	}
+
+	if runes := []rune(name); len(runes) > maxUserFieldLength {
+		name = string(runes[:maxUserFieldLength])
+	}
+
+	return name
+}
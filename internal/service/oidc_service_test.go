@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"textile-admin/pkg/jwt"
+	"textile-admin/pkg/oidc"
+	"textile-admin/pkg/oidc/oidctest"
+	"time"
+)
+
+// newTestOIDCService returns an OIDCService signing in at a mock provider.
+// It has no repositories, so logins fail once the ID token is verified.
+func newTestOIDCService(t *testing.T) *OIDCService {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(nil)
+	provider, err := oidctest.NewProvider("http://"+server.Listener.Addr().String(), "textile-admin", "mock-secret", "reader@example.com", "Reader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Config.Handler = provider.Handler()
+	server.Start()
+	t.Cleanup(server.Close)
+
+	client := oidc.NewClient(oidc.Config{
+		Issuer:       provider.Issuer(),
+		ClientID:     "textile-admin",
+		ClientSecret: "mock-secret",
+		RedirectURL:  "http://app.example/api/auth/oidc/callback",
+		Scopes:       []string{"openid", "email"},
+	})
+	auth := NewAuthService(nil, nil, []byte("test-secret"), time.Minute, time.Hour)
+	return NewOIDCService(client, provider.Issuer(), auth, nil, nil)
+}
+
+// signIn starts a login and follows it through the provider, returning the
+// login state token and the callback's state and code
+func signIn(t *testing.T, s *OIDCService) (loginState, state, code string) {
+	t.Helper()
+
+	authURL, loginState, err := s.StartLogin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := browser.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loginState, callback.Query().Get("state"), callback.Query().Get("code")
+}
+
+func TestFinishLoginChecksState(t *testing.T) {
+	s := newTestOIDCService(t)
+	loginState, state, code := signIn(t, s)
+	otherLoginState, otherState, _ := signIn(t, s)
+
+	if state == "" || state == otherState {
+		t.Fatalf("logins got states %q and %q, want distinct ones", state, otherState)
+	}
+
+	foreign, err := jwt.SignHS256(oidcLoginClaims{Type: oidcLoginType, State: state, ExpiresAt: time.Now().Add(time.Minute).Unix()}, []byte("other-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongType, err := jwt.SignHS256(oidcLoginClaims{Type: "access", State: state, ExpiresAt: time.Now().Add(time.Minute).Unix()}, s.auth.secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := jwt.SignHS256(oidcLoginClaims{Type: oidcLoginType, State: state, ExpiresAt: time.Now().Add(-time.Hour).Unix()}, s.auth.secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		loginState string
+		state      string
+	}{
+		{"forged state", loginState, "forged"},
+		{"missing state", loginState, ""},
+		{"state of another login", otherLoginState, state},
+		{"login state signed elsewhere", foreign, state},
+		{"not a login state", wrongType, state},
+		{"expired login state", expired, state},
+		{"no login state", "", state},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.FinishLogin(context.Background(), tt.loginState, tt.state, code)
+			if !errors.Is(err, ErrSSOFailed) {
+				t.Errorf("FinishLogin() error = %v, want ErrSSOFailed", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+	"unicode/utf8"
+)
+
+// maxOrgNameLength is the maximum length of an organization's name
+const maxOrgNameLength = 255
+
+// defaultOrgName is the name the default organization is created with
+const defaultOrgName = "Default"
+
+// OrganizationService handles the business logic for organizations
+type OrganizationService struct {
+	repo     *repository.OrganizationRepository
+	userRepo *repository.UserRepository
+}
+
+// NewOrganizationService creates a new instance of OrganizationService
+func NewOrganizationService(repo *repository.OrganizationRepository, userRepo *repository.UserRepository) *OrganizationService {
+	return &OrganizationService{repo: repo, userRepo: userRepo}
+}
+
+// SeedDefaultOrganization creates the organization new users join, unless
+// it exists already
+func (s *OrganizationService) SeedDefaultOrganization() error {
+	return s.repo.EnsureOrganization(&entity.Organization{ID: entity.DefaultOrgID, Name: defaultOrgName})
+}
+
+// ListOrganizations retrieves all organizations
+func (s *OrganizationService) ListOrganizations() ([]*entity.Organization, error) {
+	orgs, err := s.repo.ListOrganizations()
+	if err != nil {
+		return nil, err
+	}
+	if orgs == nil {
+		orgs = []*entity.Organization{}
+	}
+
+	return orgs, nil
+}
+
+// CreateOrganization creates an organization. It returns ErrOrgExists when
+// the name is taken.
+func (s *OrganizationService) CreateOrganization(name string) (*entity.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidOrg)
+	}
+	if utf8.RuneCountInString(name) > maxOrgNameLength {
+		return nil, fmt.Errorf("%w: name is longer than %d characters", ErrInvalidOrg, maxOrgNameLength)
+	}
+
+	org := &entity.Organization{Name: name}
+	err := s.repo.CreateOrganization(org)
+	if errors.Is(err, repository.ErrDuplicateOrgName) {
+		return nil, ErrOrgExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// MoveUser moves a user to another organization. Users who own reading
+// tasks are not moved, since their tasks would stay behind in the old
+// organization.
+func (s *OrganizationService) MoveUser(userID, orgID int64) (*entity.User, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	org, err := s.repo.GetOrganizationByID(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, ErrOrgNotFound
+	}
+
+	if user.OrgID == org.ID {
+		return user, nil
+	}
+
+	tasks, err := s.userRepo.CountUserTasks(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tasks > 0 {
+		return nil, fmt.Errorf("%w: %d tasks must be deleted first", ErrUserHasTasks, tasks)
+	}
+
+	if err := s.userRepo.SetUserOrg(user.ID, org.ID); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Moved user %d from organization %d to %d", user.ID, user.OrgID, org.ID)
+	user.OrgID = org.ID
+	return user, nil
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+	"time"
+)
+
+// maxClockSkew bounds how far in the future a client's last-read time may
+// be. Without it, one device with a fast clock would lock out the others.
+const maxClockSkew = 5 * time.Minute
+
+// ProgressService handles the business logic for reading progress
+type ProgressService struct {
+	repo      *repository.ProgressRepository
+	tasksRepo *repository.ReadingRepository
+}
+
+// NewProgressService creates a new instance of ProgressService
+func NewProgressService(repo *repository.ProgressRepository, tasksRepo *repository.ReadingRepository) *ProgressService {
+	return &ProgressService{
+		repo:      repo,
+		tasksRepo: tasksRepo,
+	}
+}
+
+// GetProgress retrieves a user's progress on a task. It returns
+// ErrTaskNotFound for unknown tasks and ErrProgressNotFound when the user
+// has not recorded any progress.
+func (s *ProgressService) GetProgress(userID, taskID int64) (*entity.ReadingProgress, error) {
+	task, err := s.tasksRepo.ForOwner(userID).GetTaskByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Other users' tasks are reported as missing
+	if task == nil || task.UserID != userID {
+		return nil, ErrTaskNotFound
+	}
+
+	progress, err := s.repo.GetProgress(userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if progress == nil {
+		return nil, ErrProgressNotFound
+	}
+
+	return progress, nil
+}
+
+// SaveProgress records a user's progress on a task. A zero LastReadAt means
+// now. Progress read earlier than the stored progress is rejected with
+// ErrStaleProgress, and the stored progress is returned alongside.
+func (s *ProgressService) SaveProgress(progress *entity.ReadingProgress) (*entity.ReadingProgress, error) {
+	task, err := s.tasksRepo.ForOwner(progress.UserID).GetTaskByID(progress.TaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if task == nil || task.UserID != progress.UserID {
+		return nil, ErrTaskNotFound
+	}
+
+	now := time.Now()
+	if progress.LastReadAt.IsZero() {
+		progress.LastReadAt = now
+	}
+	// Stored with millisecond precision, so compare at that precision too
+	progress.LastReadAt = progress.LastReadAt.Truncate(time.Millisecond)
+
+	if err := validateProgress(progress, task, now); err != nil {
+		return nil, err
+	}
+
+	err = s.repo.SaveProgress(progress)
+	if errors.Is(err, repository.ErrStaleProgress) {
+		return progress, fmt.Errorf("%w: stored progress was read at %s",
+			ErrStaleProgress, progress.LastReadAt.Format(time.RFC3339))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return progress, nil
+}
+
+// validateProgress checks that progress lies within the task's text
+func validateProgress(progress *entity.ReadingProgress, task *entity.ReadingTask, now time.Time) error {
+	if progress.Chapter < 0 || (task.ChapterCount > 0 && progress.Chapter > task.ChapterCount) {
+		return fmt.Errorf("%w: chapter must be between 0 and %d", ErrInvalidProgress, task.ChapterCount)
+	}
+
+	if progress.Offset < 0 {
+		return fmt.Errorf("%w: offset must not be negative", ErrInvalidProgress)
+	}
+
+	if progress.Percentage < 0 || progress.Percentage > 100 {
+		return fmt.Errorf("%w: percentage must be between 0 and 100", ErrInvalidProgress)
+	}
+
+	if progress.LastReadAt.After(now.Add(maxClockSkew)) {
+		return fmt.Errorf("%w: last_read_at is in the future", ErrInvalidProgress)
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+	"time"
+)
+
+func TestValidateProgress(t *testing.T) {
+	now := time.Now()
+	task := &entity.ReadingTask{ChapterCount: 3}
+
+	tests := []struct {
+		name     string
+		progress entity.ReadingProgress
+		task     *entity.ReadingTask
+		valid    bool
+	}{
+		{"start", entity.ReadingProgress{LastReadAt: now}, task, true},
+		{"last chapter finished", entity.ReadingProgress{Chapter: 3, Offset: 5000, Percentage: 100, LastReadAt: now}, task, true},
+		{"chapter past the end", entity.ReadingProgress{Chapter: 4, LastReadAt: now}, task, false},
+		{"any chapter before processing", entity.ReadingProgress{Chapter: 40, LastReadAt: now}, &entity.ReadingTask{}, true},
+		{"negative chapter", entity.ReadingProgress{Chapter: -1, LastReadAt: now}, task, false},
+		{"negative offset", entity.ReadingProgress{Offset: -1, LastReadAt: now}, task, false},
+		{"negative percentage", entity.ReadingProgress{Percentage: -0.5, LastReadAt: now}, task, false},
+		{"percentage over 100", entity.ReadingProgress{Percentage: 100.01, LastReadAt: now}, task, false},
+		{"slightly fast clock", entity.ReadingProgress{LastReadAt: now.Add(maxClockSkew - time.Second)}, task, true},
+		{"read in the future", entity.ReadingProgress{LastReadAt: now.Add(maxClockSkew + time.Second)}, task, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateProgress(&tt.progress, tt.task, now)
+			if tt.valid && err != nil {
+				t.Errorf("validateProgress() = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidProgress) {
+				t.Errorf("validateProgress() = %v, want ErrInvalidProgress", err)
+			}
+		})
+	}
+}
+
+func TestSaveProgress(t *testing.T) {
+	readingService, db := newTestReadingService(t, funcProcessor(readText), RetryPolicy{MaxAttempts: 1})
+	s := NewProgressService(repository.NewProgressRepository(db), readingService.repo)
+	reader := createTestUser(t, db, entity.DefaultOrgID, "reader@example.com")
+	other := createTestUser(t, db, entity.DefaultOrgID, "other@example.com")
+	taskID := uploadTestFile(t, readingService, reader, "book.txt", "Chapter 1").TaskID
+
+	if _, err := s.GetProgress(reader.ID, taskID); !errors.Is(err, ErrProgressNotFound) {
+		t.Errorf("GetProgress() before any progress = %v, want ErrProgressNotFound", err)
+	}
+
+	readAt := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	saved, err := s.SaveProgress(&entity.ReadingProgress{UserID: reader.ID, TaskID: taskID, Offset: 120, Percentage: 12.5, LastReadAt: readAt})
+	if err != nil {
+		t.Fatalf("SaveProgress() = %v", err)
+	}
+	if saved.Offset != 120 {
+		t.Errorf("saved offset = %d, want 120", saved.Offset)
+	}
+
+	// A device that was offline reports where it stopped earlier
+	stale, err := s.SaveProgress(&entity.ReadingProgress{UserID: reader.ID, TaskID: taskID, Offset: 40, LastReadAt: readAt.Add(-time.Minute)})
+	if !errors.Is(err, ErrStaleProgress) {
+		t.Fatalf("SaveProgress() of older progress = %v, want ErrStaleProgress", err)
+	}
+	if stale == nil || stale.Offset != 120 || !stale.LastReadAt.Equal(readAt) {
+		t.Errorf("SaveProgress() of older progress returned %+v, want the stored progress", stale)
+	}
+
+	if _, err := s.SaveProgress(&entity.ReadingProgress{UserID: reader.ID, TaskID: taskID, Offset: 300, Percentage: 30}); err != nil {
+		t.Fatalf("SaveProgress() of newer progress = %v", err)
+	}
+	progress, err := s.GetProgress(reader.ID, taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Offset != 300 || progress.Percentage != 30 || !progress.LastReadAt.After(readAt) {
+		t.Errorf("GetProgress() = %+v, want the newer progress", progress)
+	}
+
+	// Other users cannot see or record progress on the task
+	if _, err := s.GetProgress(other.ID, taskID); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("GetProgress() by another user = %v, want ErrTaskNotFound", err)
+	}
+	if _, err := s.SaveProgress(&entity.ReadingProgress{UserID: other.ID, TaskID: taskID}); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("SaveProgress() by another user = %v, want ErrTaskNotFound", err)
+	}
+	if _, err := s.SaveProgress(&entity.ReadingProgress{UserID: reader.ID, TaskID: taskID, Percentage: 150}); !errors.Is(err, ErrInvalidProgress) {
+		t.Errorf("SaveProgress() of invalid progress = %v, want ErrInvalidProgress", err)
+	}
+}
@@ -1,158 +1,898 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"mime/multipart"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/processor"
 	"textile-admin/internal/repository"
-
-	"github.com/google/uuid"
+	"textile-admin/internal/search"
+	"textile-admin/internal/storage"
+	"textile-admin/pkg/charset"
+	"time"
+	"unicode/utf8"
 )
 
 // ReadingService handles the business logic for reading tasks
 type ReadingService struct {
 	repo          *repository.ReadingRepository
-	uploadDir     string
+	userRepo      *repository.UserRepository
+	processors    *processor.Registry
+	index         *search.Index
+	files         storage.Backend
 	fileURLPrefix string
+	presignExpiry time.Duration
+	retryPolicy   RetryPolicy
+	leaseDuration time.Duration
+	readingSpeed  ReadingSpeed
+}
+
+// FileDownload is a task's file to send to a client: either a presigned
+// URL to redirect the client to, or the file's content and information.
+// FileName is the name the file was uploaded with, and SHA256 the file's
+// hex-encoded hash, if known. ContentType and ContentDisposition are the
+// headers to serve the content with.
+type FileDownload struct {
+	URL                string
+	FileName           string
+	SHA256             string
+	ContentType        string
+	ContentDisposition string
+	Content            io.ReadSeekCloser
+	Info               *storage.ObjectInfo
 }
 
-// NewReadingService creates a new instance of ReadingService
-func NewReadingService(repo *repository.ReadingRepository, uploadDir, fileURLPrefix string) *ReadingService {
+// NewReadingService creates a new instance of ReadingService. Uploaded
+// files are kept in files; with a presignExpiry above zero, downloads are
+// handed out as presigned URLs valid that long, if files supports them.
+// Tasks moved to processing by hand are leased for leaseDuration, as
+// workers lease the tasks they claim.
+func NewReadingService(repo *repository.ReadingRepository, userRepo *repository.UserRepository, processors *processor.Registry, index *search.Index, files storage.Backend, fileURLPrefix string, presignExpiry time.Duration, retryPolicy RetryPolicy, leaseDuration time.Duration, readingSpeed ReadingSpeed) *ReadingService {
 	return &ReadingService{
 		repo:          repo,
-		uploadDir:     uploadDir,
+		userRepo:      userRepo,
+		processors:    processors,
+		index:         index,
+		files:         files,
 		fileURLPrefix: fileURLPrefix,
+		presignExpiry: presignExpiry,
+		retryPolicy:   retryPolicy,
+		leaseDuration: leaseDuration,
+		readingSpeed:  readingSpeed,
 	}
 }
 
-// CreateTask creates a new reading task and saves the uploaded file
-func (s *ReadingService) CreateTask(userID int64, file *multipart.FileHeader) (*entity.UploadResponse, error) {
-	// Generate a unique filename to prevent collisions
-	originalFilename := filepath.Base(file.Filename)
-	uniqueFilename := generateUniqueFilename(originalFilename)
-	
-	// Define the file path
-	filePath := filepath.Join(s.uploadDir, uniqueFilename)
-	
-	// Save the file
-	if err := saveUploadedFile(file, filePath); err != nil {
-		log.Printf("Error saving file: %v", err)
+// CreateTask creates a new reading task and stores the uploaded file.
+// Text files are also stored as a UTF-8 copy; encoding names the source
+// encoding, or is empty to detect it. A file identical to one uploaded
+// before in the user's organization is not stored again but shares the
+// stored copy. When expected is not nil, the file is rejected with
+// ErrChecksumMismatch unless its checksums match. It returns
+// ErrUserNotFound for unknown users.
+func (s *ReadingService) CreateTask(ctx context.Context, userID int64, file *multipart.FileHeader, encoding string, expected *Checksums) (*entity.UploadResponse, error) {
+	src, err := file.Open()
+	if err != nil {
 		return nil, err
 	}
-	
+	defer src.Close()
+
+	return s.CreateTaskFromReader(ctx, userID, file.Filename, src, file.Size, encoding, expected)
+}
+
+// CreateTaskFromReader creates a new reading task for a file of size bytes
+// read from src, which was uploaded with fileName. Otherwise it works like
+// CreateTask.
+func (s *ReadingService) CreateTaskFromReader(ctx context.Context, userID int64, fileName string, src io.Reader, size int64, encoding string, expected *Checksums) (*entity.UploadResponse, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	// Validate a client-supplied encoding before storing anything
+	if encoding != "" {
+		canonical, err := charset.Lookup(encoding)
+		if err != nil {
+			return nil, err
+		}
+		encoding = canonical
+	}
+
+	originalFilename := filepath.Base(fileName)
+
+	task := &entity.ReadingTask{
+		UserID:   userID,
+		OrgID:    user.OrgID,
+		FileName: originalFilename,
+	}
+
+	// Store the file, and a UTF-8 copy of text files, as blobs
+	existed, err := s.storeUpload(ctx, task, src, size, encoding, expected)
+	if err != nil {
+		log.Printf("Error storing file: %v", err)
+		return nil, err
+	}
+
+	// Only a copy among the user's own tasks is reported: whether other
+	// users have uploaded the file is none of the user's business
+	deduplicated := false
+	if existed {
+		deduplicated, err = s.repo.ForOwner(userID).HasTaskWithHash(task.SHA256)
+		if err != nil {
+			s.releaseFiles(ctx, task)
+			return nil, err
+		}
+	}
+
 	// Create task in database
-	taskID, err := s.repo.CreateTask(userID, originalFilename, filePath)
+	taskID, err := s.repo.ForOrg(user.OrgID).CreateTask(task)
 	if err != nil {
-		// Attempt to delete the file if database operation fails
-		os.Remove(filePath)
+		// Give up the task's references to the stored files
+		s.releaseFiles(ctx, task)
 		return nil, err
 	}
-	
-	// Build the file URL
-	fileURL := fmt.Sprintf("%s/%s", s.fileURLPrefix, uniqueFilename)
-	
+
 	return &entity.UploadResponse{
-		TaskID:   taskID,
-		FileName: originalFilename,
-		FileURL:  fileURL,
+		TaskID:            taskID,
+		FileName:          originalFilename,
+		FileURL:           fmt.Sprintf("%s/%s", s.fileURLPrefix, path.Base(task.FilePath)),
+		FileSize:          task.FileSize,
+		SHA256:            task.SHA256,
+		MD5:               task.MD5,
+		Deduplicated:      deduplicated,
+		Encoding:          task.Encoding,
+		NormalizedFileURL: s.normalizedFileURL(task),
 	}, nil
 }
 
-// GetTaskByID retrieves a task by its ID and converts it to response format
-func (s *ReadingService) GetTaskByID(taskID int64) (*entity.TaskResponse, error) {
-	task, err := s.repo.GetTaskByID(taskID)
+// GetTaskByID retrieves a task by its ID and converts it to response format.
+// It returns ErrTaskNotFound for unknown tasks and tasks requester may not
+// access.
+func (s *ReadingService) GetTaskByID(requester *entity.User, taskID int64) (*entity.TaskResponse, error) {
+	task, err := s.getTask(requester, taskID)
 	if err != nil {
 		return nil, err
 	}
-	
-	if task == nil {
-		return nil, nil
-	}
-	
-	// Extract the filename from the file path
-	filename := filepath.Base(task.FilePath)
-	fileURL := fmt.Sprintf("%s/%s", s.fileURLPrefix, filename)
-	
-	return &entity.TaskResponse{
-		TaskID:    task.ID,
-		UserID:    task.UserID,
-		FileName:  task.FileName,
-		FileURL:   fileURL,
-		Status:    task.Status,
-		CreatedAt: task.CreatedAt,
-	}, nil
+
+	return s.toTaskResponse(task), nil
 }
 
-// GetTasksByUserID retrieves all tasks for a user and converts them to response format
-func (s *ReadingService) GetTasksByUserID(userID int64) ([]*entity.TaskResponse, error) {
-	tasks, err := s.repo.GetTasksByUserID(userID)
+// GetTasksByUserID retrieves all tasks for a user and converts them to
+// response format. Listing another user's tasks requires PermTasksReadAll;
+// without it ErrForbidden is returned.
+func (s *ReadingService) GetTasksByUserID(requester *entity.User, userID int64) ([]*entity.TaskResponse, error) {
+	if err := authorizeUser(s.userRepo, requester, userID, entity.PermTasksReadAll); err != nil {
+		return nil, err
+	}
+
+	tasks, err := s.repo.ForOrg(requester.OrgID).GetTasksByUserID(userID)
 	if err != nil {
 		return nil, err
 	}
 	
 	var responses []*entity.TaskResponse
 	for _, task := range tasks {
-		filename := filepath.Base(task.FilePath)
-		fileURL := fmt.Sprintf("%s/%s", s.fileURLPrefix, filename)
-		
-		responses = append(responses, &entity.TaskResponse{
-			TaskID:    task.ID,
-			UserID:    task.UserID,
-			FileName:  task.FileName,
-			FileURL:   fileURL,
-			Status:    task.Status,
-			CreatedAt: task.CreatedAt,
-		})
+		responses = append(responses, s.toTaskResponse(task))
 	}
 	
 	return responses, nil
 }
 
-// UpdateTaskStatus updates the status of a reading task
-func (s *ReadingService) UpdateTaskStatus(taskID int64, status string) error {
-	return s.repo.UpdateTaskStatus(taskID, status)
+// UpdateTaskStatus moves a reading task to a new status on behalf of actor.
+// Failed tasks sent back to pending get a fresh attempt budget, as with
+// RetryTask, and tasks moved to processing are leased to actor like a
+// worker's claim, so that they are picked up again if actor never
+// finishes them. It returns ErrTaskNotFound for unknown tasks and
+// ErrInvalidTransition when the move is not allowed from the task's current
+// status.
+func (s *ReadingService) UpdateTaskStatus(requester *entity.User, taskID int64, status, actor, reason string) error {
+	task, err := s.getTask(requester, taskID)
+	if err != nil {
+		return err
+	}
+
+	if !CanTransition(task.Status, status) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, task.Status, status)
+	}
+
+	repo := s.repo.ForOrg(requester.OrgID)
+	switch {
+	case status == entity.TaskStatusProcessing:
+		err = repo.StartTask(taskID, task.Status, actor, reason, s.leaseDuration)
+	case task.Status == entity.TaskStatusFailed && status == entity.TaskStatusPending:
+		err = repo.RequeueFailedTask(taskID, actor, reason)
+	default:
+		err = repo.TransitionTaskStatus(taskID, task.Status, status, actor, reason)
+	}
+	if errors.Is(err, repository.ErrStatusChanged) {
+		return fmt.Errorf("%w: task is no longer %s", ErrInvalidTransition, task.Status)
+	}
+
+	return err
 }
 
-// GetFilePath returns the actual file path for a given task
-func (s *ReadingService) GetFilePath(taskID int64) (string, error) {
-	task, err := s.repo.GetTaskByID(taskID)
+// GetTaskContent retrieves a page of a task's extracted text. offset and
+// length count characters. It returns ErrTaskNotFound for unknown tasks and
+// ErrContentNotAvailable when the task has not been processed yet.
+func (s *ReadingService) GetTaskContent(requester *entity.User, taskID int64, offset, length int) (*entity.ContentResponse, error) {
+	task, err := s.getTask(requester, taskID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	
-	if task == nil {
-		return "", fmt.Errorf("task not found")
+
+	// Text from an earlier run stays stored while a task is requeued or
+	// processed again, and may be replaced at any moment
+	if task.Status != entity.TaskStatusCompleted {
+		return nil, ErrContentNotAvailable
 	}
-	
-	return task.FilePath, nil
+
+	content, err := s.repo.ForOrg(requester.OrgID).GetTaskContentPage(taskID, offset, length)
+	if err != nil {
+		return nil, err
+	}
+
+	if content == nil {
+		return nil, ErrContentNotAvailable
+	}
+
+	page := &entity.ContentResponse{
+		TaskID:     taskID,
+		Offset:     offset,
+		Length:     utf8.RuneCountInString(content.Content),
+		TotalChars: content.CharCount,
+		Content:    content.Content,
+	}
+
+	if next := offset + page.Length; next < content.CharCount {
+		page.NextOffset = &next
+	}
+
+	return page, nil
 }
 
-// saveUploadedFile saves the uploaded file to the specified destination
-func saveUploadedFile(file *multipart.FileHeader, dst string) error {
-	src, err := file.Open()
+// GetTaskChapters retrieves the chapter list of a task. It returns
+// ErrTaskNotFound for unknown tasks and ErrContentNotAvailable when the task
+// has not been processed yet.
+func (s *ReadingService) GetTaskChapters(requester *entity.User, taskID int64) ([]*entity.ReadingChapter, error) {
+	task, err := s.getTask(requester, taskID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer src.Close()
-	
-	out, err := os.Create(dst)
+
+	if task.Status != entity.TaskStatusCompleted {
+		return nil, ErrContentNotAvailable
+	}
+
+	return s.repo.ForOrg(requester.OrgID).GetTaskChapters(taskID)
+}
+
+// GetTaskChapter retrieves a chapter of a task, numbered from 1, with its
+// text. It returns ErrChapterNotFound when the task has no such chapter.
+func (s *ReadingService) GetTaskChapter(requester *entity.User, taskID int64, ordinal int) (*entity.ChapterResponse, error) {
+	task, err := s.getTask(requester, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if task.Status != entity.TaskStatusCompleted {
+		return nil, ErrContentNotAvailable
+	}
+
+	chapter, err := s.repo.ForOrg(requester.OrgID).GetTaskChapter(taskID, ordinal)
+	if err != nil {
+		return nil, err
+	}
+
+	if chapter == nil {
+		return nil, ErrChapterNotFound
+	}
+
+	content, err := s.repo.ForOrg(requester.OrgID).GetTaskContentPage(taskID, chapter.StartOffset, chapter.EndOffset-chapter.StartOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	if content == nil {
+		return nil, ErrContentNotAvailable
+	}
+
+	return &entity.ChapterResponse{
+		TaskID:      taskID,
+		Chapter:     chapter.Ordinal,
+		Title:       chapter.Title,
+		StartOffset: chapter.StartOffset,
+		EndOffset:   chapter.EndOffset,
+		Content:     content.Content,
+	}, nil
+}
+
+// RetryTask sends a failed task back to the queue with a fresh attempt budget.
+// It returns ErrTaskNotFound for unknown tasks and ErrInvalidTransition when
+// the task has not failed.
+func (s *ReadingService) RetryTask(requester *entity.User, taskID int64, actor string) error {
+	task, err := s.getTask(requester, taskID)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
-	
-	_, err = io.Copy(out, src)
+
+	if task.Status != entity.TaskStatusFailed {
+		return fmt.Errorf("%w: only failed tasks can be retried, task is %s", ErrInvalidTransition, task.Status)
+	}
+
+	err = s.repo.ForOrg(requester.OrgID).RequeueFailedTask(taskID, actor, "manual retry")
+	if errors.Is(err, repository.ErrStatusChanged) {
+		return fmt.Errorf("%w: task is no longer failed", ErrInvalidTransition)
+	}
+
 	return err
 }
 
-// generateUniqueFilename creates a unique filename by adding a UUID
-func generateUniqueFilename(originalName string) string {
-	ext := filepath.Ext(originalName)
-	name := originalName[:len(originalName)-len(ext)]
-	uuid := uuid.New().String()
-	return fmt.Sprintf("%s_%s%s", name, uuid, ext)
-} 
\ No newline at end of file
+// DeleteTask deletes a reading task with its extracted text, reading data
+// and search index entry, and releases its files, which are deleted once no
+// other task shares them. Deleting another user's task requires
+// PermTasksDeleteAll; without it ErrForbidden is returned. Tasks being
+// processed cannot be deleted and give ErrTaskProcessing.
+func (s *ReadingService) DeleteTask(ctx context.Context, requester *entity.User, taskID int64) error {
+	task, err := s.getTask(requester, taskID)
+	if err != nil {
+		return err
+	}
+
+	if task.UserID != requester.ID && !requester.HasPermission(entity.PermTasksDeleteAll) {
+		return ErrForbidden
+	}
+
+	err = s.repo.ForOrg(requester.OrgID).DeleteTask(taskID)
+	if errors.Is(err, repository.ErrStatusChanged) {
+		return ErrTaskProcessing
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.index.Remove(taskID); err != nil {
+		log.Printf("Error removing task %d from the search index: %v", taskID, err)
+	}
+
+	s.releaseFiles(ctx, task)
+	return nil
+}
+
+// GetTaskEvents retrieves the status history of a reading task
+func (s *ReadingService) GetTaskEvents(requester *entity.User, taskID int64) ([]*entity.ReadingTaskEvent, error) {
+	if _, err := s.getTask(requester, taskID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.ForOrg(requester.OrgID).GetTaskEvents(taskID)
+}
+
+// OpenFile resolves the name of an uploaded file, as used in file URLs, to
+// a download. The file may be a task's original or its UTF-8 copy, stored
+// under the requester's organization or, for files uploaded before there
+// were organizations, at the top level. The download is offered under the
+// name the file was uploaded with; inline lets browsers display types that
+// are safe to. It returns ErrFileNotFound for unknown and missing files and
+// files of tasks requester may not access. The caller must close the
+// download's Content.
+func (s *ReadingService) OpenFile(ctx context.Context, requester *entity.User, fileName string, inline bool) (*FileDownload, error) {
+	key, task, err := s.findFileKey(requester, path.Base(fileName))
+	if err != nil {
+		return nil, err
+	}
+
+	download := &FileDownload{
+		FileName:    task.FileName,
+		SHA256:      fileSHA256(task, key),
+		ContentType: fileContentType(task, key),
+	}
+	download.ContentDisposition = contentDisposition(task.FileName, download.ContentType, inline)
+
+	if s.presignExpiry > 0 {
+		url, err := s.files.PresignGet(ctx, key, s.presignExpiry, storage.DownloadHeaders{
+			ContentType:        download.ContentType,
+			ContentDisposition: download.ContentDisposition,
+		})
+		if err == nil {
+			download.URL = url
+			return download, nil
+		}
+		if !errors.Is(err, storage.ErrPresignNotSupported) {
+			return nil, err
+		}
+	}
+
+	info, err := s.files.Stat(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Range requests need to read the content from any offset
+	content, err := storage.OpenSeeker(ctx, s.files, key, info.Size)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	download.Content = content
+	download.Info = info
+	return download, nil
+}
+
+// findFileKey returns the storage key of a file of a task requester may
+// access, and that task, looking under the requester's organization first.
+// Files shared by several tasks are looked up among the tasks requester
+// may access.
+func (s *ReadingService) findFileKey(requester *entity.User, name string) (string, *entity.ReadingTask, error) {
+	repo := s.repo.ForOrg(requester.OrgID)
+	if !requester.HasPermission(entity.PermTasksReadAll) {
+		repo = s.repo.ForOwner(requester.ID)
+	}
+
+	for _, key := range []string{orgFileKey(requester.OrgID, name), name} {
+		task, err := repo.GetTaskByFileKey(key)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if task != nil && canAccessTask(requester, task) {
+			return key, task, nil
+		}
+	}
+
+	return "", nil, ErrFileNotFound
+}
+
+// fileSHA256 returns the hash of the task's file stored under key: its
+// original or its UTF-8 copy
+func fileSHA256(task *entity.ReadingTask, key string) string {
+	if key == task.NormalizedPath {
+		return task.NormalizedSHA256
+	}
+	return task.SHA256
+}
+
+// orgFileKey returns the storage key of an organization's file. Each
+// organization's files are kept under a prefix of their own.
+func orgFileKey(orgID int64, name string) string {
+	return strconv.FormatInt(orgID, 10) + "/" + name
+}
+
+// getTask loads a task that requester may access. Unknown tasks and tasks
+// of other users both give ErrTaskNotFound.
+func (s *ReadingService) getTask(requester *entity.User, taskID int64) (*entity.ReadingTask, error) {
+	task, err := s.repo.ForOrg(requester.OrgID).GetTaskByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if task == nil || !canAccessTask(requester, task) {
+		return nil, ErrTaskNotFound
+	}
+
+	return task, nil
+}
+
+// ClaimNextTask leases the next pending task to owner for processing. Tasks
+// whose lease expired on their last attempt under the retry policy are
+// failed on the way. It returns nil when there is no task to claim.
+func (s *ReadingService) ClaimNextTask(owner string, leaseDuration time.Duration) (*entity.ReadingTask, error) {
+	return s.repo.System().ClaimTask(owner, leaseDuration, s.retryPolicy.MaxAttempts)
+}
+
+// RenewTaskLease extends the lease on a task being processed
+func (s *ReadingService) RenewTaskLease(task *entity.ReadingTask, leaseDuration time.Duration) error {
+	return s.repo.System().RenewLease(task.ID, task.LeaseOwner, leaseDuration)
+}
+
+// ProcessTask runs the processor for a claimed task and records the outcome.
+// A failed attempt is scheduled for retry with exponential backoff until the
+// retry policy's attempt limit is reached, after which the task is failed.
+// Errors that would recur on every attempt, such as an unsupported format,
+// fail the task immediately. The processing error, if any, is returned.
+func (s *ReadingService) ProcessTask(ctx context.Context, task *entity.ReadingTask) error {
+	procErr := s.runProcessor(ctx, task)
+	if procErr == nil {
+		return s.repo.System().CompleteLeasedTask(task.ID, task.LeaseOwner, entity.TaskStatusCompleted, "", nil, "")
+	}
+
+	errorMessage := procErr.Error()
+	if !processor.IsPermanent(procErr) && s.retryPolicy.ShouldRetry(task.Attempts) {
+		nextAttemptAt := time.Now().Add(s.retryPolicy.Backoff(task.Attempts))
+		reason := fmt.Sprintf("attempt %d failed, retrying at %s: %s",
+			task.Attempts, nextAttemptAt.Format(time.RFC3339), errorMessage)
+		if err := s.repo.System().CompleteLeasedTask(task.ID, task.LeaseOwner, entity.TaskStatusPending, errorMessage, &nextAttemptAt, reason); err != nil {
+			return err
+		}
+		return procErr
+	}
+
+	reason := fmt.Sprintf("attempt %d failed, giving up: %s", task.Attempts, errorMessage)
+	if err := s.repo.System().CompleteLeasedTask(task.ID, task.LeaseOwner, entity.TaskStatusFailed, errorMessage, nil, reason); err != nil {
+		return err
+	}
+
+	return procErr
+}
+
+// toTaskResponse converts a task to its response format
+func (s *ReadingService) toTaskResponse(task *entity.ReadingTask) *entity.TaskResponse {
+	// Extract the filename from the file key
+	filename := path.Base(task.FilePath)
+	fileURL := fmt.Sprintf("%s/%s", s.fileURLPrefix, filename)
+
+	return &entity.TaskResponse{
+		TaskID:         task.ID,
+		UserID:         task.UserID,
+		FileName:       task.FileName,
+		FileURL:        fileURL,
+		Status:         task.Status,
+		CreatedAt:      task.CreatedAt,
+		FileSize:       task.FileSize,
+		SHA256:         task.SHA256,
+		MD5:            task.MD5,
+		MIMEType:       task.MIMEType,
+		PageCount:      task.PageCount,
+		ChapterCount:   task.ChapterCount,
+		WordCount:      task.WordCount,
+		CJKCharCount:   task.CJKCharCount,
+		ReadingMinutes: task.ReadingMinutes,
+		ErrorMessage:   task.ErrorMessage,
+		Attempts:       task.Attempts,
+		NextAttemptAt:  task.NextAttemptAt,
+
+		Encoding:          task.Encoding,
+		NormalizedFileURL: s.normalizedFileURL(task),
+	}
+}
+
+// normalizedFileURL returns the download URL of a task's UTF-8 copy, if any
+func (s *ReadingService) normalizedFileURL(task *entity.ReadingTask) string {
+	if task.NormalizedPath == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", s.fileURLPrefix, path.Base(task.NormalizedPath))
+}
+
+// runProcessor dispatches the task's file to the processor registered for
+// its type and stores what the processor found
+func (s *ReadingService) runProcessor(ctx context.Context, task *entity.ReadingTask) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Identical files are processed only once
+	if reused, err := s.reuseExtraction(task); err != nil || reused {
+		return err
+	}
+
+	// Text files are processed from their UTF-8 copy when there is one
+	key := task.FilePath
+	if task.NormalizedPath != "" {
+		key = task.NormalizedPath
+	}
+
+	// Processors read files from local disk
+	filePath, release, err := storage.LocalFile(ctx, s.files, key)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	proc, mimeType, err := s.processors.Lookup(filePath, task.FileName)
+	if err != nil {
+		return err
+	}
+
+	doc := &processor.Document{
+		Path:     filePath,
+		FileName: task.FileName,
+		MIMEType: mimeType,
+	}
+
+	result, err := proc.Process(ctx, doc)
+	if err != nil {
+		return fmt.Errorf("%s processor: %w", proc.Name(), err)
+	}
+
+	// Formats without their own chapter structure are split on headings
+	chapters := result.Chapters
+	if len(chapters) == 0 {
+		chapters = processor.SegmentChapters(result.Text)
+	}
+
+	records := make([]*entity.ReadingChapter, 0, len(chapters))
+	for i, chapter := range chapters {
+		records = append(records, &entity.ReadingChapter{
+			TaskID:      task.ID,
+			Ordinal:     i + 1,
+			Title:       chapter.Title,
+			StartOffset: chapter.Start,
+			EndOffset:   chapter.End,
+		})
+	}
+
+	stats := processor.CountText(result.Text)
+
+	return s.saveExtraction(task.ID, result.Text, records, &entity.DocumentInfo{
+		MIMEType:       mimeType,
+		PageCount:      result.PageCount,
+		ChapterCount:   len(records),
+		WordCount:      stats.Words,
+		CJKCharCount:   stats.CJKChars,
+		ReadingMinutes: s.readingSpeed.Minutes(stats),
+	})
+}
+
+// reuseExtraction copies the extracted text, chapters and document
+// information of a processed task with the same files to task. It reports
+// false when there is no such task.
+func (s *ReadingService) reuseExtraction(task *entity.ReadingTask) (bool, error) {
+	if task.SHA256 == "" {
+		return false, nil
+	}
+
+	repo := s.repo.ForOrg(task.OrgID)
+
+	source, err := repo.GetProcessedTaskByHash(task.SHA256, task.NormalizedSHA256, task.ID)
+	if err != nil || source == nil {
+		return false, err
+	}
+
+	content, err := repo.GetTaskContent(source.ID)
+	if err != nil || content == nil {
+		return false, err
+	}
+
+	chapters, err := repo.GetTaskChapters(source.ID)
+	if err != nil {
+		return false, err
+	}
+
+	records := make([]*entity.ReadingChapter, 0, len(chapters))
+	for _, chapter := range chapters {
+		records = append(records, &entity.ReadingChapter{
+			TaskID:      task.ID,
+			Ordinal:     chapter.Ordinal,
+			Title:       chapter.Title,
+			StartOffset: chapter.StartOffset,
+			EndOffset:   chapter.EndOffset,
+		})
+	}
+
+	log.Printf("Task %d reuses the extraction of task %d", task.ID, source.ID)
+
+	return true, s.saveExtraction(task.ID, content.Content, records, &entity.DocumentInfo{
+		MIMEType:       source.MIMEType,
+		PageCount:      source.PageCount,
+		ChapterCount:   source.ChapterCount,
+		WordCount:      source.WordCount,
+		CJKCharCount:   source.CJKCharCount,
+		ReadingMinutes: source.ReadingMinutes,
+	})
+}
+
+// saveExtraction stores the extracted text, chapters and document
+// information of a task and adds the text to the search index
+func (s *ReadingService) saveExtraction(taskID int64, text string, chapters []*entity.ReadingChapter, info *entity.DocumentInfo) error {
+	if err := s.repo.System().SaveTaskContent(taskID, text); err != nil {
+		return err
+	}
+
+	if err := s.index.Add(taskID, text); err != nil {
+		return fmt.Errorf("failed to index content: %w", err)
+	}
+
+	if err := s.repo.System().SaveTaskChapters(taskID, chapters); err != nil {
+		return err
+	}
+
+	return s.repo.System().UpdateTaskDocumentInfo(taskID, info)
+}
+
+// storeUpload stores an uploaded file of size bytes as a blob of the
+// task's organization and sets the task's FilePath, FileSize and
+// checksums, after checking them against expected. For text files it also
+// detects the encoding and stores a UTF-8 copy, setting the task's
+// Encoding, NormalizedPath and NormalizedSHA256. It reports whether the
+// file had been stored before.
+func (s *ReadingService) storeUpload(ctx context.Context, task *entity.ReadingTask, src io.Reader, size int64, encoding string, expected *Checksums) (bool, error) {
+	// The hash names the blob, so the file is hashed before it is stored.
+	// Files are spooled to disk rather than memory, since uploads can be
+	// large.
+	spool, sums, err := spoolUpload(src, size)
+	if err != nil {
+		return false, err
+	}
+	defer removeSpool(spool)
+
+	if err := expected.verify(sums); err != nil {
+		return false, err
+	}
+
+	var text *textCopy
+	if isTextFile(task.FileName) {
+		text, err = spoolUTF8(spool, encoding)
+		if err != nil {
+			return false, err
+		}
+		defer removeSpool(text.file)
+	}
+
+	setFileInfo(task, sums, size)
+	existed, err := s.storeBlob(ctx, task.OrgID, task.SHA256, size, func() (io.Reader, error) {
+		_, err := spool.Seek(0, io.SeekStart)
+		return spool, err
+	})
+	if err != nil || text == nil {
+		return existed, err
+	}
+	task.Encoding = text.encoding
+
+	// A copy identical to the original means it is plain UTF-8 already
+	if text.sha256 != task.SHA256 {
+		_, err := s.storeBlob(ctx, task.OrgID, text.sha256, text.size, func() (io.Reader, error) {
+			_, err := text.file.Seek(0, io.SeekStart)
+			return text.file, err
+		})
+		if err != nil {
+			s.releaseBlob(ctx, task.OrgID, task.SHA256)
+			return false, err
+		}
+		task.NormalizedPath = orgFileKey(task.OrgID, text.sha256)
+		task.NormalizedSHA256 = text.sha256
+	}
+
+	return existed, nil
+}
+
+// setFileInfo records the size and checksums of a task's uploaded file,
+// and its key, which the SHA-256 hash names
+func setFileInfo(task *entity.ReadingTask, sums *Checksums, size int64) {
+	task.SHA256 = hex.EncodeToString(sums.SHA256)
+	task.MD5 = hex.EncodeToString(sums.MD5)
+	task.FileSize = size
+	task.FilePath = orgFileKey(task.OrgID, task.SHA256)
+}
+
+// storeBlob adds a reference to the blob of an organization with the given
+// hash and stores its content, read from open, unless it is stored
+// already. It reports whether it was.
+func (s *ReadingService) storeBlob(ctx context.Context, orgID int64, sum string, size int64, open func() (io.Reader, error)) (bool, error) {
+	// Referencing the blob first keeps a concurrent release from deleting
+	// the file after it was found to exist
+	if err := s.repo.ForOrg(orgID).AcquireBlob(sum, size); err != nil {
+		return false, err
+	}
+
+	key := orgFileKey(orgID, sum)
+	_, err := s.files.Stat(ctx, key)
+	if err == nil {
+		return true, nil
+	}
+
+	if errors.Is(err, storage.ErrNotFound) {
+		var content io.Reader
+		content, err = open()
+		if err == nil {
+			err = s.files.Put(ctx, key, content, size)
+		}
+	}
+	if err != nil {
+		s.releaseBlob(ctx, orgID, sum)
+		return false, err
+	}
+
+	return false, nil
+}
+
+// releaseBlob removes a reference to a blob of an organization, deleting
+// the stored file with the last one. Failures are logged.
+func (s *ReadingService) releaseBlob(ctx context.Context, orgID int64, sum string) {
+	key := orgFileKey(orgID, sum)
+	s.repo.ForOrg(orgID).ReleaseBlob(sum, func() error {
+		return s.files.Delete(ctx, key)
+	})
+}
+
+// releaseFiles gives up a task's references to its stored files. Files of
+// tasks created before files were shared are deleted right away.
+func (s *ReadingService) releaseFiles(ctx context.Context, task *entity.ReadingTask) {
+	if task.SHA256 == "" {
+		s.deleteFiles(ctx, task)
+		return
+	}
+
+	s.releaseBlob(ctx, task.OrgID, task.SHA256)
+	if task.NormalizedSHA256 != "" {
+		s.releaseBlob(ctx, task.OrgID, task.NormalizedSHA256)
+	}
+}
+
+// deleteFiles removes a task's stored files, logging failures
+func (s *ReadingService) deleteFiles(ctx context.Context, task *entity.ReadingTask) {
+	for _, key := range []string{task.FilePath, task.NormalizedPath} {
+		if key == "" {
+			continue
+		}
+		if err := s.files.Delete(ctx, key); err != nil {
+			log.Printf("Error deleting file %s: %v", key, err)
+		}
+	}
+}
+
+// isTextFile reports whether a file name has the extension of a text format
+// whose character encoding must be detected
+func isTextFile(fileName string) bool {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".txt", ".text", ".md", ".markdown", ".html", ".htm":
+		return true
+	}
+	return false
+}
+
+// textCopy is the UTF-8 copy of a text file, spooled to a temporary file
+type textCopy struct {
+	file     *os.File
+	encoding string
+	sha256   string
+	size     int64
+}
+
+// spoolUTF8 detects the encoding of a spooled text file, unless encoding
+// names it, and converts it to UTF-8 in another temporary file, hashing it
+// on the way. The caller must remove the copy with removeSpool.
+func spoolUTF8(src *os.File, encoding string) (*textCopy, error) {
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if encoding == "" {
+		detected, err := charset.DetectReader(src)
+		if err != nil {
+			return nil, err
+		}
+		encoding = detected
+
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	converted, err := charset.NewUTF8Reader(src, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp("", "textile-utf8-*")
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), converted)
+	if err != nil {
+		removeSpool(file)
+		return nil, err
+	}
+
+	return &textCopy{file: file, encoding: encoding, sha256: hex.EncodeToString(hash.Sum(nil)), size: size}, nil
+}
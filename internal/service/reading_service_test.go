@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/processor"
+	"textile-admin/internal/repository"
+	"textile-admin/internal/repository/repotest"
+	"textile-admin/internal/search"
+	"textile-admin/internal/storage"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// funcProcessor processes documents with a function
+type funcProcessor func(ctx context.Context, doc *processor.Document) (*processor.Result, error)
+
+func (f funcProcessor) Name() string { return "test" }
+
+func (f funcProcessor) Process(ctx context.Context, doc *processor.Document) (*processor.Result, error) {
+	return f(ctx, doc)
+}
+
+// readText is a processor returning the text of a file
+func readText(ctx context.Context, doc *processor.Document) (*processor.Result, error) {
+	data, err := os.ReadFile(doc.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &processor.Result{Text: string(data)}, nil
+}
+
+// openTestDB returns a test database with the built-in roles
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := repotest.Open(t)
+	if err := NewRoleService(repository.NewRoleRepository(db), repository.NewUserRepository(db)).SeedRoles(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// newTestReadingService returns a reading service on a test database that
+// stores files in a temporary directory and processes text files with proc
+func newTestReadingService(t *testing.T, proc processor.Processor, retryPolicy RetryPolicy) (*ReadingService, *gorm.DB) {
+	t.Helper()
+
+	db := openTestDB(t)
+	files, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := search.NewIndex(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := processor.NewRegistry()
+	registry.Register(proc, []string{"text/plain"}, []string{".txt"})
+
+	s := NewReadingService(repository.NewReadingRepository(db), repository.NewUserRepository(db), registry, index, files,
+		"/api/files", 0, retryPolicy, time.Minute, ReadingSpeed{})
+	return s, db
+}
+
+// createTestUser adds a user to an organization with the given roles
+func createTestUser(t *testing.T, db *gorm.DB, orgID int64, email string, roles ...string) *entity.User {
+	t.Helper()
+
+	user := &entity.User{OrgID: orgID, Username: email, Email: email}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(roles) > 0 {
+		roleRepo := repository.NewRoleRepository(db)
+		found, err := roleRepo.GetRolesByNames(roles)
+		if err != nil || len(found) != len(roles) {
+			t.Fatalf("GetRolesByNames(%v) = %v, %v", roles, found, err)
+		}
+		if err := roleRepo.SetUserRoles(user, found); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded, err := repository.NewUserRepository(db).GetUserByID(user.ID)
+	if err != nil || loaded == nil {
+		t.Fatalf("GetUserByID(%d) = %v, %v", user.ID, loaded, err)
+	}
+	return loaded
+}
+
+// uploadTestFile creates a task for a text file of user
+func uploadTestFile(t *testing.T, s *ReadingService, user *entity.User, fileName, content string) *entity.UploadResponse {
+	t.Helper()
+
+	upload, err := s.CreateTaskFromReader(context.Background(), user.ID, fileName, strings.NewReader(content), int64(len(content)), "", nil)
+	if err != nil {
+		t.Fatalf("CreateTaskFromReader(%s) = %v", fileName, err)
+	}
+	return upload
+}
+
+// processTestTask claims and processes the next pending task, which must be taskID
+func processTestTask(t *testing.T, s *ReadingService, taskID int64) {
+	t.Helper()
+
+	task, err := s.ClaimNextTask("worker-a", time.Minute)
+	if err != nil || task == nil || task.ID != taskID {
+		t.Fatalf("ClaimNextTask() = %v, %v; want task %d", task, err, taskID)
+	}
+	if err := s.ProcessTask(context.Background(), task); err != nil {
+		t.Fatalf("ProcessTask(%d) = %v", taskID, err)
+	}
+}
+
+// getTestTask loads a task regardless of its organization
+func getTestTask(t *testing.T, s *ReadingService, taskID int64) *entity.ReadingTask {
+	t.Helper()
+
+	task, err := s.repo.System().GetTaskByID(taskID)
+	if err != nil || task == nil {
+		t.Fatalf("GetTaskByID(%d) = %v, %v", taskID, task, err)
+	}
+	return task
+}
+
+func TestProcessTaskRetriesWithBackoff(t *testing.T) {
+	attempts := 0
+	s, db := newTestReadingService(t, funcProcessor(func(ctx context.Context, doc *processor.Document) (*processor.Result, error) {
+		attempts++
+		return nil, fmt.Errorf("disk hiccup %d", attempts)
+	}), RetryPolicy{MaxAttempts: 2, BaseDelay: time.Hour, MaxDelay: 24 * time.Hour})
+	user := createTestUser(t, db, entity.DefaultOrgID, "reader@example.com")
+	taskID := uploadTestFile(t, s, user, "book.txt", "Chapter 1").TaskID
+
+	task, err := s.ClaimNextTask("worker-a", time.Minute)
+	if err != nil || task == nil {
+		t.Fatalf("ClaimNextTask() = %v, %v", task, err)
+	}
+	if err := s.ProcessTask(context.Background(), task); err == nil || err.Error() != "test processor: disk hiccup 1" {
+		t.Errorf("ProcessTask() = %v, want the processing error", err)
+	}
+
+	stored := getTestTask(t, s, taskID)
+	if stored.Status != entity.TaskStatusPending || stored.LeaseOwner != "" || stored.ErrorMessage != "test processor: disk hiccup 1" {
+		t.Errorf("after a failed attempt: status %s, lease %q, error %q", stored.Status, stored.LeaseOwner, stored.ErrorMessage)
+	}
+	// DATETIME columns keep whole seconds
+	if stored.NextAttemptAt == nil || time.Until(*stored.NextAttemptAt) < 59*time.Minute || time.Until(*stored.NextAttemptAt) > time.Hour+time.Second {
+		t.Errorf("next attempt at %v, want an hour from now", stored.NextAttemptAt)
+	}
+
+	// The retry waits for its time
+	if task, err := s.ClaimNextTask("worker-a", time.Minute); err != nil || task != nil {
+		t.Fatalf("ClaimNextTask() before the retry is due = %v, %v", task, err)
+	}
+	if err := db.Model(&entity.ReadingTask{}).Where("id = ?", taskID).Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	task, err = s.ClaimNextTask("worker-b", time.Minute)
+	if err != nil || task == nil || task.Attempts != 2 {
+		t.Fatalf("ClaimNextTask() of the retry = %+v, %v", task, err)
+	}
+	if err := s.ProcessTask(context.Background(), task); err == nil {
+		t.Error("ProcessTask() = nil, want the processing error")
+	}
+
+	stored = getTestTask(t, s, taskID)
+	if stored.Status != entity.TaskStatusFailed || stored.NextAttemptAt != nil || stored.ErrorMessage != "test processor: disk hiccup 2" {
+		t.Errorf("after the last attempt: status %s, next attempt %v, error %q; want failed",
+			stored.Status, stored.NextAttemptAt, stored.ErrorMessage)
+	}
+
+	events, err := s.repo.System().GetTaskEvents(taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("events = %+v, want two claims and two failures", events)
+	}
+	if !strings.HasPrefix(events[1].Reason, "attempt 1 failed, retrying at ") || events[1].ToStatus != entity.TaskStatusPending {
+		t.Errorf("first failure event = %+v", events[1])
+	}
+	if events[3].Reason != "attempt 2 failed, giving up: test processor: disk hiccup 2" || events[3].ToStatus != entity.TaskStatusFailed {
+		t.Errorf("last failure event = %+v", events[3])
+	}
+}
+
+func TestProcessTaskFailsPermanentErrorsAtOnce(t *testing.T) {
+	s, db := newTestReadingService(t, funcProcessor(func(ctx context.Context, doc *processor.Document) (*processor.Result, error) {
+		return nil, fmt.Errorf("%w: truncated file", processor.ErrInvalidDocument)
+	}), RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour})
+	user := createTestUser(t, db, entity.DefaultOrgID, "reader@example.com")
+	taskID := uploadTestFile(t, s, user, "book.txt", "Chapter 1").TaskID
+
+	task, err := s.ClaimNextTask("worker-a", time.Minute)
+	if err != nil || task == nil {
+		t.Fatalf("ClaimNextTask() = %v, %v", task, err)
+	}
+	if err := s.ProcessTask(context.Background(), task); !errors.Is(err, processor.ErrInvalidDocument) {
+		t.Errorf("ProcessTask() = %v, want ErrInvalidDocument", err)
+	}
+
+	if stored := getTestTask(t, s, taskID); stored.Status != entity.TaskStatusFailed || stored.Attempts != 1 {
+		t.Errorf("status %s after %d attempts, want failed after 1", stored.Status, stored.Attempts)
+	}
+}
+
+func TestCreateTaskRejectsUnknownUser(t *testing.T) {
+	s, _ := newTestReadingService(t, funcProcessor(readText), RetryPolicy{MaxAttempts: 1})
+
+	_, err := s.CreateTaskFromReader(context.Background(), 404, "book.txt", strings.NewReader("Chapter 1"), 9, "", nil)
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("CreateTaskFromReader() for an unknown user = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestDeleteTaskKeepsSharedFiles(t *testing.T) {
+	s, db := newTestReadingService(t, funcProcessor(readText), RetryPolicy{MaxAttempts: 1})
+	user := createTestUser(t, db, entity.DefaultOrgID, "reader@example.com")
+	ctx := context.Background()
+
+	first := uploadTestFile(t, s, user, "book.txt", "Chapter 1")
+	second := uploadTestFile(t, s, user, "copy.txt", "Chapter 1")
+	if !second.Deduplicated {
+		t.Fatalf("second upload = %+v, want it deduplicated", second)
+	}
+	key := getTestTask(t, s, first.TaskID).FilePath
+
+	if err := s.DeleteTask(ctx, user, first.TaskID); err != nil {
+		t.Fatalf("DeleteTask() = %v", err)
+	}
+	if _, err := s.files.Stat(ctx, key); err != nil {
+		t.Errorf("Stat() after deleting one of two tasks = %v, want the file kept", err)
+	}
+
+	if err := s.DeleteTask(ctx, user, second.TaskID); err != nil {
+		t.Fatalf("DeleteTask() = %v", err)
+	}
+	if _, err := s.files.Stat(ctx, key); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Stat() after deleting both tasks = %v, want ErrNotFound", err)
+	}
+}
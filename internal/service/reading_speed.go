@@ -0,0 +1,28 @@
+package service
+
+import (
+	"math"
+	"textile-admin/internal/processor"
+)
+
+// ReadingSpeed is the pace used to estimate how long a document takes to read
+type ReadingSpeed struct {
+	// WordsPerMinute applies to text in alphabetic scripts
+	WordsPerMinute int
+	// CJKCharsPerMinute applies to Chinese, Japanese and Korean text
+	CJKCharsPerMinute int
+}
+
+// Minutes returns the estimated reading time of text with the given
+// statistics, rounded up to whole minutes
+func (s ReadingSpeed) Minutes(stats processor.Stats) int {
+	var minutes float64
+	if s.WordsPerMinute > 0 {
+		minutes += float64(stats.Words) / float64(s.WordsPerMinute)
+	}
+	if s.CJKCharsPerMinute > 0 {
+		minutes += float64(stats.CJKChars) / float64(s.CJKCharsPerMinute)
+	}
+
+	return int(math.Ceil(minutes))
+}
@@ -0,0 +1,34 @@
+package service
+
+import "time"
+
+// RetryPolicy controls how failed processing attempts are retried
+type RetryPolicy struct {
+	// MaxAttempts is the number of processing attempts before a task fails
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry; it doubles per attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between attempts
+	MaxDelay time.Duration
+}
+
+// ShouldRetry reports whether a task that has made attempts attempts may be retried
+func (p RetryPolicy) ShouldRetry(attempts int) bool {
+	return attempts < p.MaxAttempts
+}
+
+// Backoff returns the wait before the retry following the given attempt
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	capped := RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	uncapped := RetryPolicy{MaxAttempts: 10, BaseDelay: 30 * time.Second}
+
+	tests := []struct {
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{capped, 1, time.Second},
+		{capped, 2, 2 * time.Second},
+		{capped, 3, 4 * time.Second},
+		{capped, 4, 8 * time.Second},
+		{capped, 5, 10 * time.Second},
+		{capped, 6, 10 * time.Second},
+		{capped, 1000, 10 * time.Second},
+		{uncapped, 1, 30 * time.Second},
+		{uncapped, 5, 8 * time.Minute},
+		{RetryPolicy{BaseDelay: time.Minute, MaxDelay: time.Second}, 1, time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := tt.policy.Backoff(tt.attempt); got != tt.want {
+			t.Errorf("%+v.Backoff(%d) = %s, want %s", tt.policy, tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3}
+
+	for attempts, want := range map[int]bool{0: true, 1: true, 2: true, 3: false, 4: false} {
+		if got := policy.ShouldRetry(attempts); got != want {
+			t.Errorf("ShouldRetry(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+)
+
+// permissionDescriptions describes each permission
+var permissionDescriptions = map[string]string{
+	entity.PermTasksReadAll:     "Read every user's tasks, task lists and files",
+	entity.PermTasksStatusWrite: "Change the status of tasks",
+	entity.PermTasksDeleteAll:   "Delete every user's tasks",
+	entity.PermStatsReadAll:     "Read every user's reading statistics",
+	entity.PermUsersManage:      "List users, change other users' accounts and assign roles",
+	entity.PermOrgsManage:       "List and create organizations, move users between them and assign the superadmin role",
+}
+
+// defaultRoles are the built-in roles. Readers have no permissions: they
+// can access only their own tasks and data. Admins are limited to their
+// organization; only super admins act across organizations.
+var defaultRoles = []struct {
+	name        string
+	description string
+	permissions []string
+}{
+	{
+		name:        entity.RoleAdmin,
+		description: "Administrator with access to all users and tasks",
+		permissions: []string{entity.PermTasksReadAll, entity.PermTasksStatusWrite, entity.PermTasksDeleteAll, entity.PermStatsReadAll, entity.PermUsersManage},
+	},
+	{
+		name:        entity.RoleSuperAdmin,
+		description: "Deployment administrator who manages organizations",
+		permissions: []string{entity.PermOrgsManage},
+	},
+	{
+		name:        entity.RoleWorker,
+		description: "Processing worker that reads tasks and reports their status",
+		permissions: []string{entity.PermTasksReadAll, entity.PermTasksStatusWrite},
+	},
+	{
+		name:        entity.RoleReader,
+		description: "Reader with access to their own tasks",
+	},
+}
+
+// RoleService handles the business logic for roles and permissions
+type RoleService struct {
+	repo     *repository.RoleRepository
+	userRepo *repository.UserRepository
+}
+
+// NewRoleService creates a new instance of RoleService
+func NewRoleService(repo *repository.RoleRepository, userRepo *repository.UserRepository) *RoleService {
+	return &RoleService{repo: repo, userRepo: userRepo}
+}
+
+// SeedRoles creates the built-in roles and permissions, and resets the
+// built-in roles to their default permissions
+func (s *RoleService) SeedRoles() error {
+	for _, def := range defaultRoles {
+		role := &entity.Role{Name: def.name, Description: def.description}
+		for _, name := range def.permissions {
+			role.Permissions = append(role.Permissions, &entity.Permission{
+				Name:        name,
+				Description: permissionDescriptions[name],
+			})
+		}
+
+		if err := s.repo.EnsureRole(role); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SeedAdmin creates an admin account, which is also the super admin, when
+// no user holds the admin role yet. It does nothing when email is empty.
+// An existing account with the email is never promoted, since anyone could
+// have signed up with it.
+func (s *RoleService) SeedAdmin(username, email, password string) error {
+	if email == "" {
+		return nil
+	}
+
+	admins, err := s.repo.CountUsersWithRole(entity.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	existing, err := s.userRepo.GetUserByEmail(strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		log.Printf("Not seeding admin: user %d already has email %s; assign the admin role to an account instead", existing.ID, email)
+		return nil
+	}
+
+	roles, err := s.repo.GetRolesByNames([]string{entity.RoleAdmin, entity.RoleSuperAdmin})
+	if err != nil {
+		return err
+	}
+
+	user := &entity.User{OrgID: entity.DefaultOrgID, Roles: roles}
+	if err := applyUserFields(user, &username, &email); err != nil {
+		return err
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+
+	if err := s.userRepo.CreateUser(user); err != nil {
+		return err
+	}
+
+	log.Printf("Seeded admin account %s (user %d)", user.Email, user.ID)
+	return nil
+}
+
+// ListRoles retrieves all roles with their permissions
+func (s *RoleService) ListRoles() ([]*entity.Role, error) {
+	return s.repo.ListRoles()
+}
+
+// SetUserRoles replaces the roles of a user in the requester's organization
+// with the named ones. It returns ErrUserNotFound for unknown users and
+// users of other organizations, ErrInvalidRole for unknown role names and
+// ErrForbidden when a requester without PermOrgsManage grants or revokes
+// the super admin role, which would reach beyond their organization.
+func (s *RoleService) SetUserRoles(requester *entity.User, userID int64, names []string) (*entity.User, error) {
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil || user.OrgID != requester.OrgID {
+		return nil, ErrUserNotFound
+	}
+
+	roles, err := s.repo.GetRolesByNames(names)
+	if err != nil {
+		return nil, err
+	}
+
+	if missing := missingRoles(names, roles); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRole, strings.Join(missing, ", "))
+	}
+
+	if hasRole(user.Roles, entity.RoleSuperAdmin) != hasRole(roles, entity.RoleSuperAdmin) && !requester.HasPermission(entity.PermOrgsManage) {
+		return nil, ErrForbidden
+	}
+
+	if err := s.repo.SetUserRoles(user, roles); err != nil {
+		return nil, err
+	}
+
+	return s.userRepo.GetUserByID(userID)
+}
+
+// hasRole reports whether roles include the named role
+func hasRole(roles []*entity.Role, name string) bool {
+	for _, role := range roles {
+		if role.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// missingRoles returns the names that none of roles has, sorted
+func missingRoles(names []string, roles []*entity.Role) []string {
+	found := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		found[role.Name] = true
+	}
+
+	var missing []string
+	for _, name := range names {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// testPassword is a valid password for test accounts
+const testPassword = "correct horse"
+
+// createTestOrg adds an organization and returns its ID
+func createTestOrg(t *testing.T, db *gorm.DB, name string) int64 {
+	t.Helper()
+
+	org := &entity.Organization{Name: name}
+	if err := repository.NewOrganizationRepository(db).CreateOrganization(org); err != nil {
+		t.Fatal(err)
+	}
+	return org.ID
+}
+
+func TestSeedRolesResetsPermissions(t *testing.T) {
+	db := openTestDB(t)
+	roleRepo := repository.NewRoleRepository(db)
+	s := NewRoleService(roleRepo, repository.NewUserRepository(db))
+
+	// Hand out an extra permission, as an operator might have
+	worker, err := roleRepo.GetRolesByNames([]string{entity.RoleWorker})
+	if err != nil || len(worker) != 1 {
+		t.Fatalf("GetRolesByNames(worker) = %v, %v", worker, err)
+	}
+	var manage entity.Permission
+	if err := db.Where("name = ?", entity.PermUsersManage).First(&manage).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(worker[0]).Association("Permissions").Append(&manage); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SeedRoles(); err != nil {
+		t.Fatalf("SeedRoles() again = %v", err)
+	}
+
+	roles, err := s.ListRoles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{entity.RoleAdmin: 5, entity.RoleReader: 0, entity.RoleSuperAdmin: 1, entity.RoleWorker: 2}
+	if len(roles) != len(want) {
+		t.Fatalf("ListRoles() = %d roles, want %d", len(roles), len(want))
+	}
+	for _, role := range roles {
+		if len(role.Permissions) != want[role.Name] {
+			t.Errorf("role %s has %d permissions, want %d", role.Name, len(role.Permissions), want[role.Name])
+		}
+	}
+}
+
+func TestSeedAdmin(t *testing.T) {
+	db := openTestDB(t)
+	userRepo := repository.NewUserRepository(db)
+	s := NewRoleService(repository.NewRoleRepository(db), userRepo)
+
+	// An account someone signed up with is never promoted
+	taken := createTestUser(t, db, entity.DefaultOrgID, "taken@example.com")
+	if err := s.SeedAdmin("Admin", "taken@example.com", testPassword); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := userRepo.GetUserByID(taken.ID); user.HasPermission(entity.PermUsersManage) {
+		t.Error("SeedAdmin() promoted an existing account")
+	}
+
+	if err := s.SeedAdmin("Admin", "Admin@Example.com", testPassword); err != nil {
+		t.Fatalf("SeedAdmin() = %v", err)
+	}
+	admin, err := userRepo.GetUserByEmail("admin@example.com")
+	if err != nil || admin == nil {
+		t.Fatalf("GetUserByEmail(admin) = %v, %v", admin, err)
+	}
+	if !admin.HasPermission(entity.PermUsersManage) || !admin.HasPermission(entity.PermOrgsManage) {
+		t.Errorf("seeded admin has roles %+v, want admin and superadmin", admin.Roles)
+	}
+
+	// Once there is an admin, no other is seeded
+	if err := s.SeedAdmin("Second", "second@example.com", testPassword); err != nil {
+		t.Fatal(err)
+	}
+	if second, _ := userRepo.GetUserByEmail("second@example.com"); second != nil {
+		t.Error("SeedAdmin() created a second admin")
+	}
+}
+
+func TestSetUserRoles(t *testing.T) {
+	db := openTestDB(t)
+	s := NewRoleService(repository.NewRoleRepository(db), repository.NewUserRepository(db))
+	admin := createTestUser(t, db, entity.DefaultOrgID, "admin@example.com", entity.RoleAdmin)
+	superAdmin := createTestUser(t, db, entity.DefaultOrgID, "root@example.com", entity.RoleAdmin, entity.RoleSuperAdmin)
+	reader := createTestUser(t, db, entity.DefaultOrgID, "reader@example.com", entity.RoleReader)
+	outsider := createTestUser(t, db, createTestOrg(t, db, "Other"), "outsider@example.com", entity.RoleReader)
+
+	user, err := s.SetUserRoles(admin, reader.ID, []string{entity.RoleWorker, entity.RoleReader})
+	if err != nil {
+		t.Fatalf("SetUserRoles() = %v", err)
+	}
+	if !user.HasPermission(entity.PermTasksStatusWrite) || user.HasPermission(entity.PermUsersManage) {
+		t.Errorf("user roles = %+v, want worker and reader", user.Roles)
+	}
+
+	if _, err := s.SetUserRoles(admin, reader.ID, []string{"owner", entity.RoleReader, "editor"}); !errors.Is(err, ErrInvalidRole) || err.Error() != "invalid role: editor, owner" {
+		t.Errorf("SetUserRoles() with unknown roles = %v, want ErrInvalidRole naming them", err)
+	}
+	if _, err := s.SetUserRoles(admin, outsider.ID, []string{entity.RoleAdmin}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("SetUserRoles() in another organization = %v, want ErrUserNotFound", err)
+	}
+
+	// Only super admins grant or revoke the super admin role
+	if _, err := s.SetUserRoles(admin, reader.ID, []string{entity.RoleSuperAdmin}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("SetUserRoles() granting superadmin = %v, want ErrForbidden", err)
+	}
+	if _, err := s.SetUserRoles(admin, superAdmin.ID, []string{entity.RoleAdmin}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("SetUserRoles() revoking superadmin = %v, want ErrForbidden", err)
+	}
+	if _, err := s.SetUserRoles(superAdmin, reader.ID, []string{entity.RoleSuperAdmin}); err != nil {
+		t.Errorf("SetUserRoles() granting superadmin as a super admin = %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"strings"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+	"textile-admin/internal/search"
+	"unicode"
+)
+
+// Snippet layout, in characters
+const (
+	snippetContext = 40  // shown before a match
+	snippetLength  = 160 // total length of a snippet
+	maxSnippets    = 3   // per task
+)
+
+// SearchService handles full-text search over the extracted text of tasks
+type SearchService struct {
+	index     *search.Index
+	tasksRepo *repository.ReadingRepository
+}
+
+// NewSearchService creates a new instance of SearchService
+func NewSearchService(index *search.Index, tasksRepo *repository.ReadingRepository) *SearchService {
+	return &SearchService{
+		index:     index,
+		tasksRepo: tasksRepo,
+	}
+}
+
+// Search finds the user's processed tasks whose text contains every term of
+// query and returns up to limit of them, best matches first. Tasks processed
+// before search was available are indexed on first use.
+func (s *SearchService) Search(userID int64, query string, limit int) (*entity.SearchResponse, error) {
+	tasksRepo := s.tasksRepo.ForOwner(userID)
+	tasks, err := tasksRepo.GetTasksByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*entity.ReadingTask, len(tasks))
+	var docIDs []int64
+	for _, task := range tasks {
+		if task.Status != entity.TaskStatusCompleted {
+			continue
+		}
+		byID[task.ID] = task
+		docIDs = append(docIDs, task.ID)
+	}
+
+	missing, err := s.index.Missing(docIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, taskID := range missing {
+		if err := s.indexTask(tasksRepo, taskID); err != nil {
+			return nil, err
+		}
+	}
+
+	results, err := s.index.Search(query, docIDs)
+	if errors.Is(err, search.ErrEmptyQuery) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response := &entity.SearchResponse{
+		Query:   query,
+		Total:   len(results),
+		Results: make([]*entity.SearchResult, 0, limit),
+	}
+
+	for _, result := range results {
+		if len(response.Results) == limit {
+			break
+		}
+
+		snippets, err := s.buildSnippets(tasksRepo, result)
+		if err != nil {
+			return nil, err
+		}
+
+		matchCount := result.Phrases
+		if matchCount == 0 {
+			matchCount = len(result.Matches)
+		}
+
+		response.Results = append(response.Results, &entity.SearchResult{
+			TaskID:     result.DocID,
+			FileName:   byID[result.DocID].FileName,
+			Score:      math.Round(result.Score*1000) / 1000,
+			MatchCount: matchCount,
+			Snippets:   snippets,
+		})
+	}
+
+	return response, nil
+}
+
+// indexTask indexes the content of a task that is missing from the index
+func (s *SearchService) indexTask(tasksRepo *repository.ReadingRepository, taskID int64) error {
+	content, err := tasksRepo.GetTaskContent(taskID)
+	if err != nil {
+		return err
+	}
+
+	if content == nil {
+		return nil
+	}
+
+	log.Printf("Indexing content of task %d", taskID)
+	return s.index.Add(taskID, content.Content)
+}
+
+// buildSnippets cuts passages around the first few matches of a result,
+// skipping matches that fall into an earlier passage
+func (s *SearchService) buildSnippets(tasksRepo *repository.ReadingRepository, result *search.Result) ([]*entity.SearchSnippet, error) {
+	chapters, err := tasksRepo.GetTaskChapters(result.DocID)
+	if err != nil {
+		return nil, err
+	}
+
+	snippets := []*entity.SearchSnippet{}
+	nextFree := 0
+
+	for _, match := range result.Matches {
+		if len(snippets) == maxSnippets {
+			break
+		}
+		if match.Start < nextFree {
+			continue
+		}
+
+		start := match.Start - snippetContext
+		if start < 0 {
+			start = 0
+		}
+
+		page, err := tasksRepo.GetTaskContentPage(result.DocID, start, snippetLength)
+		if err != nil {
+			return nil, err
+		}
+		if page == nil {
+			break
+		}
+
+		text := []rune(page.Content)
+		end := start + len(text)
+		nextFree = end
+
+		snippet := &entity.SearchSnippet{
+			Offset: match.Start,
+			Text:   highlightSnippet(text, start, result.Highlights, start > 0, end < page.CharCount),
+		}
+		for _, chapter := range chapters {
+			if match.Start >= chapter.StartOffset && match.Start < chapter.EndOffset {
+				snippet.Chapter = chapter.Ordinal
+				snippet.ChapterTitle = chapter.Title
+				break
+			}
+		}
+
+		snippets = append(snippets, snippet)
+	}
+
+	return snippets, nil
+}
+
+// highlightSnippet renders text, which starts at character offset start of
+// the document, as HTML with the highlights that fall into it marked.
+// Overlapping highlights, such as the bigrams of a CJK phrase, are merged.
+func highlightSnippet(text []rune, start int, highlights []search.Posting, leading, trailing bool) string {
+	end := start + len(text)
+
+	var b strings.Builder
+	if leading {
+		b.WriteString("…")
+	}
+
+	pos := start // next character of text to write
+	for i := 0; i < len(highlights); i++ {
+		hlStart := highlights[i].Start
+		hlEnd := hlStart + highlights[i].Length
+		if hlEnd <= pos || hlStart >= end {
+			continue
+		}
+
+		for i+1 < len(highlights) && highlights[i+1].Start <= hlEnd {
+			if e := highlights[i+1].Start + highlights[i+1].Length; e > hlEnd {
+				hlEnd = e
+			}
+			i++
+		}
+
+		if hlStart < pos {
+			hlStart = pos
+		}
+		if hlEnd > end {
+			hlEnd = end
+		}
+
+		b.WriteString(snippetText(text[pos-start : hlStart-start]))
+		b.WriteString("<mark>")
+		b.WriteString(snippetText(text[hlStart-start : hlEnd-start]))
+		b.WriteString("</mark>")
+		pos = hlEnd
+	}
+
+	b.WriteString(snippetText(text[pos-start:]))
+	if trailing {
+		b.WriteString("…")
+	}
+
+	return b.String()
+}
+
+// snippetText escapes text for HTML and collapses runs of white space,
+// including line breaks, into single spaces
+func snippetText(text []rune) string {
+	var b strings.Builder
+	space := false
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		b.WriteRune(r)
+		space = false
+	}
+	return html.EscapeString(b.String())
+}
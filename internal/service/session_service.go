@@ -0,0 +1,198 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+	"time"
+)
+
+// maxStatsDays limits the length of the date range of reading stats
+const maxStatsDays = 366
+
+// dateLayout is the format of dates in reading stats
+const dateLayout = "2006-01-02"
+
+// SessionService handles the business logic for reading sessions and the
+// statistics built from them
+type SessionService struct {
+	repo         *repository.SessionRepository
+	progressRepo *repository.ProgressRepository
+	tasksRepo    *repository.ReadingRepository
+	userRepo     *repository.UserRepository
+	idleTimeout  time.Duration
+}
+
+// NewSessionService creates a new instance of SessionService. A session that
+// receives no heartbeat for idleTimeout is ended at its last heartbeat.
+func NewSessionService(repo *repository.SessionRepository, progressRepo *repository.ProgressRepository, tasksRepo *repository.ReadingRepository, userRepo *repository.UserRepository, idleTimeout time.Duration) *SessionService {
+	if idleTimeout <= 0 {
+		idleTimeout = 5 * time.Minute
+	}
+
+	return &SessionService{
+		repo:         repo,
+		progressRepo: progressRepo,
+		tasksRepo:    tasksRepo,
+		userRepo:     userRepo,
+		idleTimeout:  idleTimeout,
+	}
+}
+
+// StartSession opens a reading session of a user on a task
+func (s *SessionService) StartSession(userID, taskID int64) (*entity.ReadingSession, error) {
+	task, err := s.tasksRepo.ForOwner(userID).GetTaskByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Other users' tasks are reported as missing
+	if task == nil || task.UserID != userID {
+		return nil, ErrTaskNotFound
+	}
+
+	now := time.Now()
+	session := &entity.ReadingSession{
+		UserID:          userID,
+		TaskID:          taskID,
+		StartedAt:       now,
+		LastHeartbeatAt: now,
+	}
+
+	if err := s.repo.CreateSession(session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Heartbeat records that a user is still reading in a session
+func (s *SessionService) Heartbeat(userID, sessionID int64) (*entity.ReadingSession, error) {
+	return s.extendSession(userID, sessionID, false)
+}
+
+// EndSession closes a user's reading session
+func (s *SessionService) EndSession(userID, sessionID int64) (*entity.ReadingSession, error) {
+	return s.extendSession(userID, sessionID, true)
+}
+
+// extendSession credits a session with the time since its last heartbeat.
+// It returns ErrSessionClosed when the session ended or went idle for longer
+// than the idle timeout; the client should start a new one.
+func (s *SessionService) extendSession(userID, sessionID int64, end bool) (*entity.ReadingSession, error) {
+	session, err := s.repo.GetSessionByID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Other users' sessions are reported as missing
+	if session == nil || session.UserID != userID {
+		return nil, ErrSessionNotFound
+	}
+
+	session, err = s.repo.ExtendSession(sessionID, time.Now(), s.idleTimeout, end)
+	switch {
+	case errors.Is(err, repository.ErrSessionEnded):
+		return nil, fmt.Errorf("%w: session has already ended", ErrSessionClosed)
+	case errors.Is(err, repository.ErrSessionExpired):
+		return nil, fmt.Errorf("%w: no heartbeat for more than %s", ErrSessionClosed, s.idleTimeout)
+	case err != nil:
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// GetUserStats aggregates a user's reading between two dates, both
+// inclusive, with days counted in loc. Sessions count towards the day they
+// started on. The current streak is the run of reading days ending on the
+// last day of the range, or on the day before if that is today and the user
+// has not read yet. Reading another user's statistics requires
+// PermStatsReadAll; without it ErrForbidden is returned.
+func (s *SessionService) GetUserStats(requester *entity.User, userID int64, from, to time.Time, loc *time.Location) (*entity.UserReadingStats, error) {
+	if err := authorizeUser(s.userRepo, requester, userID, entity.PermStatsReadAll); err != nil {
+		return nil, err
+	}
+
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	last := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc)
+	if last.Before(start) {
+		return nil, fmt.Errorf("%w: from must not be after to", ErrInvalidDateRange)
+	}
+
+	days := 0
+	for day := start; !day.After(last); day = day.AddDate(0, 0, 1) {
+		days++
+	}
+	if days > maxStatsDays {
+		return nil, fmt.Errorf("%w: at most %d days may be requested", ErrInvalidDateRange, maxStatsDays)
+	}
+	end := last.AddDate(0, 0, 1)
+
+	sessions, err := s.repo.GetSessionsStartedBetween(userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	finished, err := s.progressRepo.CountFinishedTasks(userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	secondsByDay := make(map[string]int, days)
+	totalSeconds := 0
+	for _, session := range sessions {
+		secondsByDay[session.StartedAt.In(loc).Format(dateLayout)] += session.ActiveSeconds
+		totalSeconds += session.ActiveSeconds
+	}
+
+	stats := &entity.UserReadingStats{
+		UserID:         userID,
+		From:           start.Format(dateLayout),
+		To:             last.Format(dateLayout),
+		TimeZone:       loc.String(),
+		TotalMinutes:   secondsToMinutes(totalSeconds),
+		Sessions:       len(sessions),
+		BooksCompleted: finished,
+		Days:           make([]entity.DailyReading, 0, days),
+	}
+
+	daySeconds := make([]int, 0, days)
+	streak := 0
+	for day := start; !day.After(last); day = day.AddDate(0, 0, 1) {
+		date := day.Format(dateLayout)
+		seconds := secondsByDay[date]
+		daySeconds = append(daySeconds, seconds)
+		stats.Days = append(stats.Days, entity.DailyReading{
+			Date:    date,
+			Minutes: secondsToMinutes(seconds),
+		})
+
+		if seconds > 0 {
+			streak++
+		} else {
+			streak = 0
+		}
+		if streak > stats.LongestStreak {
+			stats.LongestStreak = streak
+		}
+	}
+
+	// Today is not over yet, so not having read today does not break the streak
+	current := len(daySeconds) - 1
+	if last.Format(dateLayout) == time.Now().In(loc).Format(dateLayout) && daySeconds[current] == 0 {
+		current--
+	}
+	for ; current >= 0 && daySeconds[current] > 0; current-- {
+		stats.CurrentStreak++
+	}
+
+	return stats, nil
+}
+
+// secondsToMinutes converts seconds to minutes rounded to one decimal
+func secondsToMinutes(seconds int) float64 {
+	return math.Round(float64(seconds)/6) / 10
+}
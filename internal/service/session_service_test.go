@@ -0,0 +1,117 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+	"time"
+)
+
+func TestSessionLifecycle(t *testing.T) {
+	readingService, db := newTestReadingService(t, funcProcessor(readText), RetryPolicy{MaxAttempts: 1})
+	s := NewSessionService(repository.NewSessionRepository(db), repository.NewProgressRepository(db),
+		readingService.repo, repository.NewUserRepository(db), time.Minute)
+	reader := createTestUser(t, db, entity.DefaultOrgID, "reader@example.com")
+	other := createTestUser(t, db, entity.DefaultOrgID, "other@example.com")
+	taskID := uploadTestFile(t, readingService, reader, "book.txt", "Chapter 1").TaskID
+
+	if _, err := s.StartSession(other.ID, taskID); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("StartSession() on another user's task = %v, want ErrTaskNotFound", err)
+	}
+
+	session, err := s.StartSession(reader.ID, taskID)
+	if err != nil {
+		t.Fatalf("StartSession() = %v", err)
+	}
+	if _, err := s.Heartbeat(other.ID, session.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Heartbeat() in another user's session = %v, want ErrSessionNotFound", err)
+	}
+	if _, err := s.Heartbeat(reader.ID, session.ID); err != nil {
+		t.Errorf("Heartbeat() = %v", err)
+	}
+	if ended, err := s.EndSession(reader.ID, session.ID); err != nil || ended.EndedAt == nil {
+		t.Errorf("EndSession() = %+v, %v; want an ended session", ended, err)
+	}
+	if _, err := s.Heartbeat(reader.ID, session.ID); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("Heartbeat() after EndSession() = %v, want ErrSessionClosed", err)
+	}
+
+	// A session left idle past the timeout is closed
+	idle, err := s.StartSession(reader.ID, taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(idle).Update("last_heartbeat_at", time.Now().Add(-2*time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Heartbeat(reader.ID, idle.ID); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("Heartbeat() after the idle timeout = %v, want ErrSessionClosed", err)
+	}
+}
+
+func TestGetUserStats(t *testing.T) {
+	readingService, db := newTestReadingService(t, funcProcessor(readText), RetryPolicy{MaxAttempts: 1})
+	sessions := repository.NewSessionRepository(db)
+	s := NewSessionService(sessions, repository.NewProgressRepository(db),
+		readingService.repo, repository.NewUserRepository(db), time.Minute)
+	reader := createTestUser(t, db, entity.DefaultOrgID, "reader@example.com")
+	other := createTestUser(t, db, entity.DefaultOrgID, "other@example.com")
+	admin := createTestUser(t, db, entity.DefaultOrgID, "admin@example.com", entity.RoleAdmin)
+	taskID := uploadTestFile(t, readingService, reader, "book.txt", "Chapter 1").TaskID
+
+	day := func(d, hour int) time.Time { return time.Date(2024, time.March, d, hour, 0, 0, 0, time.UTC) }
+	for _, session := range []*entity.ReadingSession{
+		{StartedAt: day(1, 20), ActiveSeconds: 600},
+		{StartedAt: day(2, 8), ActiveSeconds: 300},
+		{StartedAt: day(2, 23), ActiveSeconds: 300},
+		{StartedAt: day(4, 12), ActiveSeconds: 1200},
+		{StartedAt: day(5, 7), ActiveSeconds: 60},
+		{StartedAt: day(6, 7), ActiveSeconds: 60},
+	} {
+		session.UserID, session.TaskID, session.LastHeartbeatAt = reader.ID, taskID, session.StartedAt
+		if err := sessions.CreateSession(session); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := s.GetUserStats(reader, reader.ID, day(1, 0), day(5, 0), time.UTC)
+	if err != nil {
+		t.Fatalf("GetUserStats() = %v", err)
+	}
+	if stats.Sessions != 5 || stats.TotalMinutes != 41 || stats.LongestStreak != 2 || stats.CurrentStreak != 2 {
+		t.Errorf("stats: %d sessions, %v minutes, streaks %d and %d; want 5, 41, 2 and 2",
+			stats.Sessions, stats.TotalMinutes, stats.LongestStreak, stats.CurrentStreak)
+	}
+	wantMinutes := []float64{10, 10, 0, 20, 1}
+	if len(stats.Days) != len(wantMinutes) {
+		t.Fatalf("stats cover %d days, want %d", len(stats.Days), len(wantMinutes))
+	}
+	for i, want := range wantMinutes {
+		if stats.Days[i].Minutes != want {
+			t.Errorf("%s: %v minutes, want %v", stats.Days[i].Date, stats.Days[i].Minutes, want)
+		}
+	}
+
+	// Days are counted in the requested time zone
+	shifted, err := s.GetUserStats(reader, reader.ID, day(2, 0), day(2, 0), time.FixedZone("UTC+5", 5*60*60))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shifted.Sessions != 2 || shifted.TotalMinutes != 15 {
+		t.Errorf("stats in UTC+5: %d sessions, %v minutes; want 2 and 15", shifted.Sessions, shifted.TotalMinutes)
+	}
+
+	if _, err := s.GetUserStats(other, reader.ID, day(1, 0), day(5, 0), time.UTC); !errors.Is(err, ErrForbidden) {
+		t.Errorf("GetUserStats() of another user = %v, want ErrForbidden", err)
+	}
+	if _, err := s.GetUserStats(admin, reader.ID, day(1, 0), day(5, 0), time.UTC); err != nil {
+		t.Errorf("GetUserStats() by an admin = %v", err)
+	}
+	if _, err := s.GetUserStats(reader, reader.ID, day(5, 0), day(1, 0), time.UTC); !errors.Is(err, ErrInvalidDateRange) {
+		t.Errorf("GetUserStats() from after to = %v, want ErrInvalidDateRange", err)
+	}
+	if _, err := s.GetUserStats(reader, reader.ID, day(1, 0), day(1, 0).AddDate(1, 1, 0), time.UTC); !errors.Is(err, ErrInvalidDateRange) {
+		t.Errorf("GetUserStats() over more than a year = %v, want ErrInvalidDateRange", err)
+	}
+}
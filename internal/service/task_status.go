@@ -0,0 +1,28 @@
+package service
+
+import "textile-admin/internal/domain/entity"
+
+// taskTransitions lists the statuses each task status may move to.
+// Completed is terminal; failed tasks may only be sent back to pending.
+var taskTransitions = map[string][]string{
+	entity.TaskStatusPending:    {entity.TaskStatusProcessing, entity.TaskStatusFailed},
+	entity.TaskStatusProcessing: {entity.TaskStatusCompleted, entity.TaskStatusFailed, entity.TaskStatusPending},
+	entity.TaskStatusFailed:     {entity.TaskStatusPending},
+	entity.TaskStatusCompleted:  {},
+}
+
+// IsValidTaskStatus reports whether status is a known task status
+func IsValidTaskStatus(status string) bool {
+	_, ok := taskTransitions[status]
+	return ok
+}
+
+// CanTransition reports whether a task may move from one status to another
+func CanTransition(from, to string) bool {
+	for _, allowed := range taskTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
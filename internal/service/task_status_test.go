@@ -0,0 +1,51 @@
+package service
+
+import (
+	"testing"
+	"textile-admin/internal/domain/entity"
+)
+
+func TestCanTransition(t *testing.T) {
+	statuses := []string{entity.TaskStatusPending, entity.TaskStatusProcessing, entity.TaskStatusCompleted, entity.TaskStatusFailed}
+	allowed := map[[2]string]bool{
+		{entity.TaskStatusPending, entity.TaskStatusProcessing}:   true,
+		{entity.TaskStatusPending, entity.TaskStatusFailed}:       true,
+		{entity.TaskStatusProcessing, entity.TaskStatusCompleted}: true,
+		{entity.TaskStatusProcessing, entity.TaskStatusFailed}:    true,
+		{entity.TaskStatusProcessing, entity.TaskStatusPending}:   true,
+		{entity.TaskStatusFailed, entity.TaskStatusPending}:       true,
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := allowed[[2]string{from, to}]
+			if got := CanTransition(from, to); got != want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+
+	for _, pair := range [][2]string{
+		{"archived", entity.TaskStatusPending},
+		{entity.TaskStatusPending, "archived"},
+		{"", entity.TaskStatusPending},
+		{entity.TaskStatusPending, ""},
+	} {
+		if CanTransition(pair[0], pair[1]) {
+			t.Errorf("CanTransition(%q, %q) = true for an unknown status", pair[0], pair[1])
+		}
+	}
+}
+
+func TestIsValidTaskStatus(t *testing.T) {
+	for _, status := range []string{entity.TaskStatusPending, entity.TaskStatusProcessing, entity.TaskStatusCompleted, entity.TaskStatusFailed} {
+		if !IsValidTaskStatus(status) {
+			t.Errorf("IsValidTaskStatus(%s) = false", status)
+		}
+	}
+	for _, status := range []string{"", "archived", "Pending"} {
+		if IsValidTaskStatus(status) {
+			t.Errorf("IsValidTaskStatus(%q) = true", status)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+	"textile-admin/internal/storage"
+	"textile-admin/pkg/charset"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxUploadMetadata is the maximum length of an upload's raw metadata
+	maxUploadMetadata = 2048
+	// uploadCleanupBatch is the number of expired sessions removed per query
+	uploadCleanupBatch = 100
+	// uploadPartSize is the most bytes of a chunk buffered before they are
+	// stored as a part of the upload
+	uploadPartSize = 8 << 20
+)
+
+// UploadService handles resumable uploads. A session is created with the
+// file's length; chunks are then appended at the session's offset and
+// stored in the file storage backend, so that an interrupted upload can
+// resume where it stopped, on any instance. When the last chunk arrives the
+// chunks are joined into a new reading task.
+type UploadService struct {
+	repo           *repository.UploadRepository
+	readingService *ReadingService
+	files          storage.Backend
+	maxSize        int64
+	ttl            time.Duration
+}
+
+// NewUploadService creates a new instance of UploadService. Uploads may be
+// up to maxSize bytes; sessions expire ttl after they were last written to.
+func NewUploadService(repo *repository.UploadRepository, readingService *ReadingService, files storage.Backend, maxSize int64, ttl time.Duration) *UploadService {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+
+	return &UploadService{
+		repo:           repo,
+		readingService: readingService,
+		files:          files,
+		maxSize:        maxSize,
+		ttl:            ttl,
+	}
+}
+
+// MaxSize returns the largest upload accepted, in bytes
+func (s *UploadService) MaxSize() int64 {
+	return s.maxSize
+}
+
+// CreateUpload starts an upload of a file of length bytes for requester.
+// encoding is the optional source encoding of text files, and metadata the
+// client's raw metadata, returned with the session. It returns
+// ErrUploadTooLarge above the size limit.
+func (s *UploadService) CreateUpload(ctx context.Context, requester *entity.User, length int64, fileName, encoding, metadata string) (*entity.UploadSession, error) {
+	if length < 0 {
+		return nil, fmt.Errorf("%w: length must not be negative", ErrInvalidUpload)
+	}
+	if length > s.maxSize {
+		return nil, fmt.Errorf("%w: %d bytes is more than %d", ErrUploadTooLarge, length, s.maxSize)
+	}
+
+	fileName = filepath.Base(strings.TrimSpace(fileName))
+	if fileName == "" || fileName == "." || fileName == string(filepath.Separator) {
+		return nil, fmt.Errorf("%w: a file name is required", ErrInvalidUpload)
+	}
+	if utf8.RuneCountInString(fileName) > maxUserFieldLength {
+		return nil, fmt.Errorf("%w: file name is longer than %d characters", ErrInvalidUpload, maxUserFieldLength)
+	}
+	if len(metadata) > maxUploadMetadata {
+		return nil, fmt.Errorf("%w: metadata is longer than %d bytes", ErrInvalidUpload, maxUploadMetadata)
+	}
+
+	// Validate a client-supplied encoding before accepting any data
+	if encoding != "" {
+		canonical, err := charset.Lookup(encoding)
+		if err != nil {
+			return nil, err
+		}
+		encoding = canonical
+	}
+
+	id, err := newUploadID()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &entity.UploadSession{
+		ID:        id,
+		UserID:    requester.ID,
+		FileName:  fileName,
+		Encoding:  encoding,
+		Metadata:  metadata,
+		Length:    length,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+
+	if err := s.repo.CreateSession(session); err != nil {
+		return nil, err
+	}
+
+	// An empty file is complete without any chunks
+	if length == 0 {
+		if err := s.complete(ctx, session); err != nil {
+			return nil, err
+		}
+	}
+
+	return session, nil
+}
+
+// GetUpload retrieves an upload session of requester. Sessions of other
+// users are reported as missing, and expired ones with ErrUploadExpired.
+func (s *UploadService) GetUpload(requester *entity.User, uploadID string) (*entity.UploadSession, error) {
+	session, err := s.repo.GetSession(uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	if session == nil || session.UserID != requester.ID {
+		return nil, ErrUploadNotFound
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		return nil, ErrUploadExpired
+	}
+
+	return session, nil
+}
+
+// WriteChunk appends size bytes read from body to an upload at offset,
+// which must be the session's current offset, and creates the reading task
+// once the upload is complete. The chunk is stored in parts as it arrives,
+// so when it is cut short what was received is kept and the client resumes
+// from the session's offset; the session is returned together with the
+// read error then. A chunk without data at the end of an upload whose task
+// could not be created retries creating it.
+func (s *UploadService) WriteChunk(ctx context.Context, requester *entity.User, uploadID string, offset int64, body io.Reader, size int64) (*entity.UploadSession, error) {
+	session, err := s.GetUpload(requester, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset != session.Offset {
+		return nil, fmt.Errorf("%w: upload is at offset %d", ErrUploadOffsetMismatch, session.Offset)
+	}
+
+	if session.Complete() {
+		if session.TaskID == nil && size == 0 {
+			return session, s.complete(ctx, session)
+		}
+		return nil, fmt.Errorf("%w: upload is complete", ErrUploadOffsetMismatch)
+	}
+
+	if size > session.Length-offset {
+		return nil, fmt.Errorf("%w: chunk ends after the upload's length of %d bytes", ErrUploadTooLarge, session.Length)
+	}
+
+	// A client that disconnects cancels the request, but what it sent
+	// must still be stored
+	storeCtx := context.WithoutCancel(ctx)
+
+	buf := make([]byte, min(size, uploadPartSize))
+	for remaining := size; remaining > 0; {
+		n, readErr := io.ReadFull(body, buf[:min(remaining, int64(len(buf)))])
+		if n > 0 {
+			if err := s.appendPart(storeCtx, session, buf[:n]); err != nil {
+				return nil, err
+			}
+			remaining -= int64(n)
+		}
+
+		if readErr == io.EOF {
+			readErr = io.ErrUnexpectedEOF
+		}
+		if readErr != nil {
+			return session, readErr
+		}
+	}
+
+	if size > 0 && session.Complete() {
+		if err := s.complete(ctx, session); err != nil {
+			return session, err
+		}
+	}
+
+	return session, nil
+}
+
+// appendPart stores data as the part of an upload at its current offset
+// and advances the session past it
+func (s *UploadService) appendPart(ctx context.Context, session *entity.UploadSession, data []byte) error {
+	suffix, err := newUploadID()
+	if err != nil {
+		return err
+	}
+
+	part := &entity.UploadPart{
+		SessionID: session.ID,
+		Offset:    session.Offset,
+		Size:      int64(len(data)),
+		Key:       fmt.Sprintf("tus/%s/%d_%s", session.ID, session.Offset, suffix[:8]),
+	}
+
+	if err := s.files.Put(ctx, part.Key, bytes.NewReader(data), part.Size); err != nil {
+		s.deleteObject(part.Key)
+		return err
+	}
+
+	expiresAt := time.Now().Add(s.ttl)
+	appended, err := s.repo.AppendPart(part, expiresAt)
+	if err != nil || !appended {
+		s.deleteObject(part.Key)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: upload was written to concurrently", ErrUploadOffsetMismatch)
+	}
+
+	session.Offset += part.Size
+	session.ExpiresAt = expiresAt
+	return nil
+}
+
+// TerminateUpload abandons an upload and deletes what was received. The
+// task of a completed upload is kept.
+func (s *UploadService) TerminateUpload(requester *entity.User, uploadID string) error {
+	session, err := s.GetUpload(requester, uploadID)
+	if err != nil {
+		return err
+	}
+
+	return s.deleteSession(session)
+}
+
+// CleanupExpired deletes the upload sessions that expired before now and
+// their chunks, returning how many were deleted
+func (s *UploadService) CleanupExpired(now time.Time) (int, error) {
+	deleted := 0
+
+	for {
+		sessions, err := s.repo.GetExpiredSessions(now, uploadCleanupBatch)
+		if err != nil {
+			return deleted, err
+		}
+
+		for _, session := range sessions {
+			if err := s.deleteSession(session); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+
+		if len(sessions) < uploadCleanupBatch {
+			return deleted, nil
+		}
+	}
+}
+
+// complete joins the chunks of a finished upload into a reading task and
+// deletes them. The session is claimed first, so that of concurrent
+// requests only one creates the task; the others fail with
+// ErrUploadOffsetMismatch.
+func (s *UploadService) complete(ctx context.Context, session *entity.UploadSession) error {
+	claimed, err := s.repo.ClaimCompletion(session.ID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return fmt.Errorf("%w: upload is being completed", ErrUploadOffsetMismatch)
+	}
+
+	taskID, err := s.createTask(ctx, session)
+	if err != nil {
+		// Let a chunk without data retry creating the task
+		if releaseErr := s.repo.ReleaseCompletion(session.ID); releaseErr != nil {
+			log.Printf("Error releasing upload %s after a failed completion: %v", session.ID, releaseErr)
+		}
+		return err
+	}
+	session.TaskID = &taskID
+
+	log.Printf("Upload %s completed as task %d", session.ID, taskID)
+	return nil
+}
+
+// createTask creates the reading task of a claimed upload from its chunks,
+// records it in the session and deletes the chunks
+func (s *UploadService) createTask(ctx context.Context, session *entity.UploadSession) (int64, error) {
+	parts, err := s.repo.GetParts(session.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	content := &partsReader{ctx: ctx, files: s.files, parts: parts}
+	defer content.Close()
+
+	result, err := s.readingService.CreateTaskFromReader(ctx, session.UserID, session.FileName, content, session.Length, session.Encoding, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.repo.CompleteSession(session.ID, result.TaskID); err != nil {
+		return 0, err
+	}
+
+	for _, part := range parts {
+		s.deleteObject(part.Key)
+	}
+
+	return result.TaskID, nil
+}
+
+// deleteSession deletes an upload session and its chunks
+func (s *UploadService) deleteSession(session *entity.UploadSession) error {
+	parts, err := s.repo.GetParts(session.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, part := range parts {
+		s.deleteObject(part.Key)
+	}
+
+	return s.repo.DeleteSession(session.ID)
+}
+
+// deleteObject removes a stored chunk, logging failures. Chunks left behind
+// take up space but are never read again.
+func (s *UploadService) deleteObject(key string) {
+	if err := s.files.Delete(context.Background(), key); err != nil {
+		log.Printf("Error deleting upload chunk %s: %v", key, err)
+	}
+}
+
+// partsReader reads the chunks of an upload one after another, opening each
+// only when the previous one is exhausted
+type partsReader struct {
+	ctx     context.Context
+	files   storage.Backend
+	parts   []*entity.UploadPart
+	current io.ReadCloser
+}
+
+// Read reads from the current chunk, moving on to the next at its end
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+
+			body, err := r.files.Get(r.ctx, r.parts[0].Key)
+			if err != nil {
+				return 0, err
+			}
+			r.current = body
+			r.parts = r.parts[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			err = nil
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// Close closes the chunk being read, if any
+func (r *partsReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
+
+// newUploadID returns a random upload session ID of 32 hex digits
+func newUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+	"time"
+)
+
+// newTestUploadService returns an upload service sharing the storage of a
+// test reading service, and the user uploading
+func newTestUploadService(t *testing.T) (*UploadService, *ReadingService, *entity.User) {
+	t.Helper()
+
+	readingService, db := newTestReadingService(t, funcProcessor(readText), RetryPolicy{MaxAttempts: 1})
+	s := NewUploadService(repository.NewUploadRepository(db), readingService, readingService.files, 1<<20, time.Hour)
+	return s, readingService, createTestUser(t, db, entity.DefaultOrgID, "reader@example.com")
+}
+
+// countTestTasks returns how many tasks user has
+func countTestTasks(t *testing.T, s *ReadingService, user *entity.User) int {
+	t.Helper()
+
+	tasks, err := s.repo.System().GetTasksByUserID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(tasks)
+}
+
+func TestWriteChunk(t *testing.T) {
+	s, readingService, user := newTestUploadService(t)
+	ctx := context.Background()
+	const content = "Chapter 1\nIt was a dark night.\n"
+
+	session, err := s.CreateUpload(ctx, user, int64(len(content)), "book.txt", "", "")
+	if err != nil {
+		t.Fatalf("CreateUpload() = %v", err)
+	}
+
+	if _, err := s.WriteChunk(ctx, user, session.ID, 0, strings.NewReader(content+"!"), int64(len(content))+1); !errors.Is(err, ErrUploadTooLarge) {
+		t.Errorf("WriteChunk() past the length = %v, want ErrUploadTooLarge", err)
+	}
+	if session, err = s.WriteChunk(ctx, user, session.ID, 0, strings.NewReader(content[:10]), 10); err != nil || session.Offset != 10 {
+		t.Fatalf("WriteChunk() of the first chunk = %+v, %v; want offset 10", session, err)
+	}
+	if _, err := s.WriteChunk(ctx, user, session.ID, 0, strings.NewReader(content[10:]), int64(len(content)-10)); !errors.Is(err, ErrUploadOffsetMismatch) {
+		t.Errorf("WriteChunk() at a stale offset = %v, want ErrUploadOffsetMismatch", err)
+	}
+
+	session, err = s.WriteChunk(ctx, user, session.ID, 10, strings.NewReader(content[10:]), int64(len(content)-10))
+	if err != nil || session.TaskID == nil {
+		t.Fatalf("WriteChunk() of the last chunk = %+v, %v; want a task", session, err)
+	}
+	file, err := readingService.files.Get(ctx, getTestTask(t, readingService, *session.TaskID).FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if stored, err := io.ReadAll(file); err != nil || string(stored) != content {
+		t.Errorf("task file = %q, %v; want the uploaded content", stored, err)
+	}
+
+	if _, err := s.WriteChunk(ctx, user, session.ID, session.Offset, strings.NewReader(""), 0); !errors.Is(err, ErrUploadOffsetMismatch) {
+		t.Errorf("WriteChunk() after completion = %v, want ErrUploadOffsetMismatch", err)
+	}
+	if count := countTestTasks(t, readingService, user); count != 1 {
+		t.Errorf("%d tasks after the upload, want 1", count)
+	}
+}
+
+func TestWriteChunkRetriesCompletion(t *testing.T) {
+	s, readingService, user := newTestUploadService(t)
+	ctx := context.Background()
+
+	// An empty upload whose task could not be created
+	session := &entity.UploadSession{ID: "0123456789abcdef0123456789abcdef", UserID: user.ID, FileName: "empty.txt", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := s.repo.CreateSession(session); err != nil {
+		t.Fatal(err)
+	}
+
+	// A request that is creating it holds the session
+	if claimed, err := s.repo.ClaimCompletion(session.ID); err != nil || !claimed {
+		t.Fatalf("ClaimCompletion() = %v, %v", claimed, err)
+	}
+	if _, err := s.WriteChunk(ctx, user, session.ID, 0, strings.NewReader(""), 0); !errors.Is(err, ErrUploadOffsetMismatch) {
+		t.Errorf("WriteChunk() while the upload is being completed = %v, want ErrUploadOffsetMismatch", err)
+	}
+	if err := s.repo.ReleaseCompletion(session.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Of concurrent retries, only one creates the task
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = s.WriteChunk(ctx, user, session.ID, 0, strings.NewReader(""), 0)
+		}(i)
+	}
+	wg.Wait()
+
+	completed := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			completed++
+		case !errors.Is(err, ErrUploadOffsetMismatch):
+			t.Errorf("WriteChunk() of a concurrent retry = %v, want ErrUploadOffsetMismatch", err)
+		}
+	}
+	if completed != 1 {
+		t.Errorf("%d retries completed the upload, want 1", completed)
+	}
+	if count := countTestTasks(t, readingService, user); count != 1 {
+		t.Errorf("%d tasks after concurrent retries, want 1", count)
+	}
+
+	if stored, err := s.GetUpload(user, session.ID); err != nil || stored.TaskID == nil || stored.Completing {
+		t.Errorf("GetUpload() = %+v, %v; want a completed session", stored, err)
+	}
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+	"time"
+	"unicode/utf8"
+)
+
+// maxUserFieldLength is the column size of usernames and email addresses
+const maxUserFieldLength = 255
+
+// UserService handles the business logic for users
+type UserService struct {
+	repo      *repository.UserRepository
+	tokenRepo *repository.TokenRepository
+	roleRepo  *repository.RoleRepository
+}
+
+// NewUserService creates a new instance of UserService
+func NewUserService(repo *repository.UserRepository, tokenRepo *repository.TokenRepository, roleRepo *repository.RoleRepository) *UserService {
+	return &UserService{repo: repo, tokenRepo: tokenRepo, roleRepo: roleRepo}
+}
+
+// CreateUser creates a user with the reader role in an organization who
+// logs in with password. It returns ErrEmailExists when the email address is taken.
+func (s *UserService) CreateUser(orgID int64, username, email, password string) (*entity.User, error) {
+	user := &entity.User{OrgID: orgID}
+	if err := applyUserFields(user, &username, &email); err != nil {
+		return nil, err
+	}
+
+	roles, err := s.roleRepo.GetRolesByNames([]string{entity.RoleReader})
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = roles
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user.PasswordHash = hash
+
+	err = s.repo.CreateUser(user)
+	if errors.Is(err, repository.ErrDuplicateEmail) {
+		return nil, ErrEmailExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetUser retrieves a user by ID. Other users' accounts require
+// PermUsersManage; without it ErrForbidden is returned.
+func (s *UserService) GetUser(requester *entity.User, userID int64) (*entity.User, error) {
+	if err := authorizeUser(s.repo, requester, userID, entity.PermUsersManage); err != nil {
+		return nil, err
+	}
+
+	return s.getUser(userID)
+}
+
+// getUser retrieves a user by ID, returning ErrUserNotFound for unknown users
+func (s *UserService) getUser(userID int64) (*entity.User, error) {
+	user, err := s.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// ListUsers retrieves a page of the users in the requester's organization;
+// page numbers start at 1
+func (s *UserService) ListUsers(requester *entity.User, page, pageSize int) (*entity.UserListResponse, error) {
+	users, total, err := s.repo.ListUsers(requester.OrgID, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.UserListResponse{
+		Users:    users,
+		Page:     page,
+		PageSize: pageSize,
+		Total:    total,
+	}, nil
+}
+
+// UpdateUser changes the username, email and/or password of a user; nil
+// fields keep their values. Changing the password signs the user out of
+// every session by revoking their refresh tokens. Other users' accounts
+// require PermUsersManage, and those of users with permissions the
+// requester lacks PermOrgsManage; without them ErrForbidden is returned.
+func (s *UserService) UpdateUser(requester *entity.User, userID int64, username, email, password *string) (*entity.User, error) {
+	if err := authorizeUser(s.repo, requester, userID, entity.PermUsersManage); err != nil {
+		return nil, err
+	}
+
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := authorizeOutranked(requester, user); err != nil {
+		return nil, err
+	}
+
+	if err := applyUserFields(user, username, email); err != nil {
+		return nil, err
+	}
+
+	if password != nil {
+		hash, err := hashPassword(*password)
+		if err != nil {
+			return nil, err
+		}
+		user.PasswordHash = hash
+	}
+
+	err = s.repo.UpdateUser(user)
+	if errors.Is(err, repository.ErrDuplicateEmail) {
+		return nil, ErrEmailExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if password != nil {
+		if err := s.tokenRepo.RevokeUserRefreshTokens(user.ID, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// DeleteUser deletes a user. Users who still own reading tasks are not
+// deleted, so that their files and reading data are not orphaned. Other
+// users' accounts require PermUsersManage, and those of users with
+// permissions the requester lacks PermOrgsManage; without them
+// ErrForbidden is returned.
+func (s *UserService) DeleteUser(requester *entity.User, userID int64) error {
+	if err := authorizeUser(s.repo, requester, userID, entity.PermUsersManage); err != nil {
+		return err
+	}
+
+	user, err := s.getUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := authorizeOutranked(requester, user); err != nil {
+		return err
+	}
+
+	tasks, err := s.repo.CountUserTasks(userID)
+	if err != nil {
+		return err
+	}
+
+	if tasks > 0 {
+		return fmt.Errorf("%w: %d tasks must be deleted first", ErrUserHasTasks, tasks)
+	}
+
+	return s.repo.DeleteUser(userID)
+}
+
+// authorizeOutranked returns ErrForbidden when user holds a permission
+// that requester lacks, such as a super admin changed by an admin, so
+// that no one can take over or remove an account more powerful than their
+// own. Users may always change themselves, and requesters with
+// PermOrgsManage anyone.
+func authorizeOutranked(requester, user *entity.User) error {
+	if requester.ID == user.ID || requester.HasPermission(entity.PermOrgsManage) {
+		return nil
+	}
+
+	for _, role := range user.Roles {
+		for _, permission := range role.Permissions {
+			if !requester.HasPermission(permission.Name) {
+				return ErrForbidden
+			}
+		}
+	}
+	return nil
+}
+
+// applyUserFields validates and sets the given fields of user. Email
+// addresses are stored in lower case so uniqueness ignores case.
+func applyUserFields(user *entity.User, username, email *string) error {
+	if username != nil {
+		name := strings.TrimSpace(*username)
+		if name == "" {
+			return fmt.Errorf("%w: username is required", ErrInvalidUser)
+		}
+		if utf8.RuneCountInString(name) > maxUserFieldLength {
+			return fmt.Errorf("%w: username is longer than %d characters", ErrInvalidUser, maxUserFieldLength)
+		}
+		user.Username = name
+	}
+
+	if email != nil {
+		address := strings.ToLower(strings.TrimSpace(*email))
+		parsed, err := mail.ParseAddress(address)
+		if err != nil || parsed.Address != address {
+			return fmt.Errorf("%w: invalid email address", ErrInvalidUser)
+		}
+		if len(address) > maxUserFieldLength {
+			return fmt.Errorf("%w: email is longer than %d characters", ErrInvalidUser, maxUserFieldLength)
+		}
+		user.Email = address
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+)
+
+func TestUpdateAndDeleteUserRequireOutranking(t *testing.T) {
+	db := openTestDB(t)
+	userRepo := repository.NewUserRepository(db)
+	s := NewUserService(userRepo, repository.NewTokenRepository(db), repository.NewRoleRepository(db))
+	admin := createTestUser(t, db, entity.DefaultOrgID, "admin@example.com", entity.RoleAdmin)
+	otherAdmin := createTestUser(t, db, entity.DefaultOrgID, "admin2@example.com", entity.RoleAdmin)
+	superAdmin := createTestUser(t, db, entity.DefaultOrgID, "root@example.com", entity.RoleAdmin, entity.RoleSuperAdmin)
+	operator := createTestUser(t, db, entity.DefaultOrgID, "operator@example.com", entity.RoleAdmin, entity.RoleSuperAdmin)
+	worker := createTestUser(t, db, entity.DefaultOrgID, "worker@example.com", entity.RoleWorker)
+	reader := createTestUser(t, db, entity.DefaultOrgID, "reader@example.com", entity.RoleReader)
+
+	password := "taken over"
+	tests := []struct {
+		name      string
+		requester *entity.User
+		target    *entity.User
+		wantErr   error
+	}{
+		{"admin changes the super admin", admin, superAdmin, ErrForbidden},
+		{"reader changes an admin", reader, admin, ErrForbidden},
+		{"admin changes another admin", admin, otherAdmin, nil},
+		{"admin changes a worker", admin, worker, nil},
+		{"super admin changes itself", superAdmin, superAdmin, nil},
+		{"super admin changes another", operator, superAdmin, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.UpdateUser(tt.requester, tt.target.ID, nil, nil, &password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("UpdateUser() = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, err := userRepo.GetUserByID(tt.target.ID)
+			if err != nil || stored == nil {
+				t.Fatalf("GetUserByID(%d) = %v, %v", tt.target.ID, stored, err)
+			}
+			if changed := checkPassword(stored.PasswordHash, password); changed != (tt.wantErr == nil) {
+				t.Errorf("password changed: %v, want %v", changed, tt.wantErr == nil)
+			}
+		})
+	}
+
+	if err := s.DeleteUser(admin, superAdmin.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("DeleteUser() of the super admin by an admin = %v, want ErrForbidden", err)
+	}
+	if stored, _ := userRepo.GetUserByID(superAdmin.ID); stored == nil {
+		t.Fatal("DeleteUser() by an admin removed the super admin")
+	}
+	if err := s.DeleteUser(admin, worker.ID); err != nil {
+		t.Errorf("DeleteUser() of a worker by an admin = %v", err)
+	}
+	if err := s.DeleteUser(operator, superAdmin.ID); err != nil {
+		t.Errorf("DeleteUser() of a super admin by another = %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{"uploads/2024/05/book.txt", true},
+		{"book.txt", true},
+		{"uploads/..book.txt", true},
+		{"", false},
+		{"/etc/passwd", false},
+		{"../secret", false},
+		{"uploads/../../secret", false},
+		{"uploads/./book.txt", false},
+		{"uploads//book.txt", false},
+		{"uploads/", false},
+		{"uploads\\..\\secret", false},
+		{".", false},
+		{"..", false},
+	}
+
+	for _, tt := range tests {
+		err := checkKey(tt.key)
+		if tt.valid && err != nil {
+			t.Errorf("checkKey(%q) = %v, want nil", tt.key, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("checkKey(%q) = %v, want ErrInvalidKey", tt.key, err)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	root := filepath.Join(t.TempDir(), "files")
+	l, err := NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const key = "uploads/2024/book.txt"
+	content := "第一章 春天来了。"
+	if err := l.Put(ctx, key, strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	p, err := l.Path(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(root, "uploads", "2024", "book.txt"); p != want {
+		t.Errorf("Path() = %q, want %q", p, want)
+	}
+
+	info, err := l.Stat(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Key != key || info.Size != int64(len(content)) {
+		t.Errorf("Stat() = %+v", info)
+	}
+
+	r, err := l.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != content {
+		t.Errorf("Get() read %q, %v", data, err)
+	}
+
+	if err := l.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete error = %v, want ErrNotFound", err)
+	}
+	if _, err := l.Stat(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat() after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := l.Delete(ctx, key); err != nil {
+		t.Errorf("second Delete() error = %v, want nil", err)
+	}
+
+	if _, err := l.PresignGet(ctx, key, time.Minute, DownloadHeaders{}); !errors.Is(err, ErrPresignNotSupported) {
+		t.Errorf("PresignGet() error = %v, want ErrPresignNotSupported", err)
+	}
+}
+
+func TestLocalShortPut(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	l, err := NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Put(ctx, "short.txt", strings.NewReader("abc"), 10)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Put() of a short body error = %v, want io.ErrUnexpectedEOF", err)
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("short Put() left %d files behind", len(entries))
+	}
+}
+
+func TestLocalRejectsInvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	root := filepath.Join(dir, "files")
+	l, err := NewLocal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A file next to the root that escaping keys would reach
+	outside := filepath.Join(dir, "secret")
+	if err := os.WriteFile(outside, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"../secret", "a/../../secret", outside, "", "a\\..\\..\\secret"} {
+		if _, err := l.Path(key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Path(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+		if err := l.Put(ctx, key, strings.NewReader("x"), 1); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := l.Get(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Get(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := l.Stat(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Stat(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+		if err := l.Delete(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Delete(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+	}
+
+	if data, err := os.ReadFile(outside); err != nil || string(data) != "secret" {
+		t.Errorf("file outside the root was changed: %q, %v", data, err)
+	}
+}
@@ -0,0 +1,206 @@
+// Package storage keeps uploaded files as objects addressed by key, either
+// on local disk or in an S3-compatible object store, so that API replicas
+// need not share a disk.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when no object is stored under a key
+	ErrNotFound = errors.New("object not found")
+
+	// ErrInvalidKey is returned for keys that are empty, absolute or
+	// contain "." or ".." segments
+	ErrInvalidKey = errors.New("invalid object key")
+
+	// ErrPresignNotSupported is returned by backends that cannot hand out
+	// URLs for downloading objects directly
+	ErrPresignNotSupported = errors.New("backend cannot presign URLs")
+)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Backend stores objects under slash-separated keys such as
+// "1/<sha256>": an organization's ID and the hex SHA-256 hash of the
+// content, without an extension
+type Backend interface {
+	// Put stores size bytes read from r under key, replacing any object
+	// stored there
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get opens an object for reading. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Stat returns the size and modification time of an object
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Delete removes an object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// PresignGet returns a URL that downloads an object without further
+	// authentication until expiry has passed. The download is served with
+	// the given headers.
+	PresignGet(ctx context.Context, key string, expiry time.Duration, headers DownloadHeaders) (string, error)
+}
+
+// DownloadHeaders are response headers of presigned downloads. Empty
+// fields leave the store's own headers in place.
+type DownloadHeaders struct {
+	ContentType        string
+	ContentDisposition string
+}
+
+// RangeGetter is implemented by backends that can read part of an object
+type RangeGetter interface {
+	// GetRange opens length bytes of an object starting at offset for
+	// reading. The caller must close it.
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+}
+
+// Filer is implemented by backends that keep objects as files on local
+// disk
+type Filer interface {
+	// Path returns the path of the file holding an object
+	Path(key string) (string, error)
+}
+
+// LocalFile returns the path of a local file with an object's content, for
+// code that needs a file rather than a stream. Objects of backends other
+// than Filers are downloaded to a temporary file. The returned function
+// releases the file and must be called when done.
+func LocalFile(ctx context.Context, b Backend, key string) (string, func(), error) {
+	if filer, ok := b.(Filer); ok {
+		p, err := filer.Path(key)
+		if err != nil {
+			return "", nil, err
+		}
+		return p, func() {}, nil
+	}
+
+	body, err := b.Get(ctx, key)
+	if err != nil {
+		return "", nil, err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp("", "textile-*"+path.Ext(key))
+	if err != nil {
+		return "", nil, err
+	}
+	release := func() { os.Remove(tmp.Name()) }
+
+	_, err = io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		release()
+		return "", nil, err
+	}
+
+	return tmp.Name(), release, nil
+}
+
+// OpenSeeker opens an object of the given size for reading at any
+// position, as range requests need. Objects of RangeGetters are read from
+// where the reader was last seeked to, one request per seek; those of other
+// backends must be opened as seekable files. The caller must close it.
+func OpenSeeker(ctx context.Context, b Backend, key string, size int64) (io.ReadSeekCloser, error) {
+	if ranger, ok := b.(RangeGetter); ok {
+		return &rangeReader{ctx: ctx, ranger: ranger, key: key, size: size}, nil
+	}
+
+	body, err := b.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if seeker, ok := body.(io.ReadSeekCloser); ok {
+		return seeker, nil
+	}
+	body.Close()
+	return nil, fmt.Errorf("object %s cannot be read at an offset", key)
+}
+
+// rangeReader reads an object through ranged requests. The request is
+// sent on the first read after a seek, so seeking to learn the size costs
+// nothing.
+type rangeReader struct {
+	ctx    context.Context
+	ranger RangeGetter
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		body, err := r.ranger.GetRange(r.ctx, r.key, r.offset, r.size-r.offset)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek before start of object")
+	}
+
+	if offset != r.offset {
+		r.Close()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+func (r *rangeReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
+
+// checkKey rejects keys that could address something other than an object
+// below the backend's root
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
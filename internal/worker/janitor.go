@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"fmt"
+	"sync"
+	"textile-admin/internal/service"
+	"textile-admin/pkg/logger"
+	"time"
+)
+
+// Janitor periodically deletes resumable uploads that expired before they
+// were completed
+type Janitor struct {
+	service  *service.UploadService
+	interval time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// NewJanitor creates a new instance of Janitor
+func NewJanitor(service *service.UploadService, interval time.Duration) *Janitor {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+
+	return &Janitor{
+		service:  service,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start launches the janitor in the background
+func (j *Janitor) Start() {
+	logger.Info(fmt.Sprintf("Starting upload janitor (interval %s)", j.interval))
+
+	j.wg.Add(1)
+	go j.run()
+}
+
+// Stop signals the janitor to exit and waits for a running cleanup to finish
+func (j *Janitor) Stop() {
+	j.once.Do(func() {
+		close(j.stop)
+	})
+	j.wg.Wait()
+	logger.Info("Upload janitor stopped")
+}
+
+// run cleans up expired uploads on every tick until the janitor is stopped
+func (j *Janitor) run() {
+	defer j.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.cleanup()
+
+		select {
+		case <-j.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cleanup deletes the uploads that have expired by now
+func (j *Janitor) cleanup() {
+	deleted, err := j.service.CleanupExpired(time.Now())
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to clean up expired uploads: %v", err))
+	}
+	if deleted > 0 {
+		logger.Info(fmt.Sprintf("Deleted %d expired uploads", deleted))
+	}
+}
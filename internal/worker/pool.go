@@ -0,0 +1,168 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/repository"
+	"textile-admin/internal/service"
+	"textile-admin/pkg/logger"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Pool runs a fixed number of workers that process pending reading tasks
+type Pool struct {
+	service       *service.ReadingService
+	workers       int
+	pollInterval  time.Duration
+	leaseDuration time.Duration
+	instanceID    string
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// NewPool creates a new instance of Pool
+func NewPool(service *service.ReadingService, workers int, pollInterval, leaseDuration time.Duration) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if pollInterval <= 0 {
+		pollInterval = time.Second
+	}
+	if leaseDuration <= 0 {
+		leaseDuration = 5 * time.Minute
+	}
+
+	return &Pool{
+		service:       service,
+		workers:       workers,
+		pollInterval:  pollInterval,
+		leaseDuration: leaseDuration,
+		instanceID:    newInstanceID(),
+		stop:          make(chan struct{}),
+	}
+}
+
+// Start launches the workers in the background
+func (p *Pool) Start() {
+	logger.Info(fmt.Sprintf("Starting %d task workers as %s (poll interval %s, lease %s)",
+		p.workers, p.instanceID, p.pollInterval, p.leaseDuration))
+
+	for i := 1; i <= p.workers; i++ {
+		p.wg.Add(1)
+		go p.run(i)
+	}
+}
+
+// Stop signals the workers to exit and waits for in-flight tasks to finish
+func (p *Pool) Stop() {
+	p.once.Do(func() {
+		close(p.stop)
+	})
+	p.wg.Wait()
+	logger.Info("Task workers stopped")
+}
+
+// run polls for pending tasks until the pool is stopped
+func (p *Pool) run(id int) {
+	defer p.wg.Done()
+
+	owner := fmt.Sprintf("%s/%d", p.instanceID, id)
+
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain the queue before waiting for the next tick
+		for p.processNext(owner) {
+			select {
+			case <-p.stop:
+				return
+			default:
+			}
+		}
+
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processNext claims and processes a single task.
+// It reports whether a task was claimed.
+func (p *Pool) processNext(owner string) bool {
+	task, err := p.service.ClaimNextTask(owner, p.leaseDuration)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Worker %s failed to claim task: %v", owner, err))
+		return false
+	}
+
+	if task == nil {
+		return false
+	}
+
+	logger.Debug(fmt.Sprintf("Worker %s processing task %d", owner, task.ID))
+
+	// In-flight tasks are allowed to finish on shutdown, so the context is
+	// only cancelled when the lease is lost.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go p.keepLease(ctx, cancel, task, done)
+
+	err = p.service.ProcessTask(ctx, task)
+	close(done)
+	cancel()
+
+	if err != nil {
+		logger.Warn(fmt.Sprintf("Worker %s failed to process task %d: %v", owner, task.ID, err))
+		return true
+	}
+
+	logger.Debug(fmt.Sprintf("Worker %s completed task %d", owner, task.ID))
+	return true
+}
+
+// keepLease renews the lease on task until done is closed. If the lease is
+// lost to another instance, processing is cancelled.
+func (p *Pool) keepLease(ctx context.Context, cancel context.CancelFunc, task *entity.ReadingTask, done <-chan struct{}) {
+	ticker := time.NewTicker(p.leaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := p.service.RenewTaskLease(task, p.leaseDuration)
+			if errors.Is(err, repository.ErrLeaseLost) {
+				logger.Warn(fmt.Sprintf("Lease on task %d lost, cancelling processing", task.ID))
+				cancel()
+				return
+			}
+			if err != nil {
+				logger.Error(fmt.Sprintf("Failed to renew lease on task %d: %v", task.ID, err))
+			}
+		}
+	}
+}
+
+// newInstanceID returns an identifier for this process that is unique
+// across replicas
+func newInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.New().String()[:8])
+}
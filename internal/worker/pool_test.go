@@ -0,0 +1,260 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"textile-admin/internal/domain/entity"
+	"textile-admin/internal/processor"
+	"textile-admin/internal/repository"
+	"textile-admin/internal/repository/repotest"
+	"textile-admin/internal/search"
+	"textile-admin/internal/service"
+	"textile-admin/internal/storage"
+	"time"
+)
+
+// funcProcessor processes documents with a function
+type funcProcessor func(ctx context.Context, doc *processor.Document) (*processor.Result, error)
+
+func (f funcProcessor) Name() string { return "test" }
+
+func (f funcProcessor) Process(ctx context.Context, doc *processor.Document) (*processor.Result, error) {
+	return f(ctx, doc)
+}
+
+// readText is a processor returning the text of a file
+func readText(ctx context.Context, doc *processor.Document) (*processor.Result, error) {
+	data, err := os.ReadFile(doc.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &processor.Result{Text: string(data)}, nil
+}
+
+// testEnv is a reading service on a test database, processing text files
+// with a processor of the test's choosing
+type testEnv struct {
+	service *service.ReadingService
+	repo    *repository.ReadingRepository
+	userID  int64
+}
+
+func newTestEnv(t *testing.T, proc processor.Processor) *testEnv {
+	t.Helper()
+
+	db := repotest.Open(t)
+	user := &entity.User{OrgID: entity.DefaultOrgID, Username: "reader", Email: "reader@example.com"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := search.NewIndex(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := processor.NewRegistry()
+	registry.Register(proc, []string{"text/plain"}, []string{".txt"})
+
+	repo := repository.NewReadingRepository(db)
+	retryPolicy := service.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour}
+	readingService := service.NewReadingService(repo, repository.NewUserRepository(db), registry, index, files,
+		"/api/files", 0, retryPolicy, time.Minute, service.ReadingSpeed{})
+
+	return &testEnv{service: readingService, repo: repo, userID: user.ID}
+}
+
+// createTask uploads a text file and returns the new task's ID
+func (e *testEnv) createTask(t *testing.T, content string) int64 {
+	t.Helper()
+
+	upload, err := e.service.CreateTaskFromReader(context.Background(), e.userID, "book.txt",
+		strings.NewReader(content), int64(len(content)), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return upload.TaskID
+}
+
+// task loads a task
+func (e *testEnv) task(t *testing.T, taskID int64) *entity.ReadingTask {
+	t.Helper()
+
+	task, err := e.repo.System().GetTaskByID(taskID)
+	if err != nil || task == nil {
+		t.Fatalf("GetTaskByID(%d) = %v, %v", taskID, task, err)
+	}
+	return task
+}
+
+// waitFor polls until done reports true, failing the test after a while
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPoolProcessesPendingTasks(t *testing.T) {
+	env := newTestEnv(t, funcProcessor(readText))
+
+	var taskIDs []int64
+	for i := 1; i <= 5; i++ {
+		taskIDs = append(taskIDs, env.createTask(t, fmt.Sprintf("Book %d", i)))
+	}
+
+	pool := NewPool(env.service, 3, 10*time.Millisecond, time.Minute)
+	pool.Start()
+	defer pool.Stop()
+
+	waitFor(t, "tasks to complete", func() bool {
+		for _, taskID := range taskIDs {
+			if env.task(t, taskID).Status != entity.TaskStatusCompleted {
+				return false
+			}
+		}
+		return true
+	})
+
+	for i, taskID := range taskIDs {
+		task := env.task(t, taskID)
+		if task.Attempts != 1 || task.LeaseOwner != "" || task.LeaseExpiresAt != nil {
+			t.Errorf("task %d: attempts %d, lease %q until %v; want 1 attempt and no lease",
+				taskID, task.Attempts, task.LeaseOwner, task.LeaseExpiresAt)
+		}
+
+		content, err := env.repo.System().GetTaskContent(taskID)
+		if err != nil || content == nil || content.Content != fmt.Sprintf("Book %d", i+1) {
+			t.Errorf("task %d content = %+v, %v", taskID, content, err)
+		}
+
+		events, err := env.repo.System().GetTaskEvents(taskID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 || events[0].ToStatus != entity.TaskStatusProcessing || events[1].ToStatus != entity.TaskStatusCompleted {
+			t.Errorf("task %d events = %+v, want processing then completed", taskID, events)
+		}
+		if !strings.HasPrefix(events[0].Actor, pool.instanceID+"/") {
+			t.Errorf("task %d claimed by %q, want a worker of %s", taskID, events[0].Actor, pool.instanceID)
+		}
+	}
+}
+
+func TestPoolStopWaitsForInFlightTasks(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	env := newTestEnv(t, funcProcessor(func(ctx context.Context, doc *processor.Document) (*processor.Result, error) {
+		close(started)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return readText(ctx, doc)
+	}))
+	taskID := env.createTask(t, "Slow book")
+
+	pool := NewPool(env.service, 1, 10*time.Millisecond, time.Minute)
+	pool.Start()
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		pool.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("Stop() returned while a task was being processed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Stop() did not return after the task finished")
+	}
+
+	if status := env.task(t, taskID).Status; status != entity.TaskStatusCompleted {
+		t.Errorf("task status after Stop() = %s, want completed", status)
+	}
+}
+
+func TestKeepLeaseRenewsLease(t *testing.T) {
+	env := newTestEnv(t, funcProcessor(readText))
+	taskID := env.createTask(t, "Long book")
+
+	pool := NewPool(env.service, 1, time.Second, 3*time.Second)
+	task, err := env.service.ClaimNextTask("worker-a", pool.leaseDuration)
+	if err != nil || task == nil {
+		t.Fatalf("ClaimNextTask() = %v, %v", task, err)
+	}
+
+	// Let the lease run out, as it would during a long task
+	err = env.repo.System().RenewLease(taskID, "worker-a", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+	go pool.keepLease(ctx, cancel, task, done)
+
+	waitFor(t, "the lease to be renewed", func() bool {
+		expiresAt := env.task(t, taskID).LeaseExpiresAt
+		return expiresAt != nil && expiresAt.After(time.Now())
+	})
+	if ctx.Err() != nil {
+		t.Error("processing was cancelled while the lease was held")
+	}
+}
+
+func TestKeepLeaseCancelsWhenLeaseLost(t *testing.T) {
+	env := newTestEnv(t, funcProcessor(readText))
+	taskID := env.createTask(t, "Contested book")
+
+	pool := NewPool(env.service, 1, time.Second, 300*time.Millisecond)
+	task, err := env.service.ClaimNextTask("worker-a", time.Minute)
+	if err != nil || task == nil {
+		t.Fatalf("ClaimNextTask() = %v, %v", task, err)
+	}
+
+	// Another instance takes the task over
+	if err := env.repo.System().RenewLease(taskID, "worker-a", -time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if other, err := env.service.ClaimNextTask("worker-b", time.Minute); err != nil || other == nil || other.ID != taskID {
+		t.Fatalf("ClaimNextTask() of the expired task = %v, %v", other, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+	go pool.keepLease(ctx, cancel, task, done)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("processing was not cancelled after the lease was lost")
+	}
+
+	if owner := env.task(t, taskID).LeaseOwner; owner != "worker-b" {
+		t.Errorf("lease owner = %q, want worker-b", owner)
+	}
+}
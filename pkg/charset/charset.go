@@ -0,0 +1,246 @@
+package charset
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Canonical encoding names
+const (
+	UTF8        = "utf-8"
+	UTF16LE     = "utf-16le"
+	UTF16BE     = "utf-16be"
+	GBK         = "gbk"
+	GB18030     = "gb18030"
+	Big5        = "big5"
+	Windows1252 = "windows-1252"
+)
+
+// ErrUnknownEncoding is returned for encoding names that are not supported
+var ErrUnknownEncoding = errors.New("unknown encoding")
+
+// sampleSize is how much of the input the heuristics look at
+const sampleSize = 64 * 1024
+
+// aliases maps accepted encoding names to their canonical name
+var aliases = map[string]string{
+	"utf-8":        UTF8,
+	"utf8":         UTF8,
+	"utf-16le":     UTF16LE,
+	"utf-16be":     UTF16BE,
+	"gbk":          GBK,
+	"cp936":        GBK,
+	"gb2312":       GBK,
+	"x-gbk":        GBK,
+	"gb18030":      GB18030,
+	"big5":         Big5,
+	"big-5":        Big5,
+	"cp950":        Big5,
+	"windows-1252": Windows1252,
+	"cp1252":       Windows1252,
+	"latin1":       Windows1252,
+	"iso-8859-1":   Windows1252,
+}
+
+// encodings maps canonical names to their decoders
+var encodings = map[string]encoding.Encoding{
+	UTF8:        unicode.UTF8,
+	UTF16LE:     unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	UTF16BE:     unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+	GBK:         simplifiedchinese.GBK,
+	GB18030:     simplifiedchinese.GB18030,
+	Big5:        traditionalchinese.Big5,
+	Windows1252: charmap.Windows1252,
+}
+
+// byteOrderMarks are the byte order marks of the Unicode encodings
+var byteOrderMarks = map[string][]byte{
+	UTF8:    {0xEF, 0xBB, 0xBF},
+	UTF16LE: {0xFF, 0xFE},
+	UTF16BE: {0xFE, 0xFF},
+}
+
+// commonHanzi are the most frequent characters of Chinese prose in both
+// simplified and traditional forms. Text decoded with the right encoding is
+// full of them, text decoded with the wrong one almost never contains them.
+var commonHanzi = func() map[rune]bool {
+	chars := "的一是了不在人有我他这个们中来上大为和国地到以说时要就出会可也你对生能而子那得于着下自之年过发后作里用道行所然家种事成方多经么去法学如都同现当没动面起看定天分还进好小部其些主样理心她本前开但因只从想实日军者意无力它与长把机十民第公此已工使情明性知全三又关点正业外将两高间由问很最重并物手应战向头文体政美相见被利什二等产或新己制身果加西斯月话合回特代内信表化老给世位次度门任常先海通教儿原东声提立及比员解水名真论处走义各入几口认条平系气题活尔更别打女变四神总何电数安少报才结反受目太量再感建务做接必场件计管期市直德资命山金指克许统区保至队形社便空决治展马科司五基眼书非则听白却界达光放强即像难且权思王象完设式色路记南品住告类求据程北边死张该交规万取拉格望觉术领共确传师观清今切院让识候带导争运笑飞风步改收根干造言联持组每济车亲极林服快办议往元英士证近失转夫令准布始怎呢存未远叫台单影具罗字爱击流备兵连调深商算质团集百需价花党华城石级整府离况亚请技际约示复病息究线似官火断精满支视消越器容照须九增研写称企八功吗包片史委乎查轻易早曾除农找装广显吧阿李标谈吃图念六引历首医局突专费号尽另周较注语仅考落青随选列武红响虽推势参希古众构房半节土投某案黑维革划敢术這個們來為國說時會對過發後裡實動現當沒樣無與長機從還進開經麼問聽覺間見讓這點頭裡"
+	set := make(map[rune]bool, utf8.RuneCountInString(chars))
+	for _, r := range chars {
+		set[r] = true
+	}
+	return set
+}()
+
+// Lookup returns the canonical name for an encoding name or alias
+func Lookup(name string) (string, error) {
+	canonical, ok := aliases[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownEncoding, name)
+	}
+	return canonical, nil
+}
+
+// Detect guesses the encoding of data. A byte order mark wins; NUL bytes at
+// every other position mean UTF-16; valid UTF-8 is taken as UTF-8; otherwise
+// GBK, GB18030, Big5 and UTF-16 are scored by how many common Chinese
+// characters they decode to, with Windows-1252 as last resort.
+func Detect(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return UTF8
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return UTF16LE
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return UTF16BE
+	}
+
+	sample := data
+	if len(sample) > sampleSize {
+		sample = trimIncomplete(sample[:sampleSize])
+	}
+
+	if name := detectUTF16(sample); name != "" {
+		return name
+	}
+
+	if utf8.Valid(sample) {
+		return UTF8
+	}
+
+	best, bestScore := "", 0
+	for _, name := range []string{GBK, GB18030, Big5, UTF16LE, UTF16BE} {
+		if score := scoreChinese(sample, encodings[name]); score > bestScore {
+			best, bestScore = name, score
+		}
+	}
+
+	if best == "" {
+		return Windows1252
+	}
+	return best
+}
+
+// DetectReader guesses the encoding of what r reads, as Detect does, from
+// as much of its start as Detect looks at
+func DetectReader(r io.Reader) (string, error) {
+	sample, err := io.ReadAll(io.LimitReader(r, sampleSize+utf8.UTFMax))
+	if err != nil {
+		return "", err
+	}
+	return Detect(sample), nil
+}
+
+// ToUTF8 converts data from the named encoding to UTF-8, dropping any byte
+// order mark. Undecodable bytes become U+FFFD.
+func ToUTF8(data []byte, name string) ([]byte, error) {
+	canonical, err := Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimPrefix(data, byteOrderMarks[canonical])
+
+	if canonical == UTF8 {
+		if utf8.Valid(data) {
+			return data, nil
+		}
+		return bytes.ToValidUTF8(data, []byte("�")), nil
+	}
+
+	out, _, err := transform.Bytes(encodings[canonical].NewDecoder(), data)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NewUTF8Reader returns a reader that converts what r reads from the named
+// encoding to UTF-8 as it goes, dropping any byte order mark, so that large
+// files need not be held in memory. Undecodable bytes become U+FFFD.
+func NewUTF8Reader(r io.Reader, name string) (io.Reader, error) {
+	canonical, err := Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(r)
+	if bom := byteOrderMarks[canonical]; bom != nil {
+		if prefix, _ := br.Peek(len(bom)); bytes.Equal(prefix, bom) {
+			br.Discard(len(bom))
+		}
+	}
+
+	return transform.NewReader(br, encodings[canonical].NewDecoder()), nil
+}
+
+// detectUTF16 recognizes UTF-16 without a byte order mark by its NUL bytes.
+// Text in other encodings has none, while in UTF-16 every ASCII character
+// has a NUL high byte: the second byte of each pair in little-endian order,
+// the first in big-endian. It returns "" if sample doesn't look like UTF-16.
+func detectUTF16(sample []byte) string {
+	var even, odd int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+
+	// At least one character in ten ASCII, and NULs on one side only
+	units := len(sample) / 2
+	switch {
+	case units == 0:
+		return ""
+	case odd*10 >= units && even*10 <= odd:
+		return UTF16LE
+	case even*10 >= units && odd*10 <= even:
+		return UTF16BE
+	}
+	return ""
+}
+
+// scoreChinese decodes sample and rates how much it looks like Chinese prose.
+// Each common character adds a point, each undecodable byte costs ten.
+func scoreChinese(sample []byte, enc encoding.Encoding) int {
+	decoded, _, err := transform.Bytes(enc.NewDecoder(), sample)
+	if err != nil {
+		return 0
+	}
+
+	score := 0
+	for _, r := range string(decoded) {
+		switch {
+		case r == utf8.RuneError:
+			score -= 10
+		case commonHanzi[r]:
+			score++
+		}
+	}
+	return score
+}
+
+// trimIncomplete drops up to three trailing bytes that may belong to a
+// multi-byte sequence cut off by sampling
+func trimIncomplete(sample []byte) []byte {
+	for i := 0; i < 3 && len(sample) > 0 && sample[len(sample)-1] >= 0x80; i++ {
+		sample = sample[:len(sample)-1]
+	}
+	return sample
+}
@@ -0,0 +1,150 @@
+package charset
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const (
+	simplified  = "第一章 春天\n我们在这个时候还没有想到，他说的那些话会成为后来所有事情的开始。天色已经很晚了，大家都回家去了。"
+	traditional = "第一章 春天\n我們在這個時候還沒有想到，他說的那些話會成為後來所有事情的開始。天色已經很晚了，大家都回家去了。"
+	// gb18030Only has characters that GBK cannot encode
+	gb18030Only = simplified + "𠀀𠀁€"
+	english     = "Chapter 1\nIt was a bright cold day in April, and the clocks were striking thirteen.\n"
+	latin       = "Café au lait, naïve façade, déjà vu. "
+)
+
+func encode(t *testing.T, enc encoding.Encoding, text string) []byte {
+	t.Helper()
+	data, err := enc.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatalf("encoding fixture: %v", err)
+	}
+	return data
+}
+
+var (
+	utf16LE = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	utf16BE = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"ascii", []byte(english), UTF8},
+		{"utf-8 chinese", []byte(simplified), UTF8},
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, english...), UTF8},
+		{"gbk", encode(t, simplifiedchinese.GBK, simplified), GBK},
+		{"gb18030", encode(t, simplifiedchinese.GB18030, gb18030Only), GB18030},
+		{"big5", encode(t, traditionalchinese.Big5, traditional), Big5},
+		{"utf-16le bom", append([]byte{0xFF, 0xFE}, encode(t, utf16LE, simplified)...), UTF16LE},
+		{"utf-16be bom", append([]byte{0xFE, 0xFF}, encode(t, utf16BE, simplified)...), UTF16BE},
+		{"utf-16le english", encode(t, utf16LE, english), UTF16LE},
+		{"utf-16be english", encode(t, utf16BE, english), UTF16BE},
+		{"utf-16le mixed", encode(t, utf16LE, english+simplified), UTF16LE},
+		{"utf-16be mixed", encode(t, utf16BE, simplified+english), UTF16BE},
+		{"utf-16le chinese", encode(t, utf16LE, simplified), UTF16LE},
+		{"utf-16be chinese", encode(t, utf16BE, simplified), UTF16BE},
+		{"windows-1252", encode(t, charmap.Windows1252, latin), Windows1252},
+		{"gbk beyond sample", encode(t, simplifiedchinese.GBK, strings.Repeat(simplified, 2000)), GBK},
+		{"empty", nil, UTF8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.data); got != tt.want {
+				t.Errorf("Detect() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectReader(t *testing.T) {
+	data := encode(t, traditionalchinese.Big5, strings.Repeat(traditional, 2000))
+	got, err := DetectReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != Big5 {
+		t.Errorf("DetectReader() = %q, want %q", got, Big5)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"UTF-8", UTF8, false},
+		{" gb2312 ", GBK, false},
+		{"CP950", Big5, false},
+		{"latin1", Windows1252, false},
+		{"ebcdic", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Lookup(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Lookup(%q) = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestToUTF8(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding string
+		want     string
+	}{
+		{"utf-8", []byte(simplified), UTF8, simplified},
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, simplified...), UTF8, simplified},
+		{"invalid utf-8", []byte("ab\xffcd"), UTF8, "ab�cd"},
+		{"gbk", encode(t, simplifiedchinese.GBK, simplified), GBK, simplified},
+		{"gb18030", encode(t, simplifiedchinese.GB18030, gb18030Only), GB18030, gb18030Only},
+		{"big5", encode(t, traditionalchinese.Big5, traditional), Big5, traditional},
+		{"utf-16le bom", append([]byte{0xFF, 0xFE}, encode(t, utf16LE, simplified)...), UTF16LE, simplified},
+		{"utf-16be", encode(t, utf16BE, english), UTF16BE, english},
+		{"windows-1252", encode(t, charmap.Windows1252, latin), Windows1252, latin},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToUTF8(tt.data, tt.encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ToUTF8() = %q, want %q", got, tt.want)
+			}
+
+			r, err := NewUTF8Reader(bytes.NewReader(tt.data), tt.encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			streamed, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(streamed) != tt.want {
+				t.Errorf("NewUTF8Reader() read %q, want %q", streamed, tt.want)
+			}
+		})
+	}
+
+	if _, err := ToUTF8(nil, "ebcdic"); err == nil {
+		t.Error("ToUTF8 with an unknown encoding succeeded")
+	}
+}
@@ -0,0 +1,82 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrUnknownKey is returned when a key set has no key with the requested ID
+var ErrUnknownKey = errors.New("unknown key")
+
+// JWK is a JSON Web Key (RFC 7517). Only RSA public keys are supported.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set, as served at an identity provider's jwks_uri
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewRSAJWK describes an RSA public key as a signing JWK
+func NewRSAJWK(kid string, key *rsa.PublicKey) JWK {
+	return JWK{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		Alg: "RS256",
+		N:   encodeSegment(key.N.Bytes()),
+		E:   encodeSegment(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// RSAPublicKey decodes the key as an RSA public key
+func (k JWK) RSAPublicKey() (*rsa.PublicKey, error) {
+	if k.Kty != "RSA" {
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+
+	n, err := decodeSegment(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("key %q: modulus: %v", k.Kid, err)
+	}
+	e, err := decodeSegment(k.E)
+	if err != nil {
+		return nil, fmt.Errorf("key %q: exponent: %v", k.Kid, err)
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("key %q: invalid RSA parameters", k.Kid)
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+// RSAPublicKey returns the signing key with the given ID. An empty kid
+// matches a set holding a single key. Keys that are not RSA, or not for
+// signatures, are skipped.
+func (s *JWKS) RSAPublicKey(kid string) (*rsa.PublicKey, error) {
+	var candidates []JWK
+	for _, key := range s.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		if kid == "" || key.Kid == kid {
+			candidates = append(candidates, key)
+		}
+	}
+
+	if len(candidates) != 1 {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+
+	return candidates[0].RSAPublicKey()
+}
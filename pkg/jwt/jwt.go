@@ -0,0 +1,186 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Leeway is the clock skew tolerated when checking exp and nbf
+const Leeway = time.Minute
+
+var (
+	// ErrInvalidToken is returned for malformed tokens and bad signatures
+	ErrInvalidToken = errors.New("invalid token")
+
+	// ErrTokenExpired is returned for tokens past their exp claim
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// header is the JOSE header of a token
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// timeClaims are the registered claims checked by Parse functions
+type timeClaims struct {
+	ExpiresAt int64 `json:"exp"`
+	NotBefore int64 `json:"nbf"`
+}
+
+// SignHS256 encodes claims as a JWT signed with HMAC-SHA256
+func SignHS256(claims interface{}, secret []byte) (string, error) {
+	headerJSON, err := json.Marshal(header{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	payloadJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encodeSegment(headerJSON) + "." + encodeSegment(payloadJSON)
+	return signingInput + "." + encodeSegment(hmacSHA256([]byte(signingInput), secret)), nil
+}
+
+// ParseHS256 verifies a token signed with HMAC-SHA256 and decodes its
+// payload into claims. Tokens past their exp or before their nbf claim are
+// rejected.
+func ParseHS256(token string, secret []byte, claims interface{}) error {
+	return parse(token, claims, func(h *header, signingInput string, signature []byte) error {
+		if h.Alg != "HS256" {
+			return fmt.Errorf("%w: unexpected algorithm %q", ErrInvalidToken, h.Alg)
+		}
+		if !hmac.Equal(signature, hmacSHA256([]byte(signingInput), secret)) {
+			return fmt.Errorf("%w: signature mismatch", ErrInvalidToken)
+		}
+		return nil
+	})
+}
+
+// SignRS256 encodes claims as a JWT signed with RSASSA-PKCS1-v1_5 and
+// SHA-256. kid names the key in the header, so verifiers can pick it from
+// a key set.
+func SignRS256(claims interface{}, kid string, key *rsa.PrivateKey) (string, error) {
+	headerJSON, err := json.Marshal(header{Alg: "RS256", Typ: "JWT", Kid: kid})
+	if err != nil {
+		return "", err
+	}
+
+	payloadJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encodeSegment(headerJSON) + "." + encodeSegment(payloadJSON)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + encodeSegment(signature), nil
+}
+
+// ParseRS256 verifies a token signed with RS256 and decodes its payload
+// into claims. key returns the public key for the kid in the token's
+// header. Tokens past their exp or before their nbf claim are rejected.
+func ParseRS256(token string, key func(kid string) (*rsa.PublicKey, error), claims interface{}) error {
+	return parse(token, claims, func(h *header, signingInput string, signature []byte) error {
+		if h.Alg != "RS256" {
+			return fmt.Errorf("%w: unexpected algorithm %q", ErrInvalidToken, h.Alg)
+		}
+
+		publicKey, err := key(h.Kid)
+		if err != nil {
+			return err
+		}
+
+		digest := sha256.Sum256([]byte(signingInput))
+		if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("%w: signature mismatch", ErrInvalidToken)
+		}
+		return nil
+	})
+}
+
+// parse splits a token, checks its signature with verify and its time
+// claims, and decodes the payload into claims
+func parse(token string, claims interface{}, verify func(h *header, signingInput string, signature []byte) error) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: expected three segments", ErrInvalidToken)
+	}
+
+	headerJSON, err := decodeSegment(parts[0])
+	if err != nil {
+		return fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+
+	var h header
+	if err := json.Unmarshal(headerJSON, &h); err != nil {
+		return fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+
+	signature, err := decodeSegment(parts[2])
+	if err != nil {
+		return fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+
+	// Verify before looking at the payload, which is untrusted until then
+	if err := verify(&h, parts[0]+"."+parts[1], signature); err != nil {
+		return err
+	}
+
+	payloadJSON, err := decodeSegment(parts[1])
+	if err != nil {
+		return fmt.Errorf("%w: payload: %v", ErrInvalidToken, err)
+	}
+
+	var times timeClaims
+	if err := json.Unmarshal(payloadJSON, &times); err != nil {
+		return fmt.Errorf("%w: payload: %v", ErrInvalidToken, err)
+	}
+
+	now := time.Now()
+	if times.ExpiresAt != 0 && now.After(time.Unix(times.ExpiresAt, 0).Add(Leeway)) {
+		return ErrTokenExpired
+	}
+	if times.NotBefore != 0 && now.Add(Leeway).Before(time.Unix(times.NotBefore, 0)) {
+		return fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	}
+
+	if err := json.Unmarshal(payloadJSON, claims); err != nil {
+		return fmt.Errorf("%w: payload: %v", ErrInvalidToken, err)
+	}
+
+	return nil
+}
+
+// hmacSHA256 computes the HMAC-SHA256 of data
+func hmacSHA256(data, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// encodeSegment encodes a token segment as unpadded base64url
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeSegment decodes an unpadded base64url token segment
+func decodeSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(segment)
+}
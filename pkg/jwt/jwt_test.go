@@ -0,0 +1,222 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testClaims struct {
+	Subject   string `json:"sub"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+}
+
+var secret = []byte("test-secret")
+
+// forge builds a token from a raw header and claims, signed by sign
+func forge(t *testing.T, h header, claims interface{}, sign func(signingInput string) []byte) string {
+	t.Helper()
+	headerJSON, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloadJSON, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signingInput := encodeSegment(headerJSON) + "." + encodeSegment(payloadJSON)
+	return signingInput + "." + encodeSegment(sign(signingInput))
+}
+
+// tamper replaces segment i of a token
+func tamper(token string, i int, segment string) string {
+	parts := strings.Split(token, ".")
+	parts[i] = segment
+	return strings.Join(parts, ".")
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestHS256(t *testing.T) {
+	now := time.Now()
+	sign := func(claims testClaims) string {
+		token, err := SignHS256(claims, secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	valid := sign(testClaims{Subject: "alice", ExpiresAt: now.Add(time.Hour).Unix()})
+	otherPayload, _ := json.Marshal(testClaims{Subject: "admin", ExpiresAt: now.Add(time.Hour).Unix()})
+
+	tests := []struct {
+		name    string
+		token   string
+		secret  []byte
+		wantErr error
+	}{
+		{"valid", valid, secret, nil},
+		{"no expiry", sign(testClaims{Subject: "alice"}), secret, nil},
+		{"expired within leeway", sign(testClaims{Subject: "alice", ExpiresAt: now.Add(-Leeway / 2).Unix()}), secret, nil},
+		{"expired", sign(testClaims{Subject: "alice", ExpiresAt: now.Add(-2 * Leeway).Unix()}), secret, ErrTokenExpired},
+		{"not valid yet", sign(testClaims{Subject: "alice", NotBefore: now.Add(2 * Leeway).Unix()}), secret, ErrInvalidToken},
+		{"wrong secret", valid, []byte("other-secret"), ErrInvalidToken},
+		{"tampered payload", tamper(valid, 1, encodeSegment(otherPayload)), secret, ErrInvalidToken},
+		{"tampered signature", tamper(valid, 2, encodeSegment([]byte("forged"))), secret, ErrInvalidToken},
+		{"bad signature encoding", tamper(valid, 2, "!!"), secret, ErrInvalidToken},
+		{"two segments", strings.Join(strings.Split(valid, ".")[:2], "."), secret, ErrInvalidToken},
+		{"empty", "", secret, ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var claims testClaims
+			err := ParseHS256(tt.token, tt.secret, &claims)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("ParseHS256() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && claims.Subject != "alice" {
+				t.Errorf("subject = %q, want alice", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestAlgorithmConfusion(t *testing.T) {
+	key := generateKey(t)
+	claims := testClaims{Subject: "admin"}
+	keys := func(kid string) (*rsa.PublicKey, error) { return &key.PublicKey, nil }
+
+	// An unsigned token must not pass as HS256
+	unsigned := forge(t, header{Alg: "none"}, claims, func(string) []byte { return nil })
+	if err := ParseHS256(unsigned, secret, &testClaims{}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseHS256(alg none) error = %v, want ErrInvalidToken", err)
+	}
+	if err := ParseRS256(unsigned, keys, &testClaims{}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseRS256(alg none) error = %v, want ErrInvalidToken", err)
+	}
+
+	// An HMAC keyed with the public key, which attackers know, must not
+	// pass as RS256
+	publicDER := x509.MarshalPKCS1PublicKey(&key.PublicKey)
+	hmacWithPublicKey := forge(t, header{Alg: "HS256"}, claims, func(input string) []byte {
+		return hmacSHA256([]byte(input), publicDER)
+	})
+	if err := ParseRS256(hmacWithPublicKey, keys, &testClaims{}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseRS256(alg HS256) error = %v, want ErrInvalidToken", err)
+	}
+	relabeled := forge(t, header{Alg: "RS256"}, claims, func(input string) []byte {
+		return hmacSHA256([]byte(input), publicDER)
+	})
+	if err := ParseRS256(relabeled, keys, &testClaims{}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseRS256(HMAC labeled RS256) error = %v, want ErrInvalidToken", err)
+	}
+
+	// And an RS256 token must not pass as HS256
+	rs256, err := SignRS256(claims, "", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ParseHS256(rs256, secret, &testClaims{}); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseHS256(alg RS256) error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestRS256KeyLookup(t *testing.T) {
+	current, previous, stranger := generateKey(t), generateKey(t), generateKey(t)
+
+	keySet := &JWKS{Keys: []JWK{
+		NewRSAJWK("current", &current.PublicKey),
+		NewRSAJWK("previous", &previous.PublicKey),
+		{Kty: "RSA", Kid: "encryption", Use: "enc", N: NewRSAJWK("", &stranger.PublicKey).N, E: "AQAB"},
+		{Kty: "EC", Kid: "ec"},
+	}}
+
+	sign := func(kid string, key *rsa.PrivateKey, claims testClaims) string {
+		token, err := SignRS256(claims, kid, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	claims := testClaims{Subject: "alice", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	valid := sign("current", current, claims)
+
+	tests := []struct {
+		name    string
+		token   string
+		keys    *JWKS
+		wantErr error
+	}{
+		{"current key", valid, keySet, nil},
+		{"previous key", sign("previous", previous, claims), keySet, nil},
+		{"kid of another key", sign("previous", current, claims), keySet, ErrInvalidToken},
+		{"unknown kid", sign("retired", current, claims), keySet, ErrUnknownKey},
+		{"encryption key", sign("encryption", stranger, claims), keySet, ErrUnknownKey},
+		{"non-RSA key", sign("ec", current, claims), keySet, ErrUnknownKey},
+		{"no kid, several keys", sign("", current, claims), keySet, ErrUnknownKey},
+		{"no kid, single key", sign("", current, claims), &JWKS{Keys: keySet.Keys[:1]}, nil},
+		{"expired", sign("current", current, testClaims{Subject: "alice", ExpiresAt: time.Now().Add(-time.Hour).Unix()}), keySet, ErrTokenExpired},
+		{"tampered signature", tamper(valid, 2, encodeSegment(make([]byte, 256))), keySet, ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got testClaims
+			err := ParseRS256(tt.token, tt.keys.RSAPublicKey, &got)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("ParseRS256() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && got.Subject != "alice" {
+				t.Errorf("subject = %q, want alice", got.Subject)
+			}
+		})
+	}
+}
+
+func TestJWKRoundTrip(t *testing.T) {
+	key := generateKey(t)
+
+	data, err := json.Marshal(NewRSAJWK("k1", &key.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jwk JWK
+	if err := json.Unmarshal(data, &jwk); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := jwk.RSAPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(&key.PublicKey) {
+		t.Error("decoded key differs from the original")
+	}
+
+	for name, bad := range map[string]JWK{
+		"exponent 1":     {Kty: "RSA", N: jwk.N, E: "AQ"},
+		"empty modulus":  {Kty: "RSA", N: "", E: jwk.E},
+		"bad encoding":   {Kty: "RSA", N: "!!", E: jwk.E},
+		"wrong key type": {Kty: "oct", N: jwk.N, E: jwk.E},
+	} {
+		if _, err := bad.RSAPublicKey(); err == nil {
+			t.Errorf("%s: RSAPublicKey() succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"textile-admin/pkg/oidc"
+	"textile-admin/pkg/oidc/oidctest"
+	"time"
+)
+
+const (
+	clientID     = "textile-admin"
+	clientSecret = "mock-secret"
+	redirectURL  = "http://app.example/api/auth/oidc/callback"
+)
+
+// startProvider serves a mock provider and returns it with a client for it
+func startProvider(t *testing.T) (*oidctest.Provider, *oidc.Client) {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(nil)
+	provider, err := oidctest.NewProvider("http://"+server.Listener.Addr().String(), clientID, clientSecret, "Reader@Example.com", "Reader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Config.Handler = provider.Handler()
+	server.Start()
+	t.Cleanup(server.Close)
+
+	client := oidc.NewClient(oidc.Config{
+		Issuer:       provider.Issuer(),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+	})
+	return provider, client
+}
+
+// authorize plays the browser's part of a login: it follows the client's
+// authorization URL and returns the query of the callback it is sent to
+func authorize(t *testing.T, client *oidc.Client, state, nonce, codeVerifier string) url.Values {
+	t.Helper()
+
+	authURL, err := client.AuthCodeURL(context.Background(), state, nonce, oidc.CodeChallenge(codeVerifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := browser.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorization endpoint returned %s", resp.Status)
+	}
+	location := resp.Header.Get("Location")
+	if !strings.HasPrefix(location, redirectURL+"?") {
+		t.Fatalf("redirected to %q, want the client's redirect URL", location)
+	}
+
+	callback, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return callback.Query()
+}
+
+func TestLoginFlow(t *testing.T) {
+	_, client := startProvider(t)
+	ctx := context.Background()
+
+	verifier, err := oidc.RandomString(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	callback := authorize(t, client, "state-1", "nonce-1", verifier)
+	if got := callback.Get("state"); got != "state-1" {
+		t.Errorf("callback state = %q, want state-1", got)
+	}
+
+	code := callback.Get("code")
+	idToken, err := client.Exchange(ctx, code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if idToken.Subject != "mock|reader@example.com" || idToken.Email != "reader@example.com" || !idToken.EmailVerified || idToken.Name != "Reader" {
+		t.Errorf("Exchange() = %+v", idToken)
+	}
+
+	// Codes are single use
+	if _, err := client.Exchange(ctx, code, verifier, "nonce-1"); !errors.Is(err, oidc.ErrExchangeFailed) {
+		t.Errorf("second Exchange() error = %v, want ErrExchangeFailed", err)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		// claims edits the claims of the issued ID token
+		claims func(claims map[string]interface{})
+		// verifier and nonce, when set, replace those of the login
+		verifier string
+		nonce    string
+		wantErr  error
+	}{
+		{
+			name:     "wrong code verifier",
+			verifier: "not-the-verifier",
+			wantErr:  oidc.ErrExchangeFailed,
+		},
+		{
+			name:    "nonce mismatch",
+			nonce:   "another-nonce",
+			wantErr: oidc.ErrInvalidIDToken,
+		},
+		{
+			name:    "nonce missing",
+			claims:  func(c map[string]interface{}) { delete(c, "nonce") },
+			wantErr: oidc.ErrInvalidIDToken,
+		},
+		{
+			name:    "issuer mismatch",
+			claims:  func(c map[string]interface{}) { c["iss"] = "https://idp.attacker.example" },
+			wantErr: oidc.ErrInvalidIDToken,
+		},
+		{
+			name:    "audience mismatch",
+			claims:  func(c map[string]interface{}) { c["aud"] = "another-client" },
+			wantErr: oidc.ErrInvalidIDToken,
+		},
+		{
+			name:    "several audiences without authorized party",
+			claims:  func(c map[string]interface{}) { c["aud"] = []string{"another-client", clientID} },
+			wantErr: oidc.ErrInvalidIDToken,
+		},
+		{
+			name: "several audiences with authorized party",
+			claims: func(c map[string]interface{}) {
+				c["aud"] = []string{"another-client", clientID}
+				c["azp"] = clientID
+			},
+		},
+		{
+			name:    "expired",
+			claims:  func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+			wantErr: oidc.ErrInvalidIDToken,
+		},
+		{
+			name:    "no subject",
+			claims:  func(c map[string]interface{}) { delete(c, "sub") },
+			wantErr: oidc.ErrInvalidIDToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, client := startProvider(t)
+			provider.Claims = tt.claims
+
+			verifier := "verifier-0123456789-0123456789-0123456789"
+			callback := authorize(t, client, "state", "nonce", verifier)
+
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			nonce := "nonce"
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+
+			_, err := client.Exchange(context.Background(), callback.Get("code"), verifier, nonce)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("Exchange() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	provider, _ := startProvider(t)
+
+	// An issuer that differs from the one the provider names, if only by a
+	// trailing slash, is not trusted
+	client := oidc.NewClient(oidc.Config{Issuer: provider.Issuer() + "/", ClientID: clientID, RedirectURL: redirectURL})
+	if _, err := client.AuthCodeURL(context.Background(), "state", "nonce", "challenge"); err == nil {
+		t.Error("AuthCodeURL() succeeded for a provider with a different issuer")
+	}
+}
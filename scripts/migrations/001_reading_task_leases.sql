@@ -0,0 +1,9 @@
+-- Add worker lease columns to reading_tasks so that several API instances
+-- can share the task queue. Requires MySQL 8.0+ for SKIP LOCKED claims.
+USE textile_admin;
+
+ALTER TABLE reading_tasks
+  ADD COLUMN lease_owner VARCHAR(128) NOT NULL DEFAULT '',
+  ADD COLUMN lease_expires_at DATETIME NULL;
+
+CREATE INDEX idx_reading_tasks_lease_expires_at ON reading_tasks(lease_expires_at);
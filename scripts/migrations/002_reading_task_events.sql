@@ -0,0 +1,15 @@
+-- Record every reading task status transition
+USE textile_admin;
+
+CREATE TABLE IF NOT EXISTS reading_task_events (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  task_id BIGINT NOT NULL,
+  actor VARCHAR(128) NOT NULL,
+  from_status VARCHAR(32) NOT NULL,
+  to_status VARCHAR(32) NOT NULL,
+  reason VARCHAR(512),
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (task_id) REFERENCES reading_tasks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_reading_task_events_task_id ON reading_task_events(task_id);
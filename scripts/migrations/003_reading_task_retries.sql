@@ -0,0 +1,9 @@
+-- Track processing attempts, the last failure and the next retry time
+USE textile_admin;
+
+ALTER TABLE reading_tasks
+  ADD COLUMN error_message TEXT NULL,
+  ADD COLUMN attempts INT NOT NULL DEFAULT 0,
+  ADD COLUMN next_attempt_at DATETIME NULL;
+
+CREATE INDEX idx_reading_tasks_next_attempt_at ON reading_tasks(next_attempt_at);
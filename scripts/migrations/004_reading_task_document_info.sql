@@ -0,0 +1,6 @@
+-- Store the detected file type and page count of each reading task
+USE textile_admin;
+
+ALTER TABLE reading_tasks
+  ADD COLUMN mime_type VARCHAR(127) NOT NULL DEFAULT '',
+  ADD COLUMN page_count INT NOT NULL DEFAULT 0;
@@ -0,0 +1,11 @@
+-- Store the plain text extracted from each reading task's file
+USE textile_admin;
+
+CREATE TABLE IF NOT EXISTS reading_contents (
+  task_id BIGINT PRIMARY KEY,
+  content LONGTEXT NOT NULL,
+  char_count INT NOT NULL DEFAULT 0,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (task_id) REFERENCES reading_tasks(id) ON DELETE CASCADE
+);
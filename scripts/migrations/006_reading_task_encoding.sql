@@ -0,0 +1,6 @@
+-- Record the detected encoding of text uploads and the path of their UTF-8 copy
+USE textile_admin;
+
+ALTER TABLE reading_tasks
+  ADD COLUMN encoding VARCHAR(32) NOT NULL DEFAULT '',
+  ADD COLUMN normalized_path VARCHAR(512) NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+-- Store the chapters found in each reading task's extracted text
+USE textile_admin;
+
+CREATE TABLE IF NOT EXISTS reading_chapters (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  task_id BIGINT NOT NULL,
+  ordinal INT NOT NULL,
+  title VARCHAR(255) NOT NULL DEFAULT '',
+  start_offset INT NOT NULL,
+  end_offset INT NOT NULL,
+  UNIQUE KEY uk_reading_chapters_task_ordinal (task_id, ordinal),
+  FOREIGN KEY (task_id) REFERENCES reading_tasks(id) ON DELETE CASCADE
+);
@@ -0,0 +1,8 @@
+-- Store document statistics and the estimated reading time of each reading task
+USE textile_admin;
+
+ALTER TABLE reading_tasks
+  ADD COLUMN chapter_count INT NOT NULL DEFAULT 0,
+  ADD COLUMN word_count INT NOT NULL DEFAULT 0,
+  ADD COLUMN cjk_char_count INT NOT NULL DEFAULT 0,
+  ADD COLUMN reading_minutes INT NOT NULL DEFAULT 0;
@@ -0,0 +1,17 @@
+-- Store where each user stopped reading each task
+USE textile_admin;
+
+CREATE TABLE IF NOT EXISTS reading_progress (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  task_id BIGINT NOT NULL,
+  chapter INT NOT NULL DEFAULT 0,
+  char_offset INT NOT NULL DEFAULT 0,
+  percentage DECIMAL(5,2) NOT NULL DEFAULT 0,
+  last_read_at DATETIME(3) NOT NULL,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY uk_reading_progress_user_task (user_id, task_id),
+  INDEX idx_reading_progress_task_id (task_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (task_id) REFERENCES reading_tasks(id) ON DELETE CASCADE
+);
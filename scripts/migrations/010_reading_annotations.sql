@@ -0,0 +1,34 @@
+-- Store users' bookmarks and highlighted passages with notes
+USE textile_admin;
+
+CREATE TABLE IF NOT EXISTS reading_bookmarks (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  task_id BIGINT NOT NULL,
+  chapter INT NOT NULL DEFAULT 0,
+  start_offset INT NOT NULL,
+  end_offset INT NOT NULL,
+  title VARCHAR(255) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_reading_bookmarks_user_task (user_id, task_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (task_id) REFERENCES reading_tasks(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS reading_annotations (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  task_id BIGINT NOT NULL,
+  chapter INT NOT NULL DEFAULT 0,
+  start_offset INT NOT NULL,
+  end_offset INT NOT NULL,
+  quote TEXT,
+  note TEXT,
+  color VARCHAR(32) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_reading_annotations_user_task (user_id, task_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (task_id) REFERENCES reading_tasks(id) ON DELETE CASCADE
+);
@@ -0,0 +1,16 @@
+-- Store reading sessions for reading-time statistics
+USE textile_admin;
+
+CREATE TABLE IF NOT EXISTS reading_sessions (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  task_id BIGINT NOT NULL,
+  started_at DATETIME NOT NULL,
+  last_heartbeat_at DATETIME NOT NULL,
+  ended_at DATETIME NULL,
+  active_seconds INT NOT NULL DEFAULT 0,
+  INDEX idx_reading_sessions_user_started (user_id, started_at),
+  INDEX idx_reading_sessions_task_id (task_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (task_id) REFERENCES reading_tasks(id) ON DELETE CASCADE
+);
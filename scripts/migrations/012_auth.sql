@@ -0,0 +1,16 @@
+-- Add password credentials and refresh tokens for authentication
+USE textile_admin;
+
+ALTER TABLE users
+  ADD COLUMN password_hash VARCHAR(255) NOT NULL DEFAULT '' AFTER email;
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  token_id VARCHAR(64) NOT NULL UNIQUE,
+  user_id BIGINT NOT NULL,
+  expires_at DATETIME NOT NULL,
+  revoked_at DATETIME NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_refresh_tokens_user_id (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
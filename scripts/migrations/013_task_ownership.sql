@@ -0,0 +1,8 @@
+-- Add user roles and index file paths for resolving downloads to their task
+USE textile_admin;
+
+ALTER TABLE users
+  ADD COLUMN role VARCHAR(32) NOT NULL DEFAULT 'reader' AFTER password_hash;
+
+CREATE INDEX idx_reading_tasks_file_path ON reading_tasks(file_path);
+CREATE INDEX idx_reading_tasks_normalized_path ON reading_tasks(normalized_path);
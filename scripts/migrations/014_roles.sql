@@ -0,0 +1,53 @@
+-- Replace the users.role column with roles, permissions and role assignments
+USE textile_admin;
+
+CREATE TABLE IF NOT EXISTS roles (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  name VARCHAR(64) NOT NULL UNIQUE,
+  description VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  name VARCHAR(64) NOT NULL UNIQUE,
+  description VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+  role_id BIGINT NOT NULL,
+  permission_id BIGINT NOT NULL,
+  PRIMARY KEY (role_id, permission_id),
+  FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE,
+  FOREIGN KEY (permission_id) REFERENCES permissions(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+  user_id BIGINT NOT NULL,
+  role_id BIGINT NOT NULL,
+  PRIMARY KEY (user_id, role_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE
+);
+
+-- Built-in roles and permissions; the application resets them on start
+INSERT IGNORE INTO permissions (name, description) VALUES
+  ('tasks:read:all', 'Read every user''s tasks, task lists and files'),
+  ('tasks:status:write', 'Change the status of tasks'),
+  ('stats:read:all', 'Read every user''s reading statistics'),
+  ('users:manage', 'List users, change other users'' accounts and assign roles');
+
+INSERT IGNORE INTO roles (name, description) VALUES
+  ('admin', 'Administrator with access to all users and tasks'),
+  ('worker', 'Processing worker that reads tasks and reports their status'),
+  ('reader', 'Reader with access to their own tasks');
+
+INSERT IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p
+WHERE r.name = 'admin'
+   OR (r.name = 'worker' AND p.name IN ('tasks:read:all', 'tasks:status:write'));
+
+-- Carry over the roles of existing users
+INSERT IGNORE INTO user_roles (user_id, role_id)
+SELECT u.id, r.id FROM users u JOIN roles r ON r.name = u.role;
+
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,14 @@
+-- Link users to their OpenID Connect single sign-on accounts
+USE textile_admin;
+
+CREATE TABLE IF NOT EXISTS user_identities (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  issuer VARCHAR(255) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  email VARCHAR(255) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY uk_user_identities_issuer_subject (issuer, subject),
+  INDEX idx_user_identities_user_id (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
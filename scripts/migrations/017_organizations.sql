@@ -0,0 +1,24 @@
+-- Add organizations and scope users and reading tasks to them. Existing
+-- users and their tasks join the default organization.
+USE textile_admin;
+
+CREATE TABLE IF NOT EXISTS organizations (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  name VARCHAR(255) NOT NULL UNIQUE,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT IGNORE INTO organizations (id, name) VALUES (1, 'Default');
+
+ALTER TABLE users
+  ADD COLUMN org_id BIGINT NOT NULL DEFAULT 1 AFTER id,
+  ADD FOREIGN KEY (org_id) REFERENCES organizations(id);
+
+ALTER TABLE reading_tasks
+  ADD COLUMN org_id BIGINT NOT NULL DEFAULT 1 AFTER id,
+  ADD FOREIGN KEY (org_id) REFERENCES organizations(id);
+
+UPDATE reading_tasks t JOIN users u ON u.id = t.user_id SET t.org_id = u.org_id;
+
+CREATE INDEX idx_users_org_id ON users(org_id);
+CREATE INDEX idx_reading_tasks_org_id ON reading_tasks(org_id);
@@ -0,0 +1,14 @@
+-- Store task files by their key in the storage backend rather than by
+-- absolute path. Set @upload_dir to the absolute UPLOAD_DIR the files were
+-- uploaded to, with a trailing slash, before running this script.
+USE textile_admin;
+
+SET @upload_dir = '/var/textile-admin/uploads/';
+
+UPDATE reading_tasks
+  SET file_path = SUBSTRING(file_path, CHAR_LENGTH(@upload_dir) + 1)
+  WHERE file_path LIKE CONCAT(@upload_dir, '%');
+
+UPDATE reading_tasks
+  SET normalized_path = SUBSTRING(normalized_path, CHAR_LENGTH(@upload_dir) + 1)
+  WHERE normalized_path LIKE CONCAT(@upload_dir, '%');
@@ -0,0 +1,29 @@
+-- Track resumable (tus) uploads and the chunks received so far
+USE textile_admin;
+
+CREATE TABLE IF NOT EXISTS upload_sessions (
+  id VARCHAR(32) PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  file_name VARCHAR(255) NOT NULL,
+  encoding VARCHAR(32) NOT NULL DEFAULT '',
+  metadata VARCHAR(2048) NOT NULL DEFAULT '',
+  upload_length BIGINT NOT NULL,
+  upload_offset BIGINT NOT NULL DEFAULT 0,
+  task_id BIGINT NULL,
+  expires_at DATETIME NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_upload_sessions_user_id (user_id),
+  INDEX idx_upload_sessions_expires_at (expires_at),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS upload_parts (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  session_id VARCHAR(32) NOT NULL,
+  upload_offset BIGINT NOT NULL,
+  size BIGINT NOT NULL,
+  storage_key VARCHAR(512) NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_upload_parts_session_id (session_id),
+  FOREIGN KEY (session_id) REFERENCES upload_sessions(id) ON DELETE CASCADE
+);
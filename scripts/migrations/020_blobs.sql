@@ -0,0 +1,21 @@
+-- Store uploaded files once per organization, named by their SHA-256 hash,
+-- and count the tasks that share each one. Files of existing tasks keep
+-- their names and are not shared.
+USE textile_admin;
+
+ALTER TABLE reading_tasks
+  ADD COLUMN sha256 VARCHAR(64) NOT NULL DEFAULT '' AFTER normalized_path,
+  ADD COLUMN normalized_sha256 VARCHAR(64) NOT NULL DEFAULT '' AFTER sha256;
+
+CREATE INDEX idx_reading_tasks_sha256 ON reading_tasks(sha256);
+
+CREATE TABLE IF NOT EXISTS blobs (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  org_id BIGINT NOT NULL,
+  sha256 VARCHAR(64) NOT NULL,
+  size BIGINT NOT NULL,
+  ref_count INT NOT NULL DEFAULT 0,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY uk_blobs_org_sha256 (org_id, sha256),
+  FOREIGN KEY (org_id) REFERENCES organizations(id)
+);
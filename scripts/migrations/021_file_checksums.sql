@@ -0,0 +1,6 @@
+-- Record the size and MD5 checksum of each task's uploaded file
+USE textile_admin;
+
+ALTER TABLE reading_tasks
+  ADD COLUMN file_size BIGINT NOT NULL DEFAULT 0 AFTER normalized_path,
+  ADD COLUMN md5 VARCHAR(32) NOT NULL DEFAULT '' AFTER sha256;
@@ -0,0 +1,23 @@
+-- Move orgs:manage from the admin role, which every organization's admins
+-- hold, to a superadmin role. The admins of the default organization, who
+-- ran the deployment so far, become super admins.
+USE textile_admin;
+
+INSERT IGNORE INTO roles (name, description) VALUES
+  ('superadmin', 'Deployment administrator who manages organizations');
+
+INSERT IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r JOIN permissions p
+WHERE r.name = 'superadmin' AND p.name = 'orgs:manage';
+
+DELETE rp FROM role_permissions rp
+JOIN roles r ON r.id = rp.role_id
+JOIN permissions p ON p.id = rp.permission_id
+WHERE r.name = 'admin' AND p.name = 'orgs:manage';
+
+INSERT IGNORE INTO user_roles (user_id, role_id)
+SELECT u.id, s.id FROM users u
+JOIN user_roles ur ON ur.user_id = u.id
+JOIN roles a ON a.id = ur.role_id AND a.name = 'admin'
+JOIN roles s ON s.name = 'superadmin'
+WHERE u.org_id = 1;
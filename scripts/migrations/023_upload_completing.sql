@@ -0,0 +1,5 @@
+-- Mark upload sessions whose task is being created, so that concurrent
+-- requests completing one upload create a single task
+USE textile_admin;
+
+ALTER TABLE upload_sessions ADD COLUMN completing BOOLEAN NOT NULL DEFAULT FALSE AFTER task_id;
@@ -4,24 +4,285 @@ CREATE DATABASE IF NOT EXISTS textile_admin;
 -- Use the database
 USE textile_admin;
 
+-- Create organizations table holding the tenants users and tasks belong to
+CREATE TABLE IF NOT EXISTS organizations (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  name VARCHAR(255) NOT NULL UNIQUE,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create the default organization new users join
+INSERT IGNORE INTO organizations (id, name) VALUES (1, 'Default');
+
 -- Create users table if needed (assumed to exist based on foreign key relationship)
 CREATE TABLE IF NOT EXISTS users (
   id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  org_id BIGINT NOT NULL DEFAULT 1,
   username VARCHAR(255) NOT NULL,
   email VARCHAR(255) NOT NULL UNIQUE,
-  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+  password_hash VARCHAR(255) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_users_org_id (org_id),
+  FOREIGN KEY (org_id) REFERENCES organizations(id)
 );
 
 -- Create reading_tasks table
 CREATE TABLE IF NOT EXISTS reading_tasks (
   id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  org_id BIGINT NOT NULL DEFAULT 1,
   user_id BIGINT NOT NULL,
   file_name VARCHAR(255) NOT NULL,
   file_path VARCHAR(512) NOT NULL,
   created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
   status ENUM('pending', 'processing', 'completed', 'failed') NOT NULL DEFAULT 'pending',
+  encoding VARCHAR(32) NOT NULL DEFAULT '',
+  normalized_path VARCHAR(512) NOT NULL DEFAULT '',
+  file_size BIGINT NOT NULL DEFAULT 0,
+  sha256 VARCHAR(64) NOT NULL DEFAULT '',
+  md5 VARCHAR(32) NOT NULL DEFAULT '',
+  normalized_sha256 VARCHAR(64) NOT NULL DEFAULT '',
+  mime_type VARCHAR(127) NOT NULL DEFAULT '',
+  page_count INT NOT NULL DEFAULT 0,
+  chapter_count INT NOT NULL DEFAULT 0,
+  word_count INT NOT NULL DEFAULT 0,
+  cjk_char_count INT NOT NULL DEFAULT 0,
+  reading_minutes INT NOT NULL DEFAULT 0,
+  lease_owner VARCHAR(128) NOT NULL DEFAULT '',
+  lease_expires_at DATETIME NULL,
+  error_message TEXT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at DATETIME NULL,
+  FOREIGN KEY (org_id) REFERENCES organizations(id),
   FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
+-- Create index for listing the reading tasks of an organization
+CREATE INDEX idx_reading_tasks_org_id ON reading_tasks(org_id);
+
 -- Create index for faster lookup of reading tasks by user_id
-CREATE INDEX idx_reading_tasks_user_id ON reading_tasks(user_id); 
\ No newline at end of file
+CREATE INDEX idx_reading_tasks_user_id ON reading_tasks(user_id);
+
+-- Create index used when reclaiming tasks with expired worker leases
+CREATE INDEX idx_reading_tasks_lease_expires_at ON reading_tasks(lease_expires_at);
+
+-- Create index used when picking tasks that are due for another attempt
+CREATE INDEX idx_reading_tasks_next_attempt_at ON reading_tasks(next_attempt_at);
+
+-- Create indexes used when resolving a downloaded file to its task
+CREATE INDEX idx_reading_tasks_file_path ON reading_tasks(file_path);
+CREATE INDEX idx_reading_tasks_normalized_path ON reading_tasks(normalized_path);
+
+-- Create index used when looking for a processed task with the same file
+CREATE INDEX idx_reading_tasks_sha256 ON reading_tasks(sha256);
+
+-- Create blobs table counting the tasks that share each stored file
+CREATE TABLE IF NOT EXISTS blobs (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  org_id BIGINT NOT NULL,
+  sha256 VARCHAR(64) NOT NULL,
+  size BIGINT NOT NULL,
+  ref_count INT NOT NULL DEFAULT 0,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY uk_blobs_org_sha256 (org_id, sha256),
+  FOREIGN KEY (org_id) REFERENCES organizations(id)
+);
+
+-- Create reading_task_events table recording status transitions
+CREATE TABLE IF NOT EXISTS reading_task_events (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  task_id BIGINT NOT NULL,
+  actor VARCHAR(128) NOT NULL,
+  from_status VARCHAR(32) NOT NULL,
+  to_status VARCHAR(32) NOT NULL,
+  reason VARCHAR(512),
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (task_id) REFERENCES reading_tasks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_reading_task_events_task_id ON reading_task_events(task_id);
+
+-- Create reading_contents table holding the text extracted from each task's file
+CREATE TABLE IF NOT EXISTS reading_contents (
+  task_id BIGINT PRIMARY KEY,
+  content LONGTEXT NOT NULL,
+  char_count INT NOT NULL DEFAULT 0,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (task_id) REFERENCES reading_tasks(id) ON DELETE CASCADE
+);
+
+-- Create reading_chapters table holding the chapters of each task's text
+CREATE TABLE IF NOT EXISTS reading_chapters (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  task_id BIGINT NOT NULL,
+  ordinal INT NOT NULL,
+  title VARCHAR(255) NOT NULL DEFAULT '',
+  start_offset INT NOT NULL,
+  end_offset INT NOT NULL,
+  UNIQUE KEY uk_reading_chapters_task_ordinal (task_id, ordinal),
+  FOREIGN KEY (task_id) REFERENCES reading_tasks(id) ON DELETE CASCADE
+);
+
+-- Create reading_progress table recording where each user stopped reading each task
+CREATE TABLE IF NOT EXISTS reading_progress (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  task_id BIGINT NOT NULL,
+  chapter INT NOT NULL DEFAULT 0,
+  char_offset INT NOT NULL DEFAULT 0,
+  percentage DECIMAL(5,2) NOT NULL DEFAULT 0,
+  last_read_at DATETIME(3) NOT NULL,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY uk_reading_progress_user_task (user_id, task_id),
+  INDEX idx_reading_progress_task_id (task_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (task_id) REFERENCES reading_tasks(id) ON DELETE CASCADE
+);
+
+-- Create reading_bookmarks and reading_annotations tables holding users' bookmarks and highlights
+CREATE TABLE IF NOT EXISTS reading_bookmarks (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  task_id BIGINT NOT NULL,
+  chapter INT NOT NULL DEFAULT 0,
+  start_offset INT NOT NULL,
+  end_offset INT NOT NULL,
+  title VARCHAR(255) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_reading_bookmarks_user_task (user_id, task_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (task_id) REFERENCES reading_tasks(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS reading_annotations (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  task_id BIGINT NOT NULL,
+  chapter INT NOT NULL DEFAULT 0,
+  start_offset INT NOT NULL,
+  end_offset INT NOT NULL,
+  quote TEXT,
+  note TEXT,
+  color VARCHAR(32) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_reading_annotations_user_task (user_id, task_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (task_id) REFERENCES reading_tasks(id) ON DELETE CASCADE
+);
+
+-- Create reading_sessions table recording time spent reading
+CREATE TABLE IF NOT EXISTS reading_sessions (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  task_id BIGINT NOT NULL,
+  started_at DATETIME NOT NULL,
+  last_heartbeat_at DATETIME NOT NULL,
+  ended_at DATETIME NULL,
+  active_seconds INT NOT NULL DEFAULT 0,
+  INDEX idx_reading_sessions_user_started (user_id, started_at),
+  INDEX idx_reading_sessions_task_id (task_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (task_id) REFERENCES reading_tasks(id) ON DELETE CASCADE
+);
+
+-- Create refresh_tokens table recording issued refresh tokens
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  token_id VARCHAR(64) NOT NULL UNIQUE,
+  user_id BIGINT NOT NULL,
+  expires_at DATETIME NOT NULL,
+  revoked_at DATETIME NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_refresh_tokens_user_id (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create roles, permissions and their assignments
+CREATE TABLE IF NOT EXISTS roles (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  name VARCHAR(64) NOT NULL UNIQUE,
+  description VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  name VARCHAR(64) NOT NULL UNIQUE,
+  description VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+  role_id BIGINT NOT NULL,
+  permission_id BIGINT NOT NULL,
+  PRIMARY KEY (role_id, permission_id),
+  FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE,
+  FOREIGN KEY (permission_id) REFERENCES permissions(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+  user_id BIGINT NOT NULL,
+  role_id BIGINT NOT NULL,
+  PRIMARY KEY (user_id, role_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (role_id) REFERENCES roles(id) ON DELETE CASCADE
+);
+
+-- Create api_keys table holding hashed API keys and their scopes
+CREATE TABLE IF NOT EXISTS api_keys (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  prefix VARCHAR(16) NOT NULL,
+  key_hash VARCHAR(64) NOT NULL UNIQUE,
+  scopes VARCHAR(512) NOT NULL,
+  expires_at DATETIME NULL,
+  last_used_at DATETIME NULL,
+  revoked_at DATETIME NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_api_keys_user_id (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create user_identities table linking users to single sign-on accounts
+CREATE TABLE IF NOT EXISTS user_identities (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  issuer VARCHAR(255) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  email VARCHAR(255) NOT NULL DEFAULT '',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY uk_user_identities_issuer_subject (issuer, subject),
+  INDEX idx_user_identities_user_id (user_id),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create upload_sessions table tracking resumable (tus) uploads
+CREATE TABLE IF NOT EXISTS upload_sessions (
+  id VARCHAR(32) PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  file_name VARCHAR(255) NOT NULL,
+  encoding VARCHAR(32) NOT NULL DEFAULT '',
+  metadata VARCHAR(2048) NOT NULL DEFAULT '',
+  upload_length BIGINT NOT NULL,
+  upload_offset BIGINT NOT NULL DEFAULT 0,
+  task_id BIGINT NULL,
+  completing BOOLEAN NOT NULL DEFAULT FALSE,
+  expires_at DATETIME NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_upload_sessions_user_id (user_id),
+  INDEX idx_upload_sessions_expires_at (expires_at),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create upload_parts table holding the chunks of resumable uploads
+CREATE TABLE IF NOT EXISTS upload_parts (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  session_id VARCHAR(32) NOT NULL,
+  upload_offset BIGINT NOT NULL,
+  size BIGINT NOT NULL,
+  storage_key VARCHAR(512) NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_upload_parts_session_id (session_id),
+  FOREIGN KEY (session_id) REFERENCES upload_sessions(id) ON DELETE CASCADE
+);